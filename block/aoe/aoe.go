@@ -2,6 +2,7 @@
 package aoe
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"os"
@@ -36,6 +37,9 @@ type Server struct {
 	minor uint8
 
 	usingBPF bool
+
+	mu    sync.Mutex
+	state targetState
 }
 
 // ServerOptions specifies options for a Server.
@@ -48,6 +52,10 @@ type ServerOptions struct {
 	// network must have different major and minor addresses.
 	Major uint16
 	Minor uint8
+
+	// MACMask, if not nil, replaces the saved MAC mask list, which limits
+	// the initiators the server answers to. An empty list lets any in.
+	MACMask []net.HardwareAddr
 }
 
 // NewServer creates a new Server which utilizes the specified block volume.
@@ -77,6 +85,18 @@ func NewServer(b *block.BlockVolume, options *ServerOptions) (*Server, error) {
 		minor:  options.Minor,
 	}
 
+	if err := as.loadState(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if options.MACMask != nil {
+		as.state.MACMask = options.MACMask
+		if err := as.saveState(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
 	return as, nil
 }
 
@@ -209,9 +229,17 @@ func (s *Server) handleFrame(from net.Addr, iface *Interface, f *Frame) (int, er
 		}
 	}
 
+	src := from.(*raw.Addr).HardwareAddr
+	// Initiators left out of the MAC mask list get no answer at all. The
+	// advertisement, from the broadcast address, always goes out.
+	if !bytes.Equal(src, broadcastAddr) && s.masked(src) {
+		clog.Debugf("ignoring request from masked address %s", src)
+		return 0, nil
+	}
+
 	sender := &FrameSender{
 		orig:  f,
-		dst:   from.(*raw.Addr).HardwareAddr,
+		dst:   src,
 		src:   iface.HardwareAddr,
 		conn:  iface.PacketConn,
 		major: s.major,
@@ -220,6 +248,9 @@ func (s *Server) handleFrame(from net.Addr, iface *Interface, f *Frame) (int, er
 
 	switch hdr.Command {
 	case aoe.CommandIssueATACommand:
+		if s.reserved(src) {
+			return sender.SendError(aoe.ErrorTargetIsReserved)
+		}
 		n, err := aoe.ServeATA(sender, hdr, s.dev)
 		if err != nil {
 			clog.Errorf("ServeATA failed: %v", err)
@@ -233,21 +264,11 @@ func (s *Server) handleFrame(from net.Addr, iface *Interface, f *Frame) (int, er
 
 		return n, nil
 	case aoe.CommandQueryConfigInformation:
-		cfgarg := f.Header.Arg.(*aoe.ConfigArg)
-		clog.Tracef("cfgarg: %+v", cfgarg)
-
-		switch cfgarg.Command {
-		case aoe.ConfigCommandRead:
-			hdr.Arg = defaultConfig(iface.MTU)
-
-			return sender.Send(hdr)
-		}
-
-		return sender.SendError(aoe.ErrorUnrecognizedCommandCode)
+		return s.handleConfig(sender, src, iface.MTU, hdr)
 	case aoe.CommandMACMaskList:
-		fallthrough
+		return s.handleMACMask(sender, src, hdr)
 	case aoe.CommandReserveRelease:
-		fallthrough
+		return s.handleReserveRelease(sender, src, hdr)
 	default:
 		return sender.SendError(aoe.ErrorUnrecognizedCommandCode)
 	}
@@ -108,16 +108,19 @@ func TestServeCommandMACMaskList(t *testing.T) {
 	}
 
 	res := testRequest(t, req)
+	arg, ok := res.Arg.(*aoe.MACMaskArg)
+	if !ok {
+		t.Fatalf("incorrect argument type for MAC mask list request: %T", res.Arg)
+	}
 
-	// At this time, MAC mask lists are not implemented in the AoE server.
-	err := *req
-	err.FlagResponse = true
-	err.FlagError = true
-	err.Error = aoe.ErrorUnrecognizedCommandCode
-
-	if want, got := err, *res; !headersEqual(got, want) {
+	if want, got := *req, *res; !headersEqual(got, want) {
 		t.Fatalf("unexpected AoE header:\n - want: %+v\n-  got: %+v", want, got)
 	}
+
+	// The server starts out with an empty MAC mask list.
+	if arg.DirCount != 0 || len(arg.Directives) != 0 {
+		t.Fatalf("unexpected MAC mask list: %+v", arg)
+	}
 }
 
 func TestBPFProgramWrongMajorAddress(t *testing.T) {
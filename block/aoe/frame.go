@@ -8,6 +8,11 @@ import (
 	"github.com/mdlayher/raw"
 )
 
+// reserveReleaseHeaderLen is the length of an AoE header and the fixed part
+// of a reserve/release argument, the last byte of which counts the addresses
+// that follow.
+const reserveReleaseHeaderLen = 10 + 2
+
 type Frame struct {
 	// received ethernet frame
 	ethernet.Frame
@@ -20,7 +25,16 @@ func (f *Frame) UnmarshalBinary(data []byte) error {
 		return err
 	}
 
-	if err := f.Header.UnmarshalBinary(f.Frame.Payload); err != nil {
+	payload := f.Frame.Payload
+	// A reserve/release argument must be exactly as long as its list of
+	// addresses, so cut off the padding of a short Ethernet frame.
+	if len(payload) >= reserveReleaseHeaderLen && aoe.Command(payload[5]) == aoe.CommandReserveRelease {
+		if n := reserveReleaseHeaderLen + 6*int(payload[reserveReleaseHeaderLen-1]); n < len(payload) {
+			payload = payload[:n]
+		}
+	}
+
+	if err := f.Header.UnmarshalBinary(payload); err != nil {
 		return err
 	}
 
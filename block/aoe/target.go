@@ -0,0 +1,232 @@
+package aoe
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+
+	"github.com/mdlayher/aoe"
+)
+
+const (
+	// exportProtocol names the state a Server keeps in its volume's
+	// metadata.
+	exportProtocol = "aoe"
+
+	// maxMACList bounds the MAC mask and reserve lists, which a response
+	// must carry whole in a standard Ethernet frame.
+	maxMACList = 128
+	// maxConfigString is the longest config string AoE allows.
+	maxConfigString = 1024
+)
+
+// targetState is the state of a Server that its initiators may set: its
+// config string, the MAC mask list of initiators it serves and the reserve
+// list of initiators that may issue ATA commands. It's kept in the volume's
+// metadata, so that it survives restarts.
+type targetState struct {
+	Config  []byte             `json:"config,omitempty"`
+	MACMask []net.HardwareAddr `json:"macMask,omitempty"`
+	Reserve []net.HardwareAddr `json:"reserve,omitempty"`
+}
+
+func (s *Server) loadState() error {
+	data, err := s.dfs.ExportState(exportProtocol)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, &s.state)
+}
+
+// saveState must be called with s.mu held.
+func (s *Server) saveState() error {
+	data, err := json.Marshal(&s.state)
+	if err != nil {
+		return err
+	}
+	return s.dfs.SetExportState(exportProtocol, data)
+}
+
+func hasMAC(list []net.HardwareAddr, mac net.HardwareAddr) bool {
+	for _, m := range list {
+		if bytes.Equal(m, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// masked is true if the MAC mask list doesn't let mac reach the server.
+func (s *Server) masked(mac net.HardwareAddr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.state.MACMask) != 0 && !hasMAC(s.state.MACMask, mac)
+}
+
+// reserved is true if the reserve list excludes mac.
+func (s *Server) reserved(mac net.HardwareAddr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.state.Reserve) != 0 && !hasMAC(s.state.Reserve, mac)
+}
+
+func (s *Server) config(mtu int, cmd aoe.ConfigCommand) *aoe.ConfigArg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	arg := defaultConfig(mtu)
+	arg.Command = cmd
+	if len(s.state.Config) != 0 {
+		arg.String = append([]byte(nil), s.state.Config...)
+		arg.StringLength = uint16(len(arg.String))
+	}
+	return arg
+}
+
+func (s *Server) handleConfig(sender *FrameSender, from net.HardwareAddr, mtu int, hdr *aoe.Header) (int, error) {
+	arg := hdr.Arg.(*aoe.ConfigArg)
+	clog.Tracef("cfgarg: %+v", arg)
+
+	s.mu.Lock()
+	config := s.state.Config
+	s.mu.Unlock()
+
+	switch arg.Command {
+	case aoe.ConfigCommandRead:
+	case aoe.ConfigCommandTest:
+		// Only servers whose config string matches answer tests.
+		if !bytes.Equal(arg.String, config) {
+			return 0, nil
+		}
+	case aoe.ConfigCommandTestPrefix:
+		if !bytes.HasPrefix(config, arg.String) {
+			return 0, nil
+		}
+	case aoe.ConfigCommandSet, aoe.ConfigCommandForceSet:
+		if len(arg.String) > maxConfigString {
+			return sender.SendError(aoe.ErrorBadArgumentParameter)
+		}
+		if aerr := s.setConfig(from, arg); aerr != 0 {
+			return sender.SendError(aerr)
+		}
+	default:
+		return sender.SendError(aoe.ErrorUnrecognizedCommandCode)
+	}
+
+	hdr.Arg = s.config(mtu, arg.Command)
+	return sender.Send(hdr)
+}
+
+// setConfig sets the config string, and returns the error to answer with if
+// it can't.
+func (s *Server) setConfig(from net.HardwareAddr, arg *aoe.ConfigArg) aoe.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.state.Reserve) != 0 && !hasMAC(s.state.Reserve, from) {
+		return aoe.ErrorTargetIsReserved
+	}
+	// Set only sets an empty config string; ForceSet overwrites it.
+	if arg.Command == aoe.ConfigCommandSet && len(s.state.Config) != 0 && !bytes.Equal(arg.String, s.state.Config) {
+		return aoe.ErrorConfigStringPresent
+	}
+	s.state.Config = append([]byte(nil), arg.String...)
+	if err := s.saveState(); err != nil {
+		clog.Errorf("failed to save config string: %v", err)
+		return aoe.ErrorDeviceUnavailable
+	}
+	return 0
+}
+
+func (s *Server) handleMACMask(sender *FrameSender, from net.HardwareAddr, hdr *aoe.Header) (int, error) {
+	arg := hdr.Arg.(*aoe.MACMaskArg)
+	res := &aoe.MACMaskArg{Command: arg.Command}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch arg.Command {
+	case aoe.MACMaskCommandRead:
+	case aoe.MACMaskCommandEdit:
+		if len(s.state.Reserve) != 0 && !hasMAC(s.state.Reserve, from) {
+			return sender.SendError(aoe.ErrorTargetIsReserved)
+		}
+		mask := append([]net.HardwareAddr(nil), s.state.MACMask...)
+		for i, d := range arg.Directives {
+			switch d.Command {
+			case aoe.DirectiveCommandNone:
+				continue
+			case aoe.DirectiveCommandAdd:
+				if hasMAC(mask, d.MAC) {
+					continue
+				}
+				if len(mask) == maxMACList {
+					res.Error = aoe.MACMaskErrorListFull
+				} else {
+					mask = append(mask, d.MAC)
+				}
+			case aoe.DirectiveCommandDelete:
+				for j, m := range mask {
+					if bytes.Equal(m, d.MAC) {
+						mask = append(mask[:j], mask[j+1:]...)
+						break
+					}
+				}
+			default:
+				res.Error = aoe.MACMaskErrorBadCommand
+			}
+			if res.Error != 0 {
+				// The list is left as it was, and the response points out
+				// the directive which failed.
+				res.DirCount = uint8(i)
+				res.Directives = arg.Directives[:i]
+				hdr.Arg = res
+				return sender.Send(hdr)
+			}
+		}
+		s.state.MACMask = mask
+		if err := s.saveState(); err != nil {
+			clog.Errorf("failed to save MAC mask list: %v", err)
+			return sender.SendError(aoe.ErrorDeviceUnavailable)
+		}
+	default:
+		return sender.SendError(aoe.ErrorUnrecognizedCommandCode)
+	}
+
+	for _, m := range s.state.MACMask {
+		res.Directives = append(res.Directives, &aoe.Directive{Command: aoe.DirectiveCommandNone, MAC: m})
+	}
+	res.DirCount = uint8(len(res.Directives))
+	hdr.Arg = res
+	return sender.Send(hdr)
+}
+
+func (s *Server) handleReserveRelease(sender *FrameSender, from net.HardwareAddr, hdr *aoe.Header) (int, error) {
+	arg := hdr.Arg.(*aoe.ReserveReleaseArg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch arg.Command {
+	case aoe.ReserveReleaseCommandRead:
+	case aoe.ReserveReleaseCommandSet, aoe.ReserveReleaseCommandForceSet:
+		if arg.Command == aoe.ReserveReleaseCommandSet &&
+			len(s.state.Reserve) != 0 && !hasMAC(s.state.Reserve, from) {
+			return sender.SendError(aoe.ErrorTargetIsReserved)
+		}
+		if len(arg.MACs) > maxMACList {
+			return sender.SendError(aoe.ErrorBadArgumentParameter)
+		}
+		// An empty list releases the server.
+		s.state.Reserve = append([]net.HardwareAddr(nil), arg.MACs...)
+		if err := s.saveState(); err != nil {
+			clog.Errorf("failed to save reserve list: %v", err)
+			return sender.SendError(aoe.ErrorDeviceUnavailable)
+		}
+	default:
+		return sender.SendError(aoe.ErrorUnrecognizedCommandCode)
+	}
+
+	hdr.Arg = &aoe.ReserveReleaseArg{
+		Command: arg.Command,
+		NMACs:   uint8(len(s.state.Reserve)),
+		MACs:    append([]net.HardwareAddr(nil), s.state.Reserve...),
+	}
+	return sender.Send(hdr)
+}
@@ -0,0 +1,245 @@
+package aoe
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/metadata/temp"
+
+	"github.com/mdlayher/aoe"
+	"github.com/mdlayher/ethernet"
+	"github.com/mdlayher/raw"
+)
+
+var (
+	initiatorA = net.HardwareAddr{0x52, 0x54, 0, 0, 0, 0xa}
+	initiatorB = net.HardwareAddr{0x52, 0x54, 0, 0, 0, 0xb}
+)
+
+// A recordConn is a net.PacketConn which records the frames a server sends.
+type recordConn struct {
+	mu     sync.Mutex
+	frames [][]byte
+
+	noopPacketConn
+}
+
+func (c *recordConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames = append(c.frames, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+// A targetTest sends requests straight to a Server's handleFrame.
+type targetTest struct {
+	t     *testing.T
+	ts    *torus.Server
+	vol   *block.BlockVolume
+	s     *Server
+	iface *Interface
+	conn  *recordConn
+}
+
+func newTargetTest(t *testing.T) *targetTest {
+	ts := torus.NewMemoryServer()
+	mds, err := temp.NewTemp(ts.Cfg)
+	if err != nil {
+		t.Fatalf("failed to configure metadata service: %v", err)
+	}
+	ts.MDS = mds
+	if err = block.CreateBlockVolume(mds, "test", 1024); err != nil {
+		t.Fatalf("failed to create block volume: %v", err)
+	}
+	vol, err := block.OpenBlockVolume(ts, "test")
+	if err != nil {
+		t.Fatalf("error opening block volume: %v", err)
+	}
+	tt := &targetTest{t: t, ts: ts, vol: vol, conn: &recordConn{}}
+	tt.iface = &Interface{
+		Interface:  &net.Interface{Name: "lo", MTU: testMTU},
+		PacketConn: tt.conn,
+	}
+	tt.start(nil)
+	return tt
+}
+
+// start starts a new Server for the volume, as a restart would.
+func (tt *targetTest) start(mask []net.HardwareAddr) {
+	if tt.s != nil {
+		if err := tt.s.Close(); err != nil {
+			tt.t.Fatal(err)
+		}
+	}
+	s, err := NewServer(tt.vol, &ServerOptions{Major: 1, Minor: 1, MACMask: mask})
+	if err != nil {
+		tt.t.Fatalf("error opening AoE server: %v", err)
+	}
+	tt.s = s
+}
+
+func (tt *targetTest) close() {
+	tt.s.Close()
+	tt.ts.Close()
+}
+
+// request sends arg from the initiator, and returns the response, or nil if
+// the server didn't answer.
+func (tt *targetTest) request(from net.HardwareAddr, cmd aoe.Command, arg aoe.Arg) *aoe.Header {
+	req := &aoe.Header{
+		Version: aoe.Version,
+		Major:   1,
+		Minor:   1,
+		Command: cmd,
+		Tag:     [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Arg:     arg,
+	}
+	b, err := req.MarshalBinary()
+	if err != nil {
+		tt.t.Fatalf("failed to marshal request: %v", err)
+	}
+	b, err = (&ethernet.Frame{Source: from, Payload: b}).MarshalBinary()
+	if err != nil {
+		tt.t.Fatalf("failed to marshal request frame: %v", err)
+	}
+	var f Frame
+	if err = f.UnmarshalBinary(b); err != nil {
+		tt.t.Fatal(err)
+	}
+	tt.conn.frames = nil
+	if _, err = tt.s.handleFrame(&raw.Addr{HardwareAddr: from}, tt.iface, &f); err != nil {
+		tt.t.Fatalf("failed to handle frame: %v", err)
+	}
+	if len(tt.conn.frames) == 0 {
+		return nil
+	}
+	var res Frame
+	if err = res.UnmarshalBinary(tt.conn.frames[0]); err != nil {
+		tt.t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if !bytes.Equal(res.Destination, from) {
+		tt.t.Fatalf("response sent to %s, not %s", res.Destination, from)
+	}
+	return &res.Header
+}
+
+func (tt *targetTest) identify(from net.HardwareAddr) *aoe.Header {
+	return tt.request(from, aoe.CommandIssueATACommand, &aoe.ATAArg{
+		SectorCount: 1,
+		CmdStatus:   aoe.ATACmdStatusIdentify,
+	})
+}
+
+func expectError(t *testing.T, what string, res *aoe.Header, want aoe.Error) {
+	if res == nil {
+		t.Fatalf("%s: no response", what)
+	}
+	if want == 0 && res.FlagError || want != 0 && (!res.FlagError || res.Error != want) {
+		t.Fatalf("%s: got error %v (flag %v), want %v", what, res.Error, res.FlagError, want)
+	}
+}
+
+func TestMACMaskList(t *testing.T) {
+	tt := newTargetTest(t)
+	defer tt.close()
+
+	res := tt.request(initiatorA, aoe.CommandMACMaskList, &aoe.MACMaskArg{
+		Command:  aoe.MACMaskCommandEdit,
+		DirCount: 2,
+		Directives: []*aoe.Directive{
+			{Command: aoe.DirectiveCommandAdd, MAC: initiatorA},
+			{Command: aoe.DirectiveCommandAdd, MAC: initiatorA},
+		},
+	})
+	expectError(t, "add", res, 0)
+	if arg := res.Arg.(*aoe.MACMaskArg); arg.DirCount != 1 || !bytes.Equal(arg.Directives[0].MAC, initiatorA) {
+		t.Fatalf("unexpected MAC mask list %+v", arg)
+	}
+	expectError(t, "identify from a listed initiator", tt.identify(initiatorA), 0)
+	if res = tt.identify(initiatorB); res != nil {
+		t.Fatalf("masked initiator got a response: %+v", res)
+	}
+
+	res = tt.request(initiatorA, aoe.CommandMACMaskList, &aoe.MACMaskArg{
+		Command:  aoe.MACMaskCommandEdit,
+		DirCount: 2,
+		Directives: []*aoe.Directive{
+			{Command: aoe.DirectiveCommandAdd, MAC: initiatorB},
+			{Command: 7, MAC: initiatorB},
+		},
+	})
+	if arg := res.Arg.(*aoe.MACMaskArg); arg.Error != aoe.MACMaskErrorBadCommand || arg.DirCount != 1 {
+		t.Fatalf("bad directive: unexpected response %+v", arg)
+	}
+	if res = tt.identify(initiatorB); res != nil {
+		t.Fatal("failed edit changed the MAC mask list")
+	}
+
+	// The list survives a restart, unless the server is given another.
+	tt.start(nil)
+	if res = tt.identify(initiatorB); res != nil {
+		t.Fatal("MAC mask list lost on restart")
+	}
+	tt.start([]net.HardwareAddr{})
+	expectError(t, "identify after clearing the list", tt.identify(initiatorB), 0)
+}
+
+func TestReserveRelease(t *testing.T) {
+	tt := newTargetTest(t)
+	defer tt.close()
+
+	res := tt.request(initiatorA, aoe.CommandReserveRelease, &aoe.ReserveReleaseArg{
+		Command: aoe.ReserveReleaseCommandSet,
+		NMACs:   1,
+		MACs:    []net.HardwareAddr{initiatorA},
+	})
+	expectError(t, "reserve", res, 0)
+	if arg := res.Arg.(*aoe.ReserveReleaseArg); arg.NMACs != 1 || !bytes.Equal(arg.MACs[0], initiatorA) {
+		t.Fatalf("unexpected reserve list %+v", arg)
+	}
+	expectError(t, "identify from the holder", tt.identify(initiatorA), 0)
+	expectError(t, "identify from another", tt.identify(initiatorB), aoe.ErrorTargetIsReserved)
+	expectError(t, "config read from another", tt.request(initiatorB, aoe.CommandQueryConfigInformation, &aoe.ConfigArg{Command: aoe.ConfigCommandRead}), 0)
+
+	set := &aoe.ReserveReleaseArg{Command: aoe.ReserveReleaseCommandSet, NMACs: 1, MACs: []net.HardwareAddr{initiatorB}}
+	expectError(t, "reserve by another", tt.request(initiatorB, aoe.CommandReserveRelease, set), aoe.ErrorTargetIsReserved)
+	set.Command = aoe.ReserveReleaseCommandForceSet
+	expectError(t, "forced reserve by another", tt.request(initiatorB, aoe.CommandReserveRelease, set), 0)
+	expectError(t, "identify from the old holder", tt.identify(initiatorA), aoe.ErrorTargetIsReserved)
+
+	expectError(t, "release", tt.request(initiatorB, aoe.CommandReserveRelease, &aoe.ReserveReleaseArg{Command: aoe.ReserveReleaseCommandSet}), 0)
+	expectError(t, "identify after release", tt.identify(initiatorA), 0)
+}
+
+func TestConfigString(t *testing.T) {
+	tt := newTargetTest(t)
+	defer tt.close()
+
+	config := func(cmd aoe.ConfigCommand, s string) *aoe.Header {
+		return tt.request(initiatorA, aoe.CommandQueryConfigInformation, &aoe.ConfigArg{
+			Command:      cmd,
+			StringLength: uint16(len(s)),
+			String:       []byte(s),
+		})
+	}
+	expectError(t, "set", config(aoe.ConfigCommandSet, "torus-cluster"), 0)
+	expectError(t, "set again", config(aoe.ConfigCommandSet, "other"), aoe.ErrorConfigStringPresent)
+	if res := config(aoe.ConfigCommandTest, "other"); res != nil {
+		t.Fatal("answered a test of another config string")
+	}
+	expectError(t, "test prefix", config(aoe.ConfigCommandTestPrefix, "torus"), 0)
+
+	// The config string survives a restart.
+	tt.start(nil)
+	res := config(aoe.ConfigCommandRead, "")
+	expectError(t, "read", res, 0)
+	if s := string(res.Arg.(*aoe.ConfigArg).String); s != "torus-cluster" {
+		t.Fatalf("unexpected config string %q after restart", s)
+	}
+	expectError(t, "force set", config(aoe.ConfigCommandForceSet, "other"), 0)
+	expectError(t, "test", config(aoe.ConfigCommandTest, "other"), 0)
+}
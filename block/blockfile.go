@@ -1,6 +1,10 @@
 package block
 
 import (
+	"io"
+	"sync"
+	"time"
+
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/blockset"
 	"golang.org/x/net/context"
@@ -9,13 +13,28 @@ import (
 type BlockFile struct {
 	*torus.File
 	vol *BlockVolume
+
+	// mut serializes syncs and resizes.
+	mut       sync.Mutex
+	onResize  []func(size uint64)
+	closeChan chan struct{}
+	closeOnce sync.Once
+	// quota is charged for the blocks writes allocate.
+	quota    fileQuota
+	throttle *throttle
+	// snapshot is set on files opened by OpenSnapshot, which hold no lock.
+	snapshot bool
 }
 
 func (s *BlockVolume) OpenBlockFile() (file *BlockFile, err error) {
 	if s.volume.Type != VolumeType {
 		panic("Wrong type")
 	}
-	if err = s.mds.Lock(s.srv.Lease()); err != nil {
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+	fence, err := s.mds.Lock(s.srv.Lease())
+	if err != nil {
 		return nil, err
 	}
 	defer func() {
@@ -41,10 +60,21 @@ func (s *BlockVolume) OpenBlockFile() (file *BlockFile, err error) {
 	if err != nil {
 		return nil, err
 	}
-	return &BlockFile{
-		File: f,
-		vol:  s,
-	}, nil
+	f.SetFence(fence)
+	file = &BlockFile{
+		File:      f,
+		vol:       s,
+		closeChan: make(chan struct{}),
+		throttle:  &throttle{volume: s.volume.Name},
+	}
+	if err = file.CheckQuota(); err != nil {
+		return nil, err
+	}
+	if err = file.checkQoS(); err != nil {
+		return nil, err
+	}
+	go file.watch()
+	return file, nil
 }
 
 func (s *BlockVolume) OpenSnapshot(name string) (*BlockFile, error) {
@@ -80,8 +110,9 @@ func (s *BlockVolume) OpenSnapshot(name string) (*BlockFile, error) {
 	}
 	f.ReadOnly = true
 	return &BlockFile{
-		File: f,
-		vol:  s,
+		File:     f,
+		vol:      s,
+		snapshot: true,
 	}, nil
 }
 
@@ -89,7 +120,10 @@ func (s *BlockVolume) RestoreSnapshot(name string) (err error) {
 	if s.volume.Type != VolumeType {
 		panic("Wrong type")
 	}
-	if err = s.mds.Lock(s.srv.Lease()); err != nil {
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	if _, err = s.mds.Lock(s.srv.Lease()); err != nil {
 		return err
 	}
 	defer s.mds.Unlock()
@@ -112,6 +146,14 @@ func (s *BlockVolume) RestoreSnapshot(name string) (err error) {
 }
 
 func (f *BlockFile) Close() (err error) {
+	f.closeOnce.Do(func() {
+		if f.closeChan != nil {
+			close(f.closeChan)
+		}
+	})
+	if f.snapshot {
+		return f.File.Close()
+	}
 	defer func() {
 		// No matter what attempt to release the lock.
 		unlockErr := f.vol.mds.Unlock()
@@ -132,6 +174,8 @@ func (f *BlockFile) inodeContext() context.Context {
 }
 
 func (f *BlockFile) Sync() error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
 	if !f.WriteOpen() {
 		clog.Debugf("not syncing")
 		return nil
@@ -141,5 +185,39 @@ func (f *BlockFile) Sync() error {
 	if err != nil {
 		return err
 	}
-	return f.vol.mds.SyncINode(ref)
+	if err = f.vol.mds.SyncINode(ref); err != nil {
+		return err
+	}
+	f.quota.syncedAt(time.Now())
+	return nil
+}
+
+func (f *BlockFile) ReadAt(b []byte, off int64) (int, error) {
+	f.throttle.wait(len(b))
+	return f.File.ReadAt(b, off)
+}
+
+func (f *BlockFile) Read(b []byte) (int, error) {
+	f.throttle.wait(len(b))
+	return f.File.Read(b)
+}
+
+func (f *BlockFile) WriteAt(b []byte, off int64) (int, error) {
+	if err := f.allocate(off, len(b)); err != nil {
+		return 0, err
+	}
+	f.throttle.wait(len(b))
+	return f.File.WriteAt(b, off)
+}
+
+func (f *BlockFile) Write(b []byte) (int, error) {
+	off, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if err = f.allocate(off, len(b)); err != nil {
+		return 0, err
+	}
+	f.throttle.wait(len(b))
+	return f.File.Write(b)
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	etcdv3 "github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 	"golang.org/x/net/context"
 
 	"github.com/coreos/torus"
@@ -19,21 +20,25 @@ type blockEtcd struct {
 	vid  torus.VolumeID
 }
 
-func (b *blockEtcd) CreateBlockVolume(volume *models.Volume) error {
+func (b *blockEtcd) CreateBlockVolume(volume *models.Volume, inode torus.INodeRef, replicaOf string) error {
 	vbytes, err := volume.Marshal()
 	if err != nil {
 		return err
 	}
-	inodeBytes := torus.NewINodeRef(torus.VolumeID(volume.Id), 1).ToBytes()
+	inodeBytes := inode.ToBytes()
 
+	ops := []etcdv3.Op{
+		etcdv3.OpPut(b.MkKey("volumes", volume.Name), string(etcd.Uint64ToBytes(volume.Id))),
+		etcdv3.OpPut(b.MkKey("volumeid", etcd.Uint64ToHex(volume.Id)), string(vbytes)),
+		etcdv3.OpPut(b.MkKey("volumemeta", etcd.Uint64ToHex(volume.Id), "inode"), string(etcd.Uint64ToBytes(1))),
+		etcdv3.OpPut(b.MkKey("volumemeta", etcd.Uint64ToHex(volume.Id), "blockinode"), string(inodeBytes)),
+	}
+	if replicaOf != "" {
+		ops = append(ops, etcdv3.OpPut(b.MkKey("volumemeta", etcd.Uint64ToHex(volume.Id), "replica"), replicaOf))
+	}
 	do := b.Etcd.Client.Txn(b.getContext()).If(
-		etcdv3.Compare(etcdv3.Version(etcd.MkKey("volumes", volume.Name)), "=", 0),
-	).Then(
-		etcdv3.OpPut(etcd.MkKey("volumes", volume.Name), string(etcd.Uint64ToBytes(volume.Id))),
-		etcdv3.OpPut(etcd.MkKey("volumeid", etcd.Uint64ToHex(volume.Id)), string(vbytes)),
-		etcdv3.OpPut(etcd.MkKey("volumemeta", etcd.Uint64ToHex(volume.Id), "inode"), string(etcd.Uint64ToBytes(1))),
-		etcdv3.OpPut(etcd.MkKey("volumemeta", etcd.Uint64ToHex(volume.Id), "blockinode"), string(inodeBytes)),
-	)
+		etcdv3.Compare(etcdv3.Version(b.MkKey("volumes", volume.Name)), "=", 0),
+	).Then(ops...)
 	resp, err := do.Commit()
 	if err != nil {
 		return err
@@ -47,11 +52,11 @@ func (b *blockEtcd) CreateBlockVolume(volume *models.Volume) error {
 func (b *blockEtcd) DeleteVolume() error {
 	vid := uint64(b.vid)
 	tx := b.Etcd.Client.Txn(b.getContext()).If(
-		etcdv3.Compare(etcdv3.Version(etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")), "=", 0),
+		etcdv3.Compare(etcdv3.Version(b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")), "=", 0),
 	).Then(
-		etcdv3.OpDelete(etcd.MkKey("volumes", b.name)),
-		etcdv3.OpDelete(etcd.MkKey("volumeid", etcd.Uint64ToHex(vid))),
-		etcdv3.OpDelete(etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid)), etcdv3.WithPrefix()),
+		etcdv3.OpDelete(b.MkKey("volumes", b.name)),
+		etcdv3.OpDelete(b.MkKey("volumeid", etcd.Uint64ToHex(vid))),
+		etcdv3.OpDelete(b.MkKey("volumemeta", etcd.Uint64ToHex(vid)), etcdv3.WithPrefix()),
 	)
 	resp, err := tx.Commit()
 	if err != nil {
@@ -68,28 +73,51 @@ func (b *blockEtcd) getContext() context.Context {
 	return context.TODO()
 }
 
-func (b *blockEtcd) Lock(lease int64) error {
+func (b *blockEtcd) Lock(lease int64) (uint64, error) {
 	if lease == 0 {
-		return torus.ErrInvalid
+		return 0, torus.ErrInvalid
 	}
-	k := etcd.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "blocklock")
-	tx := b.Etcd.Client.Txn(b.getContext()).If(
-		etcdv3.Compare(etcdv3.Version(k), "=", 0),
-	).Then(
-		etcdv3.OpPut(k, b.Etcd.UUID(), etcdv3.WithLease(etcdv3.LeaseID(lease))),
-	)
-	resp, err := tx.Commit()
-	if err != nil {
-		return err
-	}
-	if !resp.Succeeded {
-		return torus.ErrLocked
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "blocklock")
+	fk := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "fence")
+	for {
+		resp, err := b.Etcd.Client.Get(b.getContext(), fk)
+		if err != nil {
+			return 0, err
+		}
+		var (
+			fence uint64
+			rev   int64
+		)
+		if len(resp.Kvs) == 1 {
+			fence = etcd.BytesToUint64(resp.Kvs[0].Value)
+			rev = resp.Kvs[0].ModRevision
+		}
+		fence++
+		tx := b.Etcd.Client.Txn(b.getContext()).If(
+			etcdv3.Compare(etcdv3.Version(k), "=", 0),
+			etcdv3.Compare(etcdv3.ModRevision(fk), "=", rev),
+		).Then(
+			etcdv3.OpPut(k, b.Etcd.UUID(), etcdv3.WithLease(etcdv3.LeaseID(lease))),
+			etcdv3.OpPut(fk, string(etcd.Uint64ToBytes(fence))),
+		).Else(
+			etcdv3.OpGet(k),
+		)
+		tresp, err := tx.Commit()
+		if err != nil {
+			return 0, err
+		}
+		if tresp.Succeeded {
+			return fence, nil
+		}
+		if len(tresp.Responses[0].GetResponseRange().Kvs) != 0 {
+			return 0, torus.ErrLocked
+		}
+		// Someone else took and released the lock in between; try again.
 	}
-	return nil
 }
 
 func (b *blockEtcd) GetINode() (torus.INodeRef, error) {
-	resp, err := b.Etcd.Client.Get(b.getContext(), etcd.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "blockinode"))
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "blockinode"))
 	if err != nil {
 		return torus.NewINodeRef(0, 0), err
 	}
@@ -100,14 +128,16 @@ func (b *blockEtcd) GetINode() (torus.INodeRef, error) {
 }
 
 func (b *blockEtcd) SyncINode(inode torus.INodeRef) error {
-	vid := uint64(inode.Volume())
+	// The INode may belong to another volume, if this is a clone restoring a
+	// snapshot taken before it was written to.
+	vid := uint64(b.vid)
 	inodeBytes := string(inode.ToBytes())
-	k := etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")
 	tx := b.Etcd.Client.Txn(b.getContext()).If(
 		etcdv3.Compare(etcdv3.Version(k), ">", 0),
 		etcdv3.Compare(etcdv3.Value(k), "=", b.Etcd.UUID()),
 	).Then(
-		etcdv3.OpPut(etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blockinode"), inodeBytes),
+		etcdv3.OpPut(b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blockinode"), inodeBytes),
 	)
 	resp, err := tx.Commit()
 	if err != nil {
@@ -121,12 +151,12 @@ func (b *blockEtcd) SyncINode(inode torus.INodeRef) error {
 
 func (b *blockEtcd) Unlock() error {
 	vid := uint64(b.vid)
-	k := etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")
 	tx := b.Etcd.Client.Txn(b.getContext()).If(
 		etcdv3.Compare(etcdv3.Version(k), ">", 0),
 		etcdv3.Compare(etcdv3.Value(k), "=", b.Etcd.UUID()),
 	).Then(
-		etcdv3.OpDelete(etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")),
+		etcdv3.OpDelete(b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blocklock")),
 	)
 	resp, err := tx.Commit()
 	if err != nil {
@@ -138,11 +168,102 @@ func (b *blockEtcd) Unlock() error {
 	return nil
 }
 
+func (b *blockEtcd) GetLockHolder() (*LockHolder, error) {
+	vid := etcd.Uint64ToHex(uint64(b.vid))
+	tx := b.Etcd.Client.Txn(b.getContext()).Then(
+		etcdv3.OpGet(b.MkKey("volumemeta", vid, "blocklock")),
+		etcdv3.OpGet(b.MkKey("volumemeta", vid, "fence")),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	kvs := resp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+	h := &LockHolder{
+		UUID:  string(kvs[0].Value),
+		Lease: kvs[0].Lease,
+		// The etcd v3 client can't read a lease's TTL without renewing it.
+		TTL: -1,
+		rev: kvs[0].CreateRevision,
+	}
+	if fkvs := resp.Responses[1].GetResponseRange().Kvs; len(fkvs) == 1 {
+		h.Fence = etcd.BytesToUint64(fkvs[0].Value)
+	}
+	return h, nil
+}
+
+func (b *blockEtcd) GetFence() (uint64, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "fence"))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return etcd.BytesToUint64(resp.Kvs[0].Value), nil
+}
+
+func (b *blockEtcd) WatchFence() (<-chan struct{}, func(), error) {
+	return b.watchMeta("fence")
+}
+
+func (b *blockEtcd) ForceUnlock(h *LockHolder, by string) error {
+	vid := etcd.Uint64ToHex(uint64(b.vid))
+	k := b.MkKey("volumemeta", vid, "blocklock")
+	rec := LockRecord{
+		When:   time.Now(),
+		By:     by,
+		Holder: *h,
+	}
+	bytes, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tx := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.CreateRevision(k), "=", h.rev),
+		etcdv3.Compare(etcdv3.Value(k), "=", h.UUID),
+	).Then(
+		etcdv3.OpDelete(k),
+		etcdv3.OpPut(b.MkKey("volumemeta", vid, "lockaudit", lockRecordName(rec)), string(bytes)),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrAgain
+	}
+	_, err = b.Etcd.Client.Revoke(b.getContext(), etcdv3.LeaseID(h.Lease))
+	if err == rpctypes.ErrLeaseNotFound {
+		return nil
+	}
+	return err
+}
+
+func (b *blockEtcd) GetForcedUnlocks() ([]LockRecord, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(),
+		b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "lockaudit"),
+		etcdv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LockRecord, len(resp.Kvs))
+	for i, r := range resp.Kvs {
+		if err := json.Unmarshal(r.Value, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
 func (b *blockEtcd) SaveSnapshot(name string) error {
 	vid := uint64(b.vid)
 	for {
-		sshotKey := etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "snapshots", name)
-		inoKey := etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blockinode")
+		sshotKey := b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "snapshots", name)
+		inoKey := b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "blockinode")
 		tx := b.Etcd.Client.Txn(b.getContext()).If(
 			etcdv3.Compare(etcdv3.Version(sshotKey), "=", 0),
 		).Then(
@@ -184,7 +305,7 @@ func (b *blockEtcd) SaveSnapshot(name string) error {
 
 func (b *blockEtcd) GetSnapshots() ([]Snapshot, error) {
 	resp, err := b.Etcd.Client.Get(b.getContext(),
-		etcd.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "snapshots"),
+		b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "snapshots"),
 		etcdv3.WithPrefix())
 	if err != nil {
 		return nil, err
@@ -201,7 +322,7 @@ func (b *blockEtcd) GetSnapshots() ([]Snapshot, error) {
 
 func (b *blockEtcd) DeleteSnapshot(name string) error {
 	vid := uint64(b.vid)
-	k := etcd.MkKey("volumemeta", etcd.Uint64ToHex(vid), "snapshots", name)
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(vid), "snapshots", name)
 	tx := b.Etcd.Client.Txn(b.getContext()).If(
 		etcdv3.Compare(etcdv3.Version(k), ">", 0),
 	).Then(
@@ -217,6 +338,468 @@ func (b *blockEtcd) DeleteSnapshot(name string) error {
 	return nil
 }
 
+func (b *blockEtcd) GetReplicaOf() (string, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "replica"))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+func (b *blockEtcd) SetReplicaOf(source string) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "replica")
+	var err error
+	if source == "" {
+		_, err = b.Etcd.Client.Delete(b.getContext(), k)
+	} else {
+		_, err = b.Etcd.Client.Put(b.getContext(), k, source)
+	}
+	return err
+}
+
+func (b *blockEtcd) GetSnapshotPolicy() (SnapshotPolicy, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "snappolicy"))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	var p SnapshotPolicy
+	err = json.Unmarshal(resp.Kvs[0].Value, &p)
+	return p, err
+}
+
+func (b *blockEtcd) SetSnapshotPolicy(p SnapshotPolicy) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "snappolicy")
+	if len(p) == 0 {
+		_, err := b.Etcd.Client.Delete(b.getContext(), k)
+		return err
+	}
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = b.Etcd.Client.Put(b.getContext(), k, string(bytes))
+	return err
+}
+
+func (b *blockEtcd) ClaimScheduler(lease int64) (bool, error) {
+	if lease == 0 {
+		return false, torus.ErrInvalid
+	}
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "scheduler")
+	tx := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Version(k), "=", 0),
+	).Then(
+		etcdv3.OpPut(k, b.Etcd.UUID(), etcdv3.WithLease(etcdv3.LeaseID(lease))),
+	).Else(
+		etcdv3.OpGet(k),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	if resp.Succeeded {
+		return true, nil
+	}
+	kvs := resp.Responses[0].GetResponseRange().Kvs
+	return len(kvs) == 1 && string(kvs[0].Value) == b.Etcd.UUID(), nil
+}
+
+func (b *blockEtcd) SetVolumeSize(size uint64) error {
+	k := b.MkKey("volumeid", etcd.Uint64ToHex(uint64(b.vid)))
+	for {
+		resp, err := b.Etcd.Client.Get(b.getContext(), k)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return torus.ErrNotExist
+		}
+		vol := &models.Volume{}
+		if err = vol.Unmarshal(resp.Kvs[0].Value); err != nil {
+			return err
+		}
+		vol.MaxBytes = size
+		vbytes, err := vol.Marshal()
+		if err != nil {
+			return err
+		}
+		tresp, err := b.Etcd.Client.Txn(b.getContext()).If(
+			etcdv3.Compare(etcdv3.ModRevision(k), "=", resp.Kvs[0].ModRevision),
+		).Then(
+			etcdv3.OpPut(k, string(vbytes)),
+		).Commit()
+		if err != nil {
+			return err
+		}
+		if tresp.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (b *blockEtcd) GetResizeRequest() (uint64, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "resize"))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return etcd.BytesToUint64(resp.Kvs[0].Value), nil
+}
+
+func (b *blockEtcd) SetResizeRequest(size uint64) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "resize")
+	var err error
+	if size == 0 {
+		_, err = b.Etcd.Client.Delete(b.getContext(), k)
+	} else {
+		_, err = b.Etcd.Client.Put(b.getContext(), k, string(etcd.Uint64ToBytes(size)))
+	}
+	return err
+}
+
+func (b *blockEtcd) ClearResizeRequest(done uint64) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "resize")
+	_, err := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Value(k), "=", string(etcd.Uint64ToBytes(done))),
+	).Then(
+		etcdv3.OpDelete(k),
+	).Commit()
+	return err
+}
+
+func (b *blockEtcd) GetUsage() (*Usage, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "usage"))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	u := &Usage{}
+	err = json.Unmarshal(resp.Kvs[0].Value, u)
+	return u, err
+}
+
+func (b *blockEtcd) SetUsage(u *Usage) error {
+	bytes, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	_, err = b.Etcd.Client.Put(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "usage"), string(bytes))
+	return err
+}
+
+func (b *blockEtcd) GetQuota() (uint64, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "quota"))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return etcd.BytesToUint64(resp.Kvs[0].Value), nil
+}
+
+func (b *blockEtcd) SetQuota(quota uint64) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "quota")
+	var err error
+	if quota == 0 {
+		_, err = b.Etcd.Client.Delete(b.getContext(), k)
+	} else {
+		_, err = b.Etcd.Client.Put(b.getContext(), k, string(etcd.Uint64ToBytes(quota)))
+	}
+	return err
+}
+
+func (b *blockEtcd) GetQoS() (*QoS, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "qos"))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	q := &QoS{}
+	err = json.Unmarshal(resp.Kvs[0].Value, q)
+	return q, err
+}
+
+func (b *blockEtcd) SetQoS(q *QoS) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "qos")
+	if q == nil {
+		_, err := b.Etcd.Client.Delete(b.getContext(), k)
+		return err
+	}
+	bytes, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	_, err = b.Etcd.Client.Put(b.getContext(), k, string(bytes))
+	return err
+}
+
+func (b *blockEtcd) GetReservations() ([]byte, int64, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "reservations"))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+	return resp.Kvs[0].Value, resp.Kvs[0].Version, nil
+}
+
+func (b *blockEtcd) SetReservations(state []byte, version int64) error {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "reservations")
+	resp, err := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Version(k), "=", version),
+	).Then(
+		etcdv3.OpPut(k, string(state)),
+	).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrAgain
+	}
+	return nil
+}
+
+func (b *blockEtcd) WatchReservations() (<-chan struct{}, func(), error) {
+	return b.watchMeta("reservations")
+}
+
+// watchMeta watches the volume's metadata key name.
+func (b *blockEtcd) watchMeta(name string) (<-chan struct{}, func(), error) {
+	k := b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), name)
+	// Watch from just after a read, so that no change in between is missed.
+	resp, err := b.Etcd.Client.Get(b.getContext(), k)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(b.getContext())
+	wch := b.Etcd.Client.Watch(ctx, k, etcdv3.WithRev(resp.Header.Revision+1))
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				clog.Errorf("error watching %s: %s", name, err)
+				return
+			}
+			notify(changes)
+		}
+	}()
+	return changes, cancel, nil
+}
+
+func (b *blockEtcd) GetExportState(protocol string) ([]byte, error) {
+	resp, err := b.Etcd.Client.Get(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "export", protocol))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (b *blockEtcd) SetExportState(protocol string, state []byte) error {
+	_, err := b.Etcd.Client.Put(b.getContext(), b.MkKey("volumemeta", etcd.Uint64ToHex(uint64(b.vid)), "export", protocol), string(state))
+	return err
+}
+
+func (b *blockEtcd) TrashVolume(t *TrashedVolume) error {
+	vid := etcd.Uint64ToHex(uint64(b.vid))
+	bytes, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tx := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Version(b.MkKey("volumemeta", vid, "blocklock")), "=", 0),
+	).Then(
+		etcdv3.OpDelete(b.MkKey("volumes", b.name)),
+		etcdv3.OpDelete(b.MkKey("volumeid", vid)),
+		etcdv3.OpPut(b.MkKey("trash", vid), string(bytes)),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockEtcd) UndeleteVolume(vol *models.Volume) error {
+	vid := etcd.Uint64ToHex(uint64(b.vid))
+	vbytes, err := vol.Marshal()
+	if err != nil {
+		return err
+	}
+	tx := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Version(b.MkKey("volumes", vol.Name)), "=", 0),
+		etcdv3.Compare(etcdv3.Version(b.MkKey("trash", vid)), ">", 0),
+	).Then(
+		etcdv3.OpPut(b.MkKey("volumes", vol.Name), string(etcd.Uint64ToBytes(vol.Id))),
+		etcdv3.OpPut(b.MkKey("volumeid", vid), string(vbytes)),
+		etcdv3.OpDelete(b.MkKey("trash", vid)),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrExists
+	}
+	return nil
+}
+
+func (b *blockEtcd) PurgeVolume() error {
+	vid := etcd.Uint64ToHex(uint64(b.vid))
+	tx := b.Etcd.Client.Txn(b.getContext()).If(
+		etcdv3.Compare(etcdv3.Version(b.MkKey("trash", vid)), ">", 0),
+	).Then(
+		etcdv3.OpDelete(b.MkKey("trash", vid)),
+		etcdv3.OpDelete(b.MkKey("volumemeta", vid), etcdv3.WithPrefix()),
+	)
+	resp, err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrNotExist
+	}
+	return nil
+}
+
+func listEtcdTrash(mds torus.MetadataService) ([]TrashedVolume, error) {
+	e, ok := mds.(*etcd.Etcd)
+	if !ok {
+		panic("how are we listing etcd metadata that doesn't implement it but reports as being etcd")
+	}
+	resp, err := e.Client.Get(context.TODO(), e.MkKey("trash"), etcdv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var out []TrashedVolume
+	for _, kv := range resp.Kvs {
+		var t TrashedVolume
+		if err = json.Unmarshal(kv.Value, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func saveEtcdSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	e := mds.(*etcd.Etcd)
+	groupKey := e.MkKey("snapgroups", g.Name)
+	gbytes, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	// The group and its snapshots must not exist yet.
+	absent := []etcdv3.Cmp{etcdv3.Compare(etcdv3.Version(groupKey), "=", 0)}
+	existing := []etcdv3.Op{etcdv3.OpGet(groupKey)}
+	var gets []etcdv3.Op
+	for _, m := range g.Volumes {
+		vid := etcd.Uint64ToHex(uint64(m.ID))
+		k := e.MkKey("volumemeta", vid, "snapshots", g.Name)
+		absent = append(absent, etcdv3.Compare(etcdv3.Version(k), "=", 0))
+		existing = append(existing, etcdv3.OpGet(k))
+		gets = append(gets, etcdv3.OpGet(e.MkKey("volumemeta", vid, "blockinode")))
+	}
+	for {
+		resp, err := e.Client.Txn(context.TODO()).If(absent...).Then(gets...).Commit()
+		if err != nil {
+			return err
+		}
+		if !resp.Succeeded {
+			return torus.ErrExists
+		}
+		// Put every snapshot only if they're all still absent, and no
+		// volume has synced since.
+		cmps := append([]etcdv3.Cmp(nil), absent...)
+		puts := []etcdv3.Op{etcdv3.OpPut(groupKey, string(gbytes))}
+		for i, m := range g.Volumes {
+			vid := etcd.Uint64ToHex(uint64(m.ID))
+			kvs := resp.Responses[i].GetResponseRange().Kvs
+			if len(kvs) == 0 {
+				return torus.ErrNotExist
+			}
+			v := kvs[0]
+			bytes, err := json.Marshal(Snapshot{
+				Name:     g.Name,
+				When:     g.When,
+				INodeRef: v.Value,
+			})
+			if err != nil {
+				return err
+			}
+			cmps = append(cmps, etcdv3.Compare(etcdv3.Version(e.MkKey("volumemeta", vid, "blockinode")), "=", v.Version))
+			puts = append(puts, etcdv3.OpPut(e.MkKey("volumemeta", vid, "snapshots", g.Name), string(bytes)))
+		}
+		resp, err = e.Client.Txn(context.TODO()).If(cmps...).Then(puts...).Else(existing...).Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		for _, r := range resp.Responses {
+			if len(r.GetResponseRange().Kvs) != 0 {
+				return torus.ErrExists
+			}
+		}
+		// A volume synced in between; snapshot its new contents.
+	}
+}
+
+func listEtcdSnapshotGroups(mds torus.MetadataService) ([]SnapshotGroup, error) {
+	e := mds.(*etcd.Etcd)
+	resp, err := e.Client.Get(context.TODO(), e.MkKey("snapgroups"), etcdv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SnapshotGroup, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		if err = json.Unmarshal(kv.Value, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func deleteEtcdSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	e := mds.(*etcd.Etcd)
+	groupKey := e.MkKey("snapgroups", g.Name)
+	dels := []etcdv3.Op{etcdv3.OpDelete(groupKey)}
+	for _, m := range g.Volumes {
+		dels = append(dels, etcdv3.OpDelete(e.MkKey("volumemeta", etcd.Uint64ToHex(uint64(m.ID)), "snapshots", g.Name)))
+	}
+	resp, err := e.Client.Txn(context.TODO()).If(
+		etcdv3.Compare(etcdv3.Version(groupKey), ">", 0),
+	).Then(dels...).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrNotExist
+	}
+	return nil
+}
+
 func createBlockEtcdMetadata(mds torus.MetadataService, name string, vid torus.VolumeID) (blockMetadata, error) {
 	if e, ok := mds.(*etcd.Etcd); ok {
 		return &blockEtcd{
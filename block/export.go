@@ -0,0 +1,37 @@
+package block
+
+// Reservations returns the volume's SCSI persistent reservation state, as
+// kept by package reservation, and its version.
+func (s *BlockVolume) Reservations() ([]byte, int64, error) { return s.mds.GetReservations() }
+
+// SetReservations replaces the volume's reservation state if it's still at
+// version, and returns torus.ErrAgain otherwise.
+func (s *BlockVolume) SetReservations(state []byte, version int64) error {
+	return s.mds.SetReservations(state, version)
+}
+
+// WatchReservations watches the volume's reservation state for changes.
+func (s *BlockVolume) WatchReservations() (<-chan struct{}, func(), error) {
+	return s.mds.WatchReservations()
+}
+
+// notify signals a change on a channel with a buffer of one, without
+// waiting; changes not yet received are merged.
+func notify(changes chan struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// ExportState returns the state an export protocol, such as AoE, keeps for
+// the volume, or nil if it has none.
+func (s *BlockVolume) ExportState(protocol string) ([]byte, error) {
+	return s.mds.GetExportState(protocol)
+}
+
+// SetExportState replaces the state the export protocol keeps for the
+// volume.
+func (s *BlockVolume) SetExportState(protocol string, state []byte) error {
+	return s.mds.SetExportState(protocol, state)
+}
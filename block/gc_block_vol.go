@@ -22,6 +22,8 @@ type blockvolGC struct {
 	set        map[torus.BlockRef]bool
 	highwaters map[torus.VolumeID]torus.INodeID
 	curINodes  []torus.INodeRef
+	// trashPrepped is set once the volumes in the trash have been prepared.
+	trashPrepped bool
 }
 
 func NewBlockVolGC(srv *torus.Server, inodes gc.INodeFetcher) (gc.GC, error) {
@@ -42,6 +44,27 @@ func (b *blockvolGC) PrepVolume(vol *models.Volume) error {
 	if vol.Type != VolumeType {
 		return nil
 	}
+	return b.prepBlockVolume(vol)
+}
+
+// Prepare keeps the blocks of the volumes in the trash, expired or not;
+// they're only dead once purged. Until it succeeds, no block of a volume
+// the GC doesn't know is dead, as it may be in the trash.
+func (b *blockvolGC) Prepare() error {
+	trash, err := ListTrash(b.srv.MDS)
+	if err != nil {
+		return err
+	}
+	for _, t := range trash {
+		if err = b.prepBlockVolume(t.Volume); err != nil {
+			return err
+		}
+	}
+	b.trashPrepped = true
+	return nil
+}
+
+func (b *blockvolGC) prepBlockVolume(vol *models.Volume) error {
 	mds, err := createBlockMetadata(b.srv.MDS, vol.Name, torus.VolumeID(vol.Id))
 	if err != nil {
 		return err
@@ -50,7 +73,10 @@ func (b *blockvolGC) PrepVolume(vol *models.Volume) error {
 	if err != nil {
 		return err
 	}
-	b.highwaters[curRef.Volume()] = 0
+	// Don't reset the highwater if a clone of this volume was prepared first.
+	if _, ok := b.highwaters[torus.VolumeID(vol.Id)]; !ok {
+		b.highwaters[torus.VolumeID(vol.Id)] = 0
+	}
 	if curRef.INode <= 1 {
 		return nil
 	}
@@ -67,6 +93,11 @@ func (b *blockvolGC) PrepVolume(vol *models.Volume) error {
 	}
 
 	for _, x := range curINodes {
+		// Clones reference INodes and blocks of the volume they were cloned
+		// from, which must be kept even once that volume is deleted.
+		if _, ok := b.highwaters[x.Volume()]; !ok {
+			b.highwaters[x.Volume()] = 0
+		}
 		inode, err := b.inodes.GetINode(b.getContext(), x)
 		if err != nil {
 			return err
@@ -93,6 +124,10 @@ func (b *blockvolGC) PrepVolume(vol *models.Volume) error {
 func (b *blockvolGC) IsDead(ref torus.BlockRef) bool {
 	v, ok := b.highwaters[ref.Volume()]
 	if !ok {
+		if !b.trashPrepped {
+			// It may be in the trash.
+			return false
+		}
 		if clog.LevelAt(capnslog.TRACE) {
 			clog.Tracef("%s doesn't exist anymore", ref)
 		}
@@ -135,4 +170,5 @@ func (b *blockvolGC) Clear() {
 	b.highwaters = make(map[torus.VolumeID]torus.INodeID)
 	b.curINodes = make([]torus.INodeRef, 0, len(b.curINodes))
 	b.set = make(map[torus.BlockRef]bool)
+	b.trashPrepped = false
 }
@@ -0,0 +1,190 @@
+package block
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/coreos/torus"
+)
+
+// A SnapshotGroup is a set of snapshots of several block volumes, all taken at
+// the same instant. Each volume's snapshot has the name of the group.
+type SnapshotGroup struct {
+	Name    string
+	When    time.Time
+	Volumes []SnapshotGroupMember
+}
+
+// SnapshotGroupMember is a volume snapshotted in a SnapshotGroup.
+type SnapshotGroupMember struct {
+	Name string
+	ID   torus.VolumeID
+}
+
+// CreateSnapshotGroup snapshots the block volumes at once, as the group name.
+// It fails with torus.ErrExists if the group or a snapshot of that name
+// already exists.
+func CreateSnapshotGroup(mds torus.MetadataService, name string, volumes []string) error {
+	if name == "" || len(volumes) == 0 {
+		return torus.ErrInvalid
+	}
+	g := &SnapshotGroup{Name: name, When: time.Now()}
+	seen := make(map[string]bool)
+	for _, v := range volumes {
+		if seen[v] {
+			return torus.ErrInvalid
+		}
+		seen[v] = true
+		vol, err := mds.GetVolume(v)
+		if err != nil {
+			return err
+		}
+		if vol.Type != VolumeType {
+			return torus.ErrWrongVolumeType
+		}
+		g.Volumes = append(g.Volumes, SnapshotGroupMember{Name: vol.Name, ID: torus.VolumeID(vol.Id)})
+	}
+	clog.Infof("taking group snapshot %s of %d volumes", name, len(g.Volumes))
+	switch mds.Kind() {
+	case torus.EtcdMetadata:
+		return saveEtcdSnapshotGroup(mds, g)
+	case torus.TempMetadata:
+		return saveTempSnapshotGroup(mds, g)
+	case torus.LocalMetadata, torus.RaftMetadata:
+		return saveLocalSnapshotGroup(mds, g)
+	}
+	return errors.New("unimplemented for this kind of metadata")
+}
+
+type groupsByName []SnapshotGroup
+
+func (g groupsByName) Len() int           { return len(g) }
+func (g groupsByName) Less(i, j int) bool { return g[i].Name < g[j].Name }
+func (g groupsByName) Swap(i, j int)      { g[i], g[j] = g[j], g[i] }
+
+// ListSnapshotGroups returns the snapshot groups of the cluster, by name.
+func ListSnapshotGroups(mds torus.MetadataService) ([]SnapshotGroup, error) {
+	var (
+		out []SnapshotGroup
+		err error
+	)
+	switch mds.Kind() {
+	case torus.EtcdMetadata:
+		out, err = listEtcdSnapshotGroups(mds)
+	case torus.TempMetadata:
+		out, err = listTempSnapshotGroups(mds)
+	case torus.LocalMetadata, torus.RaftMetadata:
+		out, err = listLocalSnapshotGroups(mds)
+	default:
+		return nil, errors.New("unimplemented for this kind of metadata")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(groupsByName(out))
+	return out, nil
+}
+
+// GetSnapshotGroup returns the snapshot group name, or torus.ErrNotExist.
+func GetSnapshotGroup(mds torus.MetadataService, name string) (*SnapshotGroup, error) {
+	groups, err := ListSnapshotGroups(mds)
+	if err != nil {
+		return nil, err
+	}
+	for i, g := range groups {
+		if g.Name == name {
+			return &groups[i], nil
+		}
+	}
+	return nil, torus.ErrNotExist
+}
+
+// DeleteSnapshotGroup deletes the snapshot group name and the snapshots of
+// its volumes at once.
+func DeleteSnapshotGroup(mds torus.MetadataService, name string) error {
+	g, err := GetSnapshotGroup(mds, name)
+	if err != nil {
+		return err
+	}
+	clog.Infof("deleting group snapshot %s", name)
+	switch mds.Kind() {
+	case torus.EtcdMetadata:
+		return deleteEtcdSnapshotGroup(mds, g)
+	case torus.TempMetadata:
+		return deleteTempSnapshotGroup(mds, g)
+	case torus.LocalMetadata, torus.RaftMetadata:
+		return deleteLocalSnapshotGroup(mds, g)
+	}
+	return errors.New("unimplemented for this kind of metadata")
+}
+
+// RestoreSnapshotGroup restores every volume of the snapshot group name to
+// its snapshot. It holds the locks of all the volumes while doing so, and
+// fails with torus.ErrLocked, changing nothing, if any of them is attached.
+func RestoreSnapshotGroup(srv *torus.Server, name string) (err error) {
+	g, err := GetSnapshotGroup(srv.MDS, name)
+	if err != nil {
+		return err
+	}
+	vols, _, err := srv.MDS.GetVolumes()
+	if err != nil {
+		return err
+	}
+	names := make(map[torus.VolumeID]string)
+	for _, v := range vols {
+		names[torus.VolumeID(v.Id)] = v.Name
+	}
+	var (
+		locked []*BlockVolume
+		refs   []torus.INodeRef
+	)
+	defer func() {
+		for _, vol := range locked {
+			unlockErr := vol.mds.Unlock()
+			if err == nil {
+				err = unlockErr
+			}
+		}
+	}()
+	for _, m := range g.Volumes {
+		// The volume may have been renamed by undeleting it.
+		n, ok := names[m.ID]
+		if !ok {
+			return torus.ErrNotExist
+		}
+		vol, err := OpenBlockVolume(srv, n)
+		if err != nil {
+			return err
+		}
+		if err = vol.checkWritable(); err != nil {
+			return err
+		}
+		if _, err = vol.mds.Lock(srv.Lease()); err != nil {
+			return err
+		}
+		locked = append(locked, vol)
+		snaps, err := vol.mds.GetSnapshots()
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, x := range snaps {
+			if x.Name == g.Name {
+				refs = append(refs, torus.INodeRefFromBytes(x.INodeRef))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return torus.ErrNotExist
+		}
+	}
+	clog.Infof("restoring group snapshot %s", name)
+	for i, vol := range locked {
+		if err = vol.mds.SyncINode(refs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,615 @@
+package iscsi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxRecvData is the MaxRecvDataSegmentLength the target declares, and
+	// defaultSendData the initiator's, until it declares its own.
+	maxRecvData     = 64 * 1024
+	defaultSendData = 8 * 1024
+
+	// maxBurst and firstBurst bound the data solicited by one R2T, and sent
+	// as immediate data, respectively.
+	maxBurst   = 256 * 1024
+	firstBurst = 64 * 1024
+
+	// cmdWindow is how many commands the initiator may queue.
+	cmdWindow = 32
+)
+
+// login stages
+const (
+	stageSecurity    = 0
+	stageOperational = 1
+	stageFullFeature = 3
+)
+
+// login status classes and details
+const (
+	loginSuccess        = 0x0000
+	loginAuthFailure    = 0x0201
+	loginNotFound       = 0x0203
+	loginUnsupportedVer = 0x0205
+	loginMissingParam   = 0x0207
+	loginInvalidDuring  = 0x020c
+	loginTargetError    = 0x0300
+	loginUnavailable    = 0x0301
+)
+
+// errLogout ends a connection whose initiator has logged out.
+var errLogout = errors.New("iscsi: logged out")
+
+type conn struct {
+	s *Server
+	c net.Conn
+	r *bufio.Reader
+
+	statSN   uint32
+	expCmdSN uint32
+
+	// sendData is the initiator's MaxRecvDataSegmentLength.
+	sendData int
+
+	initiator string
+	target    string
+	discovery bool
+	isid      [6]byte
+	tsih      uint16
+
+	luns  []*LUN
+	tasks map[uint32]*task
+	ttt   uint32
+}
+
+// A task is a write command waiting for its data.
+type task struct {
+	cmd      *pdu
+	data     []byte
+	received int
+	// solicited is the end of the data asked for by the last R2T.
+	solicited int
+	r2tSN     uint32
+	ttt       uint32
+}
+
+func newConn(s *Server, c net.Conn) *conn {
+	return &conn{
+		s:        s,
+		c:        c,
+		r:        bufio.NewReaderSize(c, 64*1024),
+		sendData: defaultSendData,
+		tasks:    make(map[uint32]*task),
+	}
+}
+
+func (c *conn) errorf(format string, args ...interface{}) {
+	clog.Errorf("%s: %s: %s", c.c.RemoteAddr(), c.initiator, fmt.Sprintf(format, args...))
+}
+
+func (c *conn) close() {
+	if c.luns != nil {
+		if err := c.s.closeTarget(c.target); err != nil {
+			c.errorf("couldn't close target %s: %v", c.target, err)
+		}
+	}
+	c.c.Close()
+}
+
+func (c *conn) serve() error {
+	if err := c.login(); err != nil {
+		return err
+	}
+	clog.Debugf("%s: %s logged in to %q", c.c.RemoteAddr(), c.initiator, c.target)
+	for {
+		p, err := readPDU(c.r, maxRecvData)
+		if err != nil {
+			return err
+		}
+		if p.opcode() != opDataOut && !p.immediate() {
+			c.expCmdSN = p.cmdSN() + 1
+		}
+		switch p.opcode() {
+		case opNopOut:
+			err = c.nopOut(p)
+		case opSCSICmd:
+			if c.discovery {
+				err = c.reject(p, rejectProtocolError)
+				break
+			}
+			err = c.scsiCommand(p)
+		case opDataOut:
+			err = c.dataOut(p)
+		case opTextReq:
+			err = c.textRequest(p)
+		case opTaskMgmt:
+			err = c.taskMgmt(p)
+		case opLogoutReq:
+			err = c.logout(p)
+		default:
+			err = c.reject(p, rejectCommandNotSupported)
+		}
+		if err == errLogout {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// send sends a response, filling in the command sequence numbers, and the
+// status sequence number if it carries status.
+func (c *conn) send(p *pdu, status bool) error {
+	if status {
+		p.setU32(24, c.statSN)
+		c.statSN++
+	}
+	p.setU32(28, c.expCmdSN)
+	p.setU32(32, c.expCmdSN+cmdWindow-1)
+	return p.writeTo(c.c)
+}
+
+func (c *conn) reject(p *pdu, reason byte) error {
+	rep := newPDU(opReject)
+	rep.bhs[1] = flagFinal
+	rep.bhs[2] = reason
+	rep.setITT(reservedTag)
+	rep.data = p.bhs[:]
+	return c.send(rep, true)
+}
+
+func (c *conn) login() error {
+	var (
+		text   []byte
+		first  = true
+		stage  = stageSecurity
+		agreed = make(map[string]bool)
+	)
+	for {
+		p, err := readPDU(c.r, maxRecvData)
+		if err != nil {
+			return err
+		}
+		if p.opcode() != opLoginReq {
+			return fmt.Errorf("iscsi: got opcode 0x%x during login", p.opcode())
+		}
+		transit := p.flags()&flagTransit != 0
+		csg := int(p.flags()>>2) & 3
+		nsg := int(p.flags()) & 3
+		if first {
+			copy(c.isid[:], p.bhs[8:14])
+			c.statSN = p.expStatSN()
+			if p.bhs[3] > 0 {
+				return c.loginReject(p, loginUnsupportedVer)
+			}
+		}
+		c.expCmdSN = p.cmdSN()
+
+		text = append(text, p.data...)
+		if p.flags()&flagContinue != 0 {
+			// Wait for the rest of the text before answering it.
+			if err = c.loginReply(p, false, csg, nsg, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		params := parseParams(text)
+		text = nil
+
+		if csg < stage || csg == stageFullFeature || (transit && nsg <= csg) {
+			return c.loginReject(p, loginInvalidDuring)
+		}
+		stage = csg
+		var reply []param
+		if first {
+			status := c.loginStart(params)
+			if status != loginSuccess {
+				return c.loginReject(p, status)
+			}
+			if !c.discovery {
+				reply = append(reply, param{"TargetPortalGroupTag", "1"})
+			}
+			first = false
+		}
+		rep, status := c.negotiate(csg, params, agreed)
+		if status != loginSuccess {
+			return c.loginReject(p, status)
+		}
+		reply = append(reply, rep...)
+		if transit && nsg == stageFullFeature {
+			if !agreed["MaxRecvDataSegmentLength"] {
+				reply = append(reply, param{"MaxRecvDataSegmentLength", strconv.Itoa(maxRecvData)})
+			}
+			if !c.discovery {
+				luns, err := c.s.openTarget(c.target)
+				if err != nil {
+					c.errorf("couldn't open target %s: %v", c.target, err)
+					return c.loginReject(p, loginUnavailable)
+				}
+				c.luns = luns
+			}
+			c.tsih = c.s.newTSIH()
+			return c.loginReply(p, true, csg, nsg, reply)
+		}
+		if err = c.loginReply(p, transit, csg, nsg, reply); err != nil {
+			return err
+		}
+		if transit {
+			stage = nsg
+		}
+	}
+}
+
+// loginStart checks the declarations of the first login request.
+func (c *conn) loginStart(params []param) int {
+	for _, kv := range params {
+		switch kv.key {
+		case "InitiatorName":
+			c.initiator = kv.value
+		case "SessionType":
+			c.discovery = kv.value == "Discovery"
+		case "TargetName":
+			c.target = kv.value
+		}
+	}
+	if c.initiator == "" {
+		return loginMissingParam
+	}
+	if c.discovery {
+		return loginSuccess
+	}
+	if c.target == "" {
+		return loginMissingParam
+	}
+	names, err := c.s.finder.ListTargets()
+	if err != nil {
+		c.errorf("couldn't list targets: %v", err)
+		return loginTargetError
+	}
+	for _, n := range names {
+		if n == c.target {
+			return loginSuccess
+		}
+	}
+	return loginNotFound
+}
+
+// negotiate answers the keys of a login request.
+func (c *conn) negotiate(stage int, params []param, agreed map[string]bool) ([]param, int) {
+	var reply []param
+	answer := func(key, value string) {
+		reply = append(reply, param{key, value})
+		agreed[key] = true
+	}
+	for _, kv := range params {
+		switch kv.key {
+		case "InitiatorName", "InitiatorAlias", "SessionType", "TargetName":
+			// declarations
+		case "AuthMethod":
+			if stage != stageSecurity {
+				return nil, loginInvalidDuring
+			}
+			if !hasValue(kv.value, "None") {
+				return nil, loginAuthFailure
+			}
+			answer(kv.key, "None")
+		case "HeaderDigest", "DataDigest":
+			if !hasValue(kv.value, "None") {
+				answer(kv.key, "Reject")
+			} else {
+				answer(kv.key, "None")
+			}
+		case "MaxRecvDataSegmentLength":
+			n, err := strconv.Atoi(kv.value)
+			if err != nil || n < 512 {
+				answer(kv.key, "Reject")
+				continue
+			}
+			c.sendData = n
+			answer(kv.key, strconv.Itoa(maxRecvData))
+		case "MaxBurstLength":
+			answer(kv.key, minValue(kv.value, maxBurst))
+		case "FirstBurstLength":
+			answer(kv.key, minValue(kv.value, firstBurst))
+		case "InitialR2T":
+			// Unsolicited data isn't taken.
+			answer(kv.key, "Yes")
+		case "ImmediateData":
+			answer(kv.key, kv.value)
+		case "MaxConnections", "MaxOutstandingR2T":
+			answer(kv.key, "1")
+		case "DataPDUInOrder", "DataSequenceInOrder":
+			answer(kv.key, "Yes")
+		case "ErrorRecoveryLevel", "DefaultTime2Retain":
+			answer(kv.key, "0")
+		case "DefaultTime2Wait":
+			answer(kv.key, kv.value)
+		case "IFMarker", "OFMarker":
+			answer(kv.key, "No")
+		case "IFMarkInt", "OFMarkInt":
+			answer(kv.key, "Irrelevant")
+		default:
+			answer(kv.key, "NotUnderstood")
+		}
+	}
+	return reply, loginSuccess
+}
+
+func hasValue(list, value string) bool {
+	for _, v := range strings.Split(list, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// minValue answers a numerical key whose result is the lesser offer.
+func minValue(offer string, max int) string {
+	n, err := strconv.Atoi(offer)
+	if err != nil {
+		return "Reject"
+	}
+	if n > max {
+		n = max
+	}
+	return strconv.Itoa(n)
+}
+
+func (c *conn) loginReply(req *pdu, transit bool, csg, nsg int, params []param) error {
+	rep := newPDU(opLoginResp)
+	if transit {
+		rep.bhs[1] = flagTransit | byte(csg<<2) | byte(nsg)
+	} else {
+		rep.bhs[1] = byte(csg << 2)
+	}
+	copy(rep.bhs[8:14], c.isid[:])
+	rep.bhs[14], rep.bhs[15] = byte(c.tsih>>8), byte(c.tsih)
+	rep.setITT(req.itt())
+	rep.data = encodeParams(params)
+	return c.send(rep, true)
+}
+
+func (c *conn) loginReject(req *pdu, status int) error {
+	rep := newPDU(opLoginResp)
+	copy(rep.bhs[8:14], c.isid[:])
+	rep.setITT(req.itt())
+	rep.bhs[36], rep.bhs[37] = byte(status>>8), byte(status)
+	if err := c.send(rep, true); err != nil {
+		return err
+	}
+	return fmt.Errorf("iscsi: login of %q to %q failed with status 0x%04x", c.initiator, c.target, status)
+}
+
+func (c *conn) nopOut(p *pdu) error {
+	if p.itt() == reservedTag {
+		// a ping answering ours, which are never sent
+		return nil
+	}
+	rep := newPDU(opNopIn)
+	rep.bhs[1] = flagFinal
+	copy(rep.bhs[8:16], p.bhs[8:16])
+	rep.setITT(p.itt())
+	rep.setTTT(reservedTag)
+	rep.data = p.data
+	return c.send(rep, true)
+}
+
+func (c *conn) textRequest(p *pdu) error {
+	rep := newPDU(opTextResp)
+	rep.bhs[1] = flagFinal
+	rep.setITT(p.itt())
+	rep.setTTT(reservedTag)
+	var reply []param
+	for _, kv := range parseParams(p.data) {
+		if kv.key != "SendTargets" {
+			reply = append(reply, param{kv.key, "NotUnderstood"})
+			continue
+		}
+		names, err := c.s.finder.ListTargets()
+		if err != nil {
+			c.errorf("couldn't list targets: %v", err)
+			break
+		}
+		addr := c.c.LocalAddr().String() + ",1"
+		for _, n := range names {
+			if kv.value != "All" && kv.value != n && !(kv.value == "" && n == c.target) {
+				continue
+			}
+			reply = append(reply, param{"TargetName", n}, param{"TargetAddress", addr})
+		}
+	}
+	rep.data = encodeParams(reply)
+	if len(rep.data) > c.sendData {
+		// Too many targets to list in one PDU; list as many as fit.
+		rep.data = rep.data[:c.sendData]
+		if i := strings.LastIndex(string(rep.data), "\x00TargetName="); i >= 0 {
+			rep.data = rep.data[:i+1]
+		}
+	}
+	return c.send(rep, true)
+}
+
+// task management function responses
+const (
+	tmfComplete     = 0
+	tmfNoTask       = 1
+	tmfNotSupported = 5
+)
+
+func (c *conn) taskMgmt(p *pdu) error {
+	rep := newPDU(opTaskMgmtResp)
+	rep.bhs[1] = flagFinal
+	rep.setITT(p.itt())
+	switch p.flags() & 0x7f {
+	case 1: // ABORT TASK
+		// Commands are run as they arrive, so only a write waiting for its
+		// data can still be aborted.
+		if _, ok := c.tasks[p.u32(20)]; ok {
+			delete(c.tasks, p.u32(20))
+		} else {
+			rep.bhs[2] = tmfNoTask
+		}
+	case 2, 3, 5, 6, 7:
+		// ABORT TASK SET, CLEAR ACA, CLEAR TASK SET, LOGICAL UNIT RESET and
+		// TARGET WARM RESET
+		c.tasks = make(map[uint32]*task)
+	default:
+		rep.bhs[2] = tmfNotSupported
+	}
+	return c.send(rep, true)
+}
+
+func (c *conn) logout(p *pdu) error {
+	rep := newPDU(opLogoutResp)
+	rep.bhs[1] = flagFinal
+	rep.setITT(p.itt())
+	if err := c.send(rep, true); err != nil {
+		return err
+	}
+	return errLogout
+}
+
+func (c *conn) scsiCommand(p *pdu) error {
+	t := &task{cmd: p}
+	edtl := int(p.u32(20))
+	if p.flags()&flagWrite == 0 || edtl == 0 {
+		return c.execute(t)
+	}
+	if edtl > maxTransfer {
+		return c.respond(t, checkCondition(senseIllegalRequest, ascInvalidFieldInCDB), nil)
+	}
+	t.data = make([]byte, edtl)
+	t.received = copy(t.data, p.data)
+	t.solicited = t.received
+	if t.received == edtl {
+		return c.execute(t)
+	}
+	c.ttt++
+	if c.ttt == reservedTag {
+		c.ttt = 0
+	}
+	t.ttt = c.ttt
+	c.tasks[p.itt()] = t
+	return c.r2t(t)
+}
+
+// r2t asks for the next burst of the data of a write.
+func (c *conn) r2t(t *task) error {
+	n := len(t.data) - t.received
+	if n > maxBurst {
+		n = maxBurst
+	}
+	rep := newPDU(opR2T)
+	rep.bhs[1] = flagFinal
+	copy(rep.bhs[8:16], t.cmd.bhs[8:16])
+	rep.setITT(t.cmd.itt())
+	rep.setTTT(t.ttt)
+	rep.setU32(24, c.statSN)
+	rep.setU32(36, t.r2tSN)
+	rep.setU32(40, uint32(t.received))
+	rep.setU32(44, uint32(n))
+	t.r2tSN++
+	t.solicited = t.received + n
+	return c.send(rep, false)
+}
+
+func (c *conn) dataOut(p *pdu) error {
+	t, ok := c.tasks[p.itt()]
+	if !ok || p.ttt() != t.ttt {
+		// aborted, or never asked for
+		return nil
+	}
+	off := int(p.u32(40))
+	if off+len(p.data) > t.solicited {
+		delete(c.tasks, p.itt())
+		return c.reject(p, rejectProtocolError)
+	}
+	copy(t.data[off:], p.data)
+	t.received += len(p.data)
+	if !p.final() {
+		return nil
+	}
+	if t.received < len(t.data) {
+		return c.r2t(t)
+	}
+	delete(c.tasks, p.itt())
+	return c.execute(t)
+}
+
+func (c *conn) execute(t *task) error {
+	var lun *LUN
+	if n := int(t.cmd.lun()); n < len(c.luns) {
+		lun = c.luns[n]
+	}
+	in, res := c.command(lun, t.cmd.bhs[32:48], t.data)
+	return c.respond(t, res, in)
+}
+
+// respond ends a command, sending the data it read along with its status.
+func (c *conn) respond(t *task, res result, in []byte) error {
+	edtl := int(t.cmd.u32(20))
+	var flags byte
+	var residual int
+	if t.cmd.flags()&flagRead != 0 || t.cmd.flags()&flagWrite == 0 {
+		if len(in) > edtl {
+			flags, residual = flagOverflow, len(in)-edtl
+			in = in[:edtl]
+		} else if len(in) < edtl {
+			flags, residual = flagUnderflow, edtl-len(in)
+		}
+	}
+	if len(in) > 0 && res.status == statusGood {
+		return c.dataIn(t, in, flags, residual)
+	}
+	rep := newPDU(opSCSIResp)
+	rep.bhs[1] = flagFinal | flags
+	rep.bhs[3] = res.status
+	rep.setITT(t.cmd.itt())
+	rep.setU32(44, uint32(residual))
+	if res.sense != nil {
+		rep.data = make([]byte, 2+len(res.sense))
+		rep.data[0], rep.data[1] = byte(len(res.sense)>>8), byte(len(res.sense))
+		copy(rep.data[2:], res.sense)
+	}
+	return c.send(rep, true)
+}
+
+// dataIn sends the data of a read in PDUs the initiator can take, with the
+// status of the command on the last.
+func (c *conn) dataIn(t *task, in []byte, flags byte, residual int) error {
+	var sn uint32
+	for off := 0; off < len(in); sn++ {
+		n := len(in) - off
+		if n > c.sendData {
+			n = c.sendData
+		}
+		rep := newPDU(opDataIn)
+		copy(rep.bhs[8:16], t.cmd.bhs[8:16])
+		rep.setITT(t.cmd.itt())
+		rep.setTTT(reservedTag)
+		rep.setU32(36, sn)
+		rep.setU32(40, uint32(off))
+		rep.data = in[off : off+n]
+		off += n
+		last := off == len(in)
+		if last || off%maxBurst == 0 {
+			rep.bhs[1] |= flagFinal
+		}
+		if last {
+			rep.bhs[1] |= flagStatus | flags
+			rep.bhs[3] = statusGood
+			rep.setU32(44, uint32(residual))
+		}
+		if err := c.send(rep, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package iscsi
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// initiator is a minimal iSCSI initiator, for testing the target.
+type initiator struct {
+	c net.Conn
+	r *bufio.Reader
+
+	itt       uint32
+	cmdSN     uint32
+	expStatSN uint32
+
+	// maxSend is the target's MaxRecvDataSegmentLength.
+	maxSend    int
+	firstBurst int
+	immediate  bool
+}
+
+const initiatorName = "iqn.2016-09.com.example:test"
+
+func dial(addr string) (*initiator, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &initiator{
+		c:       c,
+		r:       bufio.NewReader(c),
+		maxSend: defaultSendData,
+	}, nil
+}
+
+// loginError is the status of a failed login.
+type loginError int
+
+func (e loginError) Error() string { return fmt.Sprintf("login failed with status 0x%04x", int(e)) }
+
+// login logs in to the target, or starts a discovery session if target is
+// empty.
+func (i *initiator) login(target string) error {
+	security := []param{{"InitiatorName", initiatorName}, {"AuthMethod", "None"}}
+	if target == "" {
+		security = append(security, param{"SessionType", "Discovery"})
+	} else {
+		security = append(security, param{"SessionType", "Normal"}, param{"TargetName", target})
+	}
+	if _, err := i.loginStage(stageSecurity, stageOperational, security); err != nil {
+		return err
+	}
+	rep, err := i.loginStage(stageOperational, stageFullFeature, []param{
+		{"HeaderDigest", "None"},
+		{"DataDigest", "None"},
+		{"MaxRecvDataSegmentLength", "16384"},
+		{"ImmediateData", "Yes"},
+		{"InitialR2T", "Yes"},
+		{"FirstBurstLength", "65536"},
+		{"MaxBurstLength", "1048576"},
+		{"ErrorRecoveryLevel", "0"},
+	})
+	if err != nil {
+		return err
+	}
+	for _, kv := range rep {
+		switch kv.key {
+		case "MaxRecvDataSegmentLength":
+			i.maxSend, _ = strconv.Atoi(kv.value)
+		case "FirstBurstLength":
+			i.firstBurst, _ = strconv.Atoi(kv.value)
+		case "ImmediateData":
+			i.immediate = kv.value == "Yes"
+		}
+	}
+	return nil
+}
+
+func (i *initiator) loginStage(csg, nsg int, params []param) ([]param, error) {
+	p := newPDU(opLoginReq | flagImmediate)
+	p.bhs[1] = flagTransit | byte(csg<<2) | byte(nsg)
+	copy(p.bhs[8:14], []byte{0x80, 1, 2, 3, 4, 5})
+	p.setITT(i.itt)
+	p.setU32(24, i.cmdSN)
+	p.setU32(28, i.expStatSN)
+	p.data = encodeParams(params)
+	rep, err := i.roundTrip(p)
+	if err != nil {
+		return nil, err
+	}
+	if rep.opcode() != opLoginResp {
+		return nil, fmt.Errorf("got opcode 0x%x to login", rep.opcode())
+	}
+	if status := int(rep.bhs[36])<<8 | int(rep.bhs[37]); status != 0 {
+		return nil, loginError(status)
+	}
+	if rep.flags()&flagTransit == 0 || int(rep.flags()&3) != nsg {
+		return nil, fmt.Errorf("target didn't move to stage %d", nsg)
+	}
+	return parseParams(rep.data), nil
+}
+
+func (i *initiator) send(p *pdu) error {
+	return p.writeTo(i.c)
+}
+
+func (i *initiator) recv() (*pdu, error) {
+	p, err := readPDU(i.r, 1<<24)
+	if err != nil {
+		return nil, err
+	}
+	switch p.opcode() {
+	case opSCSIResp, opNopIn, opTextResp, opLogoutResp, opLoginResp, opTaskMgmtResp, opReject:
+		i.expStatSN = p.u32(24) + 1
+	case opDataIn:
+		if p.flags()&flagStatus != 0 {
+			i.expStatSN = p.u32(24) + 1
+		}
+	}
+	return p, nil
+}
+
+func (i *initiator) roundTrip(p *pdu) (*pdu, error) {
+	if err := i.send(p); err != nil {
+		return nil, err
+	}
+	return i.recv()
+}
+
+// request fills in the tags and sequence numbers of a new request.
+func (i *initiator) request(op byte) *pdu {
+	p := newPDU(op)
+	i.itt++
+	p.setITT(i.itt)
+	p.setU32(24, i.cmdSN)
+	p.setU32(28, i.expStatSN)
+	i.cmdSN++
+	return p
+}
+
+func (i *initiator) sendTargets() ([]param, error) {
+	p := i.request(opTextReq)
+	p.bhs[1] = flagFinal
+	p.setTTT(reservedTag)
+	p.data = encodeParams([]param{{"SendTargets", "All"}})
+	rep, err := i.roundTrip(p)
+	if err != nil {
+		return nil, err
+	}
+	return parseParams(rep.data), nil
+}
+
+func (i *initiator) logout() error {
+	p := i.request(opLogoutReq | flagImmediate)
+	p.bhs[1] = flagFinal
+	rep, err := i.roundTrip(p)
+	if err != nil {
+		return err
+	}
+	if rep.opcode() != opLogoutResp {
+		return fmt.Errorf("got opcode 0x%x to logout", rep.opcode())
+	}
+	return i.c.Close()
+}
+
+// command runs a SCSI command, sending out and reading up to inLen bytes.
+func (i *initiator) command(lun uint16, cdb []byte, out []byte, inLen int) ([]byte, result, error) {
+	p := i.request(opSCSICmd)
+	p.bhs[1] = flagFinal | 0x1 // simple
+	if inLen > 0 {
+		p.bhs[1] |= flagRead
+		p.setU32(20, uint32(inLen))
+	}
+	if out != nil {
+		p.bhs[1] |= flagWrite
+		p.setU32(20, uint32(len(out)))
+		if i.immediate {
+			n := len(out)
+			if n > i.firstBurst {
+				n = i.firstBurst
+			}
+			if n > i.maxSend {
+				n = i.maxSend
+			}
+			p.data = out[:n]
+		}
+	}
+	p.setLUN(lun)
+	copy(p.bhs[32:48], cdb)
+	if err := i.send(p); err != nil {
+		return nil, result{}, err
+	}
+	in := make([]byte, inLen)
+	for {
+		rep, err := i.recv()
+		if err != nil {
+			return nil, result{}, err
+		}
+		switch rep.opcode() {
+		case opR2T:
+			off, n := int(rep.u32(40)), int(rep.u32(44))
+			for sent, sn := 0, uint32(0); sent < n; sn++ {
+				d := newPDU(opDataOut)
+				chunk := n - sent
+				if chunk > i.maxSend {
+					chunk = i.maxSend
+				}
+				if sent+chunk == n {
+					d.bhs[1] = flagFinal
+				}
+				copy(d.bhs[8:16], p.bhs[8:16])
+				d.setITT(p.itt())
+				d.setTTT(rep.ttt())
+				d.setU32(28, i.expStatSN)
+				d.setU32(36, sn)
+				d.setU32(40, uint32(off+sent))
+				d.data = out[off+sent : off+sent+chunk]
+				if err = i.send(d); err != nil {
+					return nil, result{}, err
+				}
+				sent += chunk
+			}
+		case opDataIn:
+			copy(in[rep.u32(40):], rep.data)
+			if rep.flags()&flagStatus != 0 {
+				return in, result{status: rep.bhs[3]}, nil
+			}
+		case opSCSIResp:
+			res := result{status: rep.bhs[3]}
+			if len(rep.data) > 2 {
+				res.sense = rep.data[2:]
+			}
+			return nil, res, nil
+		default:
+			return nil, result{}, fmt.Errorf("got opcode 0x%x to a command", rep.opcode())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package iscsi provides an iSCSI target, serving Torus block volumes as
+// SCSI disks over TCP to initiators which have no other way to reach them,
+// such as hypervisors.
+//
+// Only what initiators need to use a disk is implemented: a single
+// connection per session, error recovery level 0, no digests and no
+// authentication.
+package iscsi
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "iscsi")
+
+const (
+	// DefaultPort is the port iSCSI targets listen on.
+	DefaultPort = 3260
+
+	// TargetPrefix starts the names of the targets of torusblk.
+	TargetPrefix = "iqn.2016-09.com.coreos.torus:"
+
+	tcpKeepAlive = 10 * time.Second
+)
+
+// ErrNoTarget is returned by a TargetFinder for a target it doesn't have.
+var ErrNoTarget = errors.New("iscsi: no such target")
+
+// Device is the storage behind a LUN. *block.BlockFile is a Device.
+type Device interface {
+	ReadAt(b []byte, off int64) (n int, err error)
+	WriteAt(b []byte, off int64) (n int, err error)
+	Sync() error
+	Trim(off, len int64) error
+	Size() uint64
+	Close() error
+}
+
+// A BlockSizer is a Device which can only trim whole blocks of its size.
+type BlockSizer interface {
+	BlockSize() uint32
+}
+
+// A LUN is a logical unit of a target.
+type LUN struct {
+	// Name identifies the LUN to initiators, in its serial number and
+	// device identifiers. It's usually the name of its volume.
+	Name   string
+	Device Device
+}
+
+// A TargetFinder finds the targets a Server serves.
+type TargetFinder interface {
+	// ListTargets returns the names of the targets, for discovery.
+	ListTargets() ([]string, error)
+	// OpenTarget opens the LUNs of a target, numbered from 0 in order.
+	OpenTarget(name string) ([]*LUN, error)
+}
+
+// Server is an iSCSI target server. Each target is opened on the first login
+// to it and stays open until its last session ends, so initiators may open
+// several sessions to it for multipathing.
+type Server struct {
+	l      net.Listener
+	finder TargetFinder
+
+	mut     sync.Mutex
+	targets map[string]*sharedTarget
+	tsih    uint16
+	conns   map[net.Conn]bool
+	wg      sync.WaitGroup
+}
+
+type sharedTarget struct {
+	luns []*LUN
+	refs int
+}
+
+// NewServer returns a Server listening on addr for the targets of finder.
+func NewServer(addr string, finder TargetFinder) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		l:       l,
+		finder:  finder,
+		targets: make(map[string]*sharedTarget),
+		conns:   make(map[net.Conn]bool),
+	}, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr { return s.l.Addr() }
+
+// Serve accepts connections until the server is closed.
+func (s *Server) Serve() error {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return err
+		}
+		if tc, ok := c.(*net.TCPConn); ok {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(tcpKeepAlive)
+		}
+		s.mut.Lock()
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.mut.Unlock()
+		go func() {
+			defer s.wg.Done()
+			conn := newConn(s, c)
+			if err := conn.serve(); err != nil {
+				clog.Errorf("%s: %v", c.RemoteAddr(), err)
+			}
+			conn.close()
+			s.mut.Lock()
+			delete(s.conns, c)
+			s.mut.Unlock()
+		}()
+	}
+}
+
+// Close stops the server, dropping the connections of its sessions and
+// closing their targets.
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.mut.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mut.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) openTarget(name string) ([]*LUN, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if t, ok := s.targets[name]; ok {
+		t.refs++
+		return t.luns, nil
+	}
+	luns, err := s.finder.OpenTarget(name)
+	if err != nil {
+		return nil, err
+	}
+	s.targets[name] = &sharedTarget{luns: luns, refs: 1}
+	return luns, nil
+}
+
+func (s *Server) closeTarget(name string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	t := s.targets[name]
+	t.refs--
+	var err error
+	for _, l := range t.luns {
+		var lerr error
+		if t.refs > 0 {
+			lerr = l.Device.Sync()
+		} else {
+			lerr = l.Device.Close()
+		}
+		if err == nil {
+			err = lerr
+		}
+	}
+	if t.refs == 0 {
+		delete(s.targets, name)
+	}
+	return err
+}
+
+// newTSIH returns a new target session identifying handle, which is never 0.
+func (s *Server) newTSIH() uint16 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.tsih++
+	if s.tsih == 0 {
+		s.tsih++
+	}
+	return s.tsih
+}
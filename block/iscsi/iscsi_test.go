@@ -0,0 +1,290 @@
+package iscsi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/coreos/go-tcmu/scsi"
+)
+
+type memDevice struct {
+	mut    sync.Mutex
+	data   []byte
+	syncs  int
+	trims  [][2]int64
+	closed bool
+}
+
+func newMemDevice(size int) *memDevice { return &memDevice{data: make([]byte, size)} }
+
+func (d *memDevice) ReadAt(b []byte, off int64) (int, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return copy(b, d.data[off:]), nil
+}
+
+func (d *memDevice) WriteAt(b []byte, off int64) (int, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return copy(d.data[off:], b), nil
+}
+
+func (d *memDevice) Trim(off, length int64) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.trims = append(d.trims, [2]int64{off, length})
+	copy(d.data[off:off+length], make([]byte, length))
+	return nil
+}
+
+func (d *memDevice) Sync() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.syncs++
+	return nil
+}
+
+func (d *memDevice) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.closed = true
+	return nil
+}
+
+func (d *memDevice) Size() uint64 { return uint64(len(d.data)) }
+
+type staticFinder map[string][]*LUN
+
+func (f staticFinder) ListTargets() ([]string, error) {
+	var names []string
+	for n := range f {
+		names = append(names, n)
+	}
+	return names, nil
+}
+
+func (f staticFinder) OpenTarget(name string) ([]*LUN, error) {
+	luns, ok := f[name]
+	if !ok {
+		return nil, ErrNoTarget
+	}
+	return luns, nil
+}
+
+func newTestServer(t *testing.T, finder TargetFinder) *Server {
+	s, err := NewServer("127.0.0.1:0", finder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	return s
+}
+
+func login(t *testing.T, s *Server, target string) *initiator {
+	i, err := dial(s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.login(target); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func rw10(op byte, lba uint32, blocks uint16) []byte {
+	cdb := make([]byte, 10)
+	cdb[0] = op
+	binary.BigEndian.PutUint32(cdb[2:6], lba)
+	binary.BigEndian.PutUint16(cdb[7:9], blocks)
+	return cdb
+}
+
+func rw16(op byte, lba uint64, blocks uint32) []byte {
+	cdb := make([]byte, 16)
+	cdb[0] = op
+	binary.BigEndian.PutUint64(cdb[2:10], lba)
+	binary.BigEndian.PutUint32(cdb[10:14], blocks)
+	return cdb
+}
+
+func checkGood(t *testing.T, what string, res result, err error) {
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+	if res.status != statusGood {
+		t.Fatalf("%s: status 0x%x, sense %x", what, res.status, res.sense)
+	}
+}
+
+func checkSense(t *testing.T, what string, res result, err error, key byte, asc uint16) {
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+	if res.status != statusCheckCondition || len(res.sense) < 14 ||
+		res.sense[2] != key || binary.BigEndian.Uint16(res.sense[12:14]) != asc {
+		t.Fatalf("%s: got status 0x%x, sense %x, want key 0x%x asc 0x%04x", what, res.status, res.sense, key, asc)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	s := newTestServer(t, staticFinder{
+		TargetPrefix + "a": nil,
+		TargetPrefix + "b": nil,
+	})
+	defer s.Close()
+	i := login(t, s, "")
+	params, err := i.sendTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, kv := range params {
+		switch kv.key {
+		case "TargetName":
+			found[kv.value] = true
+		case "TargetAddress":
+			if kv.value != s.Addr().String()+",1" {
+				t.Errorf("unexpected target address %q", kv.value)
+			}
+		}
+	}
+	if len(found) != 2 || !found[TargetPrefix+"a"] || !found[TargetPrefix+"b"] {
+		t.Fatalf("unexpected targets %v", params)
+	}
+	if err = i.logout(); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = dial(s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.login(TargetPrefix + "c"); err != loginError(loginNotFound) {
+		t.Fatalf("login to a missing target: got %v", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	dev0 := newMemDevice(4 << 20)
+	dev1 := newMemDevice(1 << 20)
+	target := TargetPrefix + "test"
+	s := newTestServer(t, staticFinder{
+		target: {{Name: "vol0", Device: dev0}, {Name: "vol1", Device: dev1}},
+	})
+	defer s.Close()
+	i := login(t, s, target)
+
+	in, res, err := i.command(0, []byte{scsi.ReportLuns, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}, nil, 256)
+	checkGood(t, "report luns", res, err)
+	if binary.BigEndian.Uint32(in[0:4]) != 16 || in[8+9] != 1 {
+		t.Fatalf("unexpected luns %x", in[:24])
+	}
+	in, res, err = i.command(0, []byte{scsi.Inquiry, 0, 0, 0, 96, 0}, nil, 96)
+	checkGood(t, "inquiry", res, err)
+	if in[0] != 0 || string(in[16:24]) != "TorusBlk" {
+		t.Fatalf("unexpected inquiry data %q", in[:36])
+	}
+	in, res, err = i.command(1, []byte{scsi.Inquiry, 1, 0x80, 0, 96, 0}, nil, 96)
+	checkGood(t, "serial number", res, err)
+	if serial := string(in[4 : 4+in[3]]); serial != (&LUN{Name: "vol1"}).serial() {
+		t.Fatalf("unexpected serial %q", serial)
+	}
+	in, res, err = i.command(1, []byte{scsi.ReadCapacity, 0, 0, 0, 0, 0, 0, 0, 0, 0}, nil, 8)
+	checkGood(t, "read capacity", res, err)
+	if last := binary.BigEndian.Uint32(in[0:4]); last != 1<<20/blockSize-1 {
+		t.Fatalf("unexpected last LBA %d", last)
+	}
+	cdb := make([]byte, 16)
+	cdb[0], cdb[1], cdb[13] = scsi.ServiceActionIn16, scsi.SaiReadCapacity16, 32
+	in, res, err = i.command(0, cdb, nil, 32)
+	checkGood(t, "read capacity 16", res, err)
+	if last := binary.BigEndian.Uint64(in[0:8]); last != 4<<20/blockSize-1 || in[14]&0x80 == 0 {
+		t.Fatalf("unexpected capacity %x", in)
+	}
+
+	// A small write goes as immediate data, a large one is solicited by R2Ts.
+	small := bytes.Repeat([]byte("torus"), 1024)[:8*blockSize]
+	_, res, err = i.command(1, rw10(scsi.Write10, 8, 8), small, 0)
+	checkGood(t, "write 10", res, err)
+	in, res, err = i.command(1, rw10(scsi.Read10, 8, 8), nil, len(small))
+	checkGood(t, "read 10", res, err)
+	if !bytes.Equal(in, small) || !bytes.Equal(dev1.data[8*blockSize:16*blockSize], small) {
+		t.Fatal("read 10 didn't read what was written")
+	}
+	large := make([]byte, 3<<20)
+	for j := range large {
+		large[j] = byte(j * 7)
+	}
+	_, res, err = i.command(0, rw16(scsi.Write16, 100, uint32(len(large)/blockSize)), large, 0)
+	checkGood(t, "write 16", res, err)
+	in, res, err = i.command(0, rw16(scsi.Read16, 100, uint32(len(large)/blockSize)), nil, len(large))
+	checkGood(t, "read 16", res, err)
+	if !bytes.Equal(in, large) || !bytes.Equal(dev0.data[100*blockSize:100*blockSize+len(large)], large) {
+		t.Fatal("read 16 didn't read what was written")
+	}
+
+	_, res, err = i.command(0, []byte{scsi.SynchronizeCache, 0, 0, 0, 0, 0, 0, 0, 0, 0}, nil, 0)
+	checkGood(t, "synchronize cache", res, err)
+	if dev0.syncs != 1 {
+		t.Fatalf("synced %d times", dev0.syncs)
+	}
+
+	unmap := make([]byte, 8+16)
+	binary.BigEndian.PutUint16(unmap[0:2], 6+16)
+	binary.BigEndian.PutUint16(unmap[2:4], 16)
+	binary.BigEndian.PutUint64(unmap[8:16], 100)
+	binary.BigEndian.PutUint32(unmap[16:20], 16)
+	_, res, err = i.command(0, []byte{scsi.Unmap, 0, 0, 0, 0, 0, 0, 0, byte(len(unmap)), 0}, unmap, 0)
+	checkGood(t, "unmap", res, err)
+	if len(dev0.trims) != 1 || dev0.trims[0] != [2]int64{100 * blockSize, 16 * blockSize} {
+		t.Fatalf("unexpected trims %v", dev0.trims)
+	}
+
+	_, res, err = i.command(1, rw10(scsi.Read10, 1<<20/blockSize-1, 2), nil, 2*blockSize)
+	checkSense(t, "read past the end", res, err, senseIllegalRequest, ascLBAOutOfRange)
+	_, res, err = i.command(2, rw10(scsi.Read10, 0, 1), nil, blockSize)
+	checkSense(t, "read of a missing LUN", res, err, senseIllegalRequest, ascLUNNotSupported)
+	_, res, err = i.command(0, []byte{0xc0, 0, 0, 0, 0, 0}, nil, 0)
+	checkSense(t, "unknown command", res, err, senseIllegalRequest, ascInvalidOpcode)
+
+	if err = i.logout(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedTarget(t *testing.T) {
+	dev := newMemDevice(1 << 20)
+	target := TargetPrefix + "test"
+	s := newTestServer(t, staticFinder{target: {{Name: "vol", Device: dev}}})
+	defer s.Close()
+
+	// Two sessions, as a multipathing initiator would open, share the
+	// target; it's closed when the last logs out.
+	a := login(t, s, target)
+	b := login(t, s, target)
+	_, res, err := a.command(0, rw10(scsi.Write10, 0, 1), bytes.Repeat([]byte{1}, blockSize), 0)
+	checkGood(t, "write", res, err)
+	in, res, err := b.command(0, rw10(scsi.Read10, 0, 1), nil, blockSize)
+	checkGood(t, "read", res, err)
+	if in[0] != 1 {
+		t.Fatal("sessions don't share the device")
+	}
+	if err = a.logout(); err != nil {
+		t.Fatal(err)
+	}
+	// Ask b something, so a's logout has surely been handled.
+	if _, res, err = b.command(0, []byte{scsi.TestUnitReady, 0, 0, 0, 0, 0}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.mut.Lock()
+	closed := dev.closed
+	dev.mut.Unlock()
+	if closed {
+		t.Fatal("device closed while a session still uses it")
+	}
+	if err = b.logout(); err != nil {
+		t.Fatal(err)
+	}
+}
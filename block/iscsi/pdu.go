@@ -0,0 +1,175 @@
+package iscsi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// opcodes
+const (
+	opNopOut       = 0x00
+	opSCSICmd      = 0x01
+	opTaskMgmt     = 0x02
+	opLoginReq     = 0x03
+	opTextReq      = 0x04
+	opDataOut      = 0x05
+	opLogoutReq    = 0x06
+	opNopIn        = 0x20
+	opSCSIResp     = 0x21
+	opTaskMgmtResp = 0x22
+	opLoginResp    = 0x23
+	opTextResp     = 0x24
+	opDataIn       = 0x25
+	opLogoutResp   = 0x26
+	opR2T          = 0x31
+	opReject       = 0x3f
+)
+
+// flags of the second byte of the header
+const (
+	flagImmediate = 0x40 // in the first byte
+	flagFinal     = 0x80
+	flagContinue  = 0x40 // login and text
+	flagTransit   = 0x80 // login
+	flagRead      = 0x40 // SCSI command
+	flagWrite     = 0x20 // SCSI command
+	flagAck       = 0x40 // Data-In
+	flagOverflow  = 0x04 // SCSI response and Data-In
+	flagUnderflow = 0x02 // SCSI response and Data-In
+	flagStatus    = 0x01 // Data-In
+)
+
+// reasons of a Reject
+const (
+	rejectCommandNotSupported = 0x05
+	rejectProtocolError       = 0x04
+	rejectInvalidField        = 0x09
+)
+
+const (
+	bhsLen = 48
+
+	// reservedTag is the task tag, or target transfer tag, of no task.
+	reservedTag = 0xffffffff
+)
+
+// A pdu is an iSCSI protocol data unit: its basic header segment and its
+// data segment. Additional header segments are skipped, and digests are
+// never negotiated.
+type pdu struct {
+	bhs  [bhsLen]byte
+	data []byte
+}
+
+func newPDU(op byte) *pdu {
+	p := &pdu{}
+	p.bhs[0] = op
+	return p
+}
+
+func (p *pdu) opcode() byte    { return p.bhs[0] & 0x3f }
+func (p *pdu) immediate() bool { return p.bhs[0]&flagImmediate != 0 }
+func (p *pdu) flags() byte     { return p.bhs[1] }
+func (p *pdu) final() bool     { return p.bhs[1]&flagFinal != 0 }
+
+func (p *pdu) u32(off int) uint32 { return binary.BigEndian.Uint32(p.bhs[off : off+4]) }
+
+func (p *pdu) setU32(off int, v uint32) { binary.BigEndian.PutUint32(p.bhs[off:off+4], v) }
+
+// Fields common to most PDUs.
+func (p *pdu) itt() uint32       { return p.u32(16) }
+func (p *pdu) setITT(v uint32)   { p.setU32(16, v) }
+func (p *pdu) ttt() uint32       { return p.u32(20) }
+func (p *pdu) setTTT(v uint32)   { p.setU32(20, v) }
+func (p *pdu) cmdSN() uint32     { return p.u32(24) }
+func (p *pdu) expStatSN() uint32 { return p.u32(28) }
+
+// lun decodes the LUN field, in the single level peripheral or flat space
+// addressing the targets report.
+func (p *pdu) lun() uint16 {
+	return uint16(p.bhs[8]&0x3f)<<8 | uint16(p.bhs[9])
+}
+
+func (p *pdu) setLUN(lun uint16) {
+	encodeLUN(p.bhs[8:16], lun)
+}
+
+func encodeLUN(b []byte, lun uint16) {
+	if lun < 256 {
+		b[0], b[1] = 0, byte(lun)
+	} else {
+		b[0], b[1] = 0x40|byte(lun>>8), byte(lun)
+	}
+}
+
+func padLen(n int) int { return (4 - n%4) % 4 }
+
+func readPDU(r io.Reader, maxData int) (*pdu, error) {
+	p := &pdu{}
+	if _, err := io.ReadFull(r, p.bhs[:]); err != nil {
+		return nil, err
+	}
+	if ahs := int(p.bhs[4]) * 4; ahs > 0 {
+		if _, err := io.CopyN(ioutil.Discard, r, int64(ahs)); err != nil {
+			return nil, err
+		}
+	}
+	n := int(p.bhs[5])<<16 | int(p.bhs[6])<<8 | int(p.bhs[7])
+	if n > maxData {
+		return nil, fmt.Errorf("iscsi: data segment of %d bytes is longer than %d", n, maxData)
+	}
+	if n > 0 {
+		p.data = make([]byte, n+padLen(n))
+		if _, err := io.ReadFull(r, p.data); err != nil {
+			return nil, err
+		}
+		p.data = p.data[:n]
+	}
+	return p, nil
+}
+
+func (p *pdu) writeTo(w io.Writer) error {
+	n := len(p.data)
+	p.bhs[4] = 0
+	p.bhs[5], p.bhs[6], p.bhs[7] = byte(n>>16), byte(n>>8), byte(n)
+	buf := make([]byte, bhsLen+n+padLen(n))
+	copy(buf, p.bhs[:])
+	copy(buf[bhsLen:], p.data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// A param is a key=value pair of a login or text PDU.
+type param struct {
+	key, value string
+}
+
+func parseParams(data []byte) []param {
+	var params []param
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		if len(kv) == 0 {
+			continue
+		}
+		i := bytes.IndexByte(kv, '=')
+		if i < 0 {
+			params = append(params, param{key: string(kv)})
+			continue
+		}
+		params = append(params, param{string(kv[:i]), string(kv[i+1:])})
+	}
+	return params
+}
+
+func encodeParams(params []param) []byte {
+	var buf bytes.Buffer
+	for _, kv := range params {
+		buf.WriteString(kv.key)
+		buf.WriteByte('=')
+		buf.WriteString(kv.value)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
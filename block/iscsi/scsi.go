@@ -0,0 +1,387 @@
+package iscsi
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+
+	"github.com/coreos/go-tcmu/scsi"
+)
+
+const (
+	// blockSize is the logical block size of every LUN, which all
+	// initiators can use, and physBlockSize the size they should align to.
+	blockSize     = 512
+	physBlockSize = 4096
+
+	// maxTransfer bounds the data of a command.
+	maxTransfer = 8 * 1024 * 1024
+
+	// maxUnmapBlocks and maxUnmapDescriptors bound an UNMAP.
+	maxUnmapBlocks      = 1 << 21
+	maxUnmapDescriptors = 64
+)
+
+// status codes
+const (
+	statusGood           = 0x00
+	statusCheckCondition = 0x02
+)
+
+// sense keys
+const (
+	senseNoSense        = 0x0
+	senseMediumError    = 0x3
+	senseIllegalRequest = 0x5
+)
+
+// additional sense codes and qualifiers
+const (
+	ascWriteError             = 0x0c00
+	ascReadError              = 0x1100
+	ascParamListLength        = 0x1a00
+	ascInvalidOpcode          = 0x2000
+	ascLBAOutOfRange          = 0x2100
+	ascInvalidFieldInCDB      = 0x2400
+	ascLUNNotSupported        = 0x2500
+	ascInvalidFieldInParamLst = 0x2600
+)
+
+// result is the status of a SCSI command, and its sense data.
+type result struct {
+	status byte
+	sense  []byte
+}
+
+var good = result{status: statusGood}
+
+func checkCondition(key byte, asc uint16) result {
+	sense := make([]byte, 18)
+	sense[0] = 0x70 // current, fixed format
+	sense[2] = key
+	sense[7] = 10
+	sense[12] = byte(asc >> 8)
+	sense[13] = byte(asc)
+	return result{status: statusCheckCondition, sense: sense}
+}
+
+// allocate truncates the data of a reply to the allocation length of its
+// command.
+func allocate(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[:n]
+	}
+	return data
+}
+
+// command runs a SCSI command on a LUN, which is nil if it doesn't exist,
+// with the data the initiator sent, returning the data to send back.
+func (c *conn) command(lun *LUN, cdb []byte, out []byte) ([]byte, result) {
+	switch cdb[0] {
+	case scsi.ReportLuns:
+		return c.reportLUNs(cdb)
+	case scsi.RequestSense:
+		sense := make([]byte, 18)
+		sense[0] = 0x70
+		sense[2] = senseNoSense
+		sense[7] = 10
+		return allocate(sense, int(cdb[4])), good
+	case scsi.Inquiry:
+		if lun == nil {
+			// peripheral qualifier 3: no LUN here
+			data := make([]byte, 36)
+			data[0] = 0x7f
+			return allocate(data, int(binary.BigEndian.Uint16(cdb[3:5]))), good
+		}
+		return lun.inquiry(cdb)
+	}
+	if lun == nil {
+		return nil, checkCondition(senseIllegalRequest, ascLUNNotSupported)
+	}
+	return lun.execute(cdb, out)
+}
+
+func (c *conn) reportLUNs(cdb []byte) ([]byte, result) {
+	data := make([]byte, 8+8*len(c.luns))
+	binary.BigEndian.PutUint32(data[0:4], uint32(8*len(c.luns)))
+	for i := range c.luns {
+		encodeLUN(data[8+8*i:], uint16(i))
+	}
+	return allocate(data, int(binary.BigEndian.Uint32(cdb[6:10]))), good
+}
+
+func (l *LUN) execute(cdb []byte, out []byte) ([]byte, result) {
+	switch cdb[0] {
+	case scsi.TestUnitReady, scsi.StartStop, scsi.AllowMediumRemoval, scsi.ModeSelect, scsi.ModeSelect10:
+		return nil, good
+	case scsi.ReadCapacity:
+		return l.readCapacity10()
+	case scsi.ServiceActionIn16:
+		if cdb[1]&0x1f == scsi.SaiReadCapacity16 {
+			return l.readCapacity16(cdb)
+		}
+	case scsi.ModeSense, scsi.ModeSense10:
+		return l.modeSense(cdb)
+	case scsi.Read6, scsi.Read10, scsi.Read12, scsi.Read16:
+		return l.read(cdb)
+	case scsi.Write6, scsi.Write10, scsi.Write12, scsi.Write16:
+		return nil, l.write(cdb, out)
+	case scsi.SynchronizeCache, scsi.SynchronizeCache16:
+		if err := l.Device.Sync(); err != nil {
+			clog.Errorf("%s: sync failed: %v", l.Name, err)
+			return nil, checkCondition(senseMediumError, ascWriteError)
+		}
+		return nil, good
+	case scsi.Unmap:
+		return nil, l.unmap(out)
+	}
+	clog.Debugf("%s: unsupported SCSI command 0x%x", l.Name, cdb[0])
+	return nil, checkCondition(senseIllegalRequest, ascInvalidOpcode)
+}
+
+func (l *LUN) blocks() uint64 { return l.Device.Size() / blockSize }
+
+// id is the unique number the LUN is identified by.
+func (l *LUN) id() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(l.Name))
+	return h.Sum64()
+}
+
+func (l *LUN) inquiry(cdb []byte) ([]byte, result) {
+	alloc := int(binary.BigEndian.Uint16(cdb[3:5]))
+	if cdb[1]&0x01 == 0 {
+		if cdb[2] != 0 {
+			return nil, checkCondition(senseIllegalRequest, ascInvalidFieldInCDB)
+		}
+		data := make([]byte, 36)
+		data[2] = 0x06 // SPC-4
+		data[3] = 0x02 // response data format
+		data[4] = byte(len(data) - 5)
+		data[7] = 0x02 // command queuing
+		copy(data[8:16], "CoreOS  ")
+		copy(data[16:32], "TorusBlk        ")
+		copy(data[32:36], "0001")
+		return allocate(data, alloc), good
+	}
+
+	var page []byte
+	switch cdb[2] {
+	case 0x00: // supported pages
+		page = []byte{0x00, 0x80, 0x83, 0xb0, 0xb2}
+	case 0x80: // unit serial number
+		page = []byte(l.serial())
+	case 0x83: // device identification
+		page = l.identification()
+	case 0xb0: // block limits
+		page = make([]byte, 0x3c)
+		binary.BigEndian.PutUint32(page[4:8], maxTransfer/blockSize)
+		binary.BigEndian.PutUint32(page[16:20], maxUnmapBlocks)
+		binary.BigEndian.PutUint32(page[20:24], maxUnmapDescriptors)
+		if bs, ok := l.Device.(BlockSizer); ok {
+			binary.BigEndian.PutUint32(page[24:28], bs.BlockSize()/blockSize)
+		}
+	case 0xb2: // logical block provisioning
+		page = make([]byte, 4)
+		page[1] = 0x80 // UNMAP
+		page[2] = 0x02 // thin provisioned
+	default:
+		return nil, checkCondition(senseIllegalRequest, ascInvalidFieldInCDB)
+	}
+	data := make([]byte, 4+len(page))
+	data[1] = cdb[2]
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(page)))
+	copy(data[4:], page)
+	return allocate(data, alloc), good
+}
+
+func (l *LUN) serial() string {
+	const hex = "0123456789abcdef"
+	id := l.id()
+	s := make([]byte, 16)
+	for i := range s {
+		s[15-i] = hex[id&0xf]
+		id >>= 4
+	}
+	return string(s)
+}
+
+// identification returns the designators of the device identification
+// page: a locally assigned NAA, and the name of the LUN.
+func (l *LUN) identification() []byte {
+	naa := make([]byte, 4+8)
+	naa[0] = 0x01 // binary
+	naa[1] = 0x03 // NAA, of the LUN
+	naa[3] = 8
+	binary.BigEndian.PutUint64(naa[4:], 3<<60|l.id()&(1<<60-1))
+
+	name := l.Name
+	// The designator length is a byte, with room for the vendor.
+	if len(name) > 240 {
+		name = name[:240]
+	}
+	vendor := []byte("CoreOS  torus:" + name)
+	t10 := make([]byte, 4+len(vendor))
+	t10[0] = 0x02 // ASCII
+	t10[1] = 0x01 // T10 vendor ID, of the LUN
+	t10[3] = byte(len(vendor))
+	copy(t10[4:], vendor)
+	return append(naa, t10...)
+}
+
+func (l *LUN) readCapacity10() ([]byte, result) {
+	last := l.blocks() - 1
+	if last > 0xffffffff {
+		last = 0xffffffff
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:4], uint32(last))
+	binary.BigEndian.PutUint32(data[4:8], blockSize)
+	return data, good
+}
+
+func (l *LUN) readCapacity16(cdb []byte) ([]byte, result) {
+	data := make([]byte, 32)
+	binary.BigEndian.PutUint64(data[0:8], l.blocks()-1)
+	binary.BigEndian.PutUint32(data[8:12], blockSize)
+	data[13] = 3    // log2(physBlockSize / blockSize)
+	data[14] = 0x80 // logical block provisioning management enabled
+	return allocate(data, int(binary.BigEndian.Uint32(cdb[10:14]))), good
+}
+
+func (l *LUN) modeSense(cdb []byte) ([]byte, result) {
+	var pages []byte
+	caching := make([]byte, 20)
+	caching[0], caching[1] = 0x08, 0x12
+	control := make([]byte, 12)
+	control[0], control[1] = 0x0a, 0x0a
+	changeable := cdb[2]>>6 == 1
+	if !changeable {
+		caching[2] = 0x04 // write cache enabled
+	}
+	switch cdb[2] & 0x3f {
+	case 0x08:
+		pages = caching
+	case 0x0a:
+		pages = control
+	case 0x3f:
+		pages = append(caching, control...)
+	default:
+		return nil, checkCondition(senseIllegalRequest, ascInvalidFieldInCDB)
+	}
+	if cdb[0] == scsi.ModeSense {
+		data := append(make([]byte, 4), pages...)
+		data[0] = byte(len(data) - 1)
+		return allocate(data, int(cdb[4])), good
+	}
+	data := append(make([]byte, 8), pages...)
+	binary.BigEndian.PutUint16(data[0:2], uint16(len(data)-2))
+	return allocate(data, int(binary.BigEndian.Uint16(cdb[7:9]))), good
+}
+
+// extent decodes the LBA, number of blocks and FUA bit of a read or write.
+func extent(cdb []byte) (lba uint64, n uint32, fua bool) {
+	switch cdb[0] {
+	case scsi.Read6, scsi.Write6:
+		lba = uint64(cdb[1]&0x1f)<<16 | uint64(cdb[2])<<8 | uint64(cdb[3])
+		n = uint32(cdb[4])
+		if n == 0 {
+			n = 256
+		}
+		return lba, n, false
+	case scsi.Read10, scsi.Write10:
+		lba = uint64(binary.BigEndian.Uint32(cdb[2:6]))
+		n = uint32(binary.BigEndian.Uint16(cdb[7:9]))
+	case scsi.Read12, scsi.Write12:
+		lba = uint64(binary.BigEndian.Uint32(cdb[2:6]))
+		n = binary.BigEndian.Uint32(cdb[6:10])
+	case scsi.Read16, scsi.Write16:
+		lba = binary.BigEndian.Uint64(cdb[2:10])
+		n = binary.BigEndian.Uint32(cdb[10:14])
+	}
+	return lba, n, cdb[1]&0x08 != 0
+}
+
+// checkRange checks that n blocks from lba lie within the LUN.
+func (l *LUN) checkRange(lba uint64, n uint64) (result, bool) {
+	if lba+n < lba || lba+n > l.blocks() {
+		return checkCondition(senseIllegalRequest, ascLBAOutOfRange), false
+	}
+	return good, true
+}
+
+func (l *LUN) read(cdb []byte) ([]byte, result) {
+	lba, n, _ := extent(cdb)
+	if res, ok := l.checkRange(lba, uint64(n)); !ok {
+		return nil, res
+	}
+	if n > maxTransfer/blockSize {
+		return nil, checkCondition(senseIllegalRequest, ascInvalidFieldInCDB)
+	}
+	data := make([]byte, int(n)*blockSize)
+	if _, err := l.Device.ReadAt(data, int64(lba*blockSize)); err != nil && err != io.EOF {
+		clog.Errorf("%s: read failed: %v", l.Name, err)
+		return nil, checkCondition(senseMediumError, ascReadError)
+	}
+	return data, good
+}
+
+func (l *LUN) write(cdb []byte, out []byte) result {
+	lba, n, fua := extent(cdb)
+	if res, ok := l.checkRange(lba, uint64(n)); !ok {
+		return res
+	}
+	length := int(n) * blockSize
+	if len(out) < length {
+		return checkCondition(senseIllegalRequest, ascInvalidFieldInCDB)
+	}
+	if _, err := l.Device.WriteAt(out[:length], int64(lba*blockSize)); err != nil {
+		clog.Errorf("%s: write failed: %v", l.Name, err)
+		return checkCondition(senseMediumError, ascWriteError)
+	}
+	if fua {
+		if err := l.Device.Sync(); err != nil {
+			clog.Errorf("%s: sync failed: %v", l.Name, err)
+			return checkCondition(senseMediumError, ascWriteError)
+		}
+	}
+	return good
+}
+
+// unmap trims the extents of the block descriptors of an UNMAP.
+func (l *LUN) unmap(out []byte) result {
+	if len(out) == 0 {
+		return good
+	}
+	if len(out) < 8 {
+		return checkCondition(senseIllegalRequest, ascParamListLength)
+	}
+	descs := out[8:]
+	if n := int(binary.BigEndian.Uint16(out[2:4])); n < len(descs) {
+		descs = descs[:n]
+	}
+	if len(descs)/16 > maxUnmapDescriptors {
+		return checkCondition(senseIllegalRequest, ascInvalidFieldInParamLst)
+	}
+	var total uint64
+	for d := descs; len(d) >= 16; d = d[16:] {
+		lba := binary.BigEndian.Uint64(d[0:8])
+		n := uint64(binary.BigEndian.Uint32(d[8:12]))
+		if res, ok := l.checkRange(lba, n); !ok {
+			return res
+		}
+		total += n
+	}
+	if total > maxUnmapBlocks {
+		return checkCondition(senseIllegalRequest, ascInvalidFieldInParamLst)
+	}
+	for d := descs; len(d) >= 16; d = d[16:] {
+		lba := binary.BigEndian.Uint64(d[0:8])
+		n := uint64(binary.BigEndian.Uint32(d[8:12]))
+		if err := l.Device.Trim(int64(lba*blockSize), int64(n*blockSize)); err != nil {
+			clog.Errorf("%s: unmap failed: %v", l.Name, err)
+			return checkCondition(senseMediumError, ascWriteError)
+		}
+	}
+	return good
+}
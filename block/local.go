@@ -0,0 +1,813 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata/local"
+	"github.com/coreos/torus/models"
+)
+
+type blockLocal struct {
+	*local.Client
+	name string
+	vid  torus.VolumeID
+}
+
+func (b *blockLocal) volumeMetaKey(s ...string) string {
+	return local.MkKey(append([]string{"volumemeta", local.Uint64ToHex(uint64(b.vid))}, s...)...)
+}
+
+func (b *blockLocal) CreateBlockVolume(volume *models.Volume, inode torus.INodeRef, replicaOf string) error {
+	vbytes, err := volume.Marshal()
+	if err != nil {
+		return err
+	}
+	inodeBytes := inode.ToBytes()
+	vid := local.Uint64ToHex(volume.Id)
+
+	ops := []local.Op{
+		local.OpPutKey(local.MkKey("volumes", volume.Name), local.Uint64ToBytes(volume.Id)),
+		local.OpPutKey(local.MkKey("volumeid", vid), vbytes),
+		local.OpPutKey(local.MkKey("volumemeta", vid, "inode"), local.Uint64ToBytes(1)),
+		local.OpPutKey(local.MkKey("volumemeta", vid, "blockinode"), inodeBytes),
+	}
+	if replicaOf != "" {
+		ops = append(ops, local.OpPutKey(local.MkKey("volumemeta", vid, "replica"), []byte(replicaOf)))
+	}
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(local.MkKey("volumes", volume.Name), "=", 0),
+		},
+		Then: ops,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrExists
+	}
+	return nil
+}
+
+func (b *blockLocal) DeleteVolume() error {
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(b.volumeMetaKey("blocklock"), "=", 0),
+		},
+		Then: []local.Op{
+			local.OpDeleteKey(local.MkKey("volumes", b.name)),
+			local.OpDeleteKey(local.MkKey("volumeid", local.Uint64ToHex(uint64(b.vid)))),
+			local.OpDeletePrefix(b.volumeMetaKey() + "/"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockLocal) Lock(lease int64) (uint64, error) {
+	if lease == 0 {
+		return 0, torus.ErrInvalid
+	}
+	k := b.volumeMetaKey("blocklock")
+	fk := b.volumeMetaKey("fence")
+	for {
+		resp, err := b.Txn(&local.Txn{
+			Then: []local.Op{local.OpGetKey(fk)},
+		})
+		if err != nil {
+			return 0, err
+		}
+		var (
+			fence   uint64
+			version int64
+		)
+		if kvs := resp.Responses[0]; len(kvs) == 1 {
+			fence = local.BytesToUint64(kvs[0].Value)
+			version = kvs[0].Version
+		}
+		fence++
+		resp, err = b.Txn(&local.Txn{
+			If: []local.Compare{
+				local.CmpVersion(k, "=", 0),
+				local.CmpVersion(fk, "=", version),
+			},
+			Then: []local.Op{
+				local.OpPutLease(k, []byte(b.UUID()), lease),
+				local.OpPutKey(fk, local.Uint64ToBytes(fence)),
+			},
+			Else: []local.Op{local.OpGetKey(k)},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if resp.Succeeded {
+			return fence, nil
+		}
+		if len(resp.Responses[0]) != 0 {
+			return 0, torus.ErrLocked
+		}
+		// Someone else took and released the lock in between; try again.
+	}
+}
+
+func (b *blockLocal) GetINode() (torus.INodeRef, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("blockinode"))},
+	})
+	if err != nil {
+		return torus.NewINodeRef(0, 0), err
+	}
+	if len(resp.Responses[0]) != 1 {
+		return torus.NewINodeRef(0, 0), errors.New("unexpected metadata for volume")
+	}
+	return torus.INodeRefFromBytes(resp.Responses[0][0].Value), nil
+}
+
+func (b *blockLocal) holdsLock() []local.Compare {
+	k := b.volumeMetaKey("blocklock")
+	return []local.Compare{
+		local.CmpVersion(k, ">", 0),
+		local.CmpValue(k, "=", []byte(b.UUID())),
+	}
+}
+
+func (b *blockLocal) SyncINode(inode torus.INodeRef) error {
+	resp, err := b.Txn(&local.Txn{
+		If:   b.holdsLock(),
+		Then: []local.Op{local.OpPutKey(b.volumeMetaKey("blockinode"), inode.ToBytes())},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockLocal) Unlock() error {
+	resp, err := b.Txn(&local.Txn{
+		If:   b.holdsLock(),
+		Then: []local.Op{local.OpDeleteKey(b.volumeMetaKey("blocklock"))},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockLocal) GetLockHolder() (*LockHolder, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{
+			local.OpGetKey(b.volumeMetaKey("blocklock")),
+			local.OpGetKey(b.volumeMetaKey("fence")),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, nil
+	}
+	kv := resp.Responses[0][0]
+	h := &LockHolder{
+		UUID:  string(kv.Value),
+		Lease: kv.Lease,
+		TTL:   -1,
+	}
+	if len(resp.Responses[1]) == 1 {
+		h.Fence = local.BytesToUint64(resp.Responses[1][0].Value)
+	}
+	if ttl, err := b.Backend().TimeToLive(kv.Lease); err == nil {
+		h.TTL = ttl
+	}
+	return h, nil
+}
+
+func (b *blockLocal) GetFence() (uint64, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("fence"))},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return 0, nil
+	}
+	return local.BytesToUint64(resp.Responses[0][0].Value), nil
+}
+
+func (b *blockLocal) WatchFence() (<-chan struct{}, func(), error) {
+	return b.watchMeta("fence")
+}
+
+func (b *blockLocal) ForceUnlock(h *LockHolder, by string) error {
+	k := b.volumeMetaKey("blocklock")
+	rec := LockRecord{
+		When:   time.Now(),
+		By:     by,
+		Holder: *h,
+	}
+	bytes, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(k, ">", 0),
+			local.CmpValue(k, "=", []byte(h.UUID)),
+		},
+		Then: []local.Op{
+			local.OpDeleteKey(k),
+			local.OpPutKey(b.volumeMetaKey("lockaudit", lockRecordName(rec)), bytes),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrAgain
+	}
+	err = b.Backend().Revoke(h.Lease)
+	if err == torus.ErrLeaseNotFound {
+		return nil
+	}
+	return err
+}
+
+func (b *blockLocal) GetForcedUnlocks() ([]LockRecord, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetPrefix(b.volumeMetaKey("lockaudit"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LockRecord, len(resp.Responses[0]))
+	for i, kv := range resp.Responses[0] {
+		if err := json.Unmarshal(kv.Value, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (b *blockLocal) SaveSnapshot(name string) error {
+	sshotKey := b.volumeMetaKey("snapshots", name)
+	inoKey := b.volumeMetaKey("blockinode")
+	for {
+		resp, err := b.Txn(&local.Txn{
+			If:   []local.Compare{local.CmpVersion(sshotKey, "=", 0)},
+			Then: []local.Op{local.OpGetKey(inoKey)},
+		})
+		if err != nil {
+			return err
+		}
+		if !resp.Succeeded {
+			return torus.ErrExists
+		}
+		v := resp.Responses[0][0]
+		bytes, err := json.Marshal(Snapshot{
+			Name:     name,
+			When:     time.Now(),
+			INodeRef: v.Value,
+		})
+		if err != nil {
+			return err
+		}
+		resp, err = b.Txn(&local.Txn{
+			If:   []local.Compare{local.CmpVersion(inoKey, "=", v.Version)},
+			Then: []local.Op{local.OpPutKey(sshotKey, bytes)},
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (b *blockLocal) GetSnapshots() ([]Snapshot, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetPrefix(b.volumeMetaKey("snapshots"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Snapshot, len(resp.Responses[0]))
+	for i, kv := range resp.Responses[0] {
+		if err := json.Unmarshal(kv.Value, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (b *blockLocal) DeleteSnapshot(name string) error {
+	k := b.volumeMetaKey("snapshots", name)
+	resp, err := b.Txn(&local.Txn{
+		If:   []local.Compare{local.CmpVersion(k, ">", 0)},
+		Then: []local.Op{local.OpDeleteKey(k)},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockLocal) GetReplicaOf() (string, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("replica"))},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return "", nil
+	}
+	return string(resp.Responses[0][0].Value), nil
+}
+
+func (b *blockLocal) SetReplicaOf(source string) error {
+	op := local.OpPutKey(b.volumeMetaKey("replica"), []byte(source))
+	if source == "" {
+		op = local.OpDeleteKey(b.volumeMetaKey("replica"))
+	}
+	_, err := b.Txn(&local.Txn{Then: []local.Op{op}})
+	return err
+}
+
+func (b *blockLocal) GetSnapshotPolicy() (SnapshotPolicy, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("snappolicy"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, nil
+	}
+	var p SnapshotPolicy
+	err = json.Unmarshal(resp.Responses[0][0].Value, &p)
+	return p, err
+}
+
+func (b *blockLocal) SetSnapshotPolicy(p SnapshotPolicy) error {
+	op := local.OpDeleteKey(b.volumeMetaKey("snappolicy"))
+	if len(p) != 0 {
+		bytes, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		op = local.OpPutKey(b.volumeMetaKey("snappolicy"), bytes)
+	}
+	_, err := b.Txn(&local.Txn{Then: []local.Op{op}})
+	return err
+}
+
+func (b *blockLocal) ClaimScheduler(lease int64) (bool, error) {
+	if lease == 0 {
+		return false, torus.ErrInvalid
+	}
+	k := b.volumeMetaKey("scheduler")
+	resp, err := b.Txn(&local.Txn{
+		If:   []local.Compare{local.CmpVersion(k, "=", 0)},
+		Then: []local.Op{local.OpPutLease(k, []byte(b.UUID()), lease)},
+		Else: []local.Op{local.OpGetKey(k)},
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.Succeeded {
+		return true, nil
+	}
+	kvs := resp.Responses[0]
+	return len(kvs) == 1 && string(kvs[0].Value) == b.UUID(), nil
+}
+
+func (b *blockLocal) SetVolumeSize(size uint64) error {
+	k := local.MkKey("volumeid", local.Uint64ToHex(uint64(b.vid)))
+	for {
+		resp, err := b.Txn(&local.Txn{
+			Then: []local.Op{local.OpGetKey(k)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Responses[0]) == 0 {
+			return torus.ErrNotExist
+		}
+		kv := resp.Responses[0][0]
+		vol := &models.Volume{}
+		if err = vol.Unmarshal(kv.Value); err != nil {
+			return err
+		}
+		vol.MaxBytes = size
+		vbytes, err := vol.Marshal()
+		if err != nil {
+			return err
+		}
+		resp, err = b.Txn(&local.Txn{
+			If:   []local.Compare{local.CmpVersion(k, "=", kv.Version)},
+			Then: []local.Op{local.OpPutKey(k, vbytes)},
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (b *blockLocal) GetResizeRequest() (uint64, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("resize"))},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return 0, nil
+	}
+	return local.BytesToUint64(resp.Responses[0][0].Value), nil
+}
+
+func (b *blockLocal) SetResizeRequest(size uint64) error {
+	op := local.OpPutKey(b.volumeMetaKey("resize"), local.Uint64ToBytes(size))
+	if size == 0 {
+		op = local.OpDeleteKey(b.volumeMetaKey("resize"))
+	}
+	_, err := b.Txn(&local.Txn{Then: []local.Op{op}})
+	return err
+}
+
+func (b *blockLocal) ClearResizeRequest(done uint64) error {
+	_, err := b.Txn(&local.Txn{
+		If:   []local.Compare{local.CmpValue(b.volumeMetaKey("resize"), "=", local.Uint64ToBytes(done))},
+		Then: []local.Op{local.OpDeleteKey(b.volumeMetaKey("resize"))},
+	})
+	return err
+}
+
+func (b *blockLocal) GetUsage() (*Usage, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("usage"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, nil
+	}
+	u := &Usage{}
+	err = json.Unmarshal(resp.Responses[0][0].Value, u)
+	return u, err
+}
+
+func (b *blockLocal) SetUsage(u *Usage) error {
+	bytes, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	_, err = b.Txn(&local.Txn{
+		Then: []local.Op{local.OpPutKey(b.volumeMetaKey("usage"), bytes)},
+	})
+	return err
+}
+
+func (b *blockLocal) GetQuota() (uint64, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("quota"))},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return 0, nil
+	}
+	return local.BytesToUint64(resp.Responses[0][0].Value), nil
+}
+
+func (b *blockLocal) SetQuota(quota uint64) error {
+	op := local.OpPutKey(b.volumeMetaKey("quota"), local.Uint64ToBytes(quota))
+	if quota == 0 {
+		op = local.OpDeleteKey(b.volumeMetaKey("quota"))
+	}
+	_, err := b.Txn(&local.Txn{Then: []local.Op{op}})
+	return err
+}
+
+func (b *blockLocal) GetQoS() (*QoS, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("qos"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, nil
+	}
+	q := &QoS{}
+	err = json.Unmarshal(resp.Responses[0][0].Value, q)
+	return q, err
+}
+
+func (b *blockLocal) SetQoS(q *QoS) error {
+	op := local.OpDeleteKey(b.volumeMetaKey("qos"))
+	if q != nil {
+		bytes, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		op = local.OpPutKey(b.volumeMetaKey("qos"), bytes)
+	}
+	_, err := b.Txn(&local.Txn{Then: []local.Op{op}})
+	return err
+}
+
+func (b *blockLocal) GetReservations() ([]byte, int64, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("reservations"))},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, 0, nil
+	}
+	return resp.Responses[0][0].Value, resp.Responses[0][0].Version, nil
+}
+
+func (b *blockLocal) SetReservations(state []byte, version int64) error {
+	k := b.volumeMetaKey("reservations")
+	resp, err := b.Txn(&local.Txn{
+		If:   []local.Compare{local.CmpVersion(k, "=", version)},
+		Then: []local.Op{local.OpPutKey(k, state)},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrAgain
+	}
+	return nil
+}
+
+func (b *blockLocal) WatchReservations() (<-chan struct{}, func(), error) {
+	return b.watchMeta("reservations")
+}
+
+// watchMeta watches the volume's metadata key name.
+func (b *blockLocal) watchMeta(name string) (<-chan struct{}, func(), error) {
+	changes := make(chan struct{}, 1)
+	cancel := b.WatchKey(b.volumeMetaKey(name), func(*local.KeyValue) {
+		notify(changes)
+	})
+	var once sync.Once
+	return changes, func() {
+		once.Do(func() {
+			cancel()
+			close(changes)
+		})
+	}, nil
+}
+
+func (b *blockLocal) GetExportState(protocol string) ([]byte, error) {
+	resp, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetKey(b.volumeMetaKey("export", protocol))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, nil
+	}
+	return resp.Responses[0][0].Value, nil
+}
+
+func (b *blockLocal) SetExportState(protocol string, state []byte) error {
+	_, err := b.Txn(&local.Txn{
+		Then: []local.Op{local.OpPutKey(b.volumeMetaKey("export", protocol), state)},
+	})
+	return err
+}
+
+func (b *blockLocal) TrashVolume(t *TrashedVolume) error {
+	vid := local.Uint64ToHex(uint64(b.vid))
+	bytes, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(b.volumeMetaKey("blocklock"), "=", 0),
+		},
+		Then: []local.Op{
+			local.OpDeleteKey(local.MkKey("volumes", b.name)),
+			local.OpDeleteKey(local.MkKey("volumeid", vid)),
+			local.OpPutKey(local.MkKey("trash", vid), bytes),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrLocked
+	}
+	return nil
+}
+
+func (b *blockLocal) UndeleteVolume(vol *models.Volume) error {
+	vid := local.Uint64ToHex(uint64(b.vid))
+	vbytes, err := vol.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(local.MkKey("volumes", vol.Name), "=", 0),
+			local.CmpVersion(local.MkKey("trash", vid), ">", 0),
+		},
+		Then: []local.Op{
+			local.OpPutKey(local.MkKey("volumes", vol.Name), local.Uint64ToBytes(vol.Id)),
+			local.OpPutKey(local.MkKey("volumeid", vid), vbytes),
+			local.OpDeleteKey(local.MkKey("trash", vid)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrExists
+	}
+	return nil
+}
+
+func (b *blockLocal) PurgeVolume() error {
+	vid := local.Uint64ToHex(uint64(b.vid))
+	resp, err := b.Txn(&local.Txn{
+		If: []local.Compare{
+			local.CmpVersion(local.MkKey("trash", vid), ">", 0),
+		},
+		Then: []local.Op{
+			local.OpDeleteKey(local.MkKey("trash", vid)),
+			local.OpDeletePrefix(b.volumeMetaKey() + "/"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrNotExist
+	}
+	return nil
+}
+
+func listLocalTrash(mds torus.MetadataService) ([]TrashedVolume, error) {
+	c, ok := mds.(*local.Client)
+	if !ok {
+		panic("how are we listing local or raft metadata that doesn't implement it but reports as being one")
+	}
+	resp, err := c.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetPrefix(local.MkKey("trash") + "/")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []TrashedVolume
+	for _, kv := range resp.Responses[0] {
+		var t TrashedVolume
+		if err = json.Unmarshal(kv.Value, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func saveLocalSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	c := mds.(*local.Client)
+	groupKey := local.MkKey("snapgroups", g.Name)
+	gbytes, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	// The group and its snapshots must not exist yet.
+	absent := []local.Compare{local.CmpVersion(groupKey, "=", 0)}
+	existing := []local.Op{local.OpGetKey(groupKey)}
+	var gets []local.Op
+	for _, m := range g.Volumes {
+		vid := local.Uint64ToHex(uint64(m.ID))
+		k := local.MkKey("volumemeta", vid, "snapshots", g.Name)
+		absent = append(absent, local.CmpVersion(k, "=", 0))
+		existing = append(existing, local.OpGetKey(k))
+		gets = append(gets, local.OpGetKey(local.MkKey("volumemeta", vid, "blockinode")))
+	}
+	for {
+		resp, err := c.Txn(&local.Txn{If: absent, Then: gets})
+		if err != nil {
+			return err
+		}
+		if !resp.Succeeded {
+			return torus.ErrExists
+		}
+		// Put every snapshot only if they're all still absent, and no
+		// volume has synced since.
+		cmps := append([]local.Compare(nil), absent...)
+		puts := []local.Op{local.OpPutKey(groupKey, gbytes)}
+		for i, m := range g.Volumes {
+			vid := local.Uint64ToHex(uint64(m.ID))
+			if len(resp.Responses[i]) == 0 {
+				return torus.ErrNotExist
+			}
+			v := resp.Responses[i][0]
+			bytes, err := json.Marshal(Snapshot{
+				Name:     g.Name,
+				When:     g.When,
+				INodeRef: v.Value,
+			})
+			if err != nil {
+				return err
+			}
+			cmps = append(cmps, local.CmpVersion(local.MkKey("volumemeta", vid, "blockinode"), "=", v.Version))
+			puts = append(puts, local.OpPutKey(local.MkKey("volumemeta", vid, "snapshots", g.Name), bytes))
+		}
+		resp, err = c.Txn(&local.Txn{If: cmps, Then: puts, Else: existing})
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		for _, kvs := range resp.Responses {
+			if len(kvs) != 0 {
+				return torus.ErrExists
+			}
+		}
+		// A volume synced in between; snapshot its new contents.
+	}
+}
+
+func listLocalSnapshotGroups(mds torus.MetadataService) ([]SnapshotGroup, error) {
+	c := mds.(*local.Client)
+	resp, err := c.Txn(&local.Txn{
+		Then: []local.Op{local.OpGetPrefix(local.MkKey("snapgroups") + "/")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SnapshotGroup, len(resp.Responses[0]))
+	for i, kv := range resp.Responses[0] {
+		if err = json.Unmarshal(kv.Value, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func deleteLocalSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	c := mds.(*local.Client)
+	groupKey := local.MkKey("snapgroups", g.Name)
+	dels := []local.Op{local.OpDeleteKey(groupKey)}
+	for _, m := range g.Volumes {
+		dels = append(dels, local.OpDeleteKey(local.MkKey("volumemeta", local.Uint64ToHex(uint64(m.ID)), "snapshots", g.Name)))
+	}
+	resp, err := c.Txn(&local.Txn{
+		If:   []local.Compare{local.CmpVersion(groupKey, ">", 0)},
+		Then: dels,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrNotExist
+	}
+	return nil
+}
+
+func createBlockLocalMetadata(mds torus.MetadataService, name string, vid torus.VolumeID) (blockMetadata, error) {
+	if c, ok := mds.(*local.Client); ok {
+		return &blockLocal{
+			Client: c,
+			name:   name,
+			vid:    vid,
+		}, nil
+	}
+	panic("how are we creating a local or raft metadata that doesn't implement it but reports as being one")
+}
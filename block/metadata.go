@@ -2,6 +2,7 @@ package block
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/coreos/pkg/capnslog"
@@ -17,21 +18,157 @@ type Snapshot struct {
 	INodeRef []byte
 }
 
+// LockHolder describes who holds the lock on a block volume.
+type LockHolder struct {
+	// UUID is the UUID of the holding server, and Address its peer address,
+	// if it's still registered.
+	UUID    string
+	Address string
+	Lease   int64
+	// TTL is the time left on the lease, or negative if the metadata
+	// service can't tell without renewing it.
+	TTL   time.Duration
+	Fence uint64
+
+	// rev identifies this hold of the lock, for the metadata services that
+	// can tell two holds by the same server apart.
+	rev int64
+}
+
+// LockRecord is the audit record of a forced unlock.
+type LockRecord struct {
+	When   time.Time
+	By     string
+	Holder LockHolder
+}
+
+// lockRecordName is the key a LockRecord is stored under; records sort by
+// time.
+func lockRecordName(rec LockRecord) string {
+	return fmt.Sprintf("%020d", rec.When.UnixNano())
+}
+
 type blockMetadata interface {
 	torus.MetadataService
 
-	Lock(lease int64) error
+	// Lock takes the volume's lock, bound to lease, and returns a fencing
+	// token greater than any returned by previous Locks of the volume.
+	Lock(lease int64) (uint64, error)
 	Unlock() error
 
+	// GetLockHolder returns the current holder of the lock, or nil if the
+	// volume is unlocked.
+	GetLockHolder() (*LockHolder, error)
+	// GetFence returns the fencing token of the last Lock of the volume, or
+	// 0 if it was never locked.
+	GetFence() (uint64, error)
+	// WatchFence returns a channel which receives whenever the fencing
+	// token changes, until stop is called. The channel is closed when the
+	// watch ends.
+	WatchFence() (changes <-chan struct{}, stop func(), err error)
+	// ForceUnlock breaks the lock taken by h, if it's still held by h,
+	// records that it was done by by, and revokes h's lease. It returns
+	// ErrAgain if the holder changed in the meantime.
+	ForceUnlock(h *LockHolder, by string) error
+	GetForcedUnlocks() ([]LockRecord, error)
+
 	GetINode() (torus.INodeRef, error)
 	SyncINode(torus.INodeRef) error
 
-	CreateBlockVolume(vol *models.Volume) error
+	// CreateBlockVolume creates vol, starting out at inode. If replicaOf
+	// isn't empty, vol is created as a mirror replica of it.
+	CreateBlockVolume(vol *models.Volume, inode torus.INodeRef, replicaOf string) error
 	DeleteVolume() error
 
 	SaveSnapshot(name string) error
 	GetSnapshots() ([]Snapshot, error)
 	DeleteSnapshot(name string) error
+
+	// GetReplicaOf returns where the volume is mirrored from, or the empty
+	// string if it isn't a mirror replica.
+	GetReplicaOf() (string, error)
+	// SetReplicaOf marks the volume as a replica mirrored from source; an
+	// empty source makes it a regular volume again.
+	SetReplicaOf(source string) error
+
+	GetSnapshotPolicy() (SnapshotPolicy, error)
+	SetSnapshotPolicy(p SnapshotPolicy) error
+	// ClaimScheduler claims the volume's periodic work, such as scheduled
+	// snapshots and space accounting, for this server, for as long as lease
+	// lasts. It returns true if this server holds the claim.
+	ClaimScheduler(lease int64) (bool, error)
+
+	// SetVolumeSize records a new size for the volume.
+	SetVolumeSize(size uint64) error
+	// GetResizeRequest returns the size the holder of the volume's lock has
+	// been asked to resize it to, or 0 if there's no such request.
+	GetResizeRequest() (uint64, error)
+	// SetResizeRequest asks the holder of the volume's lock to resize it;
+	// a size of 0 withdraws the request.
+	SetResizeRequest(size uint64) error
+	// ClearResizeRequest withdraws the request to resize to size, done, if
+	// it's still the one pending, so that a newer one isn't lost.
+	ClearResizeRequest(done uint64) error
+
+	// GetUsage returns the last space accounting of the volume, or nil if
+	// it hasn't been accounted yet.
+	GetUsage() (*Usage, error)
+	SetUsage(u *Usage) error
+	// GetQuota returns the volume's quota in bytes, or 0 if it has none.
+	GetQuota() (uint64, error)
+	SetQuota(quota uint64) error
+	// GetQoS returns the volume's I/O limits, or nil if it has none.
+	GetQoS() (*QoS, error)
+	// SetQoS sets the volume's I/O limits; nil removes them.
+	SetQoS(q *QoS) error
+
+	// GetReservations returns the volume's SCSI persistent reservation
+	// state and its version, which is 0 if it was never set.
+	GetReservations() ([]byte, int64, error)
+	// SetReservations replaces the reservation state if it's still at
+	// version, and returns torus.ErrAgain otherwise.
+	SetReservations(state []byte, version int64) error
+	// WatchReservations returns a channel which receives whenever the
+	// reservation state changes, until stop is called. The channel is
+	// closed when the watch ends.
+	WatchReservations() (changes <-chan struct{}, stop func(), err error)
+
+	// GetExportState returns the state an export protocol keeps for the
+	// volume, or nil if it has none.
+	GetExportState(protocol string) ([]byte, error)
+	SetExportState(protocol string, state []byte) error
+
+	// TrashVolume moves the volume to the trash, keeping its metadata, if
+	// it isn't locked.
+	TrashVolume(t *TrashedVolume) error
+	// UndeleteVolume takes the volume out of the trash as vol, if no other
+	// volume is named vol.Name.
+	UndeleteVolume(vol *models.Volume) error
+	// PurgeVolume deletes the trashed volume's metadata.
+	PurgeVolume() error
+}
+
+func init() {
+	torus.RegisterFenceReader(readFence)
+	torus.RegisterFenceWatcher(watchFence)
+}
+
+// readFence reads the fencing token of a block volume for storage nodes.
+func readFence(mds torus.MetadataService, vid torus.VolumeID) (uint64, error) {
+	md, err := createBlockMetadata(mds, "", vid)
+	if err != nil {
+		return 0, err
+	}
+	return md.GetFence()
+}
+
+// watchFence watches the fencing token of a block volume for storage nodes.
+func watchFence(mds torus.MetadataService, vid torus.VolumeID) (<-chan struct{}, func(), error) {
+	md, err := createBlockMetadata(mds, "", vid)
+	if err != nil {
+		return nil, nil, err
+	}
+	return md.WatchFence()
 }
 
 func createBlockMetadata(mds torus.MetadataService, name string, vid torus.VolumeID) (blockMetadata, error) {
@@ -40,6 +177,8 @@ func createBlockMetadata(mds torus.MetadataService, name string, vid torus.Volum
 		return createBlockEtcdMetadata(mds, name, vid)
 	case torus.TempMetadata:
 		return createBlockTempMetadata(mds, name, vid)
+	case torus.LocalMetadata, torus.RaftMetadata:
+		return createBlockLocalMetadata(mds, name, vid)
 	default:
 		return nil, errors.New("unimplemented for this kind of metadata")
 	}
@@ -0,0 +1,278 @@
+// Package mirror continuously replicates block volumes to a second torus
+// cluster, for disaster recovery.
+//
+// Every round, a snapshot is taken of each mirrored volume and only the blocks
+// changed since the previous round's snapshot are sent to the remote cluster,
+// where they're applied to a replica of the volume. Replicas are read-only
+// until they're promoted.
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "mirror")
+
+// SnapshotPrefix starts the names of the snapshots taken for mirroring, on
+// both the source volume and its replica.
+const SnapshotPrefix = "mirror-"
+
+var (
+	// ErrPromoted is returned when mirroring to a remote volume that is no
+	// longer a replica.
+	ErrPromoted = errors.New("mirror: remote volume isn't a replica, it has been promoted or wasn't made by mirroring")
+	// ErrNoBase is returned when the last snapshot mirrored to the replica
+	// is gone from the source volume, so changes can't be sent.
+	ErrNoBase = errors.New("mirror: last mirrored snapshot is missing from the source volume")
+)
+
+// Config configures a Mirror.
+type Config struct {
+	// Volumes are the names of the block volumes to mirror.
+	Volumes []string
+	// Interval is how often the volumes are mirrored.
+	Interval time.Duration
+	// Source names the source cluster on the replicas, for operators.
+	Source string
+}
+
+// Mirror mirrors block volumes from one cluster to another.
+type Mirror struct {
+	src, dst *torus.Server
+	cfg      Config
+
+	mut    sync.Mutex
+	synced map[string]time.Time
+
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New returns a Mirror of the volumes from the cluster of src to that of dst.
+func New(src, dst *torus.Server, cfg Config) *Mirror {
+	return &Mirror{
+		src:       src,
+		dst:       dst,
+		cfg:       cfg,
+		synced:    make(map[string]time.Time),
+		closeChan: make(chan struct{}),
+	}
+}
+
+// Start starts mirroring every Interval, until Close is called.
+func (m *Mirror) Start() {
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Close stops mirroring, waiting for the current round to end.
+func (m *Mirror) Close() {
+	close(m.closeChan)
+	m.wg.Wait()
+}
+
+func (m *Mirror) run() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		for _, name := range m.cfg.Volumes {
+			if err := m.MirrorVolume(name); err != nil {
+				clog.Errorf("couldn't mirror volume %s: %v", name, err)
+				promMirrorFailures.WithLabelValues(name).Inc()
+			}
+			m.updateLag(name)
+		}
+		select {
+		case <-m.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Mirror) setSynced(name string, t time.Time) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if t.After(m.synced[name]) {
+		m.synced[name] = t
+	}
+}
+
+func (m *Mirror) updateLag(name string) {
+	m.mut.Lock()
+	t, ok := m.synced[name]
+	m.mut.Unlock()
+	if ok {
+		promMirrorLag.WithLabelValues(name).Set(time.Since(t).Seconds())
+	}
+}
+
+// Lag returns how far behind its source the replica of the volume is, as of
+// the last snapshot mirrored to it, and whether it's known.
+func (m *Mirror) Lag(name string) (time.Duration, bool) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	t, ok := m.synced[name]
+	if !ok {
+		return 0, false
+	}
+	return time.Since(t), true
+}
+
+func snapshotName(t time.Time) string {
+	return fmt.Sprintf("%s%020d", SnapshotPrefix, t.UnixNano())
+}
+
+// snapshotTime returns when the source snapshot of a mirror snapshot was
+// taken, or false if name isn't a mirror snapshot.
+func snapshotTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, SnapshotPrefix) {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(strings.TrimPrefix(name, SnapshotPrefix), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// MirrorVolume runs one round of mirroring of the volume, creating its
+// replica if it doesn't exist yet.
+func (m *Mirror) MirrorVolume(name string) error {
+	vol, err := m.src.MDS.GetVolume(name)
+	if err != nil {
+		return err
+	}
+	if vol.Type != block.VolumeType {
+		return torus.ErrWrongVolumeType
+	}
+	src, err := block.OpenBlockVolume(m.src, name)
+	if err != nil {
+		return err
+	}
+	dst, err := m.openReplica(name, vol.MaxBytes)
+	if err != nil {
+		return err
+	}
+	source, err := dst.ReplicaOf()
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return ErrPromoted
+	}
+
+	// The base is the newest snapshot mirrored to the replica.
+	dsnaps, err := dst.GetSnapshots()
+	if err != nil {
+		return err
+	}
+	var base string
+	for _, x := range dsnaps {
+		if t, ok := snapshotTime(x.Name); ok && x.Name > base {
+			base = x.Name
+			m.setSynced(name, t)
+		}
+	}
+	ssnaps, err := src.GetSnapshots()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, x := range ssnaps {
+		if x.Name == base {
+			found = true
+		} else if _, ok := snapshotTime(x.Name); ok {
+			// Left behind by a round that failed.
+			if err = src.DeleteSnapshot(x.Name); err != nil {
+				return err
+			}
+		}
+	}
+	if base != "" && !found {
+		return ErrNoBase
+	}
+
+	now := time.Now()
+	snap := snapshotName(now)
+	if err = src.SaveSnapshot(snap); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	cw := &countWriter{w: pw}
+	sent := make(chan error, 1)
+	go func() {
+		err := src.Send(cw, snap, base)
+		pw.CloseWithError(err)
+		sent <- err
+	}()
+	h, err := block.ReadStreamHeader(pr)
+	if err == nil {
+		err = dst.Receive(h, pr)
+	}
+	pr.CloseWithError(err)
+	if sendErr := <-sent; err == nil {
+		err = sendErr
+	}
+	promMirrorSentBytes.WithLabelValues(name).Add(float64(cw.n))
+	if err != nil {
+		return err
+	}
+	m.setSynced(name, now)
+	clog.Debugf("mirrored volume %s at snapshot %s, %d bytes sent", name, snap, cw.n)
+
+	// Only the newest snapshot is needed as the base of the next round.
+	if base != "" {
+		if err = src.DeleteSnapshot(base); err != nil {
+			clog.Warningf("couldn't delete old mirror snapshot %s of volume %s: %v", base, name, err)
+		}
+		if err = dst.DeleteSnapshot(base); err != nil {
+			clog.Warningf("couldn't delete old mirror snapshot %s of replica %s: %v", base, name, err)
+		}
+	}
+	return nil
+}
+
+// openReplica opens the remote volume, creating it as a replica if it
+// doesn't exist yet.
+func (m *Mirror) openReplica(name string, size uint64) (*block.BlockVolume, error) {
+	vols, _, err := m.dst.MDS.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if v.Name == name {
+			if v.Type != block.VolumeType {
+				return nil, torus.ErrWrongVolumeType
+			}
+			return block.OpenBlockVolume(m.dst, name)
+		}
+	}
+	clog.Infof("creating replica of volume %s", name)
+	if err = block.CreateReplicaVolume(m.dst.MDS, name, size, m.cfg.Source); err != nil {
+		return nil, err
+	}
+	return block.OpenBlockVolume(m.dst, name)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,24 @@
+package mirror
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	promMirrorLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torus_mirror_lag_seconds",
+		Help: "Age of the last snapshot of the volume mirrored to its replica",
+	}, []string{"volume"})
+	promMirrorSentBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "torus_mirror_sent_bytes_total",
+		Help: "Number of bytes of snapshot streams sent to the replica of the volume",
+	}, []string{"volume"})
+	promMirrorFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "torus_mirror_failures_total",
+		Help: "Number of rounds of mirroring of the volume that failed",
+	}, []string{"volume"})
+)
+
+func init() {
+	prometheus.MustRegister(promMirrorLag)
+	prometheus.MustRegister(promMirrorSentBytes)
+	prometheus.MustRegister(promMirrorFailures)
+}
@@ -0,0 +1,48 @@
+package block
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	promVolumeLiveBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torus_block_volume_live_bytes",
+		Help: "Size of the blocks of the current contents of the block volume",
+	}, []string{"volume"})
+	promVolumeSnapshotBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torus_block_volume_snapshot_bytes",
+		Help: "Size of the blocks only the snapshots of the block volume hold",
+	}, []string{"volume"})
+	promVolumeReplicaBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torus_block_volume_replica_bytes",
+		Help: "Size of the extra copies of the blocks of the block volume kept for redundancy",
+	}, []string{"volume"})
+	promVolumeQuotaBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torus_block_volume_quota_bytes",
+		Help: "Quota of the block volume, or 0 if it has none",
+	}, []string{"volume"})
+	promVolumeThrottledSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "torus_block_volume_throttled_seconds_total",
+		Help: "Time I/O to the block volume has been held back by its QoS limits",
+	}, []string{"volume"})
+)
+
+func init() {
+	prometheus.MustRegister(promVolumeLiveBytes)
+	prometheus.MustRegister(promVolumeSnapshotBytes)
+	prometheus.MustRegister(promVolumeReplicaBytes)
+	prometheus.MustRegister(promVolumeQuotaBytes)
+	prometheus.MustRegister(promVolumeThrottledSeconds)
+}
+
+func setUsageMetrics(volume string, u *Usage, quota uint64) {
+	promVolumeLiveBytes.WithLabelValues(volume).Set(float64(u.Live))
+	promVolumeSnapshotBytes.WithLabelValues(volume).Set(float64(u.Snapshots))
+	promVolumeReplicaBytes.WithLabelValues(volume).Set(float64(u.Replicas))
+	promVolumeQuotaBytes.WithLabelValues(volume).Set(float64(quota))
+}
+
+func deleteUsageMetrics(volume string) {
+	promVolumeLiveBytes.DeleteLabelValues(volume)
+	promVolumeSnapshotBytes.DeleteLabelValues(volume)
+	promVolumeReplicaBytes.DeleteLabelValues(volume)
+	promVolumeQuotaBytes.DeleteLabelValues(volume)
+}
@@ -0,0 +1,212 @@
+package block
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/torus"
+)
+
+// A SnapshotRule takes a snapshot of a volume every Every, keeping only the
+// newest Keep of the snapshots it took. Its snapshots are named
+// "auto-<Name>-<time>".
+type SnapshotRule struct {
+	Name  string
+	Every time.Duration
+	Keep  int
+}
+
+// A SnapshotPolicy is the set of SnapshotRules of a volume.
+type SnapshotPolicy []SnapshotRule
+
+// MinSnapshotInterval is the shortest interval ParseSnapshotPolicy accepts,
+// and how often the SnapshotScheduler runs.
+const MinSnapshotInterval = time.Minute
+
+var namedSnapshotIntervals = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// ParseSnapshotPolicy parses a policy such as "hourly keep 24, daily keep 7".
+// Each rule is hourly, daily, weekly or a duration such as 15m, followed by
+// "keep" and the number of its snapshots to keep.
+func ParseSnapshotPolicy(s string) (SnapshotPolicy, error) {
+	var p SnapshotPolicy
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		f := strings.Fields(part)
+		if len(f) != 3 || f[1] != "keep" {
+			return nil, fmt.Errorf("block: bad snapshot rule %q, want SCHEDULE keep N", strings.TrimSpace(part))
+		}
+		every, ok := namedSnapshotIntervals[f[0]]
+		if !ok {
+			var err error
+			every, err = time.ParseDuration(f[0])
+			if err != nil {
+				return nil, fmt.Errorf("block: bad snapshot schedule %q, want hourly, daily, weekly or a duration", f[0])
+			}
+			if every < MinSnapshotInterval {
+				return nil, fmt.Errorf("block: snapshot schedule %q is more often than every %s", f[0], MinSnapshotInterval)
+			}
+		}
+		keep, err := strconv.Atoi(f[2])
+		if err != nil || keep < 1 {
+			return nil, fmt.Errorf("block: bad snapshot count %q, want at least 1", f[2])
+		}
+		if seen[f[0]] {
+			return nil, fmt.Errorf("block: snapshot schedule %q given twice", f[0])
+		}
+		seen[f[0]] = true
+		p = append(p, SnapshotRule{Name: f[0], Every: every, Keep: keep})
+	}
+	return p, nil
+}
+
+func (p SnapshotPolicy) String() string {
+	var rules []string
+	for _, r := range p {
+		rules = append(rules, fmt.Sprintf("%s keep %d", r.Name, r.Keep))
+	}
+	return strings.Join(rules, ", ")
+}
+
+func (r SnapshotRule) prefix() string { return "auto-" + r.Name + "-" }
+
+func (s *BlockVolume) SnapshotPolicy() (SnapshotPolicy, error) { return s.mds.GetSnapshotPolicy() }
+
+// SetSnapshotPolicy sets the rules by which the SnapshotScheduler snapshots
+// the volume; an empty policy stops it.
+func (s *BlockVolume) SetSnapshotPolicy(p SnapshotPolicy) error { return s.mds.SetSnapshotPolicy(p) }
+
+type snapshotsByTime []Snapshot
+
+func (s snapshotsByTime) Len() int           { return len(s) }
+func (s snapshotsByTime) Less(i, j int) bool { return s[i].When.Before(s[j].When) }
+func (s snapshotsByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ApplySnapshotPolicy takes the snapshots that are due under the volume's
+// policy as of now, and deletes those it no longer keeps.
+func (s *BlockVolume) ApplySnapshotPolicy(p SnapshotPolicy, now time.Time) error {
+	snaps, err := s.mds.GetSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, r := range p {
+		var taken []Snapshot
+		for _, x := range snaps {
+			if strings.HasPrefix(x.Name, r.prefix()) {
+				taken = append(taken, x)
+			}
+		}
+		sort.Sort(snapshotsByTime(taken))
+		if len(taken) == 0 || now.Sub(taken[len(taken)-1].When) >= r.Every {
+			name := r.prefix() + now.UTC().Format("20060102T150405.000Z")
+			clog.Debugf("taking scheduled snapshot %s of volume %s", name, s.volume.Name)
+			if err = s.mds.SaveSnapshot(name); err != nil {
+				return err
+			}
+			taken = append(taken, Snapshot{Name: name, When: now})
+		}
+		for len(taken) > r.Keep {
+			clog.Debugf("deleting expired snapshot %s of volume %s", taken[0].Name, s.volume.Name)
+			if err = s.mds.DeleteSnapshot(taken[0].Name); err != nil {
+				return err
+			}
+			taken = taken[1:]
+		}
+	}
+	return nil
+}
+
+// SnapshotScheduler snapshots the block volumes of a cluster according to
+// their policies. Every torusd runs one; each volume with a policy is claimed
+// by one of them through the metadata service, and passes to another when
+// the claimant's lease runs out.
+type SnapshotScheduler struct {
+	srv      *torus.Server
+	interval time.Duration
+
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewSnapshotScheduler returns a SnapshotScheduler which checks the volumes
+// of the cluster every interval.
+func NewSnapshotScheduler(srv *torus.Server, interval time.Duration) *SnapshotScheduler {
+	return &SnapshotScheduler{
+		srv:       srv,
+		interval:  interval,
+		closeChan: make(chan struct{}),
+	}
+}
+
+func (sc *SnapshotScheduler) Start() {
+	sc.wg.Add(1)
+	go sc.run()
+}
+
+func (sc *SnapshotScheduler) Close() {
+	close(sc.closeChan)
+	sc.wg.Wait()
+}
+
+func (sc *SnapshotScheduler) run() {
+	defer sc.wg.Done()
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		if err := sc.RunOnce(); err != nil {
+			clog.Errorf("snapshot scheduler: %v", err)
+		}
+		select {
+		case <-sc.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies the policies of the volumes this server has claimed, or
+// can claim.
+func (sc *SnapshotScheduler) RunOnce() error {
+	lease := sc.srv.Lease()
+	if lease == 0 {
+		return torus.ErrInvalid
+	}
+	vols, _, err := sc.srv.MDS.GetVolumes()
+	if err != nil {
+		return err
+	}
+	for _, v := range vols {
+		if v.Type != VolumeType {
+			continue
+		}
+		vol, err := OpenBlockVolume(sc.srv, v.Name)
+		if err != nil {
+			clog.Errorf("snapshot scheduler: couldn't open volume %s: %v", v.Name, err)
+			continue
+		}
+		p, err := vol.mds.GetSnapshotPolicy()
+		if err != nil || len(p) == 0 {
+			continue
+		}
+		ok, err := vol.mds.ClaimScheduler(lease)
+		if err != nil {
+			clog.Errorf("snapshot scheduler: couldn't claim volume %s: %v", v.Name, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err = vol.ApplySnapshotPolicy(p, time.Now()); err != nil {
+			clog.Errorf("snapshot scheduler: couldn't snapshot volume %s: %v", v.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package block
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// QoS limits the I/O of a block volume, so that it can't starve the other
+// volumes sharing the cluster. Zero fields are unlimited.
+type QoS struct {
+	// IOPS limits the reads and writes per second. IOPSBurst is how many
+	// may be done at once after a lull; it defaults to IOPS.
+	IOPS      uint64
+	IOPSBurst uint64 `json:",omitempty"`
+	// Bandwidth limits the bytes read and written per second.
+	// BandwidthBurst is how many may be moved at once after a lull; it
+	// defaults to Bandwidth.
+	Bandwidth      uint64
+	BandwidthBurst uint64 `json:",omitempty"`
+}
+
+// QoS returns the volume's I/O limits, or nil if it has none.
+func (s *BlockVolume) QoS() (*QoS, error) { return s.mds.GetQoS() }
+
+// SetQoS limits the I/O of the volume; nil removes the limits. Open files
+// apply new limits within a few seconds.
+func (s *BlockVolume) SetQoS(q *QoS) error {
+	if q != nil && *q == (QoS{}) {
+		q = nil
+	}
+	return s.mds.SetQoS(q)
+}
+
+func newLimiter(limit, burst uint64) *rate.Limiter {
+	if limit == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = limit
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(burst))
+}
+
+// A throttle holds the I/O of a BlockFile to its volume's QoS.
+type throttle struct {
+	volume string
+
+	mut  sync.RWMutex
+	qos  QoS
+	iops *rate.Limiter
+	bw   *rate.Limiter
+}
+
+func (t *throttle) set(q *QoS) {
+	var c QoS
+	if q != nil {
+		c = *q
+	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if c == t.qos {
+		return
+	}
+	if c == (QoS{}) {
+		clog.Infof("removing I/O limits of volume %s", t.volume)
+	} else {
+		clog.Infof("limiting I/O of volume %s to %+v", t.volume, c)
+	}
+	t.qos = c
+	t.iops = newLimiter(c.IOPS, c.IOPSBurst)
+	t.bw = newLimiter(c.Bandwidth, c.BandwidthBurst)
+}
+
+// wait blocks until an operation on n bytes is allowed.
+func (t *throttle) wait(n int) {
+	if t == nil {
+		return
+	}
+	t.mut.RLock()
+	iops, bw := t.iops, t.bw
+	t.mut.RUnlock()
+	now := time.Now()
+	var delay time.Duration
+	if iops != nil {
+		delay = iops.ReserveN(now, 1).DelayFrom(now)
+	}
+	// Reservations can't exceed the burst, so large I/O takes several.
+	for bw != nil && n > 0 {
+		c := n
+		if c > bw.Burst() {
+			c = bw.Burst()
+		}
+		if d := bw.ReserveN(now, c).DelayFrom(now); d > delay {
+			delay = d
+		}
+		n -= c
+	}
+	if delay > 0 {
+		promVolumeThrottledSeconds.WithLabelValues(t.volume).Add(delay.Seconds())
+		time.Sleep(delay)
+	}
+}
+
+func (f *BlockFile) checkQoS() error {
+	q, err := f.vol.mds.GetQoS()
+	if err != nil {
+		return err
+	}
+	f.throttle.set(q)
+	return nil
+}
@@ -0,0 +1,492 @@
+// Package reservation implements SCSI-3 persistent reservations for the SCSI
+// frontends of block volumes. The registrations and the reservation are kept
+// in the volume's metadata, so every export of a volume, on any host, sees
+// the same state, as a failover cluster sharing the volume expects.
+package reservation
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/torus"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "reservation")
+
+var (
+	// ErrConflict is returned for a command which conflicts with the
+	// registrations or the reservation. It's answered with RESERVATION
+	// CONFLICT status.
+	ErrConflict = errors.New("reservation: conflict")
+	// ErrNotReady is returned by Check when the state can't be read, as
+	// letting the command through might break a reservation held on
+	// another host. It's answered with CHECK CONDITION, NOT READY.
+	ErrNotReady = errors.New("reservation: state unavailable")
+)
+
+// IllegalRequest is returned for a malformed command. It's answered with
+// CHECK CONDITION, ILLEGAL REQUEST and itself as the additional sense code.
+type IllegalRequest uint16
+
+const (
+	ParameterListLengthError    IllegalRequest = 0x1a00
+	InvalidFieldInCDB           IllegalRequest = 0x2400
+	InvalidFieldInParameterList IllegalRequest = 0x2600
+	InvalidRelease              IllegalRequest = 0x2604
+)
+
+func (e IllegalRequest) Error() string {
+	return fmt.Sprintf("reservation: illegal request, asc 0x%04x", uint16(e))
+}
+
+// A Store keeps the state of a volume's reservations. *block.BlockVolume is
+// a Store.
+type Store interface {
+	// Reservations returns the state and its version, which is 0 if it
+	// was never set and goes up by one with each SetReservations.
+	Reservations() ([]byte, int64, error)
+	// SetReservations replaces the state if it's still at version, and
+	// returns torus.ErrAgain otherwise.
+	SetReservations(state []byte, version int64) error
+	// WatchReservations returns a channel which receives whenever the
+	// state changes after it returns, until stop is called. The channel is
+	// closed when the watch ends, whether stopped or lost.
+	WatchReservations() (changes <-chan struct{}, stop func(), err error)
+}
+
+// Type is the type of a persistent reservation.
+type Type byte
+
+const (
+	WriteExclusive                 Type = 1
+	ExclusiveAccess                Type = 3
+	WriteExclusiveRegistrantsOnly  Type = 5
+	ExclusiveAccessRegistrantsOnly Type = 6
+	WriteExclusiveAllRegistrants   Type = 7
+	ExclusiveAccessAllRegistrants  Type = 8
+)
+
+func (t Type) valid() bool {
+	switch t {
+	case WriteExclusive, ExclusiveAccess,
+		WriteExclusiveRegistrantsOnly, ExclusiveAccessRegistrantsOnly,
+		WriteExclusiveAllRegistrants, ExclusiveAccessAllRegistrants:
+		return true
+	}
+	return false
+}
+
+// allRegistrants is true of the types held by every registrant at once.
+func (t Type) allRegistrants() bool {
+	return t == WriteExclusiveAllRegistrants || t == ExclusiveAccessAllRegistrants
+}
+
+// Registration is the key an I_T nexus registered with.
+type Registration struct {
+	Nexus string
+	Key   uint64
+}
+
+// State is the registrations and reservation of a volume.
+type State struct {
+	// Generation counts the changes to the registrations.
+	Generation    uint32
+	Registrations []Registration
+	// Type is the type of the reservation, or 0 if there's none, and
+	// Holder the nexus which holds it, unless every registrant does.
+	Type   Type
+	Holder string
+}
+
+func (s *State) key(nexus string) (uint64, bool) {
+	for _, r := range s.Registrations {
+		if r.Nexus == nexus {
+			return r.Key, true
+		}
+	}
+	return 0, false
+}
+
+// holds is true if nexus holds the reservation.
+func (s *State) holds(nexus string) bool {
+	if s.Type == 0 {
+		return false
+	}
+	if s.Type.allRegistrants() {
+		_, ok := s.key(nexus)
+		return ok
+	}
+	return s.Holder == nexus
+}
+
+func (s *State) release() {
+	s.Type = 0
+	s.Holder = ""
+}
+
+// unregister removes the registrations which match, releasing the
+// reservation if its holders are gone, and returns how many it removed.
+func (s *State) unregister(match func(Registration) bool) int {
+	var regs []Registration
+	for _, r := range s.Registrations {
+		if match(r) {
+			continue
+		}
+		regs = append(regs, r)
+	}
+	n := len(s.Registrations) - len(regs)
+	s.Registrations = regs
+	if s.Type.allRegistrants() && len(regs) == 0 {
+		s.release()
+	} else if s.Type != 0 && !s.Type.allRegistrants() {
+		if _, ok := s.key(s.Holder); !ok {
+			s.release()
+		}
+	}
+	return n
+}
+
+// Allows returns whether nexus may read, or write if write is set, given
+// the reservation.
+func (s *State) Allows(nexus string, write bool) bool {
+	if s.Type == 0 || s.holds(nexus) {
+		return true
+	}
+	_, registered := s.key(nexus)
+	switch s.Type {
+	case WriteExclusive:
+		return !write
+	case WriteExclusiveRegistrantsOnly:
+		return registered || !write
+	case ExclusiveAccessRegistrantsOnly:
+		return registered
+	}
+	return false
+}
+
+// Service actions of PERSISTENT RESERVE OUT.
+const (
+	saRegister            = 0x0
+	saReserve             = 0x1
+	saRelease             = 0x2
+	saClear               = 0x3
+	saPreempt             = 0x4
+	saPreemptAndAbort     = 0x5
+	saRegisterIgnoreKey   = 0x6
+	saRegisterAndMove     = 0x7
+	outParameterListBytes = 24
+
+	// SPEC_I_PT and ALL_TG_PT, which register other nexuses than the
+	// one the command comes through, aren't supported.
+	flagSpecIPT = 0x08
+	flagAllTgPt = 0x04
+)
+
+// Service actions of PERSISTENT RESERVE IN.
+const (
+	saReadKeys           = 0x0
+	saReadReservation    = 0x1
+	saReportCapabilities = 0x2
+)
+
+// apply runs a PERSISTENT RESERVE OUT from nexus on the state.
+func (s *State) apply(nexus string, cdb, params []byte) error {
+	sa := cdb[1] & 0x1f
+	scope, typ := cdb[2]>>4, Type(cdb[2]&0x0f)
+	if sa == saRegisterAndMove || sa > saRegisterIgnoreKey {
+		return InvalidFieldInCDB
+	}
+	if len(params) != outParameterListBytes {
+		return ParameterListLengthError
+	}
+	if params[20]&(flagSpecIPT|flagAllTgPt) != 0 {
+		return InvalidFieldInParameterList
+	}
+	key := binary.BigEndian.Uint64(params[0:8])
+	sark := binary.BigEndian.Uint64(params[8:16])
+	registered, isRegistered := s.key(nexus)
+
+	switch sa {
+	case saRegister, saRegisterIgnoreKey:
+		if sa == saRegister && (isRegistered && key != registered || !isRegistered && key != 0) {
+			return ErrConflict
+		}
+		if sark == 0 {
+			if isRegistered {
+				s.unregister(func(r Registration) bool { return r.Nexus == nexus })
+				s.Generation++
+			}
+			return nil
+		}
+		if isRegistered {
+			for i := range s.Registrations {
+				if s.Registrations[i].Nexus == nexus {
+					s.Registrations[i].Key = sark
+				}
+			}
+		} else {
+			s.Registrations = append(s.Registrations, Registration{Nexus: nexus, Key: sark})
+		}
+		s.Generation++
+		return nil
+	}
+
+	// The rest may only come from a registered nexus, with its key.
+	if !isRegistered || key != registered {
+		return ErrConflict
+	}
+	switch sa {
+	case saReserve:
+		if scope != 0 || !typ.valid() {
+			return InvalidFieldInCDB
+		}
+		if s.Type == 0 {
+			s.Type, s.Holder = typ, nexus
+			return nil
+		}
+		if s.holds(nexus) && s.Type == typ {
+			return nil
+		}
+		return ErrConflict
+	case saRelease:
+		if !s.holds(nexus) {
+			return nil
+		}
+		if scope != 0 || typ != s.Type {
+			return InvalidRelease
+		}
+		s.release()
+		return nil
+	case saClear:
+		s.Registrations = nil
+		s.release()
+		s.Generation++
+		return nil
+	}
+
+	// PREEMPT, and PREEMPT AND ABORT, which is taken as PREEMPT: the tasks
+	// of the preempted nexuses aren't aborted, but fail their next check.
+	if scope != 0 || !typ.valid() {
+		return InvalidFieldInCDB
+	}
+	if s.Type.allRegistrants() && sark == 0 {
+		s.unregister(func(r Registration) bool { return r.Nexus != nexus })
+		s.Type, s.Holder = typ, nexus
+		s.Generation++
+		return nil
+	}
+	if sark == 0 {
+		return InvalidFieldInParameterList
+	}
+	if holderKey, ok := s.key(s.Holder); s.Type != 0 && !s.Type.allRegistrants() && ok && holderKey == sark {
+		s.unregister(func(r Registration) bool { return r.Key == sark && r.Nexus != nexus })
+		s.Type, s.Holder = typ, nexus
+		s.Generation++
+		return nil
+	}
+	if s.unregister(func(r Registration) bool { return r.Key == sark }) == 0 {
+		return ErrConflict
+	}
+	s.Generation++
+	return nil
+}
+
+// report answers a PERSISTENT RESERVE IN.
+func (s *State) report(cdb []byte) ([]byte, error) {
+	order := binary.BigEndian
+	switch cdb[1] & 0x1f {
+	case saReadKeys:
+		data := make([]byte, 8, 8+8*len(s.Registrations))
+		order.PutUint32(data[0:4], s.Generation)
+		order.PutUint32(data[4:8], uint32(8*len(s.Registrations)))
+		for _, r := range s.Registrations {
+			data = append(data, make([]byte, 8)...)
+			order.PutUint64(data[len(data)-8:], r.Key)
+		}
+		return data, nil
+	case saReadReservation:
+		if s.Type == 0 {
+			data := make([]byte, 8)
+			order.PutUint32(data[0:4], s.Generation)
+			return data, nil
+		}
+		data := make([]byte, 24)
+		order.PutUint32(data[0:4], s.Generation)
+		order.PutUint32(data[4:8], 16)
+		if !s.Type.allRegistrants() {
+			key, _ := s.key(s.Holder)
+			order.PutUint64(data[8:16], key)
+		}
+		data[21] = byte(s.Type)
+		return data, nil
+	case saReportCapabilities:
+		data := make([]byte, 8)
+		order.PutUint16(data[0:2], 8)
+		// Reservations always persist through power loss.
+		data[2] = 0x01
+		data[3] = 0x80 | 0x01
+		data[4] = 0x80 | 0x40 | 0x20 | 0x08 | 0x02
+		data[5] = 0x01
+		return data, nil
+	}
+	return nil, InvalidFieldInCDB
+}
+
+// Reservations are the persistent reservations of a volume. They're read
+// again for every command while they can't be watched for changes.
+type Reservations struct {
+	store Store
+
+	mut     sync.Mutex
+	state   State
+	version int64
+	// current is set while the state is the latest: it was read while the
+	// watch was open, and the watch hasn't seen a change since.
+	current  bool
+	watching bool
+	stop     func()
+	closed   bool
+}
+
+// New returns the Reservations kept in store.
+func New(store Store) *Reservations {
+	return &Reservations{store: store}
+}
+
+// A Nexus is an I_T nexus, the path from an initiator to a volume, through
+// which it registers and reserves.
+type Nexus struct {
+	r  *Reservations
+	id string
+}
+
+// Nexus returns the nexus identified by id, which is the same for every
+// command of the initiator through the same path, on any host.
+func (r *Reservations) Nexus(id string) *Nexus {
+	return &Nexus{r: r, id: id}
+}
+
+// Close stops watching the state.
+func (r *Reservations) Close() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.closed = true
+	if r.stop != nil {
+		r.stop()
+	}
+}
+
+// watch starts watching the state for changes. It must be called with r.mut
+// held.
+func (r *Reservations) watch() {
+	changes, stop, err := r.store.WatchReservations()
+	if err != nil {
+		clog.Warningf("can't watch reservations: %v", err)
+		return
+	}
+	r.watching, r.stop = true, stop
+	go func() {
+		for range changes {
+			r.mut.Lock()
+			r.current = false
+			r.mut.Unlock()
+		}
+		r.mut.Lock()
+		r.current, r.watching, r.stop = false, false, nil
+		r.mut.Unlock()
+	}()
+}
+
+// load reads the state, unless it's current and fresh isn't set. It must be
+// called with r.mut held.
+func (r *Reservations) load(fresh bool) error {
+	if r.current && !fresh {
+		return nil
+	}
+	if !r.watching && !r.closed {
+		r.watch()
+	}
+	data, version, err := r.store.Reservations()
+	if err != nil {
+		return err
+	}
+	var s State
+	if len(data) != 0 {
+		if err = json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	r.state, r.version, r.current = s, version, r.watching
+	return nil
+}
+
+// Check returns ErrConflict if the reservation doesn't allow the nexus to
+// read, or write if write is set, and ErrNotReady if the state can't be
+// read.
+func (n *Nexus) Check(write bool) error {
+	r := n.r
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if err := r.load(false); err != nil {
+		clog.Warningf("can't read reservations: %v", err)
+		return ErrNotReady
+	}
+	if !r.state.Allows(n.id, write) {
+		return ErrConflict
+	}
+	return nil
+}
+
+// In answers a PERSISTENT RESERVE IN command.
+func (n *Nexus) In(cdb []byte) ([]byte, error) {
+	r := n.r
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if err := r.load(true); err != nil {
+		return nil, err
+	}
+	return r.state.report(cdb)
+}
+
+// Out runs a PERSISTENT RESERVE OUT command with its parameter list.
+func (n *Nexus) Out(cdb, params []byte) error {
+	r := n.r
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for {
+		if err := r.load(true); err != nil {
+			return err
+		}
+		old, err := json.Marshal(r.state)
+		if err != nil {
+			return err
+		}
+		s := r.state
+		s.Registrations = append([]Registration(nil), r.state.Registrations...)
+		if err = s.apply(n.id, cdb, params); err != nil {
+			return err
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if string(data) == string(old) {
+			return nil
+		}
+		err = r.store.SetReservations(data, r.version)
+		if err == torus.ErrAgain {
+			// Another host changed the state; apply to the new one.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.state, r.version = s, r.version+1
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+package reservation
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus"
+)
+
+type memStore struct {
+	mut      sync.Mutex
+	data     []byte
+	version  int64
+	err      error
+	watchers []chan struct{}
+	// beforeSet runs before each SetReservations, as another host might.
+	beforeSet func()
+}
+
+func (m *memStore) Reservations() ([]byte, int64, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.data, m.version, m.err
+}
+
+func (m *memStore) SetReservations(state []byte, version int64) error {
+	if f := m.beforeSet; f != nil {
+		m.beforeSet = nil
+		f()
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if version != m.version {
+		return torus.ErrAgain
+	}
+	m.data = state
+	m.version++
+	for _, ch := range m.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *memStore) WatchReservations() (<-chan struct{}, func(), error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	ch := make(chan struct{}, 1)
+	m.watchers = append(m.watchers, ch)
+	return ch, func() {}, nil
+}
+
+// lose ends every watch, as losing the connection to the metadata would.
+func (m *memStore) lose() {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	for _, ch := range m.watchers {
+		close(ch)
+	}
+	m.watchers = nil
+}
+
+func out(n *Nexus, sa byte, typ Type, key, sark uint64) error {
+	params := make([]byte, outParameterListBytes)
+	binary.BigEndian.PutUint64(params[0:8], key)
+	binary.BigEndian.PutUint64(params[8:16], sark)
+	return n.Out([]byte{0x5f, sa, byte(typ), 0, 0, 0, 0, 0, 24, 0}, params)
+}
+
+func in(t *testing.T, n *Nexus, sa byte) []byte {
+	data, err := n.In([]byte{0x5e, sa, 0, 0, 0, 0, 0, 1, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func check(t *testing.T, what string, got, want error) {
+	if got != want {
+		t.Fatalf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	r := New(&memStore{})
+	a, b, c := r.Nexus("a"), r.Nexus("b"), r.Nexus("c")
+
+	check(t, "reserve unregistered", out(a, saReserve, WriteExclusive, 0, 0), ErrConflict)
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "register b", out(b, saRegister, 0, 0, 0xb), nil)
+	check(t, "register b again", out(b, saRegister, 0, 0, 0xb), ErrConflict)
+	check(t, "reserve with a wrong key", out(a, saReserve, WriteExclusive, 0xb, 0), ErrConflict)
+	check(t, "reserve", out(a, saReserve, WriteExclusive, 0xa, 0), nil)
+	check(t, "reserve again", out(a, saReserve, WriteExclusive, 0xa, 0), nil)
+	check(t, "reserve held", out(b, saReserve, WriteExclusive, 0xb, 0), ErrConflict)
+
+	check(t, "holder writes", a.Check(true), nil)
+	check(t, "registrant reads", b.Check(false), nil)
+	check(t, "registrant writes", b.Check(true), ErrConflict)
+	check(t, "stranger writes", c.Check(true), ErrConflict)
+
+	keys := in(t, c, saReadKeys)
+	if gen := binary.BigEndian.Uint32(keys[0:4]); gen != 2 || len(keys) != 24 ||
+		binary.BigEndian.Uint64(keys[8:16]) != 0xa || binary.BigEndian.Uint64(keys[16:24]) != 0xb {
+		t.Fatalf("unexpected keys %x", keys)
+	}
+	res := in(t, c, saReadReservation)
+	if len(res) != 24 || binary.BigEndian.Uint64(res[8:16]) != 0xa || Type(res[21]) != WriteExclusive {
+		t.Fatalf("unexpected reservation %x", res)
+	}
+
+	check(t, "release of another type", out(a, saRelease, ExclusiveAccess, 0xa, 0), InvalidRelease)
+	check(t, "release by a registrant", out(b, saRelease, WriteExclusive, 0xb, 0), nil)
+	check(t, "registrant still can't write", b.Check(true), ErrConflict)
+	check(t, "release", out(a, saRelease, WriteExclusive, 0xa, 0), nil)
+	if res = in(t, c, saReadReservation); len(res) != 8 {
+		t.Fatalf("reservation not released: %x", res)
+	}
+
+	// The holder unregistering releases the reservation.
+	check(t, "reserve", out(b, saReserve, ExclusiveAccess, 0xb, 0), nil)
+	check(t, "registrant reads", a.Check(false), ErrConflict)
+	check(t, "unregister holder", out(b, saRegister, 0, 0xb, 0), nil)
+	check(t, "read after release", a.Check(false), nil)
+
+	check(t, "clear", out(a, saClear, 0, 0xa, 0), nil)
+	if keys = in(t, c, saReadKeys); len(keys) != 8 {
+		t.Fatalf("registrations not cleared: %x", keys)
+	}
+}
+
+func TestPreempt(t *testing.T) {
+	r := New(&memStore{})
+	a, b, c := r.Nexus("a"), r.Nexus("b"), r.Nexus("c")
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "register b", out(b, saRegisterIgnoreKey, 0, 0x1234, 0xb), nil)
+	check(t, "register c", out(c, saRegister, 0, 0, 0xc), nil)
+	check(t, "reserve", out(a, saReserve, WriteExclusiveRegistrantsOnly, 0xa, 0), nil)
+	check(t, "registrant writes", b.Check(true), nil)
+
+	// Preempting the holder takes the reservation.
+	check(t, "preempt holder", out(b, saPreempt, ExclusiveAccess, 0xb, 0xa), nil)
+	check(t, "preempted reads", a.Check(false), ErrConflict)
+	check(t, "preempted reserves", out(a, saReserve, WriteExclusive, 0xa, 0), ErrConflict)
+	check(t, "holder writes", b.Check(true), nil)
+
+	// Preempting a registrant only removes its registration.
+	check(t, "preempt registrant", out(b, saPreemptAndAbort, ExclusiveAccess, 0xb, 0xc), nil)
+	check(t, "preempt missing key", out(b, saPreempt, ExclusiveAccess, 0xb, 0xc), ErrConflict)
+	if keys := in(t, a, saReadKeys); len(keys) != 16 || binary.BigEndian.Uint64(keys[8:16]) != 0xb {
+		t.Fatalf("unexpected keys %x", keys)
+	}
+
+	// Preempting with key 0 takes an all registrants reservation.
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "release", out(b, saRelease, ExclusiveAccess, 0xb, 0), nil)
+	check(t, "reserve", out(a, saReserve, ExclusiveAccessAllRegistrants, 0xa, 0), nil)
+	check(t, "other registrant holds", out(b, saReserve, ExclusiveAccessAllRegistrants, 0xb, 0), nil)
+	check(t, "preempt all", out(b, saPreempt, WriteExclusive, 0xb, 0), nil)
+	check(t, "preempted writes", a.Check(true), ErrConflict)
+	check(t, "preempted reads", a.Check(false), nil)
+}
+
+func TestMalformed(t *testing.T) {
+	a := New(&memStore{}).Nexus("a")
+	check(t, "short parameter list", a.Out([]byte{0x5f, saRegister, 0, 0, 0, 0, 0, 0, 8, 0}, make([]byte, 8)), ParameterListLengthError)
+	check(t, "register and move", out(a, saRegisterAndMove, 0, 0, 0xa), InvalidFieldInCDB)
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "reserve of a bad type", out(a, saReserve, 2, 0xa, 0), InvalidFieldInCDB)
+	check(t, "preempt with key 0", out(a, saPreempt, WriteExclusive, 0xa, 0), InvalidFieldInParameterList)
+	if _, err := a.In([]byte{0x5e, 0x3, 0, 0, 0, 0, 0, 1, 0, 0}); err != InvalidFieldInCDB {
+		t.Fatalf("read full status: got %v", err)
+	}
+}
+
+func TestSharedState(t *testing.T) {
+	store := &memStore{}
+	// Two hosts serving the same volume.
+	h1, h2 := New(store), New(store)
+	a, b := h1.Nexus("a"), h2.Nexus("b")
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "register b", out(b, saRegister, 0, 0, 0xb), nil)
+	check(t, "reserve", out(a, saReserve, WriteExclusive, 0xa, 0), nil)
+	check(t, "reserve on the other host", out(b, saReserve, WriteExclusive, 0xb, 0), ErrConflict)
+
+	// A change on one host between another's read and write makes it try
+	// again with the new state.
+	store.beforeSet = func() {
+		check(t, "preempt", out(New(store).Nexus("b"), saPreempt, WriteExclusive, 0xb, 0xa), nil)
+	}
+	check(t, "release after preemption", out(a, saRelease, WriteExclusive, 0xa, 0), ErrConflict)
+	if keys := in(t, a, saReadKeys); len(keys) != 16 || binary.BigEndian.Uint64(keys[8:16]) != 0xb {
+		t.Fatalf("unexpected keys %x", keys)
+	}
+}
+
+func TestCheckFollowsOtherHosts(t *testing.T) {
+	store := &memStore{}
+	h1, h2 := New(store), New(store)
+	defer h1.Close()
+	defer h2.Close()
+	a, b := h1.Nexus("a"), h2.Nexus("b")
+	check(t, "register a", out(a, saRegister, 0, 0, 0xa), nil)
+	check(t, "register b", out(b, saRegister, 0, 0, 0xb), nil)
+	check(t, "reserve", out(a, saReserve, WriteExclusive, 0xa, 0), nil)
+	check(t, "holder writes", a.Check(true), nil)
+
+	// A preemption on the other host takes effect here once the watch
+	// reports it.
+	check(t, "preempt", out(b, saPreempt, WriteExclusive, 0xb, 0xa), nil)
+	waitFor(t, "preempted writes", func() error { return a.Check(true) }, ErrConflict)
+
+	// Once the watch is lost, every check reads the state, and fails closed
+	// if it can't.
+	store.mut.Lock()
+	store.err = errors.New("metadata unreachable")
+	store.mut.Unlock()
+	store.lose()
+	waitFor(t, "check while unreachable", func() error { return a.Check(false) }, ErrNotReady)
+	waitFor(t, "holder writes while unreachable", func() error { return b.Check(true) }, ErrNotReady)
+
+	// They recover with the metadata.
+	store.mut.Lock()
+	store.err = nil
+	store.mut.Unlock()
+	check(t, "holder writes", b.Check(true), nil)
+}
+
+// waitFor calls f until it returns want, for up to a few seconds.
+func waitFor(t *testing.T, what string, f func() error, want error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := f()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: got %v, want %v", what, got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
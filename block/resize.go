@@ -0,0 +1,166 @@
+package block
+
+import (
+	"errors"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/blockset"
+)
+
+// ErrShrink is returned when shrinking a volume without forcing it.
+var ErrShrink = errors.New("block: shrinking a volume discards the data past its new size")
+
+// resizeCheckInterval is how often an open BlockFile checks for resize
+// requests and quota changes.
+const resizeCheckInterval = 5 * time.Second
+
+func (s *BlockVolume) checkResize(cur, size uint64, force bool) error {
+	if size == 0 {
+		return torus.ErrInvalid
+	}
+	if size < cur && !force {
+		return ErrShrink
+	}
+	return s.checkWritable()
+}
+
+// resizeFile resizes f, a file of the volume whose lock is held, and
+// records the volume's new size. When shrinking, the blocks past the new
+// size are dropped from the volume, for the GC to reclaim.
+func (s *BlockVolume) resizeFile(f *torus.File, size uint64) error {
+	if err := f.Resize(int64(size)); err != nil {
+		return err
+	}
+	ref, err := f.SyncAllWrites()
+	if err != nil {
+		return err
+	}
+	if err = s.mds.SyncINode(ref); err != nil {
+		return err
+	}
+	if err = s.mds.SetVolumeSize(size); err != nil {
+		return err
+	}
+	s.volume.MaxBytes = size
+	return nil
+}
+
+// Resize changes the size of the volume, which must not be attached. It
+// refuses to shrink the volume unless forced. To resize an attached volume,
+// use RequestResize.
+func (s *BlockVolume) Resize(size uint64, force bool) (err error) {
+	if s.volume.Type != VolumeType {
+		panic("Wrong type")
+	}
+	if err = s.checkResize(s.volume.MaxBytes, size, force); err != nil {
+		return err
+	}
+	fence, err := s.mds.Lock(s.srv.Lease())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		unlockErr := s.mds.Unlock()
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+	ref, err := s.mds.GetINode()
+	if err != nil {
+		return err
+	}
+	inode, err := s.getOrCreateBlockINode(ref)
+	if err != nil {
+		return err
+	}
+	bs, err := blockset.UnmarshalFromProto(inode.GetBlocks(), s.srv.Blocks)
+	if err != nil {
+		return err
+	}
+	f, err := s.srv.CreateFile(s.volume, inode, bs)
+	if err != nil {
+		return err
+	}
+	f.SetFence(fence)
+	defer f.Close()
+	clog.Infof("resizing volume %s from %d to %d bytes", s.volume.Name, f.Size(), size)
+	return s.resizeFile(f, size)
+}
+
+// RequestResize asks the holder of the volume's lock, which has it attached,
+// to resize it. The request is done once ResizeRequested returns 0.
+func (s *BlockVolume) RequestResize(size uint64, force bool) error {
+	if err := s.checkResize(s.volume.MaxBytes, size, force); err != nil {
+		return err
+	}
+	return s.mds.SetResizeRequest(size)
+}
+
+// ResizeRequested returns the size the volume was asked to be resized to by
+// RequestResize, or 0 if there's no such request pending.
+func (s *BlockVolume) ResizeRequested() (uint64, error) {
+	return s.mds.GetResizeRequest()
+}
+
+// OnResize calls fn with the new size whenever the file is resized, so that
+// frontends can tell their clients.
+func (f *BlockFile) OnResize(fn func(size uint64)) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.onResize = append(f.onResize, fn)
+}
+
+// Resize changes the size of the volume, through its open file.
+func (f *BlockFile) Resize(size uint64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if size == f.Size() {
+		return nil
+	}
+	clog.Infof("resizing attached volume %s from %d to %d bytes", f.vol.volume.Name, f.Size(), size)
+	if err := f.vol.resizeFile(f.File, size); err != nil {
+		return err
+	}
+	for _, fn := range f.onResize {
+		fn(size)
+	}
+	return nil
+}
+
+// CheckResize applies a resize requested through RequestResize, if there is
+// one. Open files check by themselves every few seconds.
+func (f *BlockFile) CheckResize() error {
+	size, err := f.vol.mds.GetResizeRequest()
+	if err != nil || size == 0 {
+		return err
+	}
+	if err = f.Resize(size); err != nil {
+		return err
+	}
+	// A request made meanwhile is left for the next check.
+	return f.vol.mds.ClearResizeRequest(size)
+}
+
+// watch checks for resize requests, quota and QoS changes while the file is
+// open.
+func (f *BlockFile) watch() {
+	ticker := time.NewTicker(resizeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.closeChan:
+			return
+		case <-ticker.C:
+			if err := f.CheckResize(); err != nil {
+				clog.Errorf("couldn't resize volume %s: %v", f.vol.volume.Name, err)
+			}
+			if err := f.CheckQuota(); err != nil {
+				clog.Errorf("couldn't check quota of volume %s: %v", f.vol.volume.Name, err)
+			}
+			if err := f.checkQoS(); err != nil {
+				clog.Errorf("couldn't check QoS of volume %s: %v", f.vol.volume.Name, err)
+			}
+		}
+	}
+}
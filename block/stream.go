@@ -0,0 +1,387 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/blockset"
+)
+
+// A snapshot stream holds the blocks of a snapshot of a block volume, or only
+// those that changed since an earlier base snapshot. It is laid out as:
+//
+//	header: "TORUSSND", version uint32, block size uint64, volume size
+//	        uint64, snapshot time int64 (ns), snapshot name and base
+//	        snapshot name (uint16 length + bytes), CRC32-C of the header
+//	records: block index uint64, kind byte, the block's data for
+//	        streamBlockData records, CRC32-C of the record
+//	trailer: index streamEnd, number of records uint64, CRC32-C
+//
+// Integers are little-endian. Blocks which became zero, by being trimmed, are
+// sent as streamBlockZero records without data.
+
+const (
+	streamMagic   = "TORUSSND"
+	streamVersion = 1
+	streamEnd     = ^uint64(0)
+)
+
+const (
+	streamBlockData byte = iota
+	streamBlockZero
+)
+
+var streamTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrBadStream is returned when receiving a snapshot stream which is
+	// corrupt or truncated.
+	ErrBadStream = errors.New("block: corrupt snapshot stream")
+	// ErrStreamBase is returned when receiving an incremental stream into a
+	// volume whose current contents aren't the stream's base snapshot.
+	ErrStreamBase = errors.New("block: volume isn't at the base snapshot of the stream")
+)
+
+// StreamHeader describes a snapshot stream.
+type StreamHeader struct {
+	BlockSize uint64
+	Size      uint64
+	When      time.Time
+	Snapshot  string
+	// Base is the snapshot the stream is relative to, or empty if the stream
+	// holds the whole snapshot.
+	Base string
+}
+
+func (h *StreamHeader) marshal() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(streamMagic)
+	binary.Write(buf, binary.LittleEndian, uint32(streamVersion))
+	binary.Write(buf, binary.LittleEndian, h.BlockSize)
+	binary.Write(buf, binary.LittleEndian, h.Size)
+	binary.Write(buf, binary.LittleEndian, h.When.UnixNano())
+	for _, s := range []string{h.Snapshot, h.Base} {
+		binary.Write(buf, binary.LittleEndian, uint16(len(s)))
+		buf.WriteString(s)
+	}
+	binary.Write(buf, binary.LittleEndian, crc32.Checksum(buf.Bytes(), streamTable))
+	return buf.Bytes()
+}
+
+// ReadStreamHeader reads the header of a snapshot stream.
+func ReadStreamHeader(r io.Reader) (*StreamHeader, error) {
+	cr := &crcReader{r: r}
+	magic := make([]byte, len(streamMagic))
+	if _, err := io.ReadFull(cr, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != streamMagic {
+		return nil, ErrBadStream
+	}
+	var (
+		version uint32
+		when    int64
+		h       StreamHeader
+	)
+	for _, x := range []interface{}{&version, &h.BlockSize, &h.Size, &when} {
+		if err := binary.Read(cr, binary.LittleEndian, x); err != nil {
+			return nil, err
+		}
+	}
+	if version != streamVersion {
+		return nil, fmt.Errorf("block: unsupported snapshot stream version %d", version)
+	}
+	h.When = time.Unix(0, when)
+	for _, s := range []*string{&h.Snapshot, &h.Base} {
+		var l uint16
+		if err := binary.Read(cr, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		b := make([]byte, l)
+		if _, err := io.ReadFull(cr, b); err != nil {
+			return nil, err
+		}
+		*s = string(b)
+	}
+	if err := cr.check(); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// crcReader keeps the CRC of what has been read through it, to be checked
+// against the one that follows.
+type crcReader struct {
+	r   io.Reader
+	crc uint32
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc = crc32.Update(c.crc, streamTable, p[:n])
+	return n, err
+}
+
+func (c *crcReader) check() error {
+	var crc uint32
+	if err := binary.Read(c.r, binary.LittleEndian, &crc); err != nil {
+		return err
+	}
+	if crc != c.crc {
+		return ErrBadStream
+	}
+	c.crc = 0
+	return nil
+}
+
+func (s *BlockVolume) findSnapshot(name string) (Snapshot, error) {
+	snaps, err := s.mds.GetSnapshots()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, x := range snaps {
+		if x.Name == name {
+			return x, nil
+		}
+	}
+	return Snapshot{}, torus.ErrNotExist
+}
+
+func (s *BlockVolume) snapshotRefs(snap Snapshot) ([]torus.BlockRef, error) {
+	inode, err := s.getOrCreateBlockINode(torus.INodeRefFromBytes(snap.INodeRef))
+	if err != nil {
+		return nil, err
+	}
+	bs, err := blockset.UnmarshalFromProto(inode.GetBlocks(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return bs.GetAllBlockRefs(), nil
+}
+
+// Send writes a stream of the snapshot to w. If base isn't empty, only the
+// blocks that changed since the snapshot base are sent, and the stream can
+// only be received by a volume at base.
+func (s *BlockVolume) Send(w io.Writer, snapshot, base string) error {
+	snap, err := s.findSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	refs, err := s.snapshotRefs(snap)
+	if err != nil {
+		return err
+	}
+	var baseRefs []torus.BlockRef
+	if base != "" {
+		bsnap, err := s.findSnapshot(base)
+		if err != nil {
+			return err
+		}
+		if baseRefs, err = s.snapshotRefs(bsnap); err != nil {
+			return err
+		}
+	}
+	f, err := s.OpenSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	blkSize := s.mds.GlobalMetadata().BlockSize
+	h := &StreamHeader{
+		BlockSize: blkSize,
+		Size:      f.Size(),
+		When:      snap.When,
+		Snapshot:  snapshot,
+		Base:      base,
+	}
+	if _, err = w.Write(h.marshal()); err != nil {
+		return err
+	}
+	var (
+		n    uint64
+		data = make([]byte, blkSize)
+		rec  = &bytes.Buffer{}
+	)
+	for i, ref := range refs {
+		var baseRef torus.BlockRef
+		if i < len(baseRefs) {
+			baseRef = baseRefs[i]
+		}
+		if ref == baseRef {
+			continue
+		}
+		rec.Reset()
+		binary.Write(rec, binary.LittleEndian, uint64(i))
+		if ref.IsZero() {
+			rec.WriteByte(streamBlockZero)
+		} else {
+			rec.WriteByte(streamBlockData)
+			for j := range data {
+				data[j] = 0
+			}
+			_, err := f.ReadAt(data, int64(i)*int64(blkSize))
+			if err != nil && err != io.EOF {
+				return err
+			}
+			rec.Write(data)
+		}
+		binary.Write(rec, binary.LittleEndian, crc32.Checksum(rec.Bytes(), streamTable))
+		if _, err = w.Write(rec.Bytes()); err != nil {
+			return err
+		}
+		n++
+	}
+	rec.Reset()
+	binary.Write(rec, binary.LittleEndian, streamEnd)
+	binary.Write(rec, binary.LittleEndian, n)
+	binary.Write(rec, binary.LittleEndian, crc32.Checksum(rec.Bytes(), streamTable))
+	_, err = w.Write(rec.Bytes())
+	return err
+}
+
+// Receive applies a stream, whose header has already been read from r, to
+// the volume and saves the stream's snapshot. The volume takes the size of
+// the stream's snapshot. If the stream is incremental, the volume must be
+// unchanged since the stream's base snapshot. If the stream is corrupt,
+// nothing is saved.
+func (s *BlockVolume) Receive(h *StreamHeader, r io.Reader) (err error) {
+	defer func() {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrBadStream
+		}
+	}()
+	if blkSize := s.mds.GlobalMetadata().BlockSize; h.BlockSize != blkSize {
+		return fmt.Errorf("block: stream has block size %d, cluster has %d", h.BlockSize, blkSize)
+	}
+	if h.Size > math.MaxInt64 {
+		return ErrBadStream
+	}
+	blocks := (h.Size + h.BlockSize - 1) / h.BlockSize
+	fence, err := s.mds.Lock(s.srv.Lease())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Writes are only synced if the whole stream was good; otherwise
+		// they're left for the GC.
+		unlockErr := s.mds.Unlock()
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+	cur, err := s.mds.GetINode()
+	if err != nil {
+		return err
+	}
+	if h.Base != "" {
+		bsnap, err := s.findSnapshot(h.Base)
+		if err == torus.ErrNotExist || (err == nil && !bytes.Equal(bsnap.INodeRef, cur.ToBytes())) {
+			return ErrStreamBase
+		}
+		if err != nil {
+			return err
+		}
+	}
+	inode, err := s.getOrCreateBlockINode(cur)
+	if err != nil {
+		return err
+	}
+	bs, err := blockset.UnmarshalFromProto(inode.GetBlocks(), s.srv.Blocks)
+	if err != nil {
+		return err
+	}
+	f, err := s.srv.CreateFile(s.volume, inode, bs)
+	if err != nil {
+		return err
+	}
+	f.SetFence(fence)
+	defer f.Close()
+	if h.Size != f.Size() {
+		// The snapshot's volume was resized since the base.
+		if err = f.Truncate(int64(h.Size)); err != nil {
+			return err
+		}
+	}
+
+	var (
+		n     uint64
+		index uint64
+		kind  = make([]byte, 1)
+		data  = make([]byte, h.BlockSize)
+	)
+	cr := &crcReader{r: r}
+	for {
+		if err = binary.Read(cr, binary.LittleEndian, &index); err != nil {
+			return err
+		}
+		if index == streamEnd {
+			break
+		}
+		if _, err = io.ReadFull(cr, kind); err != nil {
+			return err
+		}
+		// Bounded first, so that the offset can't wrap around.
+		if index >= blocks {
+			return ErrBadStream
+		}
+		off := int64(index * h.BlockSize)
+		switch kind[0] {
+		case streamBlockData:
+			if _, err = io.ReadFull(cr, data); err != nil {
+				return err
+			}
+			if err = cr.check(); err != nil {
+				return err
+			}
+			end := uint64(off) + h.BlockSize
+			if end > h.Size {
+				end = h.Size
+			}
+			_, err = f.WriteAt(data[:end-uint64(off)], off)
+		case streamBlockZero:
+			if err = cr.check(); err != nil {
+				return err
+			}
+			err = f.Trim(off, int64(h.BlockSize))
+		default:
+			return ErrBadStream
+		}
+		if err != nil {
+			return err
+		}
+		n++
+	}
+	var count uint64
+	if err = binary.Read(cr, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+	if err = cr.check(); err != nil {
+		return err
+	}
+	if count != n {
+		return ErrBadStream
+	}
+	if f.WriteOpen() {
+		ref, err := f.SyncAllWrites()
+		if err != nil {
+			return err
+		}
+		if err = s.mds.SyncINode(ref); err != nil {
+			return err
+		}
+	}
+	if h.Size != s.volume.MaxBytes {
+		if err = s.mds.SetVolumeSize(h.Size); err != nil {
+			return err
+		}
+		s.volume.MaxBytes = h.Size
+	}
+	return s.mds.SaveSnapshot(h.Snapshot)
+}
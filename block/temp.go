@@ -16,12 +16,28 @@ type blockTempMetadata struct {
 }
 
 type blockTempVolumeData struct {
-	locked string
-	id     torus.INodeRef
-	snaps  []Snapshot
+	locked      string
+	fence       uint64
+	id          torus.INodeRef
+	snaps       []Snapshot
+	forced      []LockRecord
+	source      string
+	policy      SnapshotPolicy
+	sched       string
+	resize      uint64
+	usage       *Usage
+	quota       uint64
+	qos         *QoS
+	res         []byte
+	resVer      int64
+	resWatchers map[chan struct{}]bool
+	export      map[string][]byte
+
+	// fenceWatchers are notified of each Lock.
+	fenceWatchers map[chan struct{}]bool
 }
 
-func (b *blockTempMetadata) CreateBlockVolume(volume *models.Volume) error {
+func (b *blockTempMetadata) CreateBlockVolume(volume *models.Volume, inode torus.INodeRef, replicaOf string) error {
 	b.LockData()
 	defer b.UnlockData()
 	_, ok := b.GetData(fmt.Sprint(volume.Id))
@@ -31,24 +47,29 @@ func (b *blockTempMetadata) CreateBlockVolume(volume *models.Volume) error {
 	b.CreateVolume(volume)
 	b.SetData(fmt.Sprint(volume.Id), &blockTempVolumeData{
 		locked: "",
-		id:     torus.NewINodeRef(torus.VolumeID(volume.Id), 1),
+		id:     inode,
+		source: replicaOf,
 	})
 	return nil
 }
 
-func (b *blockTempMetadata) Lock(lease int64) error {
+func (b *blockTempMetadata) Lock(lease int64) (uint64, error) {
 	b.LockData()
 	defer b.UnlockData()
 	v, ok := b.GetData(fmt.Sprint(b.vid))
 	if !ok {
-		return torus.ErrNotExist
+		return 0, torus.ErrNotExist
 	}
 	d := v.(*blockTempVolumeData)
 	if d.locked != "" {
-		return torus.ErrLocked
+		return 0, torus.ErrLocked
 	}
 	d.locked = b.UUID()
-	return nil
+	d.fence++
+	for ch := range d.fenceWatchers {
+		notify(ch)
+	}
+	return d.fence, nil
 }
 
 func (b *blockTempMetadata) GetINode() (torus.INodeRef, error) {
@@ -92,7 +113,39 @@ func (b *blockTempMetadata) Unlock() error {
 	return nil
 }
 
-func (b *blockTempMetadata) DeleteVolume() error {
+func (b *blockTempMetadata) GetLockHolder() (*LockHolder, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	if d.locked == "" {
+		return nil, nil
+	}
+	return &LockHolder{
+		UUID:  d.locked,
+		TTL:   -1,
+		Fence: d.fence,
+	}, nil
+}
+
+func (b *blockTempMetadata) GetFence() (uint64, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return 0, nil
+	}
+	return v.(*blockTempVolumeData).fence, nil
+}
+
+func (b *blockTempMetadata) WatchFence() (<-chan struct{}, func(), error) {
+	return b.watch(func(d *blockTempVolumeData) *map[chan struct{}]bool { return &d.fenceWatchers })
+}
+
+func (b *blockTempMetadata) ForceUnlock(h *LockHolder, by string) error {
 	b.LockData()
 	defer b.UnlockData()
 	v, ok := b.GetData(fmt.Sprint(b.vid))
@@ -100,9 +153,43 @@ func (b *blockTempMetadata) DeleteVolume() error {
 		return torus.ErrNotExist
 	}
 	d := v.(*blockTempVolumeData)
-	if d.locked != b.UUID() {
+	if d.locked != h.UUID {
+		return torus.ErrAgain
+	}
+	d.locked = ""
+	d.forced = append(d.forced, LockRecord{
+		When:   time.Now(),
+		By:     by,
+		Holder: *h,
+	})
+	return nil
+}
+
+func (b *blockTempMetadata) GetForcedUnlocks() ([]LockRecord, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	out := make([]LockRecord, len(d.forced))
+	copy(out, d.forced)
+	return out, nil
+}
+
+func (b *blockTempMetadata) DeleteVolume() error {
+	b.LockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		b.UnlockData()
+		return torus.ErrNotExist
+	}
+	if v.(*blockTempVolumeData).locked != "" {
+		b.UnlockData()
 		return torus.ErrLocked
 	}
+	b.UnlockData()
 	return b.Client.DeleteVolume(b.name)
 }
 
@@ -157,6 +244,422 @@ func (b *blockTempMetadata) DeleteSnapshot(name string) error {
 	return torus.ErrNotExist
 }
 
+func (b *blockTempMetadata) GetReplicaOf() (string, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return "", torus.ErrNotExist
+	}
+	return v.(*blockTempVolumeData).source, nil
+}
+
+func (b *blockTempMetadata) SetReplicaOf(source string) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	v.(*blockTempVolumeData).source = source
+	return nil
+}
+
+func (b *blockTempMetadata) GetSnapshotPolicy() (SnapshotPolicy, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	return append(SnapshotPolicy(nil), v.(*blockTempVolumeData).policy...), nil
+}
+
+func (b *blockTempMetadata) SetSnapshotPolicy(p SnapshotPolicy) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	v.(*blockTempVolumeData).policy = append(SnapshotPolicy(nil), p...)
+	return nil
+}
+
+func (b *blockTempMetadata) ClaimScheduler(lease int64) (bool, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return false, torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	if d.sched == "" {
+		d.sched = b.UUID()
+	}
+	return d.sched == b.UUID(), nil
+}
+
+func (b *blockTempMetadata) SetVolumeSize(size uint64) error {
+	vol, err := b.GetVolume(b.name)
+	if err != nil {
+		return err
+	}
+	b.LockData()
+	defer b.UnlockData()
+	vol.MaxBytes = size
+	return nil
+}
+
+func (b *blockTempMetadata) GetResizeRequest() (uint64, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return 0, torus.ErrNotExist
+	}
+	return v.(*blockTempVolumeData).resize, nil
+}
+
+func (b *blockTempMetadata) SetResizeRequest(size uint64) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	v.(*blockTempVolumeData).resize = size
+	return nil
+}
+
+func (b *blockTempMetadata) ClearResizeRequest(done uint64) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	if d := v.(*blockTempVolumeData); d.resize == done {
+		d.resize = 0
+	}
+	return nil
+}
+
+func (b *blockTempMetadata) GetUsage() (*Usage, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	u := v.(*blockTempVolumeData).usage
+	if u == nil {
+		return nil, nil
+	}
+	c := *u
+	return &c, nil
+}
+
+func (b *blockTempMetadata) SetUsage(u *Usage) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	c := *u
+	v.(*blockTempVolumeData).usage = &c
+	return nil
+}
+
+func (b *blockTempMetadata) GetQuota() (uint64, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return 0, torus.ErrNotExist
+	}
+	return v.(*blockTempVolumeData).quota, nil
+}
+
+func (b *blockTempMetadata) SetQuota(quota uint64) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	v.(*blockTempVolumeData).quota = quota
+	return nil
+}
+
+func (b *blockTempMetadata) GetQoS() (*QoS, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	q := v.(*blockTempVolumeData).qos
+	if q == nil {
+		return nil, nil
+	}
+	c := *q
+	return &c, nil
+}
+
+func (b *blockTempMetadata) SetQoS(q *QoS) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	if q == nil {
+		d.qos = nil
+		return nil
+	}
+	c := *q
+	d.qos = &c
+	return nil
+}
+
+func (b *blockTempMetadata) GetReservations() ([]byte, int64, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, 0, torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	return append([]byte(nil), d.res...), d.resVer, nil
+}
+
+func (b *blockTempMetadata) SetReservations(state []byte, version int64) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	if d.resVer != version {
+		return torus.ErrAgain
+	}
+	d.res = append([]byte(nil), state...)
+	d.resVer++
+	for ch := range d.resWatchers {
+		notify(ch)
+	}
+	return nil
+}
+
+func (b *blockTempMetadata) WatchReservations() (<-chan struct{}, func(), error) {
+	return b.watch(func(d *blockTempVolumeData) *map[chan struct{}]bool { return &d.resWatchers })
+}
+
+// watch adds a watcher to the set of the volume's data that watchers picks.
+func (b *blockTempMetadata) watch(watchers func(*blockTempVolumeData) *map[chan struct{}]bool) (<-chan struct{}, func(), error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, nil, torus.ErrNotExist
+	}
+	set := watchers(v.(*blockTempVolumeData))
+	if *set == nil {
+		*set = make(map[chan struct{}]bool)
+	}
+	changes := make(chan struct{}, 1)
+	(*set)[changes] = true
+	return changes, func() {
+		b.LockData()
+		defer b.UnlockData()
+		if (*set)[changes] {
+			delete(*set, changes)
+			close(changes)
+		}
+	}, nil
+}
+
+func (b *blockTempMetadata) GetExportState(protocol string) ([]byte, error) {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return nil, torus.ErrNotExist
+	}
+	return append([]byte(nil), v.(*blockTempVolumeData).export[protocol]...), nil
+}
+
+func (b *blockTempMetadata) SetExportState(protocol string, state []byte) error {
+	b.LockData()
+	defer b.UnlockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		return torus.ErrNotExist
+	}
+	d := v.(*blockTempVolumeData)
+	if d.export == nil {
+		d.export = make(map[string][]byte)
+	}
+	d.export[protocol] = append([]byte(nil), state...)
+	return nil
+}
+
+// tempTrashKey holds the map of trashed volumes in temp metadata.
+const tempTrashKey = "trash"
+
+func (b *blockTempMetadata) trash() map[torus.VolumeID]*TrashedVolume {
+	v, ok := b.GetData(tempTrashKey)
+	if !ok {
+		v = make(map[torus.VolumeID]*TrashedVolume)
+		b.SetData(tempTrashKey, v)
+	}
+	return v.(map[torus.VolumeID]*TrashedVolume)
+}
+
+func (b *blockTempMetadata) TrashVolume(t *TrashedVolume) error {
+	b.LockData()
+	v, ok := b.GetData(fmt.Sprint(b.vid))
+	if !ok {
+		b.UnlockData()
+		return torus.ErrNotExist
+	}
+	if v.(*blockTempVolumeData).locked != "" {
+		b.UnlockData()
+		return torus.ErrLocked
+	}
+	c := *t
+	b.trash()[b.vid] = &c
+	b.UnlockData()
+	return b.Client.DeleteVolume(b.name)
+}
+
+func (b *blockTempMetadata) UndeleteVolume(vol *models.Volume) error {
+	if _, err := b.GetVolume(vol.Name); err == nil {
+		return torus.ErrExists
+	}
+	b.LockData()
+	trash := b.trash()
+	_, ok := trash[b.vid]
+	delete(trash, b.vid)
+	b.UnlockData()
+	if !ok {
+		return torus.ErrExists
+	}
+	return b.RestoreVolume(vol)
+}
+
+func (b *blockTempMetadata) PurgeVolume() error {
+	b.LockData()
+	defer b.UnlockData()
+	trash := b.trash()
+	if _, ok := trash[b.vid]; !ok {
+		return torus.ErrNotExist
+	}
+	delete(trash, b.vid)
+	return nil
+}
+
+func listTempTrash(mds torus.MetadataService) ([]TrashedVolume, error) {
+	t, ok := mds.(*temp.Client)
+	if !ok {
+		panic("how are we listing temp metadata that doesn't implement it but reports as being temp")
+	}
+	b := &blockTempMetadata{Client: t}
+	b.LockData()
+	defer b.UnlockData()
+	var out []TrashedVolume
+	for _, x := range b.trash() {
+		out = append(out, *x)
+	}
+	return out, nil
+}
+
+// tempSnapGroupsKey holds the map of snapshot groups in temp metadata.
+const tempSnapGroupsKey = "snapgroups"
+
+func tempSnapshotGroups(t *temp.Client) map[string]*SnapshotGroup {
+	v, ok := t.GetData(tempSnapGroupsKey)
+	if !ok {
+		v = make(map[string]*SnapshotGroup)
+		t.SetData(tempSnapGroupsKey, v)
+	}
+	return v.(map[string]*SnapshotGroup)
+}
+
+func saveTempSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	t := mds.(*temp.Client)
+	t.LockData()
+	defer t.UnlockData()
+	groups := tempSnapshotGroups(t)
+	if _, ok := groups[g.Name]; ok {
+		return torus.ErrExists
+	}
+	var ds []*blockTempVolumeData
+	for _, m := range g.Volumes {
+		v, ok := t.GetData(fmt.Sprint(m.ID))
+		if !ok {
+			return torus.ErrNotExist
+		}
+		d := v.(*blockTempVolumeData)
+		for _, x := range d.snaps {
+			if x.Name == g.Name {
+				return torus.ErrExists
+			}
+		}
+		ds = append(ds, d)
+	}
+	for _, d := range ds {
+		d.snaps = append(d.snaps, Snapshot{
+			Name:     g.Name,
+			When:     g.When,
+			INodeRef: d.id.ToBytes(),
+		})
+	}
+	c := *g
+	groups[g.Name] = &c
+	return nil
+}
+
+func listTempSnapshotGroups(mds torus.MetadataService) ([]SnapshotGroup, error) {
+	t := mds.(*temp.Client)
+	t.LockData()
+	defer t.UnlockData()
+	var out []SnapshotGroup
+	for _, g := range tempSnapshotGroups(t) {
+		out = append(out, *g)
+	}
+	return out, nil
+}
+
+func deleteTempSnapshotGroup(mds torus.MetadataService, g *SnapshotGroup) error {
+	t := mds.(*temp.Client)
+	t.LockData()
+	defer t.UnlockData()
+	groups := tempSnapshotGroups(t)
+	if _, ok := groups[g.Name]; !ok {
+		return torus.ErrNotExist
+	}
+	delete(groups, g.Name)
+	for _, m := range g.Volumes {
+		v, ok := t.GetData(fmt.Sprint(m.ID))
+		if !ok {
+			continue
+		}
+		d := v.(*blockTempVolumeData)
+		for i, x := range d.snaps {
+			if x.Name == g.Name {
+				d.snaps = append(d.snaps[:i], d.snaps[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func createBlockTempMetadata(mds torus.MetadataService, name string, vid torus.VolumeID) (blockMetadata, error) {
 	if t, ok := mds.(*temp.Client); ok {
 		return &blockTempMetadata{
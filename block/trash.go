@@ -0,0 +1,150 @@
+package block
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/models"
+)
+
+// DefaultTrashRetention is how long a deleted block volume is kept in the
+// trash, unless told otherwise.
+const DefaultTrashRetention = 7 * 24 * time.Hour
+
+// TrashedVolume is a deleted block volume which can still be undeleted. Its
+// snapshots and blocks are kept until it's purged, which PurgeExpiredTrash
+// does once it Expires.
+type TrashedVolume struct {
+	Volume  *models.Volume
+	Deleted time.Time
+	Expires time.Time
+}
+
+// TrashBlockVolume deletes the block volume, keeping it in the trash for
+// retention. Like DeleteBlockVolume, it fails with torus.ErrLocked if the
+// volume is attached.
+func TrashBlockVolume(mds torus.MetadataService, volume string, retention time.Duration) error {
+	vol, err := mds.GetVolume(volume)
+	if err != nil {
+		return err
+	}
+	if vol.Type != VolumeType {
+		return torus.ErrWrongVolumeType
+	}
+	bmds, err := createBlockMetadata(mds, vol.Name, torus.VolumeID(vol.Id))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	clog.Infof("moving volume %s to the trash until %s", vol.Name, now.Add(retention))
+	return bmds.TrashVolume(&TrashedVolume{
+		Volume:  vol,
+		Deleted: now,
+		Expires: now.Add(retention),
+	})
+}
+
+// ListTrash returns the block volumes in the trash, oldest deletion first.
+func ListTrash(mds torus.MetadataService) ([]TrashedVolume, error) {
+	var (
+		out []TrashedVolume
+		err error
+	)
+	switch mds.Kind() {
+	case torus.EtcdMetadata:
+		out, err = listEtcdTrash(mds)
+	case torus.TempMetadata:
+		out, err = listTempTrash(mds)
+	case torus.LocalMetadata, torus.RaftMetadata:
+		out, err = listLocalTrash(mds)
+	default:
+		return nil, errors.New("unimplemented for this kind of metadata")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(trashByDeletion(out))
+	return out, nil
+}
+
+type trashByDeletion []TrashedVolume
+
+func (t trashByDeletion) Len() int           { return len(t) }
+func (t trashByDeletion) Less(i, j int) bool { return t[i].Deleted.Before(t[j].Deleted) }
+func (t trashByDeletion) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func findTrash(mds torus.MetadataService, id torus.VolumeID) (*TrashedVolume, error) {
+	trash, err := ListTrash(mds)
+	if err != nil {
+		return nil, err
+	}
+	for i, x := range trash {
+		if torus.VolumeID(x.Volume.Id) == id {
+			return &trash[i], nil
+		}
+	}
+	return nil, torus.ErrNotExist
+}
+
+// UndeleteBlockVolume takes the block volume with the given ID out of the
+// trash, as it was when deleted, under name, or its old name if name is
+// empty. It fails with torus.ErrExists if a volume of that name exists.
+func UndeleteBlockVolume(mds torus.MetadataService, id torus.VolumeID, name string) error {
+	t, err := findTrash(mds, id)
+	if err != nil {
+		return err
+	}
+	vol := *t.Volume
+	if name != "" {
+		vol.Name = name
+	}
+	bmds, err := createBlockMetadata(mds, vol.Name, id)
+	if err != nil {
+		return err
+	}
+	clog.Infof("undeleting volume %s", vol.Name)
+	return bmds.UndeleteVolume(&vol)
+}
+
+// PurgeBlockVolume deletes the block volume with the given ID from the
+// trash for good, letting the GC reclaim its blocks.
+func PurgeBlockVolume(mds torus.MetadataService, id torus.VolumeID) error {
+	t, err := findTrash(mds, id)
+	if err != nil {
+		return err
+	}
+	bmds, err := createBlockMetadata(mds, t.Volume.Name, id)
+	if err != nil {
+		return err
+	}
+	clog.Infof("purging volume %s from the trash", t.Volume.Name)
+	return bmds.PurgeVolume()
+}
+
+// PurgeExpiredTrash purges the block volumes whose retention has passed from
+// the trash, returning those it purged.
+func PurgeExpiredTrash(mds torus.MetadataService) ([]TrashedVolume, error) {
+	trash, err := ListTrash(mds)
+	if err != nil {
+		return nil, err
+	}
+	var purged []TrashedVolume
+	now := time.Now()
+	for _, t := range trash {
+		if !now.After(t.Expires) {
+			continue
+		}
+		err = PurgeBlockVolume(mds, torus.VolumeID(t.Volume.Id))
+		if err == torus.ErrNotExist {
+			// Purged or undeleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, t)
+	}
+	return purged, nil
+}
@@ -0,0 +1,341 @@
+package block
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coreos/torus"
+)
+
+// ErrQuota is returned by writes which would take a volume past its quota.
+var ErrQuota = errors.New("block: volume is over its quota")
+
+// Usage is how much space a volume takes up in the cluster.
+type Usage struct {
+	// Live is the size of the blocks of the volume's current contents.
+	// Blocks that were never written, or were trimmed, take no space.
+	Live uint64
+	// Snapshots is the size of the blocks only the volume's snapshots hold.
+	Snapshots uint64
+	// Replicas is the size of the extra copies of those blocks the cluster
+	// keeps for redundancy.
+	Replicas uint64
+	// When is when the volume was accounted.
+	When time.Time
+}
+
+// Allocated is the space the volume takes up before replication, which is
+// what its quota limits.
+func (u *Usage) Allocated() uint64 { return u.Live + u.Snapshots }
+
+// Stored is all the space the volume takes up on the storage nodes.
+func (u *Usage) Stored() uint64 { return u.Allocated() + u.Replicas }
+
+// ComputeUsage walks the blocksets of the volume and its snapshots to tell
+// how much space it takes up. Blocks a clone shares with the volume it was
+// cloned from count toward both.
+func (s *BlockVolume) ComputeUsage() (*Usage, error) {
+	u := &Usage{When: time.Now()}
+	ref, err := s.mds.GetINode()
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := s.mds.GetSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[torus.BlockRef]bool)
+	var (
+		live, snap uint64
+		sample     torus.BlockRef
+	)
+	count := func(ref torus.INodeRef) (uint64, error) {
+		if ref.INode <= 1 {
+			// Never written to.
+			return 0, nil
+		}
+		refs, err := s.snapshotRefs(Snapshot{INodeRef: ref.ToBytes()})
+		if err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, x := range refs {
+			if x.IsZero() || seen[x] {
+				continue
+			}
+			seen[x] = true
+			sample = x
+			n++
+		}
+		return n, nil
+	}
+	if live, err = count(ref); err != nil {
+		return nil, err
+	}
+	for _, x := range snaps {
+		n, err := count(torus.INodeRefFromBytes(x.INodeRef))
+		if err != nil {
+			return nil, err
+		}
+		snap += n
+	}
+	blkSize := s.mds.GlobalMetadata().BlockSize
+	u.Live = live * blkSize
+	u.Snapshots = snap * blkSize
+	if live+snap != 0 {
+		ring, err := s.srv.MDS.GetRing()
+		if err != nil {
+			return nil, err
+		}
+		perm, err := ring.GetPeers(sample)
+		if err != nil {
+			return nil, err
+		}
+		if perm.Replication > 1 {
+			u.Replicas = u.Allocated() * uint64(perm.Replication-1)
+		}
+	}
+	return u, nil
+}
+
+// UpdateUsage accounts the volume and saves the result, for Usage.
+func (s *BlockVolume) UpdateUsage() (*Usage, error) {
+	u, err := s.ComputeUsage()
+	if err != nil {
+		return nil, err
+	}
+	return u, s.mds.SetUsage(u)
+}
+
+// Usage returns the last saved accounting of the volume, or nil if it
+// hasn't been accounted yet.
+func (s *BlockVolume) Usage() (*Usage, error) { return s.mds.GetUsage() }
+
+// Quota returns the most space the volume may allocate, or 0 if it's
+// unlimited.
+func (s *BlockVolume) Quota() (uint64, error) { return s.mds.GetQuota() }
+
+// SetQuota limits the space the volume may allocate: writes which would
+// allocate blocks past it fail with ErrQuota. A quota of 0 removes the limit.
+func (s *BlockVolume) SetQuota(quota uint64) error { return s.mds.SetQuota(quota) }
+
+// fileQuota is the quota of the volume of an open file, and what the volume
+// has allocated: as of its last accounting, plus what the file allocated
+// since.
+type fileQuota struct {
+	mu    sync.Mutex
+	limit uint64
+	// accounted is the space allocated as of the accounting at when.
+	accounted uint64
+	when      time.Time
+	// unsynced is what writes not synced yet allocated, and synced what
+	// those synced since the accounting did.
+	unsynced uint64
+	synced   []syncedAlloc
+}
+
+// syncedAlloc is the space allocated by writes synced at when.
+type syncedAlloc struct {
+	when  time.Time
+	bytes uint64
+}
+
+func (q *fileQuota) used() uint64 {
+	n := q.accounted + q.unsynced
+	for _, a := range q.synced {
+		n += a.bytes
+	}
+	return n
+}
+
+func (q *fileQuota) over() bool {
+	return q.limit != 0 && q.used() >= q.limit
+}
+
+// CheckQuota refreshes the volume's quota and last accounting. Open files
+// check by themselves every few seconds.
+func (f *BlockFile) CheckQuota() error {
+	limit, err := f.vol.mds.GetQuota()
+	if err != nil {
+		return err
+	}
+	u, err := f.vol.mds.GetUsage()
+	if err != nil {
+		return err
+	}
+	q := &f.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	wasOver := q.over()
+	q.limit = limit
+	if u != nil && u.When.After(q.when) {
+		q.accounted, q.when = u.Allocated(), u.When
+		// Forget the writes the accounting counted.
+		var kept []syncedAlloc
+		for _, a := range q.synced {
+			if a.when.After(u.When) {
+				kept = append(kept, a)
+			}
+		}
+		q.synced = kept
+	}
+	if over := q.over(); over != wasOver {
+		if over {
+			clog.Warningf("volume %s is over its quota, refusing writes", f.vol.volume.Name)
+		} else {
+			clog.Infof("volume %s is under its quota again", f.vol.volume.Name)
+		}
+	}
+	return nil
+}
+
+// allocate charges the blocks a write of n bytes at off allocates, those
+// which were holes, to the quota, or returns ErrQuota if they'd take the
+// volume past it. A volume over its quota refuses all writes.
+func (f *BlockFile) allocate(off int64, n int) error {
+	q := &f.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limit == 0 {
+		return nil
+	}
+	if q.over() {
+		return ErrQuota
+	}
+	exts, err := f.File.Extents(off, int64(n))
+	if err != nil {
+		return err
+	}
+	blkSize := int64(f.vol.mds.GlobalMetadata().BlockSize)
+	var blocks int64
+	for _, e := range exts {
+		if e.Hole && e.Length > 0 {
+			blocks += (off+e.Length-1)/blkSize - off/blkSize + 1
+		}
+		off += e.Length
+	}
+	need := uint64(blocks * blkSize)
+	if q.used()+need > q.limit {
+		return ErrQuota
+	}
+	q.unsynced += need
+	return nil
+}
+
+// syncedAt records that the writes allocated so far were synced.
+func (q *fileQuota) syncedAt(when time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.unsynced != 0 {
+		q.synced = append(q.synced, syncedAlloc{when: when, bytes: q.unsynced})
+		q.unsynced = 0
+	}
+}
+
+// GetUsage returns the last saved accounting of the block volume, or nil if
+// it hasn't been accounted yet.
+func GetUsage(mds torus.MetadataService, volume string) (*Usage, error) {
+	vol, err := mds.GetVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	if vol.Type != VolumeType {
+		return nil, torus.ErrWrongVolumeType
+	}
+	bmds, err := createBlockMetadata(mds, vol.Name, torus.VolumeID(vol.Id))
+	if err != nil {
+		return nil, err
+	}
+	return bmds.GetUsage()
+}
+
+// UsageAccountant periodically accounts the block volumes of a cluster.
+// Every torusd runs one; each volume is accounted by the torusd that claims
+// it, which also exports its usage as metrics.
+type UsageAccountant struct {
+	srv      *torus.Server
+	interval time.Duration
+
+	closeChan chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewUsageAccountant returns a UsageAccountant which accounts the volumes of
+// the cluster every interval.
+func NewUsageAccountant(srv *torus.Server, interval time.Duration) *UsageAccountant {
+	return &UsageAccountant{
+		srv:       srv,
+		interval:  interval,
+		closeChan: make(chan struct{}),
+	}
+}
+
+func (a *UsageAccountant) Start() {
+	a.wg.Add(1)
+	go a.run()
+}
+
+func (a *UsageAccountant) Close() {
+	close(a.closeChan)
+	a.wg.Wait()
+}
+
+func (a *UsageAccountant) run() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		if err := a.RunOnce(); err != nil {
+			clog.Errorf("usage accounting: %v", err)
+		}
+		select {
+		case <-a.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce accounts the volumes this server has claimed, or can claim.
+func (a *UsageAccountant) RunOnce() error {
+	lease := a.srv.Lease()
+	if lease == 0 {
+		return torus.ErrInvalid
+	}
+	vols, _, err := a.srv.MDS.GetVolumes()
+	if err != nil {
+		return err
+	}
+	for _, v := range vols {
+		if v.Type != VolumeType {
+			continue
+		}
+		vol, err := OpenBlockVolume(a.srv, v.Name)
+		if err != nil {
+			clog.Errorf("usage accounting: couldn't open volume %s: %v", v.Name, err)
+			continue
+		}
+		ok, err := vol.mds.ClaimScheduler(lease)
+		if err != nil {
+			clog.Errorf("usage accounting: couldn't claim volume %s: %v", v.Name, err)
+			continue
+		}
+		if !ok {
+			// Another server exports it.
+			deleteUsageMetrics(v.Name)
+			continue
+		}
+		u, err := vol.UpdateUsage()
+		if err != nil {
+			clog.Errorf("usage accounting: couldn't account volume %s: %v", v.Name, err)
+			continue
+		}
+		q, err := vol.mds.GetQuota()
+		if err != nil {
+			clog.Errorf("usage accounting: couldn't get quota of volume %s: %v", v.Name, err)
+		}
+		setUsageMetrics(v.Name, u, q)
+	}
+	return nil
+}
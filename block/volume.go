@@ -1,6 +1,8 @@
 package block
 
 import (
+	"errors"
+
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/blockset"
 	"github.com/coreos/torus/models"
@@ -9,6 +11,10 @@ import (
 
 const VolumeType = "block"
 
+// ErrReplica is returned when trying to write to a mirror replica, which
+// only changes by receiving snapshots from its source until it's promoted.
+var ErrReplica = errors.New("block: volume is a read-only mirror replica")
+
 type BlockVolume struct {
 	srv    *torus.Server
 	mds    blockMetadata
@@ -16,6 +22,16 @@ type BlockVolume struct {
 }
 
 func CreateBlockVolume(mds torus.MetadataService, volume string, size uint64) error {
+	return createBlockVolume(mds, volume, size, "")
+}
+
+// CreateReplicaVolume creates a block volume which is a mirror replica of
+// source from the start.
+func CreateReplicaVolume(mds torus.MetadataService, volume string, size uint64, source string) error {
+	return createBlockVolume(mds, volume, size, source)
+}
+
+func createBlockVolume(mds torus.MetadataService, volume string, size uint64, replicaOf string) error {
 	id, err := mds.NewVolumeID()
 	if err != nil {
 		return err
@@ -29,7 +45,57 @@ func CreateBlockVolume(mds torus.MetadataService, volume string, size uint64) er
 		Id:       uint64(id),
 		Type:     VolumeType,
 		MaxBytes: size,
-	})
+	}, torus.NewINodeRef(id, 1), replicaOf)
+}
+
+// CloneSnapshot creates a new block volume, clone, whose contents start out
+// as those of the snapshot of volume. No data is copied; the clone shares the
+// snapshot's blocks until it overwrites them.
+func CloneSnapshot(mds torus.MetadataService, volume, snapshot, clone string) error {
+	vol, err := mds.GetVolume(volume)
+	if err != nil {
+		return err
+	}
+	if vol.Type != VolumeType {
+		return torus.ErrWrongVolumeType
+	}
+	srcmd, err := createBlockMetadata(mds, vol.Name, torus.VolumeID(vol.Id))
+	if err != nil {
+		return err
+	}
+	snaps, err := srcmd.GetSnapshots()
+	if err != nil {
+		return err
+	}
+	var found *Snapshot
+	for i, x := range snaps {
+		if x.Name == snapshot {
+			found = &snaps[i]
+			break
+		}
+	}
+	if found == nil {
+		return torus.ErrNotExist
+	}
+	id, err := mds.NewVolumeID()
+	if err != nil {
+		return err
+	}
+	ref := torus.INodeRefFromBytes(found.INodeRef)
+	if ref.INode <= 1 {
+		// The snapshot was taken before anything was written.
+		ref = torus.NewINodeRef(id, 1)
+	}
+	blkmd, err := createBlockMetadata(mds, clone, id)
+	if err != nil {
+		return err
+	}
+	return blkmd.CreateBlockVolume(&models.Volume{
+		Name:     clone,
+		Id:       uint64(id),
+		Type:     VolumeType,
+		MaxBytes: vol.MaxBytes,
+	}, ref, "")
 }
 
 func OpenBlockVolume(s *torus.Server, volume string) (*BlockVolume, error) {
@@ -64,17 +130,95 @@ func (s *BlockVolume) SaveSnapshot(name string) error    { return s.mds.SaveSnap
 func (s *BlockVolume) GetSnapshots() ([]Snapshot, error) { return s.mds.GetSnapshots() }
 func (s *BlockVolume) DeleteSnapshot(name string) error  { return s.mds.DeleteSnapshot(name) }
 
+// LockHolder returns the holder of the volume's lock, with its peer address
+// filled in if it's still registered, or nil if the volume is unlocked.
+func (s *BlockVolume) LockHolder() (*LockHolder, error) {
+	h, err := s.mds.GetLockHolder()
+	if err != nil || h == nil {
+		return h, err
+	}
+	peers, err := s.mds.GetPeers()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range peers {
+		if p.UUID == h.UUID {
+			h.Address = p.Address
+		}
+	}
+	return h, nil
+}
+
+// ForceUnlock takes the lock away from h, which must have come from
+// LockHolder, and revokes its lease. The next holder's fencing token keeps
+// any writes h still has in flight from landing.
+func (s *BlockVolume) ForceUnlock(h *LockHolder, by string) error {
+	clog.Noticef("%s is forcing the lock on volume %s away from %s (lease %d)", by, s.volume.Name, h.UUID, h.Lease)
+	return s.mds.ForceUnlock(h, by)
+}
+
+func (s *BlockVolume) GetForcedUnlocks() ([]LockRecord, error) { return s.mds.GetForcedUnlocks() }
+
+// ReplicaOf returns where the volume is mirrored from, or the empty string if
+// it isn't a mirror replica. Replicas can't be opened for writing.
+func (s *BlockVolume) ReplicaOf() (string, error) { return s.mds.GetReplicaOf() }
+
+// SetReplicaOf marks the volume as a replica mirrored from source.
+func (s *BlockVolume) SetReplicaOf(source string) error { return s.mds.SetReplicaOf(source) }
+
+// Promote turns a mirror replica into a regular, writable volume, at the last
+// snapshot mirrored to it. It takes the volume's lock, so it fails with
+// torus.ErrLocked while a snapshot is being received.
+func (s *BlockVolume) Promote() (err error) {
+	source, err := s.mds.GetReplicaOf()
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return torus.ErrInvalid
+	}
+	if _, err = s.mds.Lock(s.srv.Lease()); err != nil {
+		return err
+	}
+	defer func() {
+		unlockErr := s.mds.Unlock()
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+	clog.Noticef("promoting volume %s, a replica of %s", s.volume.Name, source)
+	return s.mds.SetReplicaOf("")
+}
+
+func (s *BlockVolume) checkWritable() error {
+	source, err := s.mds.GetReplicaOf()
+	if err != nil {
+		return err
+	}
+	if source != "" {
+		return ErrReplica
+	}
+	return nil
+}
+
 func (s *BlockVolume) getContext() context.Context {
 	return context.TODO()
 }
 
 func (s *BlockVolume) getOrCreateBlockINode(ref torus.INodeRef) (*models.INode, error) {
+	if ref.INode != 1 {
+		inode, err := s.srv.INodes.GetINode(s.getContext(), ref)
+		if err != nil {
+			return nil, err
+		}
+		// A clone starts out from the INode of the snapshot it was cloned
+		// from, in another volume; its blocks are shared until overwritten.
+		inode.Volume = s.volume.Id
+		return inode, nil
+	}
 	if ref.Volume() != torus.VolumeID(s.volume.Id) {
 		panic("ids managed by metadata didn't match, how is that possible?")
 	}
-	if ref.INode != 1 {
-		return s.srv.INodes.GetINode(s.getContext(), ref)
-	}
 	globals := s.mds.GlobalMetadata()
 	bs, err := blockset.CreateBlocksetFromSpec(globals.DefaultBlockSpec, nil)
 	if err != nil {
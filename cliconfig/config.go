@@ -9,4 +9,5 @@ type Etcd_config struct {
 	EtcdCAFile   string `json:"etcd-ca-file,omitempty"`
 	EtcdCertFile string `json:"etcd-cert-file,omitempty"`
 	EtcdKeyFile  string `json:"etcd-key-file,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
 }
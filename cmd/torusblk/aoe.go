@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"strconv"
@@ -34,6 +35,12 @@ eth0 network interface:
 
 	torusblk aoe vol01 eth0 1 1
 	torusblk aoe vol02 eth0 1 2
+
+Initiators can limit which hosts may reach a volume with its MAC mask list,
+and take it for themselves with its reserve list. Both are kept with the
+volume. --mac-mask replaces the MAC mask list, and --mac-mask= empties it:
+
+	torusblk aoe vol01 eth0 1 1 --mac-mask 52:54:00:12:34:56,52:54:00:12:34:57
 `),
 	Run: func(cmd *cobra.Command, args []string) {
 		err := aoeAction(cmd, args)
@@ -47,11 +54,13 @@ eth0 network interface:
 }
 
 var (
-	aoeFlush string
+	aoeFlush   string
+	aoeMACMask []string
 )
 
 func init() {
 	aoeCommand.Flags().StringVarP(&aoeFlush, "flush", "", "", "flush AOE device (e.g. torsublk aoe --flush e1.1)")
+	aoeCommand.Flags().StringSliceVarP(&aoeMACMask, "mac-mask", "", nil, "MAC addresses of the only initiators to serve, replacing the volume's MAC mask list")
 }
 
 func aoeAction(cmd *cobra.Command, args []string) error {
@@ -84,6 +93,18 @@ func aoeAction(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Failed to parse minor address %q: %v", min, err)
 	}
 
+	var mask []net.HardwareAddr
+	if cmd.Flags().Changed("mac-mask") {
+		mask = []net.HardwareAddr{}
+		for _, m := range aoeMACMask {
+			mac, err := net.ParseMAC(m)
+			if err != nil {
+				return fmt.Errorf("Failed to parse MAC mask address %q: %v", m, err)
+			}
+			mask = append(mask, mac)
+		}
+	}
+
 	blockvol, err := block.OpenBlockVolume(srv, vol)
 	if err != nil {
 		return fmt.Errorf("server doesn't support block volumes: %v", err)
@@ -95,8 +116,9 @@ func aoeAction(cmd *cobra.Command, args []string) error {
 	}
 
 	as, err := aoe.NewServer(blockvol, &aoe.ServerOptions{
-		Major: uint16(major),
-		Minor: uint8(minor),
+		Major:   uint16(major),
+		Minor:   uint8(minor),
+		MACMask: mask,
 	})
 	if err != nil {
 		return fmt.Errorf("Failed to crate AoE server: %v", err)
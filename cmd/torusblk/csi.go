@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/internal/csi"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var (
+	csiCommand = &cobra.Command{
+		Use:   "csi",
+		Short: "serve block volumes to container orchestrators as a CSI plugin",
+		Long: `Serve the Container Storage Interface, so that Kubernetes and other
+container orchestrators can create, snapshot, clone, resize and attach block
+volumes. Run one instance with --node=false to serve the controller, and one
+with --controller=false on every node that attaches volumes; nodes need the
+nbd kernel module.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := csiAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+var (
+	csiEndpoint   string
+	csiNodeID     string
+	csiController bool
+	csiNode       bool
+)
+
+func init() {
+	rootCommand.AddCommand(csiCommand)
+
+	csiCommand.Flags().StringVarP(&csiEndpoint, "endpoint", "", "unix:///csi/csi.sock", "endpoint to serve on, unix://PATH or tcp://HOST:PORT")
+	csiCommand.Flags().StringVarP(&csiNodeID, "node-id", "", "", "name of this node to the orchestrator (default the hostname)")
+	csiCommand.Flags().BoolVarP(&csiController, "controller", "", true, "serve the controller service, which manages volumes")
+	csiCommand.Flags().BoolVarP(&csiNode, "node", "", true, "serve the node service, which attaches volumes to this host")
+}
+
+// listenCSI listens on a CSI endpoint, replacing a stale unix socket.
+func listenCSI(endpoint string) (net.Listener, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("bad endpoint %s: %v", endpoint, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		path := u.Host + u.Path
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	case "tcp":
+		return net.Listen("tcp", u.Host)
+	}
+	return nil, fmt.Errorf("bad endpoint %s: want unix://PATH or tcp://HOST:PORT", endpoint)
+}
+
+func csiAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return torus.ErrUsage
+	}
+	if !csiController && !csiNode {
+		return fmt.Errorf("serve at least one of --controller and --node")
+	}
+	if csiNode && csiNodeID == "" {
+		name, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("couldn't name this node: %v; use --node-id", err)
+		}
+		csiNodeID = name
+	}
+
+	lis, err := listenCSI(csiEndpoint)
+	if err != nil {
+		return fmt.Errorf("can't listen: %v", err)
+	}
+
+	srv := createServer()
+	defer srv.Close()
+
+	driver := csi.NewDriver(srv, csi.Options{
+		Controller: csiController,
+		Node:       csiNode,
+		NodeID:     csiNodeID,
+	})
+	defer driver.Close()
+	s := grpc.NewServer()
+	driver.Register(s)
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		<-signalChan
+		close(stopped)
+		s.Stop()
+	}()
+
+	fmt.Printf("serving CSI plugin %s on %s\n", csi.PluginName, csiEndpoint)
+	if err := s.Serve(lis); err != nil {
+		select {
+		case <-stopped:
+			return nil
+		default:
+			return fmt.Errorf("server exited: %v", err)
+		}
+	}
+	return nil
+}
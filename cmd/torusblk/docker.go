@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/internal/docker"
+	"github.com/coreos/torus/internal/nbd"
+	"github.com/kardianos/osext"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	dockerPluginCommand = &cobra.Command{
+		Use:   "docker-plugin",
+		Short: "serve block volumes to Docker as a volume plugin",
+		Long: `Serve block volumes to Docker as a volume plugin, so that containers can
+use them with "docker run -v NAME:/PATH --volume-driver torus". Create volumes
+with "docker volume create -d torus NAME", with the options:
+
+  -o size=SIZE              size of a new volume (default 1GiB)
+  -o fstype=TYPE            filesystem made on the volume (default ext4)
+  -o write-level=LEVEL      write replication level while attached
+  -o write-cache-size=SIZE  write cache size while attached
+  -o trim=true              mount with discard
+
+Each attached volume is served by a "torusblk nbd" process, so this host needs
+the nbd kernel module.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := dockerPluginAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+var (
+	dockerSocket    string
+	dockerMountRoot string
+)
+
+// dockerAttachTimeout bounds how long a volume takes to attach.
+const dockerAttachTimeout = 10 * time.Second
+
+func init() {
+	rootCommand.AddCommand(dockerPluginCommand)
+
+	dockerPluginCommand.Flags().StringVarP(&dockerSocket, "socket", "", "/run/docker/plugins/torus.sock", "unix socket to serve on; Docker names the plugin after it")
+	dockerPluginCommand.Flags().StringVarP(&dockerMountRoot, "mount-root", "", "/var/lib/torus/docker", "directory to mount volumes under")
+}
+
+// nbdAttachment is a volume attached by a torusblk nbd process.
+type nbdAttachment struct {
+	dev    string
+	exited chan error
+}
+
+func (a *nbdAttachment) Device() string { return a.dev }
+
+func (a *nbdAttachment) Detach() error {
+	if err := nbd.Detach(a.dev); err != nil {
+		return err
+	}
+	select {
+	case <-a.exited:
+		return nil
+	case <-time.After(dockerAttachTimeout):
+		return fmt.Errorf("%s didn't detach within %s", a.dev, dockerAttachTimeout)
+	}
+}
+
+// configArgs returns the flags this process was given to reach the cluster,
+// for the processes it starts.
+func configArgs() []string {
+	var args []string
+	rootCommand.PersistentFlags().VisitAll(func(f *flag.Flag) {
+		if f.Changed && f.Name != "http" {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// dockerAttach attaches a volume by starting torusblk nbd, as the
+// FlexVolume driver does, so that each volume gets its own write level and
+// cache.
+func dockerAttach(volume string, opts *docker.VolumeOptions) (docker.Attachment, error) {
+	me, err := osext.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dev, err := nbd.FindDevice()
+	if err != nil {
+		return nil, err
+	}
+	args := append(configArgs(), "nbd", volume, dev)
+	if opts.WriteLevel != "" {
+		args = append(args, "--write-level", opts.WriteLevel)
+	}
+	if opts.WriteCacheSize != "" {
+		args = append(args, "--write-cache-size", opts.WriteCacheSize)
+	}
+	cmd := exec.Command(me, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	a := &nbdAttachment{dev: dev, exited: make(chan error, 1)}
+	go func() {
+		a.exited <- cmd.Wait()
+	}()
+
+	deadline := time.After(dockerAttachTimeout)
+	for !nbd.Connected(dev) {
+		select {
+		case err := <-a.exited:
+			return nil, fmt.Errorf("couldn't attach %s: torusblk nbd exited: %v", volume, err)
+		case <-deadline:
+			cmd.Process.Kill()
+			return nil, fmt.Errorf("%s wasn't attached within %s", volume, dockerAttachTimeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return a, nil
+}
+
+func dockerPluginAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return torus.ErrUsage
+	}
+	if err := os.MkdirAll(filepath.Dir(dockerSocket), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(dockerSocket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lis, err := net.Listen("unix", dockerSocket)
+	if err != nil {
+		return fmt.Errorf("can't listen: %v", err)
+	}
+
+	srv := createServer()
+	defer srv.Close()
+
+	plugin := docker.NewPlugin(srv, docker.Options{
+		Root:   dockerMountRoot,
+		Attach: dockerAttach,
+	})
+	defer func() {
+		if err := plugin.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't detach volumes: %v\n", err)
+		}
+	}()
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		<-signalChan
+		close(stopped)
+		lis.Close()
+	}()
+
+	fmt.Printf("serving Docker volume plugin on %s\n", dockerSocket)
+	if err := http.Serve(lis, plugin); err != nil {
+		select {
+		case <-stopped:
+			return nil
+		default:
+			return fmt.Errorf("server exited: %v", err)
+		}
+	}
+	return nil
+}
@@ -6,12 +6,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
 	"time"
 
 	"github.com/coreos/go-systemd/dbus"
 	"github.com/coreos/go-systemd/unit"
 	"github.com/coreos/torus"
+	"github.com/coreos/torus/internal/mount"
 	"github.com/coreos/torus/internal/nbd"
 	godbus "github.com/godbus/dbus"
 	"github.com/kardianos/osext"
@@ -22,6 +22,7 @@ type VolumeData struct {
 	VolumeName     string `json:"volume"`
 	Trim           bool   `json:"trim"`
 	Etcd           string `json:"etcd"`
+	Namespace      string `json:"namespace"`
 	FSType         string `json:"kubernetes.io/fsType"`
 	ReadWrite      string `json:"kubernetes.io/readwrite"`
 	WriteLevel     string `json:"writeLevel"`
@@ -183,6 +184,9 @@ func attachAction(cmd *cobra.Command, args []string) {
 	if vol.WriteCacheSize != "" {
 		cmdList = append(cmdList, []string{"--write-cache-size", vol.WriteCacheSize}...)
 	}
+	if vol.Namespace != "" {
+		cmdList = append(cmdList, []string{"--namespace", vol.Namespace}...)
+	}
 
 	ch := make(chan string)
 
@@ -344,20 +348,9 @@ func flexprepvolAction(cmd *cobra.Command, args []string) {
 	}
 	dev := args[0]
 	fstype := args[1]
-	out, err := exec.Command("blkid", "-p", dev).Output()
-	if err != nil {
-		// Not formatted
-		out, err := exec.Command("mkfs", "-t", fstype, dev).CombinedOutput()
-		if err != nil {
-			fmt.Println(string(out))
-			os.Exit(1)
-		}
-	} else {
-		if !strings.Contains(string(out), fstype) {
-			// wrong FS type, this is bad
-			fmt.Println("unexpected FS Type")
-			os.Exit(1)
-		}
+	if err := mount.Format(dev, fstype); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 	os.Exit(0)
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/block/iscsi"
+)
+
+var (
+	iscsiCommand = &cobra.Command{
+		Use:   "iscsi VOLUME...",
+		Short: "serve block volumes as the LUNs of an iSCSI target",
+		Long: strings.TrimSpace(`
+Serve block volumes as the LUNs of one iSCSI target, numbered from 0 in the
+order given. The target is named after the first volume unless --target is
+given. The volumes are opened when an initiator logs in to the target, and
+closed when the last logs out.
+
+	torusblk iscsi db-data db-wal
+`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := iscsiAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	iscsiServeCommand = &cobra.Command{
+		Use:   "iscsiserve",
+		Short: "serve every block volume as an iSCSI target",
+		Long: strings.TrimSpace(`
+Serve every block volume as an iSCSI target of one LUN, named
+` + iscsi.TargetPrefix + `VOLUME. Initiators can discover them all with
+SendTargets.
+`),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := iscsiServeAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+var (
+	iscsiListenAddress string
+	iscsiTarget        string
+)
+
+func init() {
+	rootCommand.AddCommand(iscsiCommand)
+	rootCommand.AddCommand(iscsiServeCommand)
+
+	listen := fmt.Sprintf("0.0.0.0:%d", iscsi.DefaultPort)
+	iscsiCommand.Flags().StringVarP(&iscsiListenAddress, "listen", "l", listen, "iSCSI target listen address")
+	iscsiCommand.Flags().StringVarP(&iscsiTarget, "target", "", "", "name of the target (default "+iscsi.TargetPrefix+"VOLUME)")
+	iscsiServeCommand.Flags().StringVarP(&iscsiListenAddress, "listen", "l", listen, "iSCSI target listen address")
+}
+
+func iscsiAction(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return torus.ErrUsage
+	}
+	srv := createServer()
+	defer srv.Close()
+	for _, v := range args {
+		if _, err := block.OpenBlockVolume(srv, v); err != nil {
+			return fmt.Errorf("can't open block volume %s: %v", v, err)
+		}
+	}
+	target := iscsiTarget
+	if target == "" {
+		target = iscsi.TargetPrefix + args[0]
+	}
+	return serveISCSI(&lunFinder{srv: srv, target: target, volumes: args})
+}
+
+func iscsiServeAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return torus.ErrUsage
+	}
+	srv := createServer()
+	defer srv.Close()
+	return serveISCSI(&targetFinder{srv})
+}
+
+func serveISCSI(finder iscsi.TargetFinder) error {
+	server, err := iscsi.NewServer(iscsiListenAddress, finder)
+	if err != nil {
+		return fmt.Errorf("can't start server: %v", err)
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	interrupted := make(chan struct{})
+	go func() {
+		<-signalChan
+		fmt.Println("\nReceived an interrupt, closing sessions...")
+		close(interrupted)
+		server.Close()
+	}()
+
+	fmt.Printf("Serving iSCSI on %s\n", server.Addr())
+	err = server.Serve()
+	// Wait for the sessions to close their volumes.
+	server.Close()
+	select {
+	case <-interrupted:
+		return nil
+	default:
+		return fmt.Errorf("server exited: %v", err)
+	}
+}
+
+// iscsiDevice serves a BlockFile as a LUN, trimming whole blocks.
+type iscsiDevice struct {
+	*block.BlockFile
+	blockSize uint32
+}
+
+func (d *iscsiDevice) BlockSize() uint32 { return d.blockSize }
+
+func openLUN(srv *torus.Server, name string) (*iscsi.LUN, error) {
+	blockvol, err := block.OpenBlockVolume(srv, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := blockvol.OpenBlockFile()
+	if err != nil {
+		return nil, err
+	}
+	return &iscsi.LUN{
+		Name: name,
+		Device: &iscsiDevice{
+			BlockFile: f,
+			blockSize: uint32(srv.MDS.GlobalMetadata().BlockSize),
+		},
+	}, nil
+}
+
+// lunFinder serves volumes as the LUNs of one target.
+type lunFinder struct {
+	srv     *torus.Server
+	target  string
+	volumes []string
+}
+
+func (f *lunFinder) ListTargets() ([]string, error) {
+	return []string{f.target}, nil
+}
+
+func (f *lunFinder) OpenTarget(name string) ([]*iscsi.LUN, error) {
+	if name != f.target {
+		return nil, iscsi.ErrNoTarget
+	}
+	var luns []*iscsi.LUN
+	for _, v := range f.volumes {
+		lun, err := openLUN(f.srv, v)
+		if err != nil {
+			for _, l := range luns {
+				l.Device.Close()
+			}
+			return nil, fmt.Errorf("volume %s: %v", v, err)
+		}
+		luns = append(luns, lun)
+	}
+	return luns, nil
+}
+
+// targetFinder serves each block volume as a target.
+type targetFinder struct {
+	srv *torus.Server
+}
+
+func (f *targetFinder) ListTargets() ([]string, error) {
+	vols, _, err := f.srv.MDS.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, v := range vols {
+		if v.Type == block.VolumeType {
+			names = append(names, iscsi.TargetPrefix+v.Name)
+		}
+	}
+	return names, nil
+}
+
+func (f *targetFinder) OpenTarget(name string) ([]*iscsi.LUN, error) {
+	if !strings.HasPrefix(name, iscsi.TargetPrefix) {
+		return nil, iscsi.ErrNoTarget
+	}
+	lun, err := openLUN(f.srv, strings.TrimPrefix(name, iscsi.TargetPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return []*iscsi.LUN{lun}, nil
+}
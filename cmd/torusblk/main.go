@@ -14,6 +14,7 @@ import (
 
 	// Register all the drivers.
 	_ "github.com/coreos/torus/metadata/etcd"
+	_ "github.com/coreos/torus/metadata/raft"
 	_ "github.com/coreos/torus/storage"
 )
 
@@ -86,7 +87,7 @@ func configureServer(cmd *cobra.Command, args []string) {
 }
 
 func createServer() *torus.Server {
-	srv, err := torus.NewServer(cfg, "etcd", "temp")
+	srv, err := torus.NewServer(cfg, flagconfig.MetadataServiceName(), "temp")
 	if err != nil {
 		fmt.Printf("Couldn't start: %s\n", err)
 		os.Exit(1)
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
 
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/block"
@@ -45,6 +50,10 @@ var (
 var (
 	serveListenAddress string
 	detachDevice       string
+	tlsCertFile        string
+	tlsKeyFile         string
+	tlsClientCAFile    string
+	tlsAllowFile       string
 )
 
 func init() {
@@ -53,6 +62,10 @@ func init() {
 
 	nbdCommand.Flags().StringVarP(&detachDevice, "detach", "d", "", "detach an NBD device from a block volume. (e.g. torsublk nbd -d /dev/nbd0)")
 	nbdServeCommand.Flags().StringVarP(&serveListenAddress, "listen", "l", "0.0.0.0:10809", "nbd server listen address")
+	nbdServeCommand.Flags().StringVarP(&tlsCertFile, "tls-cert", "", "", "certificate to serve with; clients must then start TLS")
+	nbdServeCommand.Flags().StringVarP(&tlsKeyFile, "tls-key", "", "", "key for the certificate")
+	nbdServeCommand.Flags().StringVarP(&tlsClientCAFile, "tls-client-ca", "", "", "CA certificates to verify client certificates against; clients must then present one")
+	nbdServeCommand.Flags().StringVarP(&tlsAllowFile, "tls-allow-file", "", "", "file of lines \"COMMON-NAME VOLUME...\" listing the volumes each client certificate may use (\"*\" for all)")
 }
 
 func nbdAction(cmd *cobra.Command, args []string) error {
@@ -131,6 +144,12 @@ func connectNBD(srv *torus.Server, f *block.BlockFile, target string, closer cha
 		n.Disconnect()
 	}(handle)
 
+	f.OnResize(func(size uint64) {
+		if err := handle.SetSize(int64(size)); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't resize %s: %v\n", target, err)
+		}
+	})
+
 	err = handle.Serve()
 	if err != nil {
 		return fmt.Errorf("error from nbd server: %s", err)
@@ -142,13 +161,52 @@ type finder struct {
 	srv *torus.Server
 }
 
+// FindDevice opens the block volume, or if name is of the form
+// VOLUME@SNAPSHOT, the snapshot of it, read-only.
 func (f *finder) FindDevice(name string) (nbd.Device, error) {
-	blockvol, err := block.OpenBlockVolume(f.srv, name)
+	p := strings.SplitN(name, "@", 2)
+	blockvol, err := block.OpenBlockVolume(f.srv, p[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var file *block.BlockFile
+	if len(p) == 2 {
+		file, err = blockvol.OpenSnapshot(p[1])
+	} else {
+		file, err = blockvol.OpenBlockFile()
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &nbdDevice{
+		BlockFile: file,
+		blockSize: uint32(f.srv.MDS.GlobalMetadata().BlockSize),
+		readOnly:  len(p) == 2,
+	}, nil
+}
 
-	return blockvol.OpenBlockFile()
+// nbdDevice serves a BlockFile, with its holes and block size, over NBD.
+type nbdDevice struct {
+	*block.BlockFile
+	blockSize uint32
+	readOnly  bool
+}
+
+func (d *nbdDevice) BlockSize() uint32 { return d.blockSize }
+
+func (d *nbdDevice) ReadOnly() bool { return d.readOnly }
+
+func (d *nbdDevice) Extents(off, length int64) ([]nbd.Extent, error) {
+	extents, err := d.BlockFile.Extents(off, length)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nbd.Extent, len(extents))
+	for i, e := range extents {
+		out[i] = nbd.Extent{Length: e.Length, Hole: e.Hole}
+	}
+	return out, nil
 }
 
 func (f *finder) ListDevices() ([]string, error) {
@@ -161,6 +219,20 @@ func (f *finder) ListDevices() ([]string, error) {
 
 	for _, v := range vols {
 		volnames = append(volnames, v.Name)
+		if v.Type != block.VolumeType {
+			continue
+		}
+		blockvol, err := block.OpenBlockVolume(f.srv, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		snaps, err := blockvol.GetSnapshots()
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range snaps {
+			volnames = append(volnames, v.Name+"@"+x.Name)
+		}
 	}
 
 	return volnames, nil
@@ -182,6 +254,10 @@ func nbdServeAction(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("can't start server: %v", err)
 	}
+	if err := configureTLS(server); err != nil {
+		server.Close()
+		return err
+	}
 
 	// TODO: sync all conns
 	go func() {
@@ -195,3 +271,91 @@ func nbdServeAction(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+func configureTLS(server *nbd.NBDServer) error {
+	if tlsCertFile == "" {
+		if tlsKeyFile != "" || tlsClientCAFile != "" || tlsAllowFile != "" {
+			return fmt.Errorf("--tls-key, --tls-client-ca and --tls-allow-file need --tls-cert")
+		}
+		return nil
+	}
+	if tlsAllowFile != "" && tlsClientCAFile == "" {
+		return fmt.Errorf("--tls-allow-file needs --tls-client-ca to verify clients")
+	}
+	cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		return fmt.Errorf("couldn't load cert/key: %v", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if tlsClientCAFile != "" {
+		caPem, err := ioutil.ReadFile(tlsClientCAFile)
+		if err != nil {
+			return fmt.Errorf("couldn't load client CA certs: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return fmt.Errorf("no certificates found in %s", tlsClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	var authorize nbd.Authorizer
+	if tlsAllowFile != "" {
+		allow, err := readAllowFile(tlsAllowFile)
+		if err != nil {
+			return err
+		}
+		authorize = allow.authorize
+	}
+	server.EnableTLS(cfg, authorize)
+	return nil
+}
+
+// allowList maps the common names of client certificates to the volumes
+// they may use.
+type allowList map[string]map[string]bool
+
+func readAllowFile(path string) (allowList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	allow := make(allowList)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want COMMON-NAME VOLUME...", path, n)
+		}
+		vols := allow[fields[0]]
+		if vols == nil {
+			vols = make(map[string]bool)
+			allow[fields[0]] = vols
+		}
+		for _, v := range fields[1:] {
+			vols[v] = true
+		}
+	}
+	return allow, scanner.Err()
+}
+
+func (a allowList) authorize(cs tls.ConnectionState, export string) bool {
+	if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return false
+	}
+	vols := a[cs.VerifiedChains[0][0].Subject.CommonName]
+	// A client allowed a volume may also use its snapshots.
+	volume := strings.SplitN(export, "@", 2)[0]
+	return vols["*"] || vols[export] || vols[volume]
+}
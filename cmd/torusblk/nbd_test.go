@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus/internal/nbd"
+)
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadAllowFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torusblk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeFile(t, dir, "allow", `# clients
+alice vol1 vol2 # both
+bob   vol3
+
+alice vol4
+admin *
+`)
+	allow, err := readAllowFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"alice": {"vol1", "vol2", "vol4"},
+		"bob":   {"vol3"},
+		"admin": {"*"},
+	}
+	if len(allow) != len(want) {
+		t.Fatalf("read %v, want %v", allow, want)
+	}
+	for cn, vols := range want {
+		if len(allow[cn]) != len(vols) {
+			t.Errorf("%s may use %v, want %v", cn, allow[cn], vols)
+		}
+		for _, v := range vols {
+			if !allow[cn][v] {
+				t.Errorf("%s may not use %s", cn, v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"alice\n", "vol1 # alice\nbob\n"} {
+		path := writeFile(t, dir, "bad", bad)
+		if _, err := readAllowFile(path); err == nil {
+			t.Errorf("read %q without error", bad)
+		}
+	}
+	if _, err := readAllowFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("read a missing file without error")
+	}
+}
+
+// verified returns the connection state of a client verified as cn.
+func verified(cn string) tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestAuthorize(t *testing.T) {
+	allow := allowList{
+		"alice": {"vol": true},
+		"admin": {"*": true},
+	}
+	for i, tt := range []struct {
+		cs     tls.ConnectionState
+		export string
+		want   bool
+	}{
+		{verified("alice"), "vol", true},
+		{verified("alice"), "vol@snap", true},
+		{verified("alice"), "volume", false},
+		{verified("alice"), "other", false},
+		{verified("alice"), "other@vol", false},
+		{verified("admin"), "other", true},
+		{verified("admin"), "other@snap", true},
+		{verified("bob"), "vol", false},
+		// Unverified clients get nothing, whatever they claim to be.
+		{tls.ConnectionState{PeerCertificates: verified("admin").VerifiedChains[0]}, "vol", false},
+		{tls.ConnectionState{}, "vol", false},
+	} {
+		if got := allow.authorize(tt.cs, tt.export); got != tt.want {
+			t.Errorf("%d: authorize(%q) = %v, want %v", i, tt.export, got, tt.want)
+		}
+	}
+}
+
+// writeCert writes a self-signed certificate and its key as PEM.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "torusblk"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = writeFile(t, dir, "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile = writeFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+	return certFile, keyFile
+}
+
+func TestConfigureTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torusblk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := writeCert(t, dir)
+	allow := writeFile(t, dir, "allow", "alice vol\n")
+	badAllow := writeFile(t, dir, "bad-allow", "alice\n")
+	notPem := writeFile(t, dir, "not-pem", "hello\n")
+
+	server, err := nbd.NewNBDServer("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	defer func() {
+		tlsCertFile, tlsKeyFile, tlsClientCAFile, tlsAllowFile = "", "", "", ""
+	}()
+	for i, tt := range []struct {
+		cert, key, ca, allow string
+		ok                   bool
+	}{
+		{"", "", "", "", true},
+		{cert, key, "", "", true},
+		{cert, key, cert, "", true},
+		{cert, key, cert, allow, true},
+		// Client options without a certificate to serve with.
+		{"", key, "", "", false},
+		{"", "", cert, "", false},
+		{"", "", cert, allow, false},
+		// An allow-list can't be checked without verifying clients.
+		{cert, key, "", allow, false},
+		{cert, "", "", "", false},
+		{cert, key, notPem, "", false},
+		{cert, key, cert, badAllow, false},
+	} {
+		tlsCertFile, tlsKeyFile, tlsClientCAFile, tlsAllowFile = tt.cert, tt.key, tt.ca, tt.allow
+		if err := configureTLS(server); (err == nil) != tt.ok {
+			t.Errorf("%d: configureTLS returned %v", i, err)
+		}
+	}
+}
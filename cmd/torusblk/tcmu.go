@@ -9,6 +9,7 @@ import (
 
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/block/reservation"
 	"github.com/spf13/cobra"
 
 	"github.com/coreos/torus/internal/tcmu"
@@ -65,7 +66,12 @@ func tcmuAction(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("can't open block volume: %s", err)
 	}
 	defer f.Close()
-	err = torustcmu.ConnectAndServe(f, args[0], closer)
+	// Everything on this host reaches the volume through the one device, so
+	// the host is a single nexus, named by its UUID.
+	res := reservation.New(blockvol)
+	defer res.Close()
+	pr := res.Nexus(srv.MDS.UUID())
+	err = torustcmu.ConnectAndServe(f, args[0], srv.MDS.GlobalMetadata().BlockSize, pr, closer)
 	if err != nil {
 		return fmt.Errorf("failed to serve volume using SCSI: %s", err)
 	}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
 	"github.com/coreos/torus/internal/flagconfig"
 	"github.com/spf13/cobra"
 )
@@ -20,8 +23,24 @@ var blockCreateCommand = &cobra.Command{
 	Run:   volumeCreateBlockAction,
 }
 
+var blockCloneCommand = &cobra.Command{
+	Use:   "clone VOLUME@SNAPSHOT_NAME NEW_VOLUME",
+	Short: "create a writable block volume from a snapshot",
+	Long:  "creates a block volume named NEW_VOLUME with the contents of SNAPSHOT_NAME; the new volume shares blocks with the snapshot until they're overwritten, so nothing is copied",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := blockCloneAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
 func init() {
 	blockCommand.AddCommand(blockCreateCommand)
+	blockCommand.AddCommand(blockCloneCommand)
 	flagconfig.AddConfigFlags(blockCommand.PersistentFlags())
 }
 
@@ -29,3 +48,20 @@ func blockAction(cmd *cobra.Command, args []string) {
 	cmd.Usage()
 	os.Exit(1)
 }
+
+func blockCloneAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return torus.ErrUsage
+	}
+	vol := ParseSnapName(args[0])
+	if vol.Snapshot == "" {
+		return fmt.Errorf("can't clone a volume without a snapshot name, please use the form VOLUME@SNAPSHOT_NAME")
+	}
+	mds := mustConnectToMDS()
+	defer mds.Close()
+	err := block.CloneSnapshot(mds, vol.Volume, vol.Snapshot, args[1])
+	if err != nil {
+		return fmt.Errorf("couldn't clone %s: %v", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceUnlock            bool
+	yesIAmSurePleaseUnlock bool
+)
+
+var (
+	blockLockStatusCommand = &cobra.Command{
+		Use:   "lock-status VOLUME",
+		Short: "show who holds the lock on a block volume",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockLockStatusAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	blockUnlockCommand = &cobra.Command{
+		Use:   "unlock --force VOLUME",
+		Short: "break the lock on a block volume held by a dead client",
+		Long:  "takes the lock on VOLUME away from its holder and revokes the holder's lease, which also drops every other lock it holds. Only use it when the holder is known to be gone.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockUnlockAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+func init() {
+	blockCommand.AddCommand(blockLockStatusCommand)
+	blockCommand.AddCommand(blockUnlockCommand)
+	blockUnlockCommand.Flags().BoolVarP(&forceUnlock, "force", "", false, "break the lock, even though it's held by someone else")
+	blockUnlockCommand.Flags().BoolVarP(&yesIAmSurePleaseUnlock, "yes-i-am-sure", "", false, "don't ask for confirmation")
+}
+
+func printLockHolder(vol string, h *block.LockHolder) {
+	fmt.Printf("Volume: %s\n", vol)
+	if h == nil {
+		fmt.Println("Lock: free")
+		return
+	}
+	addr := h.Address
+	if addr == "" {
+		addr = "(not registered)"
+	}
+	ttl := "unknown"
+	if h.TTL >= 0 {
+		ttl = h.TTL.String()
+	}
+	fmt.Println("Lock: in-use")
+	fmt.Printf("Holder: %s\n", h.UUID)
+	fmt.Printf("Address: %s\n", addr)
+	fmt.Printf("Lease: %x\n", h.Lease)
+	fmt.Printf("Lease TTL: %s\n", ttl)
+	fmt.Printf("Fencing token: %d\n", h.Fence)
+}
+
+func blockLockStatusAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	h, err := blockvol.LockHolder()
+	if err != nil {
+		return fmt.Errorf("couldn't get lock status for block volume %s: %v", vol, err)
+	}
+	printLockHolder(vol, h)
+	recs, err := blockvol.GetForcedUnlocks()
+	if err != nil {
+		return fmt.Errorf("couldn't get forced unlocks for block volume %s: %v", vol, err)
+	}
+	if len(recs) != 0 {
+		last := recs[len(recs)-1]
+		fmt.Printf("Last forced unlock: %s by %s, from %s\n", last.When.Format(time.RFC3339), last.By, last.Holder.UUID)
+	}
+	return nil
+}
+
+func blockUnlockAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 || !forceUnlock {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	h, err := blockvol.LockHolder()
+	if err != nil {
+		return fmt.Errorf("couldn't get lock status for block volume %s: %v", vol, err)
+	}
+	if h == nil {
+		fmt.Printf("block volume %s isn't locked\n", vol)
+		return nil
+	}
+	if !yesIAmSurePleaseUnlock {
+		printLockHolder(vol, h)
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Printf("This will revoke the lease of %s, dropping all of its locks.\nPlease type the volume name to confirm: ", h.UUID)
+		text, _ := reader.ReadString('\n')
+		if strings.TrimSpace(text) != vol {
+			fmt.Println("volume name not entered, exiting")
+			os.Exit(1)
+		}
+	}
+	err = blockvol.ForceUnlock(h, unlockedBy())
+	if err == torus.ErrAgain {
+		return fmt.Errorf("the lock on block volume %s changed hands; check `torusctl block lock-status` again", vol)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't unlock block volume %s: %v", vol, err)
+	}
+	fmt.Printf("unlocked block volume %s\n", vol)
+	return nil
+}
+
+// unlockedBy names the person forcing an unlock, for the audit record.
+func unlockedBy() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "unknown"
+	}
+	return user + "@" + host
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var blockPromoteCommand = &cobra.Command{
+	Use:   "promote VOLUME",
+	Short: "make a mirror replica of a block volume writable, for failover",
+	Long:  "turns VOLUME, a replica kept by torusd --mirror-to, into a regular block volume at the last snapshot mirrored to it. Stop mirroring to it first; mirroring to a promoted volume fails.",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := blockPromoteAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
+func init() {
+	blockCommand.AddCommand(blockPromoteCommand)
+}
+
+func blockPromoteAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	err = blockvol.Promote()
+	switch err {
+	case nil:
+	case torus.ErrInvalid:
+		return fmt.Errorf("block volume %s isn't a mirror replica", vol)
+	case torus.ErrLocked:
+		return fmt.Errorf("block volume %s is locked, a snapshot may be being mirrored to it; try again", vol)
+	default:
+		return fmt.Errorf("couldn't promote block volume %s: %v", vol, err)
+	}
+	fmt.Printf("promoted block volume %s\n", vol)
+	return nil
+}
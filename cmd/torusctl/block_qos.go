@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	qosIOPS           uint64
+	qosIOPSBurst      uint64
+	qosBandwidth      string
+	qosBandwidthBurst string
+	qosClear          bool
+)
+
+var blockQoSCommand = &cobra.Command{
+	Use:   "qos VOLUME",
+	Short: "show or limit the I/O of a block volume",
+	Long:  "shows the I/O limits of VOLUME, or sets them with the flags. Bandwidths are in bytes per second (G,GiB,M,MiB,etc suffixes accepted); a limit of 0 is unlimited. Attached volumes apply new limits within a few seconds.",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := blockQoSAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
+func init() {
+	blockCommand.AddCommand(blockQoSCommand)
+	blockQoSCommand.Flags().Uint64VarP(&qosIOPS, "iops", "", 0, "limit of reads and writes per second")
+	blockQoSCommand.Flags().Uint64VarP(&qosIOPSBurst, "iops-burst", "", 0, "reads and writes allowed at once after a lull (default --iops)")
+	blockQoSCommand.Flags().StringVarP(&qosBandwidth, "bandwidth", "", "0", "limit of bytes read and written per second")
+	blockQoSCommand.Flags().StringVarP(&qosBandwidthBurst, "bandwidth-burst", "", "0", "bytes allowed at once after a lull (default --bandwidth)")
+	blockQoSCommand.Flags().BoolVarP(&qosClear, "clear", "", false, "remove all limits")
+	blockQoSCommand.Flags().BoolVarP(&outputAsSI, "si", "", false, "output sizes in powers of 1000")
+}
+
+func blockQoSAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	if qosClear {
+		if err = blockvol.SetQoS(nil); err != nil {
+			return fmt.Errorf("couldn't clear QoS of block volume %s: %v", vol, err)
+		}
+		return nil
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("iops") && !flags.Changed("iops-burst") && !flags.Changed("bandwidth") && !flags.Changed("bandwidth-burst") {
+		q, err := blockvol.QoS()
+		if err != nil {
+			return fmt.Errorf("couldn't get QoS of block volume %s: %v", vol, err)
+		}
+		if q == nil {
+			q = &block.QoS{}
+		}
+		fmt.Printf("Volume: %s\n", vol)
+		fmt.Printf("IOPS: %s\n", qosLimit(q.IOPS, q.IOPSBurst, func(x uint64) string { return fmt.Sprint(x) }))
+		fmt.Printf("Bandwidth: %s\n", qosLimit(q.Bandwidth, q.BandwidthBurst, func(x uint64) string { return bytesOrIbytes(x, outputAsSI) + "/s" }))
+		return nil
+	}
+	q, err := blockvol.QoS()
+	if err != nil {
+		return fmt.Errorf("couldn't get QoS of block volume %s: %v", vol, err)
+	}
+	if q == nil {
+		q = &block.QoS{}
+	}
+	if flags.Changed("iops") {
+		q.IOPS = qosIOPS
+	}
+	if flags.Changed("iops-burst") {
+		q.IOPSBurst = qosIOPSBurst
+	}
+	if flags.Changed("bandwidth") {
+		if q.Bandwidth, err = humanize.ParseBytes(qosBandwidth); err != nil {
+			return fmt.Errorf("error parsing bandwidth %s: %v", qosBandwidth, err)
+		}
+	}
+	if flags.Changed("bandwidth-burst") {
+		if q.BandwidthBurst, err = humanize.ParseBytes(qosBandwidthBurst); err != nil {
+			return fmt.Errorf("error parsing bandwidth burst %s: %v", qosBandwidthBurst, err)
+		}
+	}
+	if err = blockvol.SetQoS(q); err != nil {
+		return fmt.Errorf("couldn't set QoS of block volume %s: %v", vol, err)
+	}
+	return nil
+}
+
+func qosLimit(limit, burst uint64, format func(uint64) string) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	if burst == 0 {
+		burst = limit
+	}
+	return fmt.Sprintf("%s (burst %s)", format(limit), format(burst))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceShrink   bool
+	resizeTimeout time.Duration
+)
+
+var blockResizeCommand = &cobra.Command{
+	Use:   "resize VOLUME SIZE",
+	Short: "grow or shrink a block volume",
+	Long:  "resizes VOLUME to SIZE bytes (G,GiB,M,MiB,etc suffixes accepted). If the volume is attached, the client it's attached to resizes it and tells the attached device where it can. Shrinking discards the data past SIZE, and needs --force.",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := blockResizeAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
+func init() {
+	blockCommand.AddCommand(blockResizeCommand)
+	blockResizeCommand.Flags().BoolVarP(&forceShrink, "force", "", false, "allow shrinking the volume, discarding the data past its new size")
+	blockResizeCommand.Flags().DurationVarP(&resizeTimeout, "timeout", "", time.Minute, "how long to wait for the client an attached volume is attached to")
+}
+
+func blockResizeAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	size, err := humanize.ParseBytes(args[1])
+	if err != nil {
+		return fmt.Errorf("error parsing size %s: %v", args[1], err)
+	}
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	err = blockvol.Resize(size, forceShrink)
+	switch err {
+	case nil:
+		return nil
+	case block.ErrShrink:
+		return fmt.Errorf("%v; use --force if you're sure", err)
+	case torus.ErrLocked:
+	default:
+		return fmt.Errorf("couldn't resize block volume %s: %v", vol, err)
+	}
+
+	// The volume is attached; leave the resize to its holder.
+	if err = blockvol.RequestResize(size, forceShrink); err != nil {
+		return fmt.Errorf("couldn't request resize of block volume %s: %v", vol, err)
+	}
+	fmt.Printf("block volume %s is attached, waiting for it to be resized...\n", vol)
+	deadline := time.Now().Add(resizeTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		pending, err := blockvol.ResizeRequested()
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			fmt.Printf("resized block volume %s\n", vol)
+			return nil
+		}
+	}
+	return fmt.Errorf("block volume %s wasn't resized within %s; the request stays pending until its client applies it", vol, resizeTimeout)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var sendFrom string
+
+var (
+	blockSendCommand = &cobra.Command{
+		Use:   "send VOLUME@SNAPSHOT_NAME [--from VOLUME@BASE_SNAPSHOT]",
+		Short: "write a snapshot of a block volume to stdout",
+		Long:  "writes a stream of the snapshot to stdout, which can be applied to another volume with `torusctl block receive`. With --from, only the blocks changed since the base snapshot are sent.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockSendAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	blockReceiveCommand = &cobra.Command{
+		Use:   "receive VOLUME",
+		Short: "apply a snapshot stream from stdin to a block volume",
+		Long:  "reads a stream written by `torusctl block send` from stdin and saves its snapshot on VOLUME. A full stream creates VOLUME; an incremental one needs VOLUME to be at the stream's base snapshot.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockReceiveAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+func init() {
+	blockCommand.AddCommand(blockSendCommand)
+	blockCommand.AddCommand(blockReceiveCommand)
+	blockSendCommand.Flags().StringVarP(&sendFrom, "from", "", "", "only send the changes since this snapshot")
+}
+
+func blockSendAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := ParseSnapName(args[0])
+	if vol.Snapshot == "" {
+		return fmt.Errorf("can't send a volume without a snapshot name, please use the form VOLUME@SNAPSHOT_NAME")
+	}
+	var base string
+	if sendFrom != "" {
+		from := ParseSnapName(sendFrom)
+		if from.Snapshot == "" || from.Volume != vol.Volume {
+			return fmt.Errorf("--from must be a snapshot of %s, in the form VOLUME@SNAPSHOT_NAME", vol.Volume)
+		}
+		base = from.Snapshot
+	}
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol.Volume)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol.Volume, err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	if err = blockvol.Send(w, vol.Snapshot, base); err != nil {
+		return fmt.Errorf("couldn't send %s: %v", args[0], err)
+	}
+	return w.Flush()
+}
+
+func blockReceiveAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	r := bufio.NewReader(os.Stdin)
+	h, err := block.ReadStreamHeader(r)
+	if err != nil {
+		return fmt.Errorf("couldn't read stream: %v", err)
+	}
+	srv := createServer()
+	defer srv.Close()
+	if h.Base == "" {
+		err = block.CreateBlockVolume(srv.MDS, args[0], h.Size)
+		if err != nil {
+			return fmt.Errorf("couldn't create block volume %s: %v", args[0], err)
+		}
+	}
+	blockvol, err := block.OpenBlockVolume(srv, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", args[0], err)
+	}
+	if err = blockvol.Receive(h, r); err != nil {
+		if h.Base == "" {
+			// Don't leave a half-received volume behind; its blocks are
+			// left for the GC.
+			if derr := block.DeleteBlockVolume(srv.MDS, args[0]); derr != nil {
+				fmt.Fprintf(os.Stderr, "couldn't delete block volume %s: %v\n", args[0], derr)
+			}
+		}
+		return fmt.Errorf("couldn't receive %s@%s: %v", args[0], h.Snapshot, err)
+	}
+	fmt.Fprintf(os.Stderr, "received %s@%s\n", args[0], h.Snapshot)
+	return nil
+}
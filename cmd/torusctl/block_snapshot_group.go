@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bsnapCreateGroupCommand = &cobra.Command{
+		Use:   "create-group NAME VOLUME...",
+		Short: "snapshot several block volumes at the same instant",
+		Long:  "takes a snapshot called NAME of each VOLUME at once, and records them as the snapshot group NAME",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapCreateGroupAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	bsnapListGroupsCommand = &cobra.Command{
+		Use:   "list-groups",
+		Short: "list snapshot groups",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapListGroupsAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	bsnapRestoreGroupCommand = &cobra.Command{
+		Use:   "restore-group NAME",
+		Short: "restore every volume of a snapshot group to its snapshot",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapRestoreGroupAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	bsnapDeleteGroupCommand = &cobra.Command{
+		Use:   "delete-group NAME",
+		Short: "delete a snapshot group and its snapshots",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapDeleteGroupAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+func init() {
+	blockSnapshotCommand.AddCommand(bsnapCreateGroupCommand)
+	blockSnapshotCommand.AddCommand(bsnapListGroupsCommand)
+	blockSnapshotCommand.AddCommand(bsnapRestoreGroupCommand)
+	blockSnapshotCommand.AddCommand(bsnapDeleteGroupCommand)
+	bsnapListGroupsCommand.Flags().BoolVarP(&outputAsCSV, "csv", "", false, "output as csv instead")
+}
+
+func bsnapCreateGroupAction(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return torus.ErrUsage
+	}
+	mds := mustConnectToMDS()
+	err := block.CreateSnapshotGroup(mds, args[0], args[1:])
+	if err == torus.ErrExists {
+		return fmt.Errorf("couldn't snapshot: a group or snapshot named %s exists", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't snapshot: %v", err)
+	}
+	return nil
+}
+
+func bsnapListGroupsAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return torus.ErrUsage
+	}
+	mds := mustConnectToMDS()
+	groups, err := block.ListSnapshotGroups(mds)
+	if err != nil {
+		return fmt.Errorf("couldn't list snapshot groups: %v", err)
+	}
+	table := NewTableWriter(os.Stdout)
+	table.SetHeader([]string{"Group Name", "Timestamp", "Volumes"})
+	for _, g := range groups {
+		var vols []string
+		for _, m := range g.Volumes {
+			vols = append(vols, m.Name)
+		}
+		table.Append([]string{
+			g.Name,
+			g.When.Format(time.RFC3339),
+			strings.Join(vols, " "),
+		})
+	}
+	if outputAsCSV {
+		table.RenderCSV()
+		return nil
+	}
+	table.Render()
+	return nil
+}
+
+func bsnapRestoreGroupAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	srv := createServer()
+	defer srv.Close()
+	err := block.RestoreSnapshotGroup(srv, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't restore snapshot group: %v", err)
+	}
+	return nil
+}
+
+func bsnapDeleteGroupAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	mds := mustConnectToMDS()
+	err := block.DeleteSnapshotGroup(mds, args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't delete snapshot group: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bsnapPolicyCommand = &cobra.Command{
+		Use:   "policy",
+		Short: "manage the snapshot schedules of block volumes",
+		Run:   blockAction,
+	}
+
+	bsnapPolicySetCommand = &cobra.Command{
+		Use:   "set VOLUME POLICY",
+		Short: "set the snapshot schedule of a block volume",
+		Long:  `sets the policy by which torusd snapshots VOLUME, such as "hourly keep 24, daily keep 7". Each rule is hourly, daily, weekly or a duration such as 30m, and how many of its snapshots to keep. Scheduled snapshots are named auto-<schedule>-<time>; other snapshots are left alone.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapPolicySetAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	bsnapPolicyClearCommand = &cobra.Command{
+		Use:   "clear VOLUME",
+		Short: "stop scheduled snapshots of a block volume",
+		Long:  "removes the snapshot policy of VOLUME. Snapshots already taken are kept.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapPolicyClearAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	bsnapPolicyListCommand = &cobra.Command{
+		Use:   "list",
+		Short: "list the snapshot schedules of block volumes",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := bsnapPolicyListAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+func init() {
+	blockSnapshotCommand.AddCommand(bsnapPolicyCommand)
+	bsnapPolicyCommand.AddCommand(bsnapPolicySetCommand)
+	bsnapPolicyCommand.AddCommand(bsnapPolicyClearCommand)
+	bsnapPolicyCommand.AddCommand(bsnapPolicyListCommand)
+	bsnapPolicyListCommand.Flags().BoolVarP(&outputAsCSV, "csv", "", false, "output as csv instead")
+}
+
+func bsnapPolicySetAction(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	p, err := block.ParseSnapshotPolicy(strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	if err = blockvol.SetSnapshotPolicy(p); err != nil {
+		return fmt.Errorf("couldn't set snapshot policy of block volume %s: %v", vol, err)
+	}
+	return nil
+}
+
+func bsnapPolicyClearAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	if err = blockvol.SetSnapshotPolicy(nil); err != nil {
+		return fmt.Errorf("couldn't clear snapshot policy of block volume %s: %v", vol, err)
+	}
+	return nil
+}
+
+func bsnapPolicyListAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return torus.ErrUsage
+	}
+	srv := createServer()
+	defer srv.Close()
+	vols, _, err := srv.MDS.GetVolumes()
+	if err != nil {
+		return fmt.Errorf("couldn't list volumes: %v", err)
+	}
+	table := NewTableWriter(os.Stdout)
+	table.SetHeader([]string{"Volume Name", "Snapshot Policy"})
+	for _, x := range vols {
+		if x.Type != block.VolumeType {
+			continue
+		}
+		blockvol, err := block.OpenBlockVolume(srv, x.Name)
+		if err != nil {
+			return fmt.Errorf("couldn't open block volume %s: %v", x.Name, err)
+		}
+		p, err := blockvol.SnapshotPolicy()
+		if err != nil {
+			return fmt.Errorf("couldn't get snapshot policy of block volume %s: %v", x.Name, err)
+		}
+		if len(p) == 0 {
+			continue
+		}
+		table.Append([]string{x.Name, p.String()})
+	}
+	if outputAsCSV {
+		table.RenderCSV()
+	} else {
+		table.Render()
+	}
+	return nil
+}
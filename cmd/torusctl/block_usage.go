@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var refreshUsage bool
+
+var (
+	blockUsageCommand = &cobra.Command{
+		Use:   "usage VOLUME",
+		Short: "show the space a block volume takes up",
+		Long:  "shows the space taken up by the current contents of VOLUME, by its snapshots and by their replicas, as last accounted by torusd.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockUsageAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+
+	blockQuotaCommand = &cobra.Command{
+		Use:   "quota VOLUME SIZE|none",
+		Short: "limit the space a block volume may take up",
+		Long:  "sets a hard quota of SIZE bytes (G,GiB,M,MiB,etc suffixes accepted) on the space allocated by VOLUME and its snapshots, before replication. Writes which would allocate blocks past it fail, and once the volume is over it, all writes fail until space is freed. `none` removes the quota.",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := blockQuotaAction(cmd, args)
+			if err == torus.ErrUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				die("%v", err)
+			}
+		},
+	}
+)
+
+func init() {
+	blockCommand.AddCommand(blockUsageCommand)
+	blockCommand.AddCommand(blockQuotaCommand)
+	blockUsageCommand.Flags().BoolVarP(&refreshUsage, "refresh", "", false, "account the volume now, instead of showing the last accounting")
+	blockUsageCommand.Flags().BoolVarP(&outputAsSI, "si", "", false, "output sizes in powers of 1000")
+}
+
+func blockUsageAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	var u *block.Usage
+	if refreshUsage {
+		u, err = blockvol.UpdateUsage()
+	} else {
+		u, err = blockvol.Usage()
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get usage of block volume %s: %v", vol, err)
+	}
+	q, err := blockvol.Quota()
+	if err != nil {
+		return fmt.Errorf("couldn't get quota of block volume %s: %v", vol, err)
+	}
+	fmt.Printf("Volume: %s\n", vol)
+	if u == nil {
+		fmt.Println("Not accounted yet; use --refresh")
+	} else {
+		fmt.Printf("Live: %s\n", bytesOrIbytes(u.Live, outputAsSI))
+		fmt.Printf("Snapshots: %s\n", bytesOrIbytes(u.Snapshots, outputAsSI))
+		fmt.Printf("Replicas: %s\n", bytesOrIbytes(u.Replicas, outputAsSI))
+		fmt.Printf("Total stored: %s\n", bytesOrIbytes(u.Stored(), outputAsSI))
+		fmt.Printf("Accounted: %s\n", u.When.Format(time.RFC3339))
+	}
+	if q == 0 {
+		fmt.Println("Quota: none")
+	} else {
+		fmt.Printf("Quota: %s\n", bytesOrIbytes(q, outputAsSI))
+	}
+	return nil
+}
+
+func blockQuotaAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return torus.ErrUsage
+	}
+	vol := args[0]
+	var quota uint64
+	if args[1] != "none" {
+		var err error
+		quota, err = humanize.ParseBytes(args[1])
+		if err != nil {
+			return fmt.Errorf("error parsing size %s: %v", args[1], err)
+		}
+		if quota == 0 {
+			return fmt.Errorf("a quota of 0 bytes would stop all writes; use `none` to remove the quota")
+		}
+	}
+	srv := createServer()
+	defer srv.Close()
+	blockvol, err := block.OpenBlockVolume(srv, vol)
+	if err != nil {
+		return fmt.Errorf("couldn't open block volume %s: %v", vol, err)
+	}
+	if err = blockvol.SetQuota(quota); err != nil {
+		return fmt.Errorf("couldn't set quota of block volume %s: %v", vol, err)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	// Register all the drivers.
 	_ "github.com/coreos/torus/metadata/etcd"
+	_ "github.com/coreos/torus/metadata/raft"
 	_ "github.com/coreos/torus/storage"
 
 	"github.com/dustin/go-humanize"
@@ -22,16 +23,16 @@ func die(why string, args ...interface{}) {
 
 func mustConnectToMDS() torus.MetadataService {
 	cfg := flagconfig.BuildConfigFromFlags()
-	mds, err := torus.CreateMetadataService("etcd", cfg)
+	mds, err := torus.CreateMetadataService(flagconfig.MetadataServiceName(), cfg)
 	if err != nil {
-		die("couldn't connect to etcd: %v", err)
+		die("couldn't connect to metadata service: %v", err)
 	}
 	return mds
 }
 
 func createServer() *torus.Server {
 	cfg := flagconfig.BuildConfigFromFlags()
-	srv, err := torus.NewServer(cfg, "etcd", "temp")
+	srv, err := torus.NewServer(cfg, flagconfig.MetadataServiceName(), "temp")
 	if err != nil {
 		die("Couldn't start: %s\n", err)
 	}
@@ -25,6 +25,7 @@ var (
 	etcdCertFile string
 	etcdKeyFile  string
 	etcdCAFile   string
+	namespace    string
 	config       string
 	profile      string
 	view         bool
@@ -41,6 +42,7 @@ func init() {
 	configCommand.Flags().StringVarP(&etcdCertFile, "etcd-cert-file", "", "", "Certificate to use to authenticate against etcd")
 	configCommand.Flags().StringVarP(&etcdKeyFile, "etcd-key-file", "", "", "Key for Certificate")
 	configCommand.Flags().StringVarP(&etcdCAFile, "etcd-ca-file", "", "", "CA to authenticate etcd against")
+	configCommand.Flags().StringVarP(&namespace, "namespace", "", "", "Namespace of the torus cluster, among those sharing the same etcd")
 	configCommand.Flags().StringVarP(&config, "config", "", "", "path to torus config file")
 	configCommand.Flags().StringVarP(&profile, "profile", "", "default", "profile to use in cli config file")
 	configCommand.Flags().BoolVar(&view, "view", false, "view torus configuration and exit")
@@ -54,6 +56,7 @@ func configAction(cmd *cobra.Command, args []string) {
 		EtcdCertFile: etcdCertFile,
 		EtcdKeyFile:  etcdKeyFile,
 		EtcdCAFile:   etcdCAFile,
+		Namespace:    namespace,
 	}
 
 	if config == "" {
@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 
 	_ "github.com/coreos/torus/metadata/etcd"
+	_ "github.com/coreos/torus/metadata/raft"
 )
 
 var (
@@ -67,7 +68,7 @@ func initAction(cmd *cobra.Command, args []string) {
 	if noMakeRing {
 		ringType = ring.Empty
 	}
-	err = torus.InitMDS("etcd", cfg, md, ringType)
+	err = torus.InitMDS(flagconfig.MetadataServiceName(), cfg, md, ringType)
 	if err != nil {
 		die("error writing metadata: %v", err)
 	}
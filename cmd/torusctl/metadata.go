@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/internal/flagconfig"
+)
+
+var metadataCommand = &cobra.Command{
+	Use:   "metadata",
+	Short: "back up and restore the cluster metadata",
+	Run:   metadataAction,
+}
+
+var metadataExportCommand = &cobra.Command{
+	Use:   "export FILE",
+	Short: "write all cluster metadata to FILE ('-' for stdout)",
+	Long:  "writes the global metadata, ring, volumes and snapshots of the cluster to FILE, so they can be restored with `torusctl metadata import`",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := metadataExportAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
+var metadataImportCommand = &cobra.Command{
+	Use:   "import FILE",
+	Short: "restore cluster metadata from an export in FILE",
+	Long:  "restores the metadata written by `torusctl metadata export` into an empty metadata service, in place of `torusctl init`",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := metadataImportAction(cmd, args)
+		if err == torus.ErrUsage {
+			cmd.Usage()
+			os.Exit(1)
+		} else if err != nil {
+			die("%v", err)
+		}
+	},
+}
+
+func init() {
+	metadataCommand.AddCommand(metadataExportCommand)
+	metadataCommand.AddCommand(metadataImportCommand)
+}
+
+func metadataAction(cmd *cobra.Command, args []string) {
+	cmd.Usage()
+	os.Exit(1)
+}
+
+func metadataExportAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	cfg := flagconfig.BuildConfigFromFlags()
+	exp, err := torus.ExportMDS(flagconfig.MetadataServiceName(), cfg)
+	if err != nil {
+		return fmt.Errorf("couldn't export metadata: %v", err)
+	}
+	b, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		return err
+	}
+	output, err := getWriterFromArg(args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't open output: %v", err)
+	}
+	if f, ok := output.(*os.File); ok && f != os.Stdout {
+		defer f.Close()
+	}
+	_, err = output.Write(append(b, '\n'))
+	return err
+}
+
+func metadataImportAction(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return torus.ErrUsage
+	}
+	input, err := getReaderFromArg(args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't open input: %v", err)
+	}
+	defer input.Close()
+	var exp torus.MetadataExport
+	if err = json.NewDecoder(input).Decode(&exp); err != nil {
+		return fmt.Errorf("couldn't read export: %v", err)
+	}
+	cfg := flagconfig.BuildConfigFromFlags()
+	err = torus.ImportMDS(flagconfig.MetadataServiceName(), cfg, &exp)
+	if err == torus.ErrExists {
+		return fmt.Errorf("metadata already exists; `torusctl wipe` it first")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't import metadata: %v", err)
+	}
+	fmt.Printf("imported %d volumes\n", len(exp.Volumes))
+	return nil
+}
@@ -113,7 +113,7 @@ func ringChangeAction(cmd *cobra.Command, args []string) {
 		die("couldn't create new ring: %v", err)
 	}
 	cfg := flagconfig.BuildConfigFromFlags()
-	err = torus.SetRing("etcd", cfg, newRing)
+	err = torus.SetRing(flagconfig.MetadataServiceName(), cfg, newRing)
 	if err != nil {
 		die("couldn't set new ring: %v", err)
 	}
@@ -46,6 +46,7 @@ func init() {
 	rootCommand.AddCommand(wipeCommand)
 	rootCommand.AddCommand(configCommand)
 	rootCommand.AddCommand(completionCommand)
+	rootCommand.AddCommand(metadataCommand)
 	flagconfig.AddConfigFlags(rootCommand.PersistentFlags())
 }
 
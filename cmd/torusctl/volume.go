@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/coreos/torus/block"
 	"github.com/dustin/go-humanize"
@@ -17,9 +18,15 @@ var volumeCommand = &cobra.Command{
 var volumeDeleteCommand = &cobra.Command{
 	Use:   "delete NAME",
 	Short: "delete a volume in the cluster",
+	Long:  "moves the volume NAME to the trash, from which it can be undeleted until the retention passes",
 	Run:   volumeDeleteAction,
 }
 
+var (
+	deleteRetention time.Duration
+	deletePurge     bool
+)
+
 var volumeListCommand = &cobra.Command{
 	Use:   "list",
 	Short: "list volumes in the cluster",
@@ -37,6 +44,8 @@ func init() {
 	volumeCommand.AddCommand(volumeDeleteCommand)
 	volumeCommand.AddCommand(volumeListCommand)
 	volumeCommand.AddCommand(volumeCreateBlockCommand)
+	volumeDeleteCommand.Flags().DurationVarP(&deleteRetention, "retention", "", block.DefaultTrashRetention, "how long to keep the volume in the trash")
+	volumeDeleteCommand.Flags().BoolVarP(&deletePurge, "purge", "", false, "delete the volume for good, skipping the trash")
 	volumeListCommand.Flags().BoolVarP(&outputAsCSV, "csv", "", false, "output as csv instead")
 	volumeListCommand.Flags().BoolVarP(&outputAsSI, "si", "", false, "output sizes in powers of 1000")
 }
@@ -57,11 +66,22 @@ func volumeListAction(cmd *cobra.Command, args []string) {
 		die("error listing volumes: %v\n", err)
 	}
 	table := NewTableWriter(os.Stdout)
-	table.SetHeader([]string{"Volume Name", "Size", "Type", "Status"})
+	table.SetHeader([]string{"Volume Name", "Size", "Used", "Type", "Status"})
 	for _, x := range vols {
+		used := "-"
+		if x.Type == block.VolumeType {
+			u, err := block.GetUsage(mds, x.Name)
+			if err != nil {
+				die("error getting usage of volume %s: %v\n", x.Name, err)
+			}
+			if u != nil {
+				used = bytesOrIbytes(u.Allocated(), outputAsSI)
+			}
+		}
 		table.Append([]string{
 			x.Name,
 			bytesOrIbytes(x.MaxBytes, outputAsSI),
+			used,
 			x.Type,
 			mds.GetLockStatus(x.Id),
 		})
@@ -86,7 +106,11 @@ func volumeDeleteAction(cmd *cobra.Command, args []string) {
 	}
 	switch vol.Type {
 	case "block":
-		err = block.DeleteBlockVolume(mds, name)
+		if deletePurge {
+			err = block.DeleteBlockVolume(mds, name)
+		} else {
+			err = block.TrashBlockVolume(mds, name, deleteRetention)
+		}
 	default:
 		die("unknown volume type %s", vol.Type)
 	}
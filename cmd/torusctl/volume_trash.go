@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/spf13/cobra"
+)
+
+var volumeTrashCommand = &cobra.Command{
+	Use:   "trash",
+	Short: "list deleted volumes which can be undeleted",
+	Run:   volumeTrashAction,
+}
+
+var volumeUndeleteCommand = &cobra.Command{
+	Use:   "undelete NAME",
+	Short: "take a deleted volume out of the trash",
+	Run:   volumeUndeleteAction,
+}
+
+var volumePurgeCommand = &cobra.Command{
+	Use:   "purge [NAME]",
+	Short: "delete a volume in the trash for good",
+	Long:  "delete a volume in the trash for good, or with --expired, every volume whose retention has passed",
+	Run:   volumePurgeAction,
+}
+
+var (
+	trashID      string
+	undeleteAs   string
+	purgeExpired bool
+)
+
+func init() {
+	volumeCommand.AddCommand(volumeTrashCommand)
+	volumeCommand.AddCommand(volumeUndeleteCommand)
+	volumeCommand.AddCommand(volumePurgeCommand)
+	volumeTrashCommand.Flags().BoolVarP(&outputAsCSV, "csv", "", false, "output as csv instead")
+	volumeTrashCommand.Flags().BoolVarP(&outputAsSI, "si", "", false, "output sizes in powers of 1000")
+	volumeUndeleteCommand.Flags().StringVarP(&trashID, "id", "", "", "ID of the volume, if several in the trash are named NAME")
+	volumeUndeleteCommand.Flags().StringVarP(&undeleteAs, "as", "", "", "undelete the volume under a new name")
+	volumePurgeCommand.Flags().StringVarP(&trashID, "id", "", "", "ID of the volume, if several in the trash are named NAME")
+	volumePurgeCommand.Flags().BoolVarP(&purgeExpired, "expired", "", false, "purge every volume whose retention has passed")
+}
+
+func volumeTrashAction(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	mds := mustConnectToMDS()
+	trash, err := block.ListTrash(mds)
+	if err != nil {
+		die("error listing trash: %v", err)
+	}
+	table := NewTableWriter(os.Stdout)
+	table.SetHeader([]string{"Volume Name", "ID", "Size", "Deleted", "Expires"})
+	for _, x := range trash {
+		table.Append([]string{
+			x.Volume.Name,
+			strconv.FormatUint(x.Volume.Id, 16),
+			bytesOrIbytes(x.Volume.MaxBytes, outputAsSI),
+			x.Deleted.Format(time.RFC3339),
+			x.Expires.Format(time.RFC3339),
+		})
+	}
+	if outputAsCSV {
+		table.RenderCSV()
+		return
+	}
+	table.Render()
+}
+
+// findTrashed returns the ID of the volume in the trash named name, or the one
+// given by --id.
+func findTrashed(mds torus.MetadataService, name string) (torus.VolumeID, error) {
+	trash, err := block.ListTrash(mds)
+	if err != nil {
+		return 0, err
+	}
+	var found []uint64
+	for _, x := range trash {
+		if x.Volume.Name != name {
+			continue
+		}
+		if trashID != "" && strconv.FormatUint(x.Volume.Id, 16) != trashID {
+			continue
+		}
+		found = append(found, x.Volume.Id)
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("no volume %s in the trash", name)
+	case 1:
+		return torus.VolumeID(found[0]), nil
+	}
+	return 0, fmt.Errorf("%d volumes named %s in the trash, choose one with --id", len(found), name)
+}
+
+func volumeUndeleteAction(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	mds := mustConnectToMDS()
+	id, err := findTrashed(mds, args[0])
+	if err != nil {
+		die("%v", err)
+	}
+	err = block.UndeleteBlockVolume(mds, id, undeleteAs)
+	if err == torus.ErrExists {
+		die("cannot undelete volume %s: a volume of that name exists, use --as to rename it", args[0])
+	}
+	if err != nil {
+		die("cannot undelete volume %s: %v", args[0], err)
+	}
+}
+
+func volumePurgeAction(cmd *cobra.Command, args []string) {
+	if purgeExpired {
+		if len(args) != 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+		purgeExpiredAction()
+		return
+	}
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	mds := mustConnectToMDS()
+	id, err := findTrashed(mds, args[0])
+	if err != nil {
+		die("%v", err)
+	}
+	if err = block.PurgeBlockVolume(mds, id); err != nil {
+		die("cannot purge volume %s: %v", args[0], err)
+	}
+}
+
+func purgeExpiredAction() {
+	mds := mustConnectToMDS()
+	purged, err := block.PurgeExpiredTrash(mds)
+	for _, x := range purged {
+		fmt.Printf("purged volume %s (%x)\n", x.Volume.Name, x.Volume.Id)
+	}
+	if err != nil {
+		die("cannot purge expired volumes: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/internal/flagconfig"
 	_ "github.com/coreos/torus/metadata/etcd"
+	_ "github.com/coreos/torus/metadata/raft"
 )
 
 var (
@@ -38,7 +39,7 @@ func wipeAction(cmd *cobra.Command, args []string) {
 		}
 	}
 	cfg := flagconfig.BuildConfigFromFlags()
-	err := torus.WipeMDS("etcd", cfg)
+	err := torus.WipeMDS(flagconfig.MetadataServiceName(), cfg)
 	if err != nil {
 		die("error wiping metadata: %v", err)
 	}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/dustin/go-humanize"
@@ -16,6 +17,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/block/mirror"
 	"github.com/coreos/torus/blockset"
 	"github.com/coreos/torus/distributor"
 	"github.com/coreos/torus/internal/flagconfig"
@@ -24,9 +27,9 @@ import (
 	"github.com/coreos/torus/ring"
 
 	// Register all the possible drivers.
-	_ "github.com/coreos/torus/block"
 	_ "github.com/coreos/torus/metadata/etcd"
-	_ "github.com/coreos/torus/metadata/temp"
+	_ "github.com/coreos/torus/metadata/local"
+	_ "github.com/coreos/torus/metadata/raft"
 	_ "github.com/coreos/torus/storage"
 )
 
@@ -39,9 +42,19 @@ var (
 	port        int
 	debugInit   bool
 	autojoin    bool
+	localMDS    bool
+	raftMember  string
 	logpkg      string
 	cfg         torus.Config
 
+	mirrorTo        string
+	mirrorNamespace string
+	mirrorVolumes   []string
+	mirrorInterval  time.Duration
+
+	snapshotScheduler bool
+	usageInterval     time.Duration
+
 	debug      bool
 	version    bool
 	completion bool
@@ -65,6 +78,14 @@ func init() {
 	rootCommand.PersistentFlags().StringVarP(&sizeStr, "size", "", "1GiB", "How much disk space to use for this storage node")
 	rootCommand.PersistentFlags().StringVarP(&logpkg, "logpkg", "", "", "Specific package logging")
 	rootCommand.PersistentFlags().BoolVarP(&autojoin, "auto-join", "", false, "Automatically join the storage pool")
+	rootCommand.PersistentFlags().BoolVarP(&localMDS, "local-metadata", "", false, "Keep metadata in the data directory instead of etcd (single node only)")
+	rootCommand.PersistentFlags().StringVarP(&raftMember, "raft-member", "", "", "Serve embedded raft metadata as this member, one of the URLs given to --raft")
+	rootCommand.PersistentFlags().StringVarP(&mirrorTo, "mirror-to", "", "", "Address of the etcd of a second torus cluster to mirror block volumes to")
+	rootCommand.PersistentFlags().StringVarP(&mirrorNamespace, "mirror-namespace", "", "", "Namespace of the torus cluster to mirror to, in its etcd")
+	rootCommand.PersistentFlags().StringSliceVarP(&mirrorVolumes, "mirror-volume", "", nil, "Block volume to mirror (may be repeated)")
+	rootCommand.PersistentFlags().DurationVarP(&mirrorInterval, "mirror-interval", "", time.Minute, "How often to mirror changes to block volumes")
+	rootCommand.PersistentFlags().BoolVarP(&snapshotScheduler, "snapshot-scheduler", "", true, "Take and expire block volume snapshots according to their policies")
+	rootCommand.PersistentFlags().DurationVarP(&usageInterval, "usage-interval", "", 5*time.Minute, "How often to account the space used by block volumes (0 to never)")
 	rootCommand.PersistentFlags().BoolVarP(&version, "version", "", false, "Print version info and exit")
 	rootCommand.PersistentFlags().BoolVarP(&completion, "completion", "", false, "Output bash completion code")
 	flagconfig.AddConfigFlags(rootCommand.PersistentFlags())
@@ -126,6 +147,10 @@ func configureServer(cmd *cobra.Command, args []string) {
 	cfg = flagconfig.BuildConfigFromFlags()
 	cfg.DataDir = dataDir
 	cfg.StorageSize = size
+	if localMDS {
+		cfg.MetadataAddress = ""
+	}
+	cfg.MetadataMember = raftMember
 }
 
 func parsePercentage(percentString string) (uint64, error) {
@@ -140,6 +165,28 @@ func parsePercentage(percentString string) (uint64, error) {
 	return uint64(sizeNumber), nil
 }
 
+const maxRetryDelay = 5 * time.Second
+
+// retryAgain calls f until it stops returning torus.ErrAgain, which is what
+// raft metadata returns until enough members are up to elect a leader,
+// backing off between calls.
+func retryAgain(f func() error) error {
+	delay := 100 * time.Millisecond
+	for i := 0; ; i++ {
+		err := f()
+		if err != torus.ErrAgain {
+			return err
+		}
+		if i == 0 {
+			fmt.Println("waiting for the metadata service...")
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
 func runServer(cmd *cobra.Command, args []string) {
 	if completion {
 		cmd.Root().GenBashCompletion(os.Stdout)
@@ -152,12 +199,22 @@ func runServer(cmd *cobra.Command, args []string) {
 	)
 	switch {
 	case cfg.MetadataAddress == "":
-		srv, err = torus.NewServer(cfg, "temp", "mfile")
-	case debugInit:
-		err = torus.InitMDS("etcd", cfg, torus.GlobalMetadata{
+		err = torus.InitMDS("local", cfg, torus.GlobalMetadata{
 			BlockSize:        512 * 1024,
 			DefaultBlockSpec: blockset.MustParseBlockLayerSpec("crc,base"),
 		}, ring.Ketama)
+		if err != nil && err != torus.ErrExists {
+			fmt.Printf("Couldn't initialize local metadata: %s\n", err)
+			os.Exit(1)
+		}
+		srv, err = torus.NewServer(cfg, "local", "mfile")
+	case debugInit:
+		err = retryAgain(func() error {
+			return torus.InitMDS(flagconfig.MetadataServiceName(), cfg, torus.GlobalMetadata{
+				BlockSize:        512 * 1024,
+				DefaultBlockSpec: blockset.MustParseBlockLayerSpec("crc,base"),
+			}, ring.Ketama)
+		})
 		if err != nil {
 			if err == torus.ErrExists {
 				fmt.Println("debug-init: Already exists")
@@ -168,7 +225,10 @@ func runServer(cmd *cobra.Command, args []string) {
 		}
 		fallthrough
 	default:
-		srv, err = torus.NewServer(cfg, "etcd", "mfile")
+		err = retryAgain(func() error {
+			srv, err = torus.NewServer(cfg, flagconfig.MetadataServiceName(), "mfile")
+			return err
+		})
 	}
 	if err != nil {
 		fmt.Printf("Couldn't start: %s\n", err)
@@ -219,6 +279,24 @@ func runServer(cmd *cobra.Command, args []string) {
 		fmt.Println("couldn't use server:", err)
 		os.Exit(1)
 	}
+	if snapshotScheduler {
+		sc := block.NewSnapshotScheduler(srv, block.MinSnapshotInterval)
+		sc.Start()
+		defer sc.Close()
+	}
+	if usageInterval != 0 {
+		ua := block.NewUsageAccountant(srv, usageInterval)
+		ua.Start()
+		defer ua.Close()
+	}
+	if mirrorTo != "" {
+		m, err := startMirror(srv)
+		if err != nil {
+			fmt.Println("couldn't start mirroring:", err)
+			os.Exit(1)
+		}
+		defer m.Close()
+	}
 	if httpAddress != "" {
 		http.ServeHTTP(httpAddress, srv)
 	}
@@ -226,6 +304,38 @@ func runServer(cmd *cobra.Command, args []string) {
 	<-mainClose
 }
 
+// startMirror starts mirroring the --mirror-volume block volumes to the
+// cluster at --mirror-to, as a client of it.
+func startMirror(srv *torus.Server) (*mirror.Mirror, error) {
+	if len(mirrorVolumes) == 0 {
+		return nil, errors.New("--mirror-to needs at least one --mirror-volume")
+	}
+	remoteCfg := cfg
+	remoteCfg.DataDir = ""
+	remoteCfg.StorageSize = 0
+	remoteCfg.MetadataAddress = mirrorTo
+	remoteCfg.MetadataMember = ""
+	remoteCfg.MetadataNamespace = mirrorNamespace
+	remote, err := torus.NewServer(remoteCfg, "etcd", "temp")
+	if err != nil {
+		return nil, err
+	}
+	if err = distributor.OpenReplication(remote); err != nil {
+		return nil, err
+	}
+	source, _ := os.Hostname()
+	if cfg.MetadataAddress != "" {
+		source = cfg.MetadataAddress
+	}
+	m := mirror.New(srv, remote, mirror.Config{
+		Volumes:  mirrorVolumes,
+		Interval: mirrorInterval,
+		Source:   source,
+	})
+	m.Start()
+	return m, nil
+}
+
 func doAutojoin(s *torus.Server) error {
 	for {
 		ring, err := s.MDS.GetRing()
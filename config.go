@@ -10,5 +10,14 @@ type Config struct {
 	ReadLevel       ReadLevel
 	WriteLevel      WriteLevel
 
+	// MetadataMember is the URL, out of those in MetadataAddress, at which
+	// this process serves an embedded metadata service. It is empty for
+	// processes that are only clients of the metadata service.
+	MetadataMember string
+
+	// MetadataNamespace keeps the metadata of this torus cluster apart from
+	// that of any others sharing the same etcd cluster.
+	MetadataNamespace string
+
 	TLS *tls.Config
 }
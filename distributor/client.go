@@ -134,7 +134,7 @@ func (d *distClient) PutBlock(ctx context.Context, uuid string, b torus.BlockRef
 		return torus.ErrNoPeer
 	}
 	err := conn.PutBlock(ctx, b, data)
-	if err != nil {
+	if err != nil && err != torus.ErrStaleFence {
 		d.resetConn(uuid)
 		if err == context.DeadlineExceeded {
 			return torus.ErrBlockUnavailable
@@ -25,6 +25,7 @@ type Distributor struct {
 	client    *distClient
 	rpcSrv    protocols.RPCServer
 	readCache *cache
+	fences    *fences
 
 	ring            torus.Ring
 	closed          bool
@@ -40,6 +41,11 @@ func newDistributor(srv *torus.Server, addr *url.URL) (*Distributor, error) {
 		blocks: srv.Blocks,
 		srv:    srv,
 	}
+	d.fences = newFences(func(vid torus.VolumeID) (uint64, error) {
+		return torus.ReadFence(srv.MDS, vid)
+	}, func(vid torus.VolumeID) (<-chan struct{}, func(), error) {
+		return torus.WatchFence(srv.MDS, vid)
+	})
 	gmd := d.srv.MDS.GlobalMetadata()
 	if addr != nil {
 		d.rpcSrv, err = protocols.ListenRPC(addr, d, gmd)
@@ -92,6 +98,7 @@ func (d *Distributor) Close() error {
 		d.rpcSrv.Close()
 	}
 	d.client.Close()
+	d.fences.close()
 	err := d.blocks.Close()
 	if err != nil {
 		return err
@@ -0,0 +1,135 @@
+package distributor
+
+import (
+	"sync"
+
+	"github.com/coreos/torus"
+	"golang.org/x/net/context"
+)
+
+// fences keeps the highest fencing token this node knows of for each volume.
+// A writer which lost its volume lock, and whose lease expired while it was
+// partitioned away, still carries its old token; its writes are turned away
+// once this node knows of a newer one.
+//
+// The first fenced write for a volume this node hasn't seen, as after a
+// restart, is checked against the token of the volume's last lock in the
+// metadata. From then on the node watches the token, so that a new lock
+// fences off the previous holder even before the new holder writes here.
+type fences struct {
+	mut     sync.Mutex
+	volumes map[torus.VolumeID]*volumeFence
+	closed  bool
+	// read returns the token of the last lock of a volume.
+	read func(torus.VolumeID) (uint64, error)
+	// watch watches the token of the last lock of a volume.
+	watch func(torus.VolumeID) (<-chan struct{}, func(), error)
+}
+
+type volumeFence struct {
+	highest uint64
+	stop    func()
+}
+
+func newFences(read func(torus.VolumeID) (uint64, error), watch func(torus.VolumeID) (<-chan struct{}, func(), error)) *fences {
+	return &fences{
+		volumes: make(map[torus.VolumeID]*volumeFence),
+		read:    read,
+		watch:   watch,
+	}
+}
+
+// check returns ErrStaleFence if the write for ref carries an older token
+// than the volume's, and records the token otherwise. Unfenced writes, like
+// those made when rebalancing, are always allowed.
+func (f *fences) check(ctx context.Context, ref torus.BlockRef) error {
+	fence := torus.FenceFromContext(ctx)
+	if fence == 0 {
+		return nil
+	}
+	vid := ref.Volume()
+	f.mut.Lock()
+	v := f.volumes[vid]
+	f.mut.Unlock()
+	if v == nil {
+		// Not under the lock, so that writes for other volumes don't wait
+		// on the metadata.
+		var err error
+		if v, err = f.follow(vid); err != nil {
+			clog.Errorf("couldn't read the fence of volume %d: %v", vid, err)
+			return err
+		}
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if fence < v.highest {
+		promDistStaleFenceWrites.Inc()
+		return torus.ErrStaleFence
+	}
+	v.highest = fence
+	return nil
+}
+
+// follow reads the token of the volume vid and starts watching it.
+func (f *fences) follow(vid torus.VolumeID) (*volumeFence, error) {
+	// Watch before reading, so that no new lock in between is missed.
+	changes, stop, err := f.watch(vid)
+	if err != nil {
+		return nil, err
+	}
+	last, err := f.read(vid)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	f.mut.Lock()
+	if cur := f.volumes[vid]; cur != nil || f.closed {
+		// Another write got there first, or the node is shutting down.
+		f.mut.Unlock()
+		stop()
+		if cur == nil {
+			cur = &volumeFence{highest: last}
+		}
+		return cur, nil
+	}
+	v := &volumeFence{highest: last, stop: stop}
+	f.volumes[vid] = v
+	f.mut.Unlock()
+	go f.update(vid, v, changes)
+	return v, nil
+}
+
+// update raises the token of v to each new one of the volume vid, until the
+// watch ends.
+func (f *fences) update(vid torus.VolumeID, v *volumeFence, changes <-chan struct{}) {
+	for range changes {
+		last, err := f.read(vid)
+		if err != nil {
+			clog.Errorf("couldn't read the fence of volume %d: %v", vid, err)
+			break
+		}
+		f.mut.Lock()
+		if last > v.highest {
+			v.highest = last
+		}
+		f.mut.Unlock()
+	}
+	// Forget the volume, so that its next write reads the token afresh.
+	f.mut.Lock()
+	if f.volumes[vid] == v {
+		delete(f.volumes, vid)
+	}
+	f.mut.Unlock()
+	v.stop()
+}
+
+// close stops watching the tokens.
+func (f *fences) close() {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.closed = true
+	for vid, v := range f.volumes {
+		v.stop()
+		delete(f.volumes, vid)
+	}
+}
@@ -0,0 +1,148 @@
+package distributor
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus"
+	"golang.org/x/net/context"
+)
+
+// fenceMeta keeps fencing tokens like the metadata service.
+type fenceMeta struct {
+	mu       sync.Mutex
+	last     map[torus.VolumeID]uint64
+	readErr  error
+	reads    int
+	watchers map[torus.VolumeID][]chan struct{}
+}
+
+func newFenceMeta() *fenceMeta {
+	return &fenceMeta{
+		last:     make(map[torus.VolumeID]uint64),
+		watchers: make(map[torus.VolumeID][]chan struct{}),
+	}
+}
+
+func (m *fenceMeta) read(vid torus.VolumeID) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads++
+	return m.last[vid], m.readErr
+}
+
+func (m *fenceMeta) watch(vid torus.VolumeID) (<-chan struct{}, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	m.watchers[vid] = append(m.watchers[vid], ch)
+	return ch, func() {}, nil
+}
+
+// lock takes a new token for vid.
+func (m *fenceMeta) lock(vid torus.VolumeID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last[vid]++
+	for _, ch := range m.watchers[vid] {
+		notify(ch)
+	}
+}
+
+// endWatches ends the watches of vid.
+func (m *fenceMeta) endWatches(vid torus.VolumeID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers[vid] {
+		close(ch)
+	}
+	delete(m.watchers, vid)
+}
+
+func (m *fenceMeta) readCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reads
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// eventually waits for cond to hold.
+func eventually(t *testing.T, what string, cond func() bool) {
+	for i := 0; !cond(); i++ {
+		if i == 500 {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFences(t *testing.T) {
+	m := newFenceMeta()
+	m.last[1] = 5
+	f := newFences(m.read, m.watch)
+	defer f.close()
+	write := func(vid torus.VolumeID, fence uint64) error {
+		ref := torus.BlockRef{INodeRef: torus.NewINodeRef(vid, 1)}
+		return f.check(torus.WithFence(context.Background(), fence), ref)
+	}
+
+	// A stale writer is turned away before the holder has written here.
+	if err := write(1, 4); err != torus.ErrStaleFence {
+		t.Fatalf("stale first write: %v", err)
+	}
+	if err := write(1, 5); err != nil {
+		t.Fatalf("holder's write: %v", err)
+	}
+	if err := write(1, 0); err != nil {
+		t.Fatalf("unfenced write: %v", err)
+	}
+	if n := m.readCount(); n != 1 {
+		t.Fatalf("read the fence %d times", n)
+	}
+
+	// Once the volume is locked again, the previous holder is fenced off
+	// even though the new one hasn't written here.
+	m.lock(1)
+	eventually(t, "the previous holder is fenced off", func() bool {
+		return write(1, 5) == torus.ErrStaleFence
+	})
+	if err := write(1, 6); err != nil {
+		t.Fatalf("new holder's write: %v", err)
+	}
+
+	// When the watch ends, the next write reads the fence afresh.
+	reads := m.readCount()
+	m.endWatches(1)
+	m.mu.Lock()
+	m.last[1] = 9
+	m.mu.Unlock()
+	eventually(t, "the fence is read again", func() bool {
+		return write(1, 8) == torus.ErrStaleFence
+	})
+	if m.readCount() <= reads {
+		t.Fatal("fence wasn't read again")
+	}
+
+	// Writes fail until the fence can be read.
+	readErr := errors.New("metadata unavailable")
+	m.mu.Lock()
+	m.readErr = readErr
+	m.mu.Unlock()
+	if err := write(2, 1); err != readErr {
+		t.Fatalf("write without the fence: %v", err)
+	}
+	m.mu.Lock()
+	m.readErr = nil
+	m.mu.Unlock()
+	if err := write(2, 1); err != nil {
+		t.Fatalf("write once the fence is read: %v", err)
+	}
+}
@@ -49,6 +49,10 @@ var (
 		Name: "torus_distributor_block_rpc_failures",
 		Help: "Number of PutBlock RPCs with errors",
 	})
+	promDistStaleFenceWrites = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "torus_distributor_stale_fence_writes_total",
+		Help: "Number of block writes rejected for carrying a stale fencing token",
+	})
 	promDistRebalanceRPCs = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "torus_distributor_rebalance_rpcs_total",
 		Help: "Number of Rebalance RPCs made to this node",
@@ -73,6 +77,7 @@ func init() {
 	prometheus.MustRegister(promDistPutBlockRPCFailures)
 	prometheus.MustRegister(promDistBlockRPCs)
 	prometheus.MustRegister(promDistBlockRPCFailures)
+	prometheus.MustRegister(promDistStaleFenceWrites)
 	prometheus.MustRegister(promDistRebalanceRPCs)
 	prometheus.MustRegister(promDistRebalanceRPCFailures)
 }
@@ -65,15 +65,22 @@ func (c *client) Close() error {
 }
 
 func (c *client) PutBlock(ctx context.Context, ref torus.BlockRef, data []byte) error {
-	_, err := c.handler.PutBlock(ctx, &models.PutBlockRequest{
+	resp, err := c.handler.PutBlock(ctx, &models.PutBlockRequest{
 		Refs: []*models.BlockRef{
 			ref.ToProto(),
 		},
 		Blocks: [][]byte{
 			data,
 		},
+		Fence: torus.FenceFromContext(ctx),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if !resp.Ok && resp.Err == torus.ErrStaleFence.Error() {
+		return torus.ErrStaleFence
+	}
+	return nil
 }
 
 func (c *client) Block(ctx context.Context, ref torus.BlockRef) ([]byte, error) {
@@ -119,8 +126,14 @@ func (h *handler) Block(ctx context.Context, req *models.BlockRequest) (*models.
 }
 
 func (h *handler) PutBlock(ctx context.Context, req *models.PutBlockRequest) (*models.PutResponse, error) {
+	if req.Fence != 0 {
+		ctx = torus.WithFence(ctx, req.Fence)
+	}
 	for i, ref := range req.Refs {
 		err := h.handle.PutBlock(ctx, torus.BlockFromProto(ref), req.Blocks[i])
+		if err == torus.ErrStaleFence {
+			return &models.PutResponse{Err: err.Error()}, nil
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package tdp
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
@@ -113,12 +114,24 @@ func (c *Conn) PutBlock(ctx context.Context, ref torus.BlockRef, data []byte) er
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	c.conn.SetDeadline(time.Now().Add(writeClientTimeout))
+	fence := torus.FenceFromContext(ctx)
 	c.buf[0] = cmdPutBlock
+	if fence != 0 {
+		c.buf[0] = cmdPutBlockFenced
+	}
 	ref.ToBytesBuf(c.buf[1:])
 	_, err := c.conn.Write(c.buf)
 	if err != nil {
 		return fmt.Errorf("couldn't write: %v", err)
 	}
+	if fence != 0 {
+		fencebuf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(fencebuf, fence)
+		_, err = c.conn.Write(fencebuf)
+		if err != nil {
+			return fmt.Errorf("couldn't write fence: %v", err)
+		}
+	}
 	_, err = c.conn.Write(data)
 	if err != nil {
 		return fmt.Errorf("couldn't write data: %v", err)
@@ -127,8 +140,11 @@ func (c *Conn) PutBlock(ctx context.Context, ref torus.BlockRef, data []byte) er
 	if err != nil {
 		return err
 	}
-	if c.buf[0] == respErr {
+	switch c.buf[0] {
+	case respErr:
 		return errors.New("server error")
+	case respStaleFence:
+		return torus.ErrStaleFence
 	}
 	return nil
 }
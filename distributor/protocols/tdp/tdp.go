@@ -1,6 +1,7 @@
 package tdp
 
 import (
+	"encoding/binary"
 	"errors"
 	"io"
 	"net"
@@ -21,16 +22,19 @@ const (
 	cmdPutBlock
 	cmdBlock
 	cmdRebalanceCheck
+	cmdPutBlockFenced
 )
 
 const (
 	respOk byte = iota + 1
 	respErr
+	respStaleFence
 )
 
 var (
-	headerOk  = []byte{respOk}
-	headerErr = []byte{respErr}
+	headerOk         = []byte{respOk}
+	headerErr        = []byte{respErr}
+	headerStaleFence = []byte{respStaleFence}
 )
 
 type Server struct {
@@ -113,6 +117,8 @@ func (s *Server) handle(conn net.Conn) {
 			err = s.handleBlock(conn, refbuf)
 		case cmdPutBlock:
 			err = s.handlePutBlock(conn, refbuf, null)
+		case cmdPutBlockFenced:
+			err = s.handlePutBlockFenced(conn, refbuf)
 		case cmdRebalanceCheck:
 			err := readConnIntoBuffer(conn, header)
 			if err == nil {
@@ -192,6 +198,37 @@ func (s *Server) handlePutBlock(conn net.Conn, refbuf []byte, null []byte) error
 	return err
 }
 
+// handlePutBlockFenced reads a block reference, an 8 byte fencing token and
+// the block data, and answers with respStaleFence if the token is stale.
+func (s *Server) handlePutBlockFenced(conn net.Conn, refbuf []byte) error {
+	err := readConnIntoBuffer(conn, refbuf)
+	if err != nil {
+		return err
+	}
+	ref := torus.BlockRefFromBytes(refbuf)
+	fencebuf := make([]byte, 8)
+	err = readConnIntoBuffer(conn, fencebuf)
+	if err != nil {
+		return err
+	}
+	ctx := torus.WithFence(context.TODO(), binary.LittleEndian.Uint64(fencebuf))
+	data := make([]byte, s.blocksize)
+	err = readConnIntoBuffer(conn, data)
+	if err != nil {
+		return err
+	}
+	err = s.handler.PutBlock(ctx, ref, data)
+	respheader := headerOk
+	if err == torus.ErrStaleFence {
+		respheader = headerStaleFence
+	} else if err != nil {
+		clog.Warningf("failed to put block: %v", err)
+		respheader = headerErr
+	}
+	_, err = conn.Write(respheader)
+	return err
+}
+
 func (s *Server) handleRebalanceCheck(conn net.Conn, len int, refbuf []byte) error {
 	refs := make([]torus.BlockRef, len)
 	for i := 0; i < len; i++ {
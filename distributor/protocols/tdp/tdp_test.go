@@ -18,13 +18,17 @@ import (
 var nchecks = rand.Intn(255)
 
 type mockBlockRPC struct {
-	data []byte
+	data  []byte
+	fence uint64
 }
 
 func (m *mockBlockRPC) Block(ctx context.Context, ref torus.BlockRef) ([]byte, error) {
 	return m.data, nil
 }
 func (m *mockBlockRPC) PutBlock(ctx context.Context, ref torus.BlockRef, data []byte) error {
+	if torus.FenceFromContext(ctx) < m.fence {
+		return torus.ErrStaleFence
+	}
 	if ref.INode != 2 && ref.Index != 3 {
 		return errors.New("mismatch")
 	}
@@ -190,6 +194,36 @@ func TestPutBlock(t *testing.T) {
 	}
 }
 
+func TestPutBlockFenced(t *testing.T) {
+	test := makeTestData(512 * 1024)
+	m := &mockBlockRPC{
+		data:  test,
+		fence: 5,
+	}
+	s, err := Serve("localhost:0", m, m.BlockSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c, err := Dial(s.ListenAddr().String(), time.Second, m.BlockSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ref := torus.BlockRef{
+		INodeRef: torus.NewINodeRef(1, 2),
+		Index:    3,
+	}
+	err = c.PutBlock(torus.WithFence(context.TODO(), 4), ref, test)
+	if err != torus.ErrStaleFence {
+		t.Fatalf("expected ErrStaleFence, got %v", err)
+	}
+	err = c.PutBlock(torus.WithFence(context.TODO(), 5), ref, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestPutBlockGRPC(t *testing.T) {
 	test := makeTestData(512 * 1024)
 	m := &mockBlockGRPC{
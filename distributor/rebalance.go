@@ -57,6 +57,9 @@ exit:
 				clog.Errorf("gc prep for %s failed: %s", x.Name, err)
 			}
 		}
+		if err := d.rebalancer.Prepare(); err != nil {
+			clog.Errorf("gc prep failed: %s", err)
+		}
 	ratelimit:
 		for {
 			timeout := 2 * time.Duration(n+1) * time.Millisecond
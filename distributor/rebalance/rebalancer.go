@@ -22,6 +22,7 @@ type Rebalancer interface {
 	Tick() (int, error)
 	VersionStart() int
 	PrepVolume(*models.Volume) error
+	Prepare() error
 	Reset() error
 }
 
@@ -59,6 +60,13 @@ func (r *rebalancer) PrepVolume(vol *models.Volume) error {
 	return r.gc.PrepVolume(vol)
 }
 
+func (r *rebalancer) Prepare() error {
+	if p, ok := r.gc.(gc.Preparer); ok {
+		return p.Prepare()
+	}
+	return nil
+}
+
 func (r *rebalancer) Reset() error {
 	if r.it != nil {
 		r.it.Close()
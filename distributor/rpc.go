@@ -39,6 +39,10 @@ func (d *Distributor) PutBlock(ctx context.Context, ref torus.BlockRef, data []b
 	if !ok {
 		clog.Warningf("trying to write block that doesn't belong to me.")
 	}
+	if err = d.fences.check(ctx, ref); err != nil {
+		promDistPutBlockRPCFailures.Inc()
+		return err
+	}
 	err = d.blocks.WriteBlock(ctx, ref, data)
 	if err != nil {
 		return err
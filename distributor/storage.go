@@ -177,6 +177,9 @@ func (d *Distributor) WriteBlock(ctx context.Context, i torus.BlockRef, data []b
 	if len(peers.Peers) == 0 {
 		return ErrNoPeersBlock
 	}
+	if err = d.fences.check(ctx, i); err != nil {
+		return err
+	}
 	d.readCache.Put(i.ToHexString(), data)
 	switch d.getWriteFromServer() {
 	case torus.WriteLocal:
@@ -200,8 +203,8 @@ func (d *Distributor) WriteBlock(ctx context.Context, i torus.BlockRef, data []b
 		}
 		for _, p := range peers.Peers {
 			err = d.client.PutBlock(ctx, p, i, data)
-			if err == nil {
-				return nil
+			if err == nil || err == torus.ErrStaleFence {
+				return err
 			}
 			clog.Noticef("WriteOne error, remote: %s", err)
 		}
@@ -215,6 +218,9 @@ func (d *Distributor) WriteBlock(ctx context.Context, i torus.BlockRef, data []b
 			} else {
 				err = d.client.PutBlock(ctx, p, i, data)
 			}
+			if err == torus.ErrStaleFence {
+				return err
+			}
 			if err != nil {
 				clog.Noticef("error WriteAll to peer %s: %s", p, err)
 			} else {
@@ -63,6 +63,11 @@ var (
 	// ErrLocked is returned if the resource is locked.
 	ErrLocked = errors.New("torus: locked")
 
+	// ErrStaleFence is returned by a storage node if a block write carries a
+	// fencing token older than one it has already seen for the volume; the
+	// writer has lost its lock on the volume.
+	ErrStaleFence = errors.New("torus: stale fencing token")
+
 	// ErrLeaseNotFound is returned if the lease cannot be found.
 	ErrLeaseNotFound = errors.New("torus: lease not found")
 
@@ -76,6 +76,7 @@ type File struct {
 
 	writeINodeRef INodeRef
 	writeOpen     bool
+	ctx           context.Context
 }
 
 func (f *File) WriteOpen() bool {
@@ -126,9 +127,18 @@ func (f *File) writeToBlock(i, from, to int, data []byte) (int, error) {
 }
 
 func (f *File) getContext() context.Context {
+	if f.ctx != nil {
+		return f.ctx
+	}
 	return f.srv.getContext()
 }
 
+// SetFence fences all further block writes to the file with the token
+// returned when its volume was locked.
+func (f *File) SetFence(fence uint64) {
+	f.ctx = WithFence(f.srv.getContext(), fence)
+}
+
 func (f *File) Write(b []byte) (n int, err error) {
 	n, err = f.WriteAt(b, f.offset)
 	f.offset += int64(n)
@@ -138,6 +148,10 @@ func (f *File) Write(b []byte) (n int, err error) {
 func (f *File) WriteAt(b []byte, off int64) (n int, err error) {
 	f.mut.Lock()
 	defer f.mut.Unlock()
+	return f.writeAt(b, off)
+}
+
+func (f *File) writeAt(b []byte, off int64) (n int, err error) {
 	err = f.openWrite()
 	if err != nil {
 		return 0, err
@@ -306,7 +320,7 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 		return 0, errors.New("invalid whence")
 	}
 
-	return offset, nil
+	return f.offset, nil
 }
 
 func (f *File) Close() error {
@@ -332,8 +346,18 @@ func (f *File) Truncate(size int64) error {
 	return nil
 }
 
+// Resize truncates or extends the file to size, like Truncate, but can be
+// called while reads and writes are in flight.
+func (f *File) Resize(size int64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.Truncate(size)
+}
+
 // Trim zeroes data in the middle of a file.
 func (f *File) Trim(offset, length int64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
 	clog.Debugf("trimming %d %d", offset, length)
 	err := f.openWrite()
 	if err != nil {
@@ -345,10 +369,90 @@ func (f *File) Trim(offset, length int64) error {
 		blkFrom += 1
 	}
 	blkTo := (offset + length) / f.blkSize
-	return f.blocks.Trim(int(blkFrom), int(blkTo))
+	return f.trimBlocks(blkFrom, blkTo)
+}
+
+// trimBlocks zeroes the blocks in [from, to), writing out and dropping the
+// cached block first so it can't overwrite them later.
+func (f *File) trimBlocks(from, to int64) error {
+	if from >= to {
+		return nil
+	}
+	if err := f.cache.sync(f.getContext()); err != nil {
+		return err
+	}
+	f.cache = newSingleBlockCache(f.blocks, uint64(f.blkSize))
+	f.cache.newINode(f.writeINodeRef)
+	return f.blocks.Trim(int(from), int(to))
+}
+
+// Zero zeroes length bytes of the file at offset. Unlike Trim, it zeroes
+// partial blocks at the edges too; whole blocks are left as holes.
+func (f *File) Zero(offset, length int64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	clog.Debugf("zeroing %d %d", offset, length)
+	blkFrom := (offset + f.blkSize - 1) / f.blkSize
+	blkTo := (offset + length) / f.blkSize
+	if blkFrom >= blkTo {
+		_, err := f.writeAt(make([]byte, length), offset)
+		return err
+	}
+	if head := blkFrom*f.blkSize - offset; head > 0 {
+		if _, err := f.writeAt(make([]byte, head), offset); err != nil {
+			return err
+		}
+	}
+	if tail := offset + length - blkTo*f.blkSize; tail > 0 {
+		if _, err := f.writeAt(make([]byte, tail), blkTo*f.blkSize); err != nil {
+			return err
+		}
+	}
+	if err := f.openWrite(); err != nil {
+		return err
+	}
+	return f.trimBlocks(blkFrom, blkTo)
+}
+
+// Extent is a run of a file which either holds data or is a hole.
+type Extent struct {
+	Length int64
+	// Hole is set for blocks that were never written, or were trimmed.
+	// They take up no space and read as zeroes.
+	Hole bool
+}
+
+// Extents maps the length bytes of the file at offset into extents.
+func (f *File) Extents(offset, length int64) ([]Extent, error) {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+	refs := f.blocks.GetAllBlockRefs()
+	if n := f.blocks.Length(); len(refs) > n {
+		// Wrapping blocksets append the refs of their own blocks.
+		refs = refs[:n]
+	}
+	var out []Extent
+	for length > 0 {
+		i := offset / f.blkSize
+		n := (i+1)*f.blkSize - offset
+		if n > length {
+			n = length
+		}
+		hole := (i >= int64(len(refs)) || refs[i].IsZero()) && !f.cache.dirty(int(i))
+		if len(out) > 0 && out[len(out)-1].Hole == hole {
+			out[len(out)-1].Length += n
+		} else {
+			out = append(out, Extent{Length: n, Hole: hole})
+		}
+		offset += n
+		length -= n
+	}
+	return out, nil
 }
 
 func (f *File) SyncAllWrites() (INodeRef, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
 	err := f.SyncBlocks()
 	if err != nil {
 		return ZeroINode(), err
@@ -1,6 +1,7 @@
 package torus
 
 import (
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -11,6 +12,8 @@ type fileCache interface {
 	writeToBlock(ctx context.Context, i, from, to int, data []byte) (int, error)
 	getBlock(ctx context.Context, i int) ([]byte, error)
 	sync(context.Context) error
+	// dirty returns whether block i has writes not yet put.
+	dirty(i int) bool
 }
 
 type singleBlockCache struct {
@@ -23,6 +26,8 @@ type singleBlockCache struct {
 
 	blocks Blockset
 
+	// readMut guards the read block, as reads may run concurrently.
+	readMut  sync.Mutex
 	readIdx  int
 	readData []byte
 
@@ -106,28 +111,35 @@ func (sb *singleBlockCache) sync(ctx context.Context) error {
 	return err
 }
 
-func (sb *singleBlockCache) openRead(ctx context.Context, i int) error {
+func (sb *singleBlockCache) dirty(i int) bool {
+	return sb.openWrote && sb.openIdx == i
+}
+
+func (sb *singleBlockCache) openRead(ctx context.Context, i int) ([]byte, error) {
 	start := time.Now()
 	d, err := sb.blocks.GetBlock(ctx, i)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	delta := time.Since(start)
 	promFileBlockRead.Observe(float64(delta.Nanoseconds()) / 1000)
+	sb.readMut.Lock()
 	sb.readData = d
 	sb.readIdx = i
-	return nil
+	sb.readMut.Unlock()
+	return d, nil
 }
 
 func (sb *singleBlockCache) getBlock(ctx context.Context, i int) ([]byte, error) {
 	if sb.openIdx == i {
 		return sb.openData, nil
 	}
-	if sb.readIdx != i {
-		err := sb.openRead(ctx, i)
-		if err != nil {
-			return nil, err
-		}
+	sb.readMut.Lock()
+	if sb.readIdx == i {
+		d := sb.readData
+		sb.readMut.Unlock()
+		return d, nil
 	}
-	return sb.readData, nil
+	sb.readMut.Unlock()
+	return sb.openRead(ctx, i)
 }
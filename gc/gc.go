@@ -22,6 +22,12 @@ type GC interface {
 	Clear()
 }
 
+// Preparer is implemented by a GC with more to prepare than the volumes,
+// which it does once they all have been, before any block is checked.
+type Preparer interface {
+	Prepare() error
+}
+
 type INodeFetcher interface {
 	GetINode(context.Context, torus.INodeRef) (*models.INode, error)
 }
@@ -54,6 +60,19 @@ func (c *controller) PrepVolume(vol *models.Volume) error {
 	return nil
 }
 
+func (c *controller) Prepare() error {
+	for _, x := range c.gcs {
+		p, ok := x.(Preparer)
+		if !ok {
+			continue
+		}
+		if err := p.Prepare(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *controller) IsDead(ref torus.BlockRef) bool {
 	for _, x := range c.gcs {
 		if x.IsDead(ref) {
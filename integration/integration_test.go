@@ -9,11 +9,14 @@ import (
 	"math/rand"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/coreos/torus"
 	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/block/mirror"
+	"github.com/coreos/torus/block/reservation"
 	"github.com/coreos/torus/distributor"
 	"github.com/coreos/torus/metadata/temp"
 	"github.com/coreos/torus/models"
@@ -329,3 +332,869 @@ func BenchmarkLoadThree(b *testing.B) {
 	closeAll(b, servers...)
 	b.StartTimer()
 }
+
+func readVol(t *testing.T, server *torus.Server, volname string) []byte {
+	f := openVol(t, server, volname)
+	defer f.Close()
+	output := &bytes.Buffer{}
+	_, err := io.Copy(output, f)
+	if err != nil {
+		t.Fatalf("couldn't copy: %v", err)
+	}
+	return output.Bytes()
+}
+
+func TestClone(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	size := BlockSize * 100
+	data := makeTestData(size)
+	f := createVol(t, client, "testvol", uint64(size))
+	if _, err = f.Write(data); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	blockvol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = blockvol.SaveSnapshot("golden"); err != nil {
+		t.Fatal(err)
+	}
+	if err = block.CloneSnapshot(client.MDS, "testvol", "golden", "clone"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readVol(t, client, "clone"), data) {
+		t.Fatal("clone doesn't match its snapshot")
+	}
+
+	// Writes to the clone don't show up in the original, and the other way
+	// around.
+	cloneData := append([]byte(nil), data...)
+	copy(cloneData[BlockSize:], makeTestData(BlockSize))
+	f = openVol(t, client, "clone")
+	if _, err = f.WriteAt(cloneData[BlockSize:2*BlockSize], BlockSize); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	origData := append([]byte(nil), data...)
+	copy(origData[3*BlockSize:], makeTestData(BlockSize))
+	f = openVol(t, client, "testvol")
+	if _, err = f.WriteAt(origData[3*BlockSize:4*BlockSize], 3*BlockSize); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if !bytes.Equal(readVol(t, client, "clone"), cloneData) {
+		t.Error("clone has the wrong contents")
+	}
+	if !bytes.Equal(readVol(t, client, "testvol"), origData) {
+		t.Error("original has the wrong contents")
+	}
+	closeAll(t, servers...)
+}
+
+func TestSendReceive(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	size := BlockSize * 100
+	data := makeTestData(size)
+	f := createVol(t, client, "testvol", uint64(size))
+	if _, err = f.WriteAt(data[:50*BlockSize], 0); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatalf("couldn't sync: %v", err)
+	}
+	src, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = src.SaveSnapshot("one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt(data[50*BlockSize:], 50*BlockSize); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if err = src.SaveSnapshot("two"); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func(snapshot, base string) error {
+		stream := &bytes.Buffer{}
+		if err := src.Send(stream, snapshot, base); err != nil {
+			t.Fatal(err)
+		}
+		h, err := block.ReadStreamHeader(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if base == "" {
+			if err := block.CreateBlockVolume(client.MDS, "copy", h.Size); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dst, err := block.OpenBlockVolume(client, "copy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dst.Receive(h, stream)
+	}
+	if err = receive("one", ""); err != nil {
+		t.Fatal(err)
+	}
+	got := readVol(t, client, "copy")
+	if !bytes.Equal(got[:50*BlockSize], data[:50*BlockSize]) || !bytes.Equal(got[50*BlockSize:], make([]byte, 50*BlockSize)) {
+		t.Fatal("full stream didn't match the snapshot")
+	}
+	if err = receive("two", "one"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readVol(t, client, "copy"), data) {
+		t.Fatal("incremental stream didn't match the snapshot")
+	}
+	if err = receive("two", "one"); err != block.ErrStreamBase {
+		t.Fatalf("expected ErrStreamBase, got %v", err)
+	}
+
+	// A corrupt stream is refused.
+	stream := &bytes.Buffer{}
+	if err = src.Send(stream, "two", "one"); err != nil {
+		t.Fatal(err)
+	}
+	b := stream.Bytes()
+	b[len(b)/2] ^= 0xff
+	h, err := block.ReadStreamHeader(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = block.CreateBlockVolume(client.MDS, "corrupt", h.Size); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := block.OpenBlockVolume(client, "corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dst.SaveSnapshot("one"); err != nil {
+		t.Fatal(err)
+	}
+	if err = dst.Receive(h, stream); err != block.ErrBadStream {
+		t.Fatalf("expected ErrBadStream, got %v", err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestMirror(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The remote cluster is a single node with its own metadata.
+	rmds := temp.NewServer()
+	remote := newServer(t, rmds)
+	newRing, err := ring.CreateRing(&models.Ring{
+		Type: uint32(ring.Single),
+		Peers: torus.PeerInfoList{&models.PeerInfo{
+			UUID:        remote.MDS.UUID(),
+			TotalBlocks: StorageSize / BlockSize,
+		}},
+		ReplicationFactor: 1,
+		Version:           2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rmds.SetRing(newRing); err != nil {
+		t.Fatal(err)
+	}
+	if err = distributor.OpenReplication(remote); err != nil {
+		t.Fatal(err)
+	}
+
+	size := BlockSize * 100
+	data := makeTestData(size)
+	f := createVol(t, client, "testvol", uint64(size))
+	if _, err = f.WriteAt(data[:50*BlockSize], 0); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatalf("couldn't sync: %v", err)
+	}
+	m := mirror.New(client, remote, mirror.Config{Source: "test"})
+	if err = m.MirrorVolume("testvol"); err != nil {
+		t.Fatal(err)
+	}
+	replica, err := block.OpenBlockVolume(remote, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = replica.OpenBlockFile(); err != block.ErrReplica {
+		t.Fatalf("expected ErrReplica opening the replica, got %v", err)
+	}
+
+	if _, err = f.WriteAt(data[50*BlockSize:], 50*BlockSize); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if err = m.MirrorVolume("testvol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Lag("testvol"); !ok {
+		t.Fatal("lag unknown after mirroring")
+	}
+	src, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range []*block.BlockVolume{src, replica} {
+		snaps, err := vol.GetSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != 1 {
+			t.Fatalf("expected only the last mirror snapshot, got %v", snaps)
+		}
+	}
+
+	if err = replica.Promote(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readVol(t, remote, "testvol"), data) {
+		t.Fatal("promoted replica didn't match the volume")
+	}
+	if err = m.MirrorVolume("testvol"); err != mirror.ErrPromoted {
+		t.Fatalf("expected ErrPromoted, got %v", err)
+	}
+	closeAll(t, remote)
+	closeAll(t, servers...)
+}
+
+func TestSnapshotPolicy(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	f := createVol(t, client, "testvol", uint64(BlockSize*10))
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	vol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.SaveSnapshot("mine"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := block.ParseSnapshotPolicy("hourly keep 3, daily keep 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.SetSnapshotPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first server to run claims the volume; the others leave it be.
+	sc := block.NewSnapshotScheduler(servers[0], time.Minute)
+	if err = sc.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	sc = block.NewSnapshotScheduler(servers[1], time.Minute)
+	if err = sc.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := vol.GetSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("expected a snapshot for each rule besides mine, got %v", snaps)
+	}
+
+	// Over five hours, only the last three hourly snapshots are kept.
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		if err = vol.ApplySnapshotPolicy(p, now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if snaps, err = vol.GetSnapshots(); err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[string]int)
+	for _, x := range snaps {
+		if p := strings.SplitN(x.Name, "-", 3); len(p) == 3 {
+			count[p[1]]++
+		}
+	}
+	if len(snaps) != 5 || count["hourly"] != 3 || count["daily"] != 1 {
+		t.Fatalf("unexpected snapshots after retention: %v", snaps)
+	}
+	closeAll(t, servers...)
+}
+
+func TestResize(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	f := createVol(t, client, "testvol", uint64(len(data)))
+	if _, err = f.Write(data); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	vol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.Resize(BlockSize*20, false); err != nil {
+		t.Fatal(err)
+	}
+	got := readVol(t, client, "testvol")
+	if len(got) != BlockSize*20 || !bytes.Equal(got[:len(data)], data) || !bytes.Equal(got[len(data):], make([]byte, BlockSize*10)) {
+		t.Fatal("grown volume didn't keep its data")
+	}
+	if err = vol.Resize(BlockSize*5, false); err != block.ErrShrink {
+		t.Fatalf("expected ErrShrink, got %v", err)
+	}
+	if err = vol.Resize(BlockSize*5, true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readVol(t, client, "testvol"), data[:BlockSize*5]) {
+		t.Fatal("shrunk volume didn't keep its data")
+	}
+
+	// An attached volume is resized by its holder.
+	f = openVol(t, client, "testvol")
+	if err = vol.Resize(BlockSize*15, false); err != torus.ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	var told uint64
+	f.OnResize(func(size uint64) { told = size })
+	if err = vol.RequestResize(BlockSize*15, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.CheckResize(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() != BlockSize*15 || told != BlockSize*15 {
+		t.Fatalf("attached volume is %d bytes, told %d", f.Size(), told)
+	}
+	if pending, err := vol.ResizeRequested(); err != nil || pending != 0 {
+		t.Fatalf("resize still pending: %d, %v", pending, err)
+	}
+
+	// A request made while a resize runs is kept for the next check.
+	requested := false
+	f.OnResize(func(size uint64) {
+		if !requested {
+			requested = true
+			if err := vol.RequestResize(BlockSize*17, false); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if err = vol.RequestResize(BlockSize*16, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.CheckResize(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := vol.ResizeRequested(); err != nil || pending != BlockSize*17 {
+		t.Fatalf("pending resize is %d, %v", pending, err)
+	}
+	if err = f.CheckResize(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() != BlockSize*17 {
+		t.Fatalf("attached volume is %d bytes", f.Size())
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	v, err := client.MDS.GetVolume("testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.MaxBytes != BlockSize*17 {
+		t.Fatalf("volume size is %d", v.MaxBytes)
+	}
+	closeAll(t, servers...)
+}
+
+func TestUsage(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	f := createVol(t, client, "testvol", uint64(BlockSize*20))
+	if _, err = f.Write(data); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	vol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.SaveSnapshot("one"); err != nil {
+		t.Fatal(err)
+	}
+	f = openVol(t, client, "testvol")
+	if _, err = f.WriteAt(data[:BlockSize*5], 0); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+
+	if err = block.NewUsageAccountant(servers[0], time.Minute).RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := block.GetUsage(client.MDS, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ring keeps two copies of every block.
+	if u == nil || u.Live != BlockSize*10 || u.Snapshots != BlockSize*5 || u.Replicas != BlockSize*15 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	// Over its quota, the volume refuses writes.
+	if err = vol.SetQuota(BlockSize * 12); err != nil {
+		t.Fatal(err)
+	}
+	f = openVol(t, client, "testvol")
+	if _, err = f.WriteAt(data[:BlockSize], 0); err != block.ErrQuota {
+		t.Fatalf("expected ErrQuota, got %v", err)
+	}
+	if err = vol.DeleteSnapshot("one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = vol.UpdateUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.CheckQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt(data[:BlockSize], 0); err != nil {
+		t.Fatalf("couldn't write under quota: %v", err)
+	}
+
+	// New blocks count as they're written, before any accounting: the
+	// volume has 10 of its 12 blocks.
+	if _, err = f.WriteAt(data[:BlockSize*2], BlockSize*10); err != nil {
+		t.Fatalf("couldn't write up to the quota: %v", err)
+	}
+	if _, err = f.WriteAt(data[:BlockSize], BlockSize*12); err != block.ErrQuota {
+		t.Fatalf("expected ErrQuota past the quota, got %v", err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.CheckQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt(data[:BlockSize], BlockSize*12); err != block.ErrQuota {
+		t.Fatalf("expected ErrQuota past the quota after syncing, got %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestTrash(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	f := createVol(t, client, "testvol", uint64(len(data)))
+	if _, err = f.Write(data); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	// An attached volume can't be deleted.
+	if err = block.TrashBlockVolume(client.MDS, "testvol", time.Hour); err != torus.ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if err = block.TrashBlockVolume(client.MDS, "testvol", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.MDS.GetVolume("testvol"); err == nil {
+		t.Fatal("trashed volume is still visible")
+	}
+	trash, err := block.ListTrash(client.MDS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Volume.Name != "testvol" {
+		t.Fatalf("unexpected trash %v", trash)
+	}
+	id := torus.VolumeID(trash[0].Volume.Id)
+
+	// Its name is free while it's in the trash.
+	createVol(t, client, "testvol", BlockSize).Close()
+	if err = block.UndeleteBlockVolume(client.MDS, id, ""); err != torus.ErrExists {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	if err = block.UndeleteBlockVolume(client.MDS, id, "restored"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readVol(t, client, "restored"), data) {
+		t.Fatal("undeleted volume didn't keep its data")
+	}
+
+	if err = block.TrashBlockVolume(client.MDS, "restored", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = block.PurgeBlockVolume(client.MDS, id); err != nil {
+		t.Fatal(err)
+	}
+	if trash, err = block.ListTrash(client.MDS); err != nil || len(trash) != 0 {
+		t.Fatalf("unexpected trash %v, %v", trash, err)
+	}
+	if err = block.UndeleteBlockVolume(client.MDS, id, ""); err != torus.ErrNotExist {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+
+	// Only volumes past their retention are purged as expired.
+	createVol(t, client, "expired", BlockSize).Close()
+	if err = block.TrashBlockVolume(client.MDS, "expired", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = block.TrashBlockVolume(client.MDS, "testvol", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	purged, err := block.PurgeExpiredTrash(client.MDS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Volume.Name != "expired" {
+		t.Fatalf("unexpected purge %v", purged)
+	}
+	if trash, err = block.ListTrash(client.MDS); err != nil || len(trash) != 1 || trash[0].Volume.Name != "testvol" {
+		t.Fatalf("unexpected trash %v, %v", trash, err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestSnapshotGroup(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	for _, name := range []string{"data", "wal"} {
+		f := createVol(t, client, name, uint64(len(data)))
+		if _, err = f.Write(data); err != nil {
+			t.Fatalf("couldn't write: %v", err)
+		}
+		if err = f.Close(); err != nil {
+			t.Fatalf("couldn't close: %v", err)
+		}
+	}
+	if err = block.CreateSnapshotGroup(client.MDS, "backup", []string{"data", "wal"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = block.CreateSnapshotGroup(client.MDS, "backup", []string{"data"}); err != torus.ErrExists {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	groups, err := block.ListSnapshotGroups(client.MDS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Name != "backup" || len(groups[0].Volumes) != 2 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	for _, name := range []string{"data", "wal"} {
+		f := openVol(t, client, name)
+		if _, err = f.WriteAt(make([]byte, BlockSize), 0); err != nil {
+			t.Fatalf("couldn't write: %v", err)
+		}
+		if err = f.Close(); err != nil {
+			t.Fatalf("couldn't close: %v", err)
+		}
+	}
+	// Attached volumes can't be restored.
+	f := openVol(t, client, "wal")
+	if err = block.RestoreSnapshotGroup(client, "backup"); err != torus.ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if err = block.RestoreSnapshotGroup(client, "backup"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"data", "wal"} {
+		if !bytes.Equal(readVol(t, client, name), data) {
+			t.Fatalf("volume %s wasn't restored", name)
+		}
+	}
+
+	if err = block.DeleteSnapshotGroup(client.MDS, "backup"); err != nil {
+		t.Fatal(err)
+	}
+	vol, err := block.OpenBlockVolume(client, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snaps, err := vol.GetSnapshots(); err != nil || len(snaps) != 0 {
+		t.Fatalf("unexpected snapshots %v, %v", snaps, err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestQoS(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 20)
+	createVol(t, client, "testvol", uint64(len(data))).Close()
+	vol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.SetQoS(&block.QoS{IOPS: 100, IOPSBurst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	f := openVol(t, client, "testvol")
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if _, err = f.WriteAt(data[i*BlockSize:(i+1)*BlockSize], int64(i*BlockSize)); err != nil {
+			t.Fatalf("couldn't write: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 190*time.Millisecond {
+		t.Fatalf("20 writes at 100 IOPS took only %s", d)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("couldn't close: %v", err)
+	}
+	if !bytes.Equal(readVol(t, client, "testvol"), data) {
+		t.Fatal("throttled writes didn't land")
+	}
+
+	if err = vol.SetQoS(&block.QoS{Bandwidth: BlockSize * 100, BandwidthBurst: BlockSize}); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	readVol(t, client, "testvol")
+	if d := time.Since(start); d < 190*time.Millisecond {
+		t.Fatalf("reading 20 blocks at 100 blocks per second took only %s", d)
+	}
+	if err = vol.SetQoS(nil); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := vol.QoS(); err != nil || q != nil {
+		t.Fatalf("unexpected QoS %v, %v", q, err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestZeroAndExtents(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	f := createVol(t, client, "testvol", uint64(len(data)))
+	if _, err = f.WriteAt(data[:BlockSize*4], 0); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+	ext, err := f.Extents(0, BlockSize*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext) != 2 || ext[0].Hole || ext[0].Length != BlockSize*4 || !ext[1].Hole {
+		t.Fatalf("unexpected extents %v", ext)
+	}
+
+	// Zero from the middle of block 1 to the middle of block 3.
+	if err = f.Zero(BlockSize+10, BlockSize*2); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{}, data[:BlockSize*4]...)
+	copy(want[BlockSize+10:BlockSize*3+10], make([]byte, BlockSize*2))
+	got := make([]byte, BlockSize*4)
+	if _, err = f.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("zeroed data doesn't read as zeroes")
+	}
+	ext, err = f.Extents(0, BlockSize*4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext) != 3 || ext[1].Length != BlockSize || !ext[1].Hole {
+		t.Fatalf("unexpected extents %v", ext)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestOpenSnapshot(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	client := newServer(t, mds)
+	err := distributor.OpenReplication(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data := makeTestData(BlockSize * 10)
+	f := createVol(t, client, "testvol", uint64(len(data)))
+	if _, err = f.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	vol, err := block.OpenBlockVolume(client, "testvol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vol.SaveSnapshot("snap"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt(make([]byte, BlockSize), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading a snapshot while the volume is open, and closing it, mustn't
+	// disturb the volume's lock.
+	snap, err := vol.OpenSnapshot("snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(data))
+	if _, err = snap.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("snapshot doesn't read as it was taken")
+	}
+	if _, err = snap.WriteAt(got[:BlockSize], 0); err == nil {
+		t.Fatal("wrote to a snapshot")
+	}
+	if err = snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatalf("couldn't sync after closing the snapshot: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	closeAll(t, servers...)
+}
+
+func TestReservations(t *testing.T) {
+	servers, mds := ringN(t, 3)
+	// Two hosts exporting the same volume.
+	hosts := []*torus.Server{newServer(t, mds), newServer(t, mds)}
+	var vols []*block.BlockVolume
+	for _, h := range hosts {
+		if err := distributor.OpenReplication(h); err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+	}
+	createVol(t, hosts[0], "testvol", BlockSize*10).Close()
+	for _, h := range hosts {
+		vol, err := block.OpenBlockVolume(h, "testvol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		vols = append(vols, vol)
+	}
+
+	params := make([]byte, 24)
+	params[15] = 0xa
+	register := []byte{0x5f, 0, 0, 0, 0, 0, 0, 0, 24, 0}
+	reserve := []byte{0x5f, 1, byte(reservation.WriteExclusive), 0, 0, 0, 0, 0, 24, 0}
+	a := reservation.New(vols[0]).Nexus("a")
+	if err := a.Out(register, params); err != nil {
+		t.Fatalf("couldn't register: %v", err)
+	}
+	copy(params[0:8], params[8:16])
+	if err := a.Out(reserve, params); err != nil {
+		t.Fatalf("couldn't reserve: %v", err)
+	}
+	b := reservation.New(vols[1]).Nexus("b")
+	if err := b.Check(false); err != nil {
+		t.Fatalf("read on the other host: %v", err)
+	}
+	if err := b.Check(true); err != reservation.ErrConflict {
+		t.Fatalf("write on the other host: got %v", err)
+	}
+
+	_, version, err := vols[1].Reservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vols[1].SetReservations(nil, version-1); err != torus.ErrAgain {
+		t.Fatalf("set of an old version: got %v", err)
+	}
+	closeAll(t, servers...)
+}
@@ -0,0 +1,419 @@
+package csi
+
+import (
+	"fmt"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/models"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// cloneSnapshotPrefix names the snapshot a volume is cloned from, which is
+// deleted once the clone is made.
+const cloneSnapshotPrefix = "csi-clone-"
+
+// accessMode returns the access mode of c, or UNKNOWN if it has none.
+func accessMode(c *VolumeCapability) VolumeCapability_AccessMode_Mode {
+	if m := c.GetAccessMode(); m != nil {
+		return m.Mode
+	}
+	return VolumeCapability_AccessMode_UNKNOWN
+}
+
+// capacity returns the bounds of cr, which are 0 if it has none.
+func capacity(cr *CapacityRange) (required, limit int64) {
+	if cr == nil {
+		return 0, 0
+	}
+	return cr.RequiredBytes, cr.LimitBytes
+}
+
+// checkCapability returns why a volume can't be used as c, or the empty
+// string if it can.
+func checkCapability(c *VolumeCapability) string {
+	if c.GetBlock() == nil && c.GetMount() == nil {
+		return "access type is missing"
+	}
+	switch mode := accessMode(c); mode {
+	case VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+		VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		return ""
+	default:
+		return fmt.Sprintf("access mode %s isn't supported; a volume is attached to one node at a time", mode)
+	}
+}
+
+// volumeSize returns the size to make a volume within cr, which is def if
+// cr doesn't ask for one, rounded up to whole blocks.
+func (d *Driver) volumeSize(cr *CapacityRange, def uint64) (uint64, error) {
+	required, limit := capacity(cr)
+	if required < 0 || limit < 0 || limit != 0 && required > limit {
+		return 0, grpc.Errorf(codes.InvalidArgument, "bad capacity range %d-%d", required, limit)
+	}
+	size := uint64(required)
+	if size == 0 {
+		size = def
+		if limit != 0 && uint64(limit) < size {
+			size = uint64(limit)
+		}
+	}
+	bs := d.srv.MDS.GlobalMetadata().BlockSize
+	size = (size + bs - 1) / bs * bs
+	if limit != 0 && size > uint64(limit) {
+		return 0, grpc.Errorf(codes.OutOfRange, "no size of whole %d byte blocks in %d-%d", bs, required, limit)
+	}
+	return size, nil
+}
+
+func volumeInfo(vol *models.Volume, src *VolumeContentSource) *Volume {
+	return &Volume{
+		CapacityBytes: int64(vol.MaxBytes),
+		VolumeId:      vol.Name,
+		ContentSource: src,
+	}
+}
+
+func (d *Driver) CreateVolume(ctx context.Context, req *CreateVolumeRequest) (*CreateVolumeResponse, error) {
+	name := req.Name
+	if name == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume name is missing")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume capabilities are missing")
+	}
+	for _, c := range req.GetVolumeCapabilities() {
+		if why := checkCapability(c); why != "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "%s", why)
+		}
+	}
+
+	// The source of a clone gives its default size.
+	src := req.GetVolumeContentSource()
+	var source *models.Volume
+	var snapshot string
+	var err error
+	switch {
+	case src.GetSnapshot() != nil:
+		id := src.GetSnapshot().SnapshotId
+		vol, snap, ok := parseSnapshotID(id)
+		if !ok {
+			return nil, grpc.Errorf(codes.NotFound, "no snapshot %s", id)
+		}
+		snapshot = snap
+		source, err = d.lookup(vol)
+	case src.GetVolume() != nil:
+		source, err = d.lookup(src.GetVolume().VolumeId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	def := uint64(defaultVolumeSize)
+	if source != nil {
+		def = source.MaxBytes
+	}
+	size, err := d.volumeSize(req.GetCapacityRange(), def)
+	if err != nil {
+		return nil, err
+	}
+	if source != nil && size < source.MaxBytes {
+		return nil, grpc.Errorf(codes.OutOfRange, "%d bytes is smaller than the %d byte source", size, source.MaxBytes)
+	}
+
+	// Creating a volume which already exists succeeds, if it's compatible.
+	if vol, err := d.lookup(name); err == nil {
+		if _, limit := capacity(req.GetCapacityRange()); vol.MaxBytes < size || limit != 0 && vol.MaxBytes > uint64(limit) {
+			return nil, grpc.Errorf(codes.AlreadyExists, "volume %s exists with size %d", name, vol.MaxBytes)
+		}
+		return &CreateVolumeResponse{Volume: volumeInfo(vol, src)}, nil
+	} else if grpc.Code(err) != codes.NotFound {
+		return nil, err
+	}
+
+	switch {
+	case source == nil:
+		err = block.CreateBlockVolume(d.srv.MDS, name, size)
+	case snapshot != "":
+		err = d.clone(source, snapshot, name, size)
+	default:
+		err = d.cloneVolume(source, name, size)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	vol, err := d.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	clog.Infof("created volume %s of %d bytes", name, vol.MaxBytes)
+	return &CreateVolumeResponse{Volume: volumeInfo(vol, src)}, nil
+}
+
+// clone creates the volume name from a snapshot of source, and grows it to
+// size.
+func (d *Driver) clone(source *models.Volume, snapshot, name string, size uint64) error {
+	if err := block.CloneSnapshot(d.srv.MDS, source.Name, snapshot, name); err != nil {
+		return err
+	}
+	if size == source.MaxBytes {
+		return nil
+	}
+	vol, err := block.OpenBlockVolume(d.srv, name)
+	if err != nil {
+		return err
+	}
+	return vol.Resize(size, false)
+}
+
+// cloneVolume creates the volume name from the current contents of source.
+// Clones share their blocks with the snapshot they're made from, so the
+// snapshot taken to clone isn't needed once the clone exists.
+func (d *Driver) cloneVolume(source *models.Volume, name string, size uint64) error {
+	vol, err := block.OpenBlockVolume(d.srv, source.Name)
+	if err != nil {
+		return err
+	}
+	snapshot := cloneSnapshotPrefix + name
+	// Drop what's left of an earlier attempt.
+	vol.DeleteSnapshot(snapshot)
+	if err = vol.SaveSnapshot(snapshot); err != nil {
+		return err
+	}
+	defer func() {
+		if err := vol.DeleteSnapshot(snapshot); err != nil {
+			clog.Warningf("couldn't delete snapshot %s of %s: %v", snapshot, source.Name, err)
+		}
+	}()
+	return d.clone(source, snapshot, name, size)
+}
+
+func (d *Driver) DeleteVolume(ctx context.Context, req *DeleteVolumeRequest) (*DeleteVolumeResponse, error) {
+	id := req.VolumeId
+	if id == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID is missing")
+	}
+	if _, err := d.lookup(id); grpc.Code(err) == codes.NotFound {
+		return &DeleteVolumeResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	vol, err := block.OpenBlockVolume(d.srv, id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	// Snapshots go with their volume, so keep volumes which have them.
+	snaps, err := vol.GetSnapshots()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if len(snaps) != 0 {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "volume %s has %d snapshots", id, len(snaps))
+	}
+	switch err = block.DeleteBlockVolume(d.srv.MDS, id); err {
+	case nil, torus.ErrNotExist:
+		clog.Infof("deleted volume %s", id)
+		return &DeleteVolumeResponse{}, nil
+	case torus.ErrLocked:
+		return nil, grpc.Errorf(codes.FailedPrecondition, "volume %s is attached", id)
+	}
+	return nil, toStatus(err)
+}
+
+func (d *Driver) ValidateVolumeCapabilities(ctx context.Context, req *ValidateVolumeCapabilitiesRequest) (*ValidateVolumeCapabilitiesResponse, error) {
+	if req.VolumeId == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID is missing")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume capabilities are missing")
+	}
+	if _, err := d.lookup(req.VolumeId); err != nil {
+		return nil, err
+	}
+	for _, c := range req.GetVolumeCapabilities() {
+		if why := checkCapability(c); why != "" {
+			return &ValidateVolumeCapabilitiesResponse{Message: why}, nil
+		}
+	}
+	return &ValidateVolumeCapabilitiesResponse{
+		Confirmed: &ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+			Parameters:         req.GetParameters(),
+		},
+	}, nil
+}
+
+func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *ControllerGetCapabilitiesRequest) (*ControllerGetCapabilitiesResponse, error) {
+	var caps []*ControllerServiceCapability
+	for _, t := range []ControllerServiceCapability_RPC_Type{
+		ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+		ControllerServiceCapability_RPC_CLONE_VOLUME,
+		ControllerServiceCapability_RPC_EXPAND_VOLUME,
+	} {
+		caps = append(caps, &ControllerServiceCapability{Rpc: &ControllerServiceCapability_RPC{Type: t}})
+	}
+	return &ControllerGetCapabilitiesResponse{Capabilities: caps}, nil
+}
+
+func snapshotInfo(vol *models.Volume, s block.Snapshot) *Snapshot {
+	return &Snapshot{
+		SizeBytes:      int64(vol.MaxBytes),
+		SnapshotId:     snapshotID(vol.Name, s.Name),
+		SourceVolumeId: vol.Name,
+		CreationTime:   &Timestamp{Seconds: s.When.Unix(), Nanos: int32(s.When.Nanosecond())},
+		ReadyToUse:     true,
+	}
+}
+
+// findSnapshot returns the volume with a snapshot called name, and the
+// snapshot, or a nil volume if there's none.
+func (d *Driver) findSnapshot(name string) (*models.Volume, block.Snapshot, error) {
+	vols, _, err := d.srv.MDS.GetVolumes()
+	if err != nil {
+		return nil, block.Snapshot{}, err
+	}
+	for _, v := range vols {
+		if v.Type != block.VolumeType {
+			continue
+		}
+		vol, err := block.OpenBlockVolume(d.srv, v.Name)
+		if err != nil {
+			return nil, block.Snapshot{}, err
+		}
+		snaps, err := vol.GetSnapshots()
+		if err != nil {
+			return nil, block.Snapshot{}, err
+		}
+		for _, s := range snaps {
+			if s.Name == name {
+				return v, s, nil
+			}
+		}
+	}
+	return nil, block.Snapshot{}, nil
+}
+
+func (d *Driver) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*CreateSnapshotResponse, error) {
+	name, source := req.Name, req.SourceVolumeId
+	if name == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "snapshot name is missing")
+	}
+	if source == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "source volume ID is missing")
+	}
+	// Names are unique across volumes; taking a snapshot which was already
+	// taken succeeds.
+	vol, snap, err := d.findSnapshot(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if vol != nil {
+		if vol.Name != source {
+			return nil, grpc.Errorf(codes.AlreadyExists, "snapshot %s is of volume %s", name, vol.Name)
+		}
+		return &CreateSnapshotResponse{Snapshot: snapshotInfo(vol, snap)}, nil
+	}
+
+	if vol, err = d.lookup(source); err != nil {
+		return nil, err
+	}
+	blockvol, err := block.OpenBlockVolume(d.srv, source)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if err = blockvol.SaveSnapshot(name); err != nil {
+		return nil, toStatus(err)
+	}
+	snaps, err := blockvol.GetSnapshots()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, s := range snaps {
+		if s.Name == name {
+			clog.Infof("took snapshot %s of volume %s", name, source)
+			return &CreateSnapshotResponse{Snapshot: snapshotInfo(vol, s)}, nil
+		}
+	}
+	return nil, grpc.Errorf(codes.Aborted, "snapshot %s of volume %s was deleted", name, source)
+}
+
+func (d *Driver) DeleteSnapshot(ctx context.Context, req *DeleteSnapshotRequest) (*DeleteSnapshotResponse, error) {
+	if req.SnapshotId == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "snapshot ID is missing")
+	}
+	// Deleting a snapshot which doesn't exist succeeds.
+	volume, name, ok := parseSnapshotID(req.SnapshotId)
+	if !ok {
+		return &DeleteSnapshotResponse{}, nil
+	}
+	if _, err := d.lookup(volume); grpc.Code(err) == codes.NotFound {
+		return &DeleteSnapshotResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	vol, err := block.OpenBlockVolume(d.srv, volume)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	snaps, err := vol.GetSnapshots()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, s := range snaps {
+		if s.Name != name {
+			continue
+		}
+		if err = vol.DeleteSnapshot(name); err != nil {
+			return nil, toStatus(err)
+		}
+		clog.Infof("deleted snapshot %s of volume %s", name, volume)
+		break
+	}
+	return &DeleteSnapshotResponse{}, nil
+}
+
+func (d *Driver) ControllerExpandVolume(ctx context.Context, req *ControllerExpandVolumeRequest) (*ControllerExpandVolumeResponse, error) {
+	id := req.VolumeId
+	if id == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID is missing")
+	}
+	if required, _ := capacity(req.GetCapacityRange()); required <= 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "required capacity is missing")
+	}
+	size, err := d.volumeSize(req.GetCapacityRange(), 0)
+	if err != nil {
+		return nil, err
+	}
+	vol, err := d.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	// Filesystems on the volume have to be grown by the node.
+	res := &ControllerExpandVolumeResponse{
+		NodeExpansionRequired: req.GetVolumeCapability().GetBlock() == nil,
+	}
+	if vol.MaxBytes >= size {
+		res.CapacityBytes = int64(vol.MaxBytes)
+		return res, nil
+	}
+	blockvol, err := block.OpenBlockVolume(d.srv, id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = blockvol.Resize(size, false)
+	if err == torus.ErrLocked {
+		// The volume is attached; the node it's attached to resizes it.
+		err = blockvol.RequestResize(size, false)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	clog.Infof("resized volume %s from %d to %d bytes", id, vol.MaxBytes, size)
+	res.CapacityBytes = int64(size)
+	return res, nil
+}
@@ -0,0 +1,278 @@
+package csi
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	_ "github.com/coreos/torus/metadata/temp"
+	_ "github.com/coreos/torus/storage"
+)
+
+var mountCapability = []*VolumeCapability{{
+	Mount:      &VolumeCapability_MountVolume{FsType: "ext4"},
+	AccessMode: &VolumeCapability_AccessMode{Mode: VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+}}
+
+type controllerTest struct {
+	t      *testing.T
+	srv    *torus.Server
+	s      *grpc.Server
+	conn   *grpc.ClientConn
+	client ControllerClient
+	ctx    context.Context
+}
+
+// newControllerTest serves the controller over gRPC, backed by the temp
+// metadata service.
+func newControllerTest(t *testing.T) *controllerTest {
+	srv := torus.NewMemoryServer()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	NewDriver(srv, Options{Controller: true}).Register(s)
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &controllerTest{
+		t:      t,
+		srv:    srv,
+		s:      s,
+		conn:   conn,
+		client: NewControllerClient(conn),
+		ctx:    context.Background(),
+	}
+}
+
+func (ct *controllerTest) close() {
+	ct.conn.Close()
+	ct.s.Stop()
+	ct.srv.Close()
+}
+
+func (ct *controllerTest) create(req *CreateVolumeRequest) *Volume {
+	if req.VolumeCapabilities == nil {
+		req.VolumeCapabilities = mountCapability
+	}
+	res, err := ct.client.CreateVolume(ct.ctx, req)
+	if err != nil {
+		ct.t.Fatalf("create volume %s: %v", req.Name, err)
+	}
+	return res.Volume
+}
+
+func expectCode(t *testing.T, what string, err error, want codes.Code) {
+	if got := grpc.Code(err); got != want {
+		t.Fatalf("%s: got %v (%v), want %s", what, got, err, want)
+	}
+}
+
+func TestCreateDeleteVolume(t *testing.T) {
+	ct := newControllerTest(t)
+	defer ct.close()
+
+	vol := ct.create(&CreateVolumeRequest{
+		Name:          "pvc-1",
+		CapacityRange: &CapacityRange{RequiredBytes: 1000},
+		Parameters:    map[string]string{"unused": "yes"},
+	})
+	if vol.VolumeId != "pvc-1" || vol.CapacityBytes != 1024 {
+		t.Fatalf("unexpected volume %+v", vol)
+	}
+	if v, err := ct.srv.MDS.GetVolume("pvc-1"); err != nil || v.Type != block.VolumeType || v.MaxBytes != 1024 {
+		t.Fatalf("volume not created: %+v, %v", v, err)
+	}
+	if vol = ct.create(&CreateVolumeRequest{Name: "pvc-1", CapacityRange: &CapacityRange{RequiredBytes: 1000}}); vol.CapacityBytes != 1024 {
+		t.Fatalf("creating again: unexpected volume %+v", vol)
+	}
+	_, err := ct.client.CreateVolume(ct.ctx, &CreateVolumeRequest{
+		Name:               "pvc-1",
+		CapacityRange:      &CapacityRange{RequiredBytes: 4096},
+		VolumeCapabilities: mountCapability,
+	})
+	expectCode(t, "create with another size", err, codes.AlreadyExists)
+	_, err = ct.client.CreateVolume(ct.ctx, &CreateVolumeRequest{
+		Name:          "pvc-2",
+		CapacityRange: &CapacityRange{RequiredBytes: 1000, LimitBytes: 1000},
+		VolumeCapabilities: []*VolumeCapability{{
+			Block:      &VolumeCapability_BlockVolume{},
+			AccessMode: &VolumeCapability_AccessMode{Mode: VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+	})
+	expectCode(t, "create within a limit under a block", err, codes.OutOfRange)
+	_, err = ct.client.CreateVolume(ct.ctx, &CreateVolumeRequest{
+		Name: "pvc-2",
+		VolumeCapabilities: []*VolumeCapability{{
+			Mount:      &VolumeCapability_MountVolume{},
+			AccessMode: &VolumeCapability_AccessMode{Mode: VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+		}},
+	})
+	expectCode(t, "create shared between nodes", err, codes.InvalidArgument)
+
+	res, err := ct.client.ValidateVolumeCapabilities(ct.ctx, &ValidateVolumeCapabilitiesRequest{
+		VolumeId:           "pvc-1",
+		VolumeCapabilities: mountCapability,
+	})
+	if err != nil || res.Confirmed == nil || len(res.Confirmed.VolumeCapabilities) != 1 {
+		t.Fatalf("validate: unexpected response %+v, %v", res, err)
+	}
+
+	if _, err = ct.client.DeleteVolume(ct.ctx, &DeleteVolumeRequest{VolumeId: "pvc-1"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err = ct.srv.MDS.GetVolume("pvc-1"); err == nil {
+		t.Fatal("volume not deleted")
+	}
+	if _, err = ct.client.DeleteVolume(ct.ctx, &DeleteVolumeRequest{VolumeId: "pvc-1"}); err != nil {
+		t.Fatalf("delete again: %v", err)
+	}
+	_, err = ct.client.ValidateVolumeCapabilities(ct.ctx, &ValidateVolumeCapabilitiesRequest{
+		VolumeId:           "pvc-1",
+		VolumeCapabilities: mountCapability,
+	})
+	expectCode(t, "validate deleted volume", err, codes.NotFound)
+}
+
+func TestSnapshotAndClone(t *testing.T) {
+	ct := newControllerTest(t)
+	defer ct.close()
+
+	ct.create(&CreateVolumeRequest{Name: "pvc-1", CapacityRange: &CapacityRange{RequiredBytes: 1024}})
+	blockvol, err := block.OpenBlockVolume(ct.srv, "pvc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := blockvol.OpenBlockFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte("golden"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ct.client.CreateSnapshot(ct.ctx, &CreateSnapshotRequest{SourceVolumeId: "pvc-1", Name: "snap-1"})
+	if err != nil {
+		t.Fatalf("create snapshot: %v", err)
+	}
+	snap := res.Snapshot
+	if snap.SnapshotId != "pvc-1@snap-1" || snap.SourceVolumeId != "pvc-1" || !snap.ReadyToUse || snap.CreationTime == nil || snap.CreationTime.Seconds == 0 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	if res, err = ct.client.CreateSnapshot(ct.ctx, &CreateSnapshotRequest{SourceVolumeId: "pvc-1", Name: "snap-1"}); err != nil || res.Snapshot.SnapshotId != snap.SnapshotId {
+		t.Fatalf("create snapshot again: %+v, %v", res, err)
+	}
+	ct.create(&CreateVolumeRequest{Name: "pvc-2"})
+	_, err = ct.client.CreateSnapshot(ct.ctx, &CreateSnapshotRequest{SourceVolumeId: "pvc-2", Name: "snap-1"})
+	expectCode(t, "create snapshot of another volume", err, codes.AlreadyExists)
+
+	_, err = ct.client.DeleteVolume(ct.ctx, &DeleteVolumeRequest{VolumeId: "pvc-1"})
+	expectCode(t, "delete volume with snapshots", err, codes.FailedPrecondition)
+
+	// Clones of the snapshot and of the volume start out with its contents.
+	vol := ct.create(&CreateVolumeRequest{
+		Name:                "from-snap",
+		CapacityRange:       &CapacityRange{RequiredBytes: 2048},
+		VolumeContentSource: &VolumeContentSource{Snapshot: &VolumeContentSource_SnapshotSource{SnapshotId: snap.SnapshotId}},
+	})
+	if vol.CapacityBytes != 2048 || vol.ContentSource.GetSnapshot() == nil || vol.ContentSource.GetSnapshot().SnapshotId != snap.SnapshotId {
+		t.Fatalf("unexpected clone %+v", vol)
+	}
+	vol = ct.create(&CreateVolumeRequest{
+		Name:                "from-vol",
+		VolumeContentSource: &VolumeContentSource{Volume: &VolumeContentSource_VolumeSource{VolumeId: "pvc-1"}},
+	})
+	if vol.CapacityBytes != 1024 {
+		t.Fatalf("unexpected clone %+v", vol)
+	}
+	for _, name := range []string{"from-snap", "from-vol"} {
+		clone, err := block.OpenBlockVolume(ct.srv, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := clone.OpenBlockFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 6)
+		if _, err = f.ReadAt(buf, 0); err != nil || string(buf) != "golden" {
+			t.Fatalf("%s reads %q, %v", name, buf, err)
+		}
+		f.Close()
+	}
+	// Only the requested snapshot is left on the volume.
+	if snaps, err := blockvol.GetSnapshots(); err != nil || len(snaps) != 1 {
+		t.Fatalf("unexpected snapshots %+v, %v", snaps, err)
+	}
+	_, err = ct.client.CreateVolume(ct.ctx, &CreateVolumeRequest{
+		Name:                "missing",
+		VolumeCapabilities:  mountCapability,
+		VolumeContentSource: &VolumeContentSource{Snapshot: &VolumeContentSource_SnapshotSource{SnapshotId: "pvc-1@missing"}},
+	})
+	expectCode(t, "clone of a missing snapshot", err, codes.NotFound)
+
+	for i := 0; i < 2; i++ {
+		if _, err = ct.client.DeleteSnapshot(ct.ctx, &DeleteSnapshotRequest{SnapshotId: snap.SnapshotId}); err != nil {
+			t.Fatalf("delete snapshot: %v", err)
+		}
+	}
+	if _, err = ct.client.DeleteVolume(ct.ctx, &DeleteVolumeRequest{VolumeId: "pvc-1"}); err != nil {
+		t.Fatalf("delete volume: %v", err)
+	}
+}
+
+func TestExpandVolume(t *testing.T) {
+	ct := newControllerTest(t)
+	defer ct.close()
+
+	ct.create(&CreateVolumeRequest{Name: "pvc-1", CapacityRange: &CapacityRange{RequiredBytes: 1024}})
+	res, err := ct.client.ControllerExpandVolume(ct.ctx, &ControllerExpandVolumeRequest{
+		VolumeId:         "pvc-1",
+		CapacityRange:    &CapacityRange{RequiredBytes: 4096},
+		VolumeCapability: mountCapability[0],
+	})
+	if err != nil || res.CapacityBytes != 4096 || !res.NodeExpansionRequired {
+		t.Fatalf("expand: unexpected response %+v, %v", res, err)
+	}
+	if v, err := ct.srv.MDS.GetVolume("pvc-1"); err != nil || v.MaxBytes != 4096 {
+		t.Fatalf("volume not resized: %+v, %v", v, err)
+	}
+
+	// An attached volume is left for its node to resize.
+	blockvol, err := block.OpenBlockVolume(ct.srv, "pvc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := blockvol.OpenBlockFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = ct.client.ControllerExpandVolume(ct.ctx, &ControllerExpandVolumeRequest{
+		VolumeId:      "pvc-1",
+		CapacityRange: &CapacityRange{RequiredBytes: 8192},
+	}); err != nil {
+		t.Fatalf("expand attached volume: %v", err)
+	}
+	if size, err := blockvol.ResizeRequested(); err != nil || size != 8192 {
+		t.Fatalf("resize not requested: %d, %v", size, err)
+	}
+
+	_, err = ct.client.ControllerExpandVolume(ct.ctx, &ControllerExpandVolumeRequest{
+		VolumeId:      "missing",
+		CapacityRange: &CapacityRange{RequiredBytes: 8192},
+	})
+	expectCode(t, "expand missing volume", err, codes.NotFound)
+}
@@ -0,0 +1,2037 @@
+// Code generated by protoc-gen-go.
+// source: csi.proto
+// DO NOT EDIT!
+
+/*
+Package csi is a generated protocol buffer package.
+
+It is generated from these files:
+
+	csi.proto
+
+It has these top-level messages:
+
+	GetPluginInfoRequest
+	GetPluginInfoResponse
+	GetPluginCapabilitiesRequest
+	GetPluginCapabilitiesResponse
+	PluginCapability
+	ProbeRequest
+	ProbeResponse
+	BoolValue
+	Timestamp
+	CreateVolumeRequest
+	CapacityRange
+	VolumeCapability
+	VolumeContentSource
+	CreateVolumeResponse
+	Volume
+	DeleteVolumeRequest
+	DeleteVolumeResponse
+	ValidateVolumeCapabilitiesRequest
+	ValidateVolumeCapabilitiesResponse
+	ControllerGetCapabilitiesRequest
+	ControllerGetCapabilitiesResponse
+	ControllerServiceCapability
+	CreateSnapshotRequest
+	CreateSnapshotResponse
+	Snapshot
+	DeleteSnapshotRequest
+	DeleteSnapshotResponse
+	ControllerExpandVolumeRequest
+	ControllerExpandVolumeResponse
+	NodeStageVolumeRequest
+	NodeStageVolumeResponse
+	NodeUnstageVolumeRequest
+	NodeUnstageVolumeResponse
+	NodePublishVolumeRequest
+	NodePublishVolumeResponse
+	NodeUnpublishVolumeRequest
+	NodeUnpublishVolumeResponse
+	NodeExpandVolumeRequest
+	NodeExpandVolumeResponse
+	NodeGetCapabilitiesRequest
+	NodeGetCapabilitiesResponse
+	NodeServiceCapability
+	NodeGetInfoRequest
+	NodeGetInfoResponse
+*/
+package csi
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type PluginCapability_Service_Type int32
+
+const (
+	PluginCapability_Service_UNKNOWN                          PluginCapability_Service_Type = 0
+	PluginCapability_Service_CONTROLLER_SERVICE               PluginCapability_Service_Type = 1
+	PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS PluginCapability_Service_Type = 2
+)
+
+var PluginCapability_Service_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CONTROLLER_SERVICE",
+	2: "VOLUME_ACCESSIBILITY_CONSTRAINTS",
+}
+var PluginCapability_Service_Type_value = map[string]int32{
+	"UNKNOWN":                          0,
+	"CONTROLLER_SERVICE":               1,
+	"VOLUME_ACCESSIBILITY_CONSTRAINTS": 2,
+}
+
+func (x PluginCapability_Service_Type) String() string {
+	return proto.EnumName(PluginCapability_Service_Type_name, int32(x))
+}
+func (PluginCapability_Service_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{4, 0, 0}
+}
+
+type PluginCapability_VolumeExpansion_Type int32
+
+const (
+	PluginCapability_VolumeExpansion_UNKNOWN PluginCapability_VolumeExpansion_Type = 0
+	PluginCapability_VolumeExpansion_ONLINE  PluginCapability_VolumeExpansion_Type = 1
+	PluginCapability_VolumeExpansion_OFFLINE PluginCapability_VolumeExpansion_Type = 2
+)
+
+var PluginCapability_VolumeExpansion_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "ONLINE",
+	2: "OFFLINE",
+}
+var PluginCapability_VolumeExpansion_Type_value = map[string]int32{
+	"UNKNOWN": 0,
+	"ONLINE":  1,
+	"OFFLINE": 2,
+}
+
+func (x PluginCapability_VolumeExpansion_Type) String() string {
+	return proto.EnumName(PluginCapability_VolumeExpansion_Type_name, int32(x))
+}
+func (PluginCapability_VolumeExpansion_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{4, 1, 0}
+}
+
+type VolumeCapability_AccessMode_Mode int32
+
+const (
+	VolumeCapability_AccessMode_UNKNOWN                   VolumeCapability_AccessMode_Mode = 0
+	VolumeCapability_AccessMode_SINGLE_NODE_WRITER        VolumeCapability_AccessMode_Mode = 1
+	VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY   VolumeCapability_AccessMode_Mode = 2
+	VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY    VolumeCapability_AccessMode_Mode = 3
+	VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER  VolumeCapability_AccessMode_Mode = 4
+	VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER   VolumeCapability_AccessMode_Mode = 5
+	VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER VolumeCapability_AccessMode_Mode = 6
+	VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER  VolumeCapability_AccessMode_Mode = 7
+)
+
+var VolumeCapability_AccessMode_Mode_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "SINGLE_NODE_WRITER",
+	2: "SINGLE_NODE_READER_ONLY",
+	3: "MULTI_NODE_READER_ONLY",
+	4: "MULTI_NODE_SINGLE_WRITER",
+	5: "MULTI_NODE_MULTI_WRITER",
+	6: "SINGLE_NODE_SINGLE_WRITER",
+	7: "SINGLE_NODE_MULTI_WRITER",
+}
+var VolumeCapability_AccessMode_Mode_value = map[string]int32{
+	"UNKNOWN":                   0,
+	"SINGLE_NODE_WRITER":        1,
+	"SINGLE_NODE_READER_ONLY":   2,
+	"MULTI_NODE_READER_ONLY":    3,
+	"MULTI_NODE_SINGLE_WRITER":  4,
+	"MULTI_NODE_MULTI_WRITER":   5,
+	"SINGLE_NODE_SINGLE_WRITER": 6,
+	"SINGLE_NODE_MULTI_WRITER":  7,
+}
+
+func (x VolumeCapability_AccessMode_Mode) String() string {
+	return proto.EnumName(VolumeCapability_AccessMode_Mode_name, int32(x))
+}
+func (VolumeCapability_AccessMode_Mode) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{11, 2, 0}
+}
+
+type ControllerServiceCapability_RPC_Type int32
+
+const (
+	ControllerServiceCapability_RPC_UNKNOWN                  ControllerServiceCapability_RPC_Type = 0
+	ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME     ControllerServiceCapability_RPC_Type = 1
+	ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME ControllerServiceCapability_RPC_Type = 2
+	ControllerServiceCapability_RPC_LIST_VOLUMES             ControllerServiceCapability_RPC_Type = 3
+	ControllerServiceCapability_RPC_GET_CAPACITY             ControllerServiceCapability_RPC_Type = 4
+	ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT   ControllerServiceCapability_RPC_Type = 5
+	ControllerServiceCapability_RPC_LIST_SNAPSHOTS           ControllerServiceCapability_RPC_Type = 6
+	ControllerServiceCapability_RPC_CLONE_VOLUME             ControllerServiceCapability_RPC_Type = 7
+	ControllerServiceCapability_RPC_PUBLISH_READONLY         ControllerServiceCapability_RPC_Type = 8
+	ControllerServiceCapability_RPC_EXPAND_VOLUME            ControllerServiceCapability_RPC_Type = 9
+)
+
+var ControllerServiceCapability_RPC_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CREATE_DELETE_VOLUME",
+	2: "PUBLISH_UNPUBLISH_VOLUME",
+	3: "LIST_VOLUMES",
+	4: "GET_CAPACITY",
+	5: "CREATE_DELETE_SNAPSHOT",
+	6: "LIST_SNAPSHOTS",
+	7: "CLONE_VOLUME",
+	8: "PUBLISH_READONLY",
+	9: "EXPAND_VOLUME",
+}
+var ControllerServiceCapability_RPC_Type_value = map[string]int32{
+	"UNKNOWN":                  0,
+	"CREATE_DELETE_VOLUME":     1,
+	"PUBLISH_UNPUBLISH_VOLUME": 2,
+	"LIST_VOLUMES":             3,
+	"GET_CAPACITY":             4,
+	"CREATE_DELETE_SNAPSHOT":   5,
+	"LIST_SNAPSHOTS":           6,
+	"CLONE_VOLUME":             7,
+	"PUBLISH_READONLY":         8,
+	"EXPAND_VOLUME":            9,
+}
+
+func (x ControllerServiceCapability_RPC_Type) String() string {
+	return proto.EnumName(ControllerServiceCapability_RPC_Type_name, int32(x))
+}
+func (ControllerServiceCapability_RPC_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{21, 0, 0}
+}
+
+type NodeServiceCapability_RPC_Type int32
+
+const (
+	NodeServiceCapability_RPC_UNKNOWN              NodeServiceCapability_RPC_Type = 0
+	NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME NodeServiceCapability_RPC_Type = 1
+	NodeServiceCapability_RPC_GET_VOLUME_STATS     NodeServiceCapability_RPC_Type = 2
+	NodeServiceCapability_RPC_EXPAND_VOLUME        NodeServiceCapability_RPC_Type = 3
+)
+
+var NodeServiceCapability_RPC_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "STAGE_UNSTAGE_VOLUME",
+	2: "GET_VOLUME_STATS",
+	3: "EXPAND_VOLUME",
+}
+var NodeServiceCapability_RPC_Type_value = map[string]int32{
+	"UNKNOWN":              0,
+	"STAGE_UNSTAGE_VOLUME": 1,
+	"GET_VOLUME_STATS":     2,
+	"EXPAND_VOLUME":        3,
+}
+
+func (x NodeServiceCapability_RPC_Type) String() string {
+	return proto.EnumName(NodeServiceCapability_RPC_Type_name, int32(x))
+}
+func (NodeServiceCapability_RPC_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{41, 0, 0}
+}
+
+type GetPluginInfoRequest struct {
+}
+
+func (m *GetPluginInfoRequest) Reset()                    { *m = GetPluginInfoRequest{} }
+func (m *GetPluginInfoRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetPluginInfoRequest) ProtoMessage()               {}
+func (*GetPluginInfoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type GetPluginInfoResponse struct {
+	Name          string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	VendorVersion string            `protobuf:"bytes,2,opt,name=vendor_version,json=vendorVersion" json:"vendor_version,omitempty"`
+	Manifest      map[string]string `protobuf:"bytes,3,rep,name=manifest" json:"manifest,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *GetPluginInfoResponse) Reset()                    { *m = GetPluginInfoResponse{} }
+func (m *GetPluginInfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetPluginInfoResponse) ProtoMessage()               {}
+func (*GetPluginInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *GetPluginInfoResponse) GetManifest() map[string]string {
+	if m != nil {
+		return m.Manifest
+	}
+	return nil
+}
+
+type GetPluginCapabilitiesRequest struct {
+}
+
+func (m *GetPluginCapabilitiesRequest) Reset()                    { *m = GetPluginCapabilitiesRequest{} }
+func (m *GetPluginCapabilitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetPluginCapabilitiesRequest) ProtoMessage()               {}
+func (*GetPluginCapabilitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type GetPluginCapabilitiesResponse struct {
+	Capabilities []*PluginCapability `protobuf:"bytes,1,rep,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *GetPluginCapabilitiesResponse) Reset()                    { *m = GetPluginCapabilitiesResponse{} }
+func (m *GetPluginCapabilitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetPluginCapabilitiesResponse) ProtoMessage()               {}
+func (*GetPluginCapabilitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *GetPluginCapabilitiesResponse) GetCapabilities() []*PluginCapability {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type PluginCapability struct {
+	// In the spec: oneof type { service, volume_expansion }
+	Service         *PluginCapability_Service         `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
+	VolumeExpansion *PluginCapability_VolumeExpansion `protobuf:"bytes,2,opt,name=volume_expansion,json=volumeExpansion" json:"volume_expansion,omitempty"`
+}
+
+func (m *PluginCapability) Reset()                    { *m = PluginCapability{} }
+func (m *PluginCapability) String() string            { return proto.CompactTextString(m) }
+func (*PluginCapability) ProtoMessage()               {}
+func (*PluginCapability) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *PluginCapability) GetService() *PluginCapability_Service {
+	if m != nil {
+		return m.Service
+	}
+	return nil
+}
+
+func (m *PluginCapability) GetVolumeExpansion() *PluginCapability_VolumeExpansion {
+	if m != nil {
+		return m.VolumeExpansion
+	}
+	return nil
+}
+
+type PluginCapability_Service struct {
+	Type PluginCapability_Service_Type `protobuf:"varint,1,opt,name=type,enum=csi.v1.PluginCapability_Service_Type" json:"type,omitempty"`
+}
+
+func (m *PluginCapability_Service) Reset()                    { *m = PluginCapability_Service{} }
+func (m *PluginCapability_Service) String() string            { return proto.CompactTextString(m) }
+func (*PluginCapability_Service) ProtoMessage()               {}
+func (*PluginCapability_Service) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4, 0} }
+
+type PluginCapability_VolumeExpansion struct {
+	Type PluginCapability_VolumeExpansion_Type `protobuf:"varint,1,opt,name=type,enum=csi.v1.PluginCapability_VolumeExpansion_Type" json:"type,omitempty"`
+}
+
+func (m *PluginCapability_VolumeExpansion) Reset()         { *m = PluginCapability_VolumeExpansion{} }
+func (m *PluginCapability_VolumeExpansion) String() string { return proto.CompactTextString(m) }
+func (*PluginCapability_VolumeExpansion) ProtoMessage()    {}
+func (*PluginCapability_VolumeExpansion) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{4, 1}
+}
+
+type ProbeRequest struct {
+}
+
+func (m *ProbeRequest) Reset()                    { *m = ProbeRequest{} }
+func (m *ProbeRequest) String() string            { return proto.CompactTextString(m) }
+func (*ProbeRequest) ProtoMessage()               {}
+func (*ProbeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type ProbeResponse struct {
+	Ready *BoolValue `protobuf:"bytes,1,opt,name=ready" json:"ready,omitempty"`
+}
+
+func (m *ProbeResponse) Reset()                    { *m = ProbeResponse{} }
+func (m *ProbeResponse) String() string            { return proto.CompactTextString(m) }
+func (*ProbeResponse) ProtoMessage()               {}
+func (*ProbeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ProbeResponse) GetReady() *BoolValue {
+	if m != nil {
+		return m.Ready
+	}
+	return nil
+}
+
+// Shaped as google.protobuf.BoolValue.
+type BoolValue struct {
+	Value bool `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *BoolValue) Reset()                    { *m = BoolValue{} }
+func (m *BoolValue) String() string            { return proto.CompactTextString(m) }
+func (*BoolValue) ProtoMessage()               {}
+func (*BoolValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+// Shaped as google.protobuf.Timestamp.
+type Timestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds" json:"seconds,omitempty"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos" json:"nanos,omitempty"`
+}
+
+func (m *Timestamp) Reset()                    { *m = Timestamp{} }
+func (m *Timestamp) String() string            { return proto.CompactTextString(m) }
+func (*Timestamp) ProtoMessage()               {}
+func (*Timestamp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+type CreateVolumeRequest struct {
+	Name                string               `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	CapacityRange       *CapacityRange       `protobuf:"bytes,2,opt,name=capacity_range,json=capacityRange" json:"capacity_range,omitempty"`
+	VolumeCapabilities  []*VolumeCapability  `protobuf:"bytes,3,rep,name=volume_capabilities,json=volumeCapabilities" json:"volume_capabilities,omitempty"`
+	Parameters          map[string]string    `protobuf:"bytes,4,rep,name=parameters" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Secrets             map[string]string    `protobuf:"bytes,5,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeContentSource *VolumeContentSource `protobuf:"bytes,6,opt,name=volume_content_source,json=volumeContentSource" json:"volume_content_source,omitempty"`
+}
+
+func (m *CreateVolumeRequest) Reset()                    { *m = CreateVolumeRequest{} }
+func (m *CreateVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateVolumeRequest) ProtoMessage()               {}
+func (*CreateVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *CreateVolumeRequest) GetCapacityRange() *CapacityRange {
+	if m != nil {
+		return m.CapacityRange
+	}
+	return nil
+}
+
+func (m *CreateVolumeRequest) GetVolumeCapabilities() []*VolumeCapability {
+	if m != nil {
+		return m.VolumeCapabilities
+	}
+	return nil
+}
+
+func (m *CreateVolumeRequest) GetParameters() map[string]string {
+	if m != nil {
+		return m.Parameters
+	}
+	return nil
+}
+
+func (m *CreateVolumeRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *CreateVolumeRequest) GetVolumeContentSource() *VolumeContentSource {
+	if m != nil {
+		return m.VolumeContentSource
+	}
+	return nil
+}
+
+type CapacityRange struct {
+	RequiredBytes int64 `protobuf:"varint,1,opt,name=required_bytes,json=requiredBytes" json:"required_bytes,omitempty"`
+	LimitBytes    int64 `protobuf:"varint,2,opt,name=limit_bytes,json=limitBytes" json:"limit_bytes,omitempty"`
+}
+
+func (m *CapacityRange) Reset()                    { *m = CapacityRange{} }
+func (m *CapacityRange) String() string            { return proto.CompactTextString(m) }
+func (*CapacityRange) ProtoMessage()               {}
+func (*CapacityRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type VolumeCapability struct {
+	// In the spec: oneof access_type { block, mount }
+	Block      *VolumeCapability_BlockVolume `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	Mount      *VolumeCapability_MountVolume `protobuf:"bytes,2,opt,name=mount" json:"mount,omitempty"`
+	AccessMode *VolumeCapability_AccessMode  `protobuf:"bytes,3,opt,name=access_mode,json=accessMode" json:"access_mode,omitempty"`
+}
+
+func (m *VolumeCapability) Reset()                    { *m = VolumeCapability{} }
+func (m *VolumeCapability) String() string            { return proto.CompactTextString(m) }
+func (*VolumeCapability) ProtoMessage()               {}
+func (*VolumeCapability) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *VolumeCapability) GetBlock() *VolumeCapability_BlockVolume {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+func (m *VolumeCapability) GetMount() *VolumeCapability_MountVolume {
+	if m != nil {
+		return m.Mount
+	}
+	return nil
+}
+
+func (m *VolumeCapability) GetAccessMode() *VolumeCapability_AccessMode {
+	if m != nil {
+		return m.AccessMode
+	}
+	return nil
+}
+
+type VolumeCapability_BlockVolume struct {
+}
+
+func (m *VolumeCapability_BlockVolume) Reset()         { *m = VolumeCapability_BlockVolume{} }
+func (m *VolumeCapability_BlockVolume) String() string { return proto.CompactTextString(m) }
+func (*VolumeCapability_BlockVolume) ProtoMessage()    {}
+func (*VolumeCapability_BlockVolume) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{11, 0}
+}
+
+type VolumeCapability_MountVolume struct {
+	FsType     string   `protobuf:"bytes,1,opt,name=fs_type,json=fsType" json:"fs_type,omitempty"`
+	MountFlags []string `protobuf:"bytes,2,rep,name=mount_flags,json=mountFlags" json:"mount_flags,omitempty"`
+}
+
+func (m *VolumeCapability_MountVolume) Reset()         { *m = VolumeCapability_MountVolume{} }
+func (m *VolumeCapability_MountVolume) String() string { return proto.CompactTextString(m) }
+func (*VolumeCapability_MountVolume) ProtoMessage()    {}
+func (*VolumeCapability_MountVolume) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{11, 1}
+}
+
+type VolumeCapability_AccessMode struct {
+	Mode VolumeCapability_AccessMode_Mode `protobuf:"varint,1,opt,name=mode,enum=csi.v1.VolumeCapability_AccessMode_Mode" json:"mode,omitempty"`
+}
+
+func (m *VolumeCapability_AccessMode) Reset()         { *m = VolumeCapability_AccessMode{} }
+func (m *VolumeCapability_AccessMode) String() string { return proto.CompactTextString(m) }
+func (*VolumeCapability_AccessMode) ProtoMessage()    {}
+func (*VolumeCapability_AccessMode) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{11, 2}
+}
+
+type VolumeContentSource struct {
+	// In the spec: oneof type { snapshot, volume }
+	Snapshot *VolumeContentSource_SnapshotSource `protobuf:"bytes,1,opt,name=snapshot" json:"snapshot,omitempty"`
+	Volume   *VolumeContentSource_VolumeSource   `protobuf:"bytes,2,opt,name=volume" json:"volume,omitempty"`
+}
+
+func (m *VolumeContentSource) Reset()                    { *m = VolumeContentSource{} }
+func (m *VolumeContentSource) String() string            { return proto.CompactTextString(m) }
+func (*VolumeContentSource) ProtoMessage()               {}
+func (*VolumeContentSource) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *VolumeContentSource) GetSnapshot() *VolumeContentSource_SnapshotSource {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+func (m *VolumeContentSource) GetVolume() *VolumeContentSource_VolumeSource {
+	if m != nil {
+		return m.Volume
+	}
+	return nil
+}
+
+type VolumeContentSource_SnapshotSource struct {
+	SnapshotId string `protobuf:"bytes,1,opt,name=snapshot_id,json=snapshotId" json:"snapshot_id,omitempty"`
+}
+
+func (m *VolumeContentSource_SnapshotSource) Reset()         { *m = VolumeContentSource_SnapshotSource{} }
+func (m *VolumeContentSource_SnapshotSource) String() string { return proto.CompactTextString(m) }
+func (*VolumeContentSource_SnapshotSource) ProtoMessage()    {}
+func (*VolumeContentSource_SnapshotSource) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{12, 0}
+}
+
+type VolumeContentSource_VolumeSource struct {
+	VolumeId string `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+}
+
+func (m *VolumeContentSource_VolumeSource) Reset()         { *m = VolumeContentSource_VolumeSource{} }
+func (m *VolumeContentSource_VolumeSource) String() string { return proto.CompactTextString(m) }
+func (*VolumeContentSource_VolumeSource) ProtoMessage()    {}
+func (*VolumeContentSource_VolumeSource) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{12, 1}
+}
+
+type CreateVolumeResponse struct {
+	Volume *Volume `protobuf:"bytes,1,opt,name=volume" json:"volume,omitempty"`
+}
+
+func (m *CreateVolumeResponse) Reset()                    { *m = CreateVolumeResponse{} }
+func (m *CreateVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateVolumeResponse) ProtoMessage()               {}
+func (*CreateVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *CreateVolumeResponse) GetVolume() *Volume {
+	if m != nil {
+		return m.Volume
+	}
+	return nil
+}
+
+type Volume struct {
+	CapacityBytes int64                `protobuf:"varint,1,opt,name=capacity_bytes,json=capacityBytes" json:"capacity_bytes,omitempty"`
+	VolumeId      string               `protobuf:"bytes,2,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	VolumeContext map[string]string    `protobuf:"bytes,3,rep,name=volume_context,json=volumeContext" json:"volume_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ContentSource *VolumeContentSource `protobuf:"bytes,4,opt,name=content_source,json=contentSource" json:"content_source,omitempty"`
+}
+
+func (m *Volume) Reset()                    { *m = Volume{} }
+func (m *Volume) String() string            { return proto.CompactTextString(m) }
+func (*Volume) ProtoMessage()               {}
+func (*Volume) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Volume) GetVolumeContext() map[string]string {
+	if m != nil {
+		return m.VolumeContext
+	}
+	return nil
+}
+
+func (m *Volume) GetContentSource() *VolumeContentSource {
+	if m != nil {
+		return m.ContentSource
+	}
+	return nil
+}
+
+type DeleteVolumeRequest struct {
+	VolumeId string            `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	Secrets  map[string]string `protobuf:"bytes,2,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *DeleteVolumeRequest) Reset()                    { *m = DeleteVolumeRequest{} }
+func (m *DeleteVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteVolumeRequest) ProtoMessage()               {}
+func (*DeleteVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *DeleteVolumeRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+type DeleteVolumeResponse struct {
+}
+
+func (m *DeleteVolumeResponse) Reset()                    { *m = DeleteVolumeResponse{} }
+func (m *DeleteVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteVolumeResponse) ProtoMessage()               {}
+func (*DeleteVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+type ValidateVolumeCapabilitiesRequest struct {
+	VolumeId           string              `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	VolumeContext      map[string]string   `protobuf:"bytes,2,rep,name=volume_context,json=volumeContext" json:"volume_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeCapabilities []*VolumeCapability `protobuf:"bytes,3,rep,name=volume_capabilities,json=volumeCapabilities" json:"volume_capabilities,omitempty"`
+	Parameters         map[string]string   `protobuf:"bytes,4,rep,name=parameters" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Secrets            map[string]string   `protobuf:"bytes,5,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ValidateVolumeCapabilitiesRequest) Reset()         { *m = ValidateVolumeCapabilitiesRequest{} }
+func (m *ValidateVolumeCapabilitiesRequest) String() string { return proto.CompactTextString(m) }
+func (*ValidateVolumeCapabilitiesRequest) ProtoMessage()    {}
+func (*ValidateVolumeCapabilitiesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{17}
+}
+
+func (m *ValidateVolumeCapabilitiesRequest) GetVolumeContext() map[string]string {
+	if m != nil {
+		return m.VolumeContext
+	}
+	return nil
+}
+
+func (m *ValidateVolumeCapabilitiesRequest) GetVolumeCapabilities() []*VolumeCapability {
+	if m != nil {
+		return m.VolumeCapabilities
+	}
+	return nil
+}
+
+func (m *ValidateVolumeCapabilitiesRequest) GetParameters() map[string]string {
+	if m != nil {
+		return m.Parameters
+	}
+	return nil
+}
+
+func (m *ValidateVolumeCapabilitiesRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+type ValidateVolumeCapabilitiesResponse struct {
+	Confirmed *ValidateVolumeCapabilitiesResponse_Confirmed `protobuf:"bytes,1,opt,name=confirmed" json:"confirmed,omitempty"`
+	Message   string                                        `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *ValidateVolumeCapabilitiesResponse) Reset()         { *m = ValidateVolumeCapabilitiesResponse{} }
+func (m *ValidateVolumeCapabilitiesResponse) String() string { return proto.CompactTextString(m) }
+func (*ValidateVolumeCapabilitiesResponse) ProtoMessage()    {}
+func (*ValidateVolumeCapabilitiesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{18}
+}
+
+func (m *ValidateVolumeCapabilitiesResponse) GetConfirmed() *ValidateVolumeCapabilitiesResponse_Confirmed {
+	if m != nil {
+		return m.Confirmed
+	}
+	return nil
+}
+
+type ValidateVolumeCapabilitiesResponse_Confirmed struct {
+	VolumeContext      map[string]string   `protobuf:"bytes,1,rep,name=volume_context,json=volumeContext" json:"volume_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeCapabilities []*VolumeCapability `protobuf:"bytes,2,rep,name=volume_capabilities,json=volumeCapabilities" json:"volume_capabilities,omitempty"`
+	Parameters         map[string]string   `protobuf:"bytes,3,rep,name=parameters" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ValidateVolumeCapabilitiesResponse_Confirmed) Reset() {
+	*m = ValidateVolumeCapabilitiesResponse_Confirmed{}
+}
+func (m *ValidateVolumeCapabilitiesResponse_Confirmed) String() string {
+	return proto.CompactTextString(m)
+}
+func (*ValidateVolumeCapabilitiesResponse_Confirmed) ProtoMessage() {}
+func (*ValidateVolumeCapabilitiesResponse_Confirmed) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{18, 0}
+}
+
+func (m *ValidateVolumeCapabilitiesResponse_Confirmed) GetVolumeContext() map[string]string {
+	if m != nil {
+		return m.VolumeContext
+	}
+	return nil
+}
+
+func (m *ValidateVolumeCapabilitiesResponse_Confirmed) GetVolumeCapabilities() []*VolumeCapability {
+	if m != nil {
+		return m.VolumeCapabilities
+	}
+	return nil
+}
+
+func (m *ValidateVolumeCapabilitiesResponse_Confirmed) GetParameters() map[string]string {
+	if m != nil {
+		return m.Parameters
+	}
+	return nil
+}
+
+type ControllerGetCapabilitiesRequest struct {
+}
+
+func (m *ControllerGetCapabilitiesRequest) Reset()         { *m = ControllerGetCapabilitiesRequest{} }
+func (m *ControllerGetCapabilitiesRequest) String() string { return proto.CompactTextString(m) }
+func (*ControllerGetCapabilitiesRequest) ProtoMessage()    {}
+func (*ControllerGetCapabilitiesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{19}
+}
+
+type ControllerGetCapabilitiesResponse struct {
+	Capabilities []*ControllerServiceCapability `protobuf:"bytes,1,rep,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *ControllerGetCapabilitiesResponse) Reset()         { *m = ControllerGetCapabilitiesResponse{} }
+func (m *ControllerGetCapabilitiesResponse) String() string { return proto.CompactTextString(m) }
+func (*ControllerGetCapabilitiesResponse) ProtoMessage()    {}
+func (*ControllerGetCapabilitiesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{20}
+}
+
+func (m *ControllerGetCapabilitiesResponse) GetCapabilities() []*ControllerServiceCapability {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type ControllerServiceCapability struct {
+	Rpc *ControllerServiceCapability_RPC `protobuf:"bytes,1,opt,name=rpc" json:"rpc,omitempty"`
+}
+
+func (m *ControllerServiceCapability) Reset()                    { *m = ControllerServiceCapability{} }
+func (m *ControllerServiceCapability) String() string            { return proto.CompactTextString(m) }
+func (*ControllerServiceCapability) ProtoMessage()               {}
+func (*ControllerServiceCapability) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *ControllerServiceCapability) GetRpc() *ControllerServiceCapability_RPC {
+	if m != nil {
+		return m.Rpc
+	}
+	return nil
+}
+
+type ControllerServiceCapability_RPC struct {
+	Type ControllerServiceCapability_RPC_Type `protobuf:"varint,1,opt,name=type,enum=csi.v1.ControllerServiceCapability_RPC_Type" json:"type,omitempty"`
+}
+
+func (m *ControllerServiceCapability_RPC) Reset()         { *m = ControllerServiceCapability_RPC{} }
+func (m *ControllerServiceCapability_RPC) String() string { return proto.CompactTextString(m) }
+func (*ControllerServiceCapability_RPC) ProtoMessage()    {}
+func (*ControllerServiceCapability_RPC) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{21, 0}
+}
+
+type CreateSnapshotRequest struct {
+	SourceVolumeId string            `protobuf:"bytes,1,opt,name=source_volume_id,json=sourceVolumeId" json:"source_volume_id,omitempty"`
+	Name           string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Secrets        map[string]string `protobuf:"bytes,3,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Parameters     map[string]string `protobuf:"bytes,4,rep,name=parameters" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CreateSnapshotRequest) Reset()                    { *m = CreateSnapshotRequest{} }
+func (m *CreateSnapshotRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateSnapshotRequest) ProtoMessage()               {}
+func (*CreateSnapshotRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *CreateSnapshotRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *CreateSnapshotRequest) GetParameters() map[string]string {
+	if m != nil {
+		return m.Parameters
+	}
+	return nil
+}
+
+type CreateSnapshotResponse struct {
+	Snapshot *Snapshot `protobuf:"bytes,1,opt,name=snapshot" json:"snapshot,omitempty"`
+}
+
+func (m *CreateSnapshotResponse) Reset()                    { *m = CreateSnapshotResponse{} }
+func (m *CreateSnapshotResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateSnapshotResponse) ProtoMessage()               {}
+func (*CreateSnapshotResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *CreateSnapshotResponse) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+type Snapshot struct {
+	SizeBytes      int64      `protobuf:"varint,1,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	SnapshotId     string     `protobuf:"bytes,2,opt,name=snapshot_id,json=snapshotId" json:"snapshot_id,omitempty"`
+	SourceVolumeId string     `protobuf:"bytes,3,opt,name=source_volume_id,json=sourceVolumeId" json:"source_volume_id,omitempty"`
+	CreationTime   *Timestamp `protobuf:"bytes,4,opt,name=creation_time,json=creationTime" json:"creation_time,omitempty"`
+	ReadyToUse     bool       `protobuf:"varint,5,opt,name=ready_to_use,json=readyToUse" json:"ready_to_use,omitempty"`
+}
+
+func (m *Snapshot) Reset()                    { *m = Snapshot{} }
+func (m *Snapshot) String() string            { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()               {}
+func (*Snapshot) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *Snapshot) GetCreationTime() *Timestamp {
+	if m != nil {
+		return m.CreationTime
+	}
+	return nil
+}
+
+type DeleteSnapshotRequest struct {
+	SnapshotId string            `protobuf:"bytes,1,opt,name=snapshot_id,json=snapshotId" json:"snapshot_id,omitempty"`
+	Secrets    map[string]string `protobuf:"bytes,2,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *DeleteSnapshotRequest) Reset()                    { *m = DeleteSnapshotRequest{} }
+func (m *DeleteSnapshotRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSnapshotRequest) ProtoMessage()               {}
+func (*DeleteSnapshotRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *DeleteSnapshotRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+type DeleteSnapshotResponse struct {
+}
+
+func (m *DeleteSnapshotResponse) Reset()                    { *m = DeleteSnapshotResponse{} }
+func (m *DeleteSnapshotResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSnapshotResponse) ProtoMessage()               {}
+func (*DeleteSnapshotResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+type ControllerExpandVolumeRequest struct {
+	VolumeId         string            `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	CapacityRange    *CapacityRange    `protobuf:"bytes,2,opt,name=capacity_range,json=capacityRange" json:"capacity_range,omitempty"`
+	Secrets          map[string]string `protobuf:"bytes,3,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeCapability *VolumeCapability `protobuf:"bytes,4,opt,name=volume_capability,json=volumeCapability" json:"volume_capability,omitempty"`
+}
+
+func (m *ControllerExpandVolumeRequest) Reset()                    { *m = ControllerExpandVolumeRequest{} }
+func (m *ControllerExpandVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*ControllerExpandVolumeRequest) ProtoMessage()               {}
+func (*ControllerExpandVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *ControllerExpandVolumeRequest) GetCapacityRange() *CapacityRange {
+	if m != nil {
+		return m.CapacityRange
+	}
+	return nil
+}
+
+func (m *ControllerExpandVolumeRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *ControllerExpandVolumeRequest) GetVolumeCapability() *VolumeCapability {
+	if m != nil {
+		return m.VolumeCapability
+	}
+	return nil
+}
+
+type ControllerExpandVolumeResponse struct {
+	CapacityBytes         int64 `protobuf:"varint,1,opt,name=capacity_bytes,json=capacityBytes" json:"capacity_bytes,omitempty"`
+	NodeExpansionRequired bool  `protobuf:"varint,2,opt,name=node_expansion_required,json=nodeExpansionRequired" json:"node_expansion_required,omitempty"`
+}
+
+func (m *ControllerExpandVolumeResponse) Reset()         { *m = ControllerExpandVolumeResponse{} }
+func (m *ControllerExpandVolumeResponse) String() string { return proto.CompactTextString(m) }
+func (*ControllerExpandVolumeResponse) ProtoMessage()    {}
+func (*ControllerExpandVolumeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{28}
+}
+
+type NodeStageVolumeRequest struct {
+	VolumeId          string            `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	PublishContext    map[string]string `protobuf:"bytes,2,rep,name=publish_context,json=publishContext" json:"publish_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	StagingTargetPath string            `protobuf:"bytes,3,opt,name=staging_target_path,json=stagingTargetPath" json:"staging_target_path,omitempty"`
+	VolumeCapability  *VolumeCapability `protobuf:"bytes,4,opt,name=volume_capability,json=volumeCapability" json:"volume_capability,omitempty"`
+	Secrets           map[string]string `protobuf:"bytes,5,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeContext     map[string]string `protobuf:"bytes,6,rep,name=volume_context,json=volumeContext" json:"volume_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *NodeStageVolumeRequest) Reset()                    { *m = NodeStageVolumeRequest{} }
+func (m *NodeStageVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeStageVolumeRequest) ProtoMessage()               {}
+func (*NodeStageVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *NodeStageVolumeRequest) GetPublishContext() map[string]string {
+	if m != nil {
+		return m.PublishContext
+	}
+	return nil
+}
+
+func (m *NodeStageVolumeRequest) GetVolumeCapability() *VolumeCapability {
+	if m != nil {
+		return m.VolumeCapability
+	}
+	return nil
+}
+
+func (m *NodeStageVolumeRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *NodeStageVolumeRequest) GetVolumeContext() map[string]string {
+	if m != nil {
+		return m.VolumeContext
+	}
+	return nil
+}
+
+type NodeStageVolumeResponse struct {
+}
+
+func (m *NodeStageVolumeResponse) Reset()                    { *m = NodeStageVolumeResponse{} }
+func (m *NodeStageVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeStageVolumeResponse) ProtoMessage()               {}
+func (*NodeStageVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+type NodeUnstageVolumeRequest struct {
+	VolumeId          string `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	StagingTargetPath string `protobuf:"bytes,2,opt,name=staging_target_path,json=stagingTargetPath" json:"staging_target_path,omitempty"`
+}
+
+func (m *NodeUnstageVolumeRequest) Reset()                    { *m = NodeUnstageVolumeRequest{} }
+func (m *NodeUnstageVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeUnstageVolumeRequest) ProtoMessage()               {}
+func (*NodeUnstageVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+type NodeUnstageVolumeResponse struct {
+}
+
+func (m *NodeUnstageVolumeResponse) Reset()                    { *m = NodeUnstageVolumeResponse{} }
+func (m *NodeUnstageVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeUnstageVolumeResponse) ProtoMessage()               {}
+func (*NodeUnstageVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+type NodePublishVolumeRequest struct {
+	VolumeId          string            `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	PublishContext    map[string]string `protobuf:"bytes,2,rep,name=publish_context,json=publishContext" json:"publish_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	StagingTargetPath string            `protobuf:"bytes,3,opt,name=staging_target_path,json=stagingTargetPath" json:"staging_target_path,omitempty"`
+	TargetPath        string            `protobuf:"bytes,4,opt,name=target_path,json=targetPath" json:"target_path,omitempty"`
+	VolumeCapability  *VolumeCapability `protobuf:"bytes,5,opt,name=volume_capability,json=volumeCapability" json:"volume_capability,omitempty"`
+	Readonly          bool              `protobuf:"varint,6,opt,name=readonly" json:"readonly,omitempty"`
+	Secrets           map[string]string `protobuf:"bytes,7,rep,name=secrets" json:"secrets,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	VolumeContext     map[string]string `protobuf:"bytes,8,rep,name=volume_context,json=volumeContext" json:"volume_context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *NodePublishVolumeRequest) Reset()                    { *m = NodePublishVolumeRequest{} }
+func (m *NodePublishVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodePublishVolumeRequest) ProtoMessage()               {}
+func (*NodePublishVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *NodePublishVolumeRequest) GetPublishContext() map[string]string {
+	if m != nil {
+		return m.PublishContext
+	}
+	return nil
+}
+
+func (m *NodePublishVolumeRequest) GetVolumeCapability() *VolumeCapability {
+	if m != nil {
+		return m.VolumeCapability
+	}
+	return nil
+}
+
+func (m *NodePublishVolumeRequest) GetSecrets() map[string]string {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
+}
+
+func (m *NodePublishVolumeRequest) GetVolumeContext() map[string]string {
+	if m != nil {
+		return m.VolumeContext
+	}
+	return nil
+}
+
+type NodePublishVolumeResponse struct {
+}
+
+func (m *NodePublishVolumeResponse) Reset()                    { *m = NodePublishVolumeResponse{} }
+func (m *NodePublishVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodePublishVolumeResponse) ProtoMessage()               {}
+func (*NodePublishVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+type NodeUnpublishVolumeRequest struct {
+	VolumeId   string `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	TargetPath string `protobuf:"bytes,2,opt,name=target_path,json=targetPath" json:"target_path,omitempty"`
+}
+
+func (m *NodeUnpublishVolumeRequest) Reset()                    { *m = NodeUnpublishVolumeRequest{} }
+func (m *NodeUnpublishVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeUnpublishVolumeRequest) ProtoMessage()               {}
+func (*NodeUnpublishVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+type NodeUnpublishVolumeResponse struct {
+}
+
+func (m *NodeUnpublishVolumeResponse) Reset()                    { *m = NodeUnpublishVolumeResponse{} }
+func (m *NodeUnpublishVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeUnpublishVolumeResponse) ProtoMessage()               {}
+func (*NodeUnpublishVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+type NodeExpandVolumeRequest struct {
+	VolumeId          string            `protobuf:"bytes,1,opt,name=volume_id,json=volumeId" json:"volume_id,omitempty"`
+	VolumePath        string            `protobuf:"bytes,2,opt,name=volume_path,json=volumePath" json:"volume_path,omitempty"`
+	CapacityRange     *CapacityRange    `protobuf:"bytes,3,opt,name=capacity_range,json=capacityRange" json:"capacity_range,omitempty"`
+	StagingTargetPath string            `protobuf:"bytes,4,opt,name=staging_target_path,json=stagingTargetPath" json:"staging_target_path,omitempty"`
+	VolumeCapability  *VolumeCapability `protobuf:"bytes,5,opt,name=volume_capability,json=volumeCapability" json:"volume_capability,omitempty"`
+}
+
+func (m *NodeExpandVolumeRequest) Reset()                    { *m = NodeExpandVolumeRequest{} }
+func (m *NodeExpandVolumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeExpandVolumeRequest) ProtoMessage()               {}
+func (*NodeExpandVolumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *NodeExpandVolumeRequest) GetCapacityRange() *CapacityRange {
+	if m != nil {
+		return m.CapacityRange
+	}
+	return nil
+}
+
+func (m *NodeExpandVolumeRequest) GetVolumeCapability() *VolumeCapability {
+	if m != nil {
+		return m.VolumeCapability
+	}
+	return nil
+}
+
+type NodeExpandVolumeResponse struct {
+	CapacityBytes int64 `protobuf:"varint,1,opt,name=capacity_bytes,json=capacityBytes" json:"capacity_bytes,omitempty"`
+}
+
+func (m *NodeExpandVolumeResponse) Reset()                    { *m = NodeExpandVolumeResponse{} }
+func (m *NodeExpandVolumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeExpandVolumeResponse) ProtoMessage()               {}
+func (*NodeExpandVolumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+type NodeGetCapabilitiesRequest struct {
+}
+
+func (m *NodeGetCapabilitiesRequest) Reset()                    { *m = NodeGetCapabilitiesRequest{} }
+func (m *NodeGetCapabilitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeGetCapabilitiesRequest) ProtoMessage()               {}
+func (*NodeGetCapabilitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+type NodeGetCapabilitiesResponse struct {
+	Capabilities []*NodeServiceCapability `protobuf:"bytes,1,rep,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *NodeGetCapabilitiesResponse) Reset()                    { *m = NodeGetCapabilitiesResponse{} }
+func (m *NodeGetCapabilitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeGetCapabilitiesResponse) ProtoMessage()               {}
+func (*NodeGetCapabilitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *NodeGetCapabilitiesResponse) GetCapabilities() []*NodeServiceCapability {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type NodeServiceCapability struct {
+	Rpc *NodeServiceCapability_RPC `protobuf:"bytes,1,opt,name=rpc" json:"rpc,omitempty"`
+}
+
+func (m *NodeServiceCapability) Reset()                    { *m = NodeServiceCapability{} }
+func (m *NodeServiceCapability) String() string            { return proto.CompactTextString(m) }
+func (*NodeServiceCapability) ProtoMessage()               {}
+func (*NodeServiceCapability) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *NodeServiceCapability) GetRpc() *NodeServiceCapability_RPC {
+	if m != nil {
+		return m.Rpc
+	}
+	return nil
+}
+
+type NodeServiceCapability_RPC struct {
+	Type NodeServiceCapability_RPC_Type `protobuf:"varint,1,opt,name=type,enum=csi.v1.NodeServiceCapability_RPC_Type" json:"type,omitempty"`
+}
+
+func (m *NodeServiceCapability_RPC) Reset()                    { *m = NodeServiceCapability_RPC{} }
+func (m *NodeServiceCapability_RPC) String() string            { return proto.CompactTextString(m) }
+func (*NodeServiceCapability_RPC) ProtoMessage()               {}
+func (*NodeServiceCapability_RPC) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41, 0} }
+
+type NodeGetInfoRequest struct {
+}
+
+func (m *NodeGetInfoRequest) Reset()                    { *m = NodeGetInfoRequest{} }
+func (m *NodeGetInfoRequest) String() string            { return proto.CompactTextString(m) }
+func (*NodeGetInfoRequest) ProtoMessage()               {}
+func (*NodeGetInfoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+type NodeGetInfoResponse struct {
+	NodeId            string `protobuf:"bytes,1,opt,name=node_id,json=nodeId" json:"node_id,omitempty"`
+	MaxVolumesPerNode int64  `protobuf:"varint,2,opt,name=max_volumes_per_node,json=maxVolumesPerNode" json:"max_volumes_per_node,omitempty"`
+}
+
+func (m *NodeGetInfoResponse) Reset()                    { *m = NodeGetInfoResponse{} }
+func (m *NodeGetInfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*NodeGetInfoResponse) ProtoMessage()               {}
+func (*NodeGetInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+func init() {
+	proto.RegisterType((*GetPluginInfoRequest)(nil), "csi.v1.GetPluginInfoRequest")
+	proto.RegisterType((*GetPluginInfoResponse)(nil), "csi.v1.GetPluginInfoResponse")
+	proto.RegisterType((*GetPluginCapabilitiesRequest)(nil), "csi.v1.GetPluginCapabilitiesRequest")
+	proto.RegisterType((*GetPluginCapabilitiesResponse)(nil), "csi.v1.GetPluginCapabilitiesResponse")
+	proto.RegisterType((*PluginCapability)(nil), "csi.v1.PluginCapability")
+	proto.RegisterType((*PluginCapability_Service)(nil), "csi.v1.PluginCapability.Service")
+	proto.RegisterType((*PluginCapability_VolumeExpansion)(nil), "csi.v1.PluginCapability.VolumeExpansion")
+	proto.RegisterType((*ProbeRequest)(nil), "csi.v1.ProbeRequest")
+	proto.RegisterType((*ProbeResponse)(nil), "csi.v1.ProbeResponse")
+	proto.RegisterType((*BoolValue)(nil), "csi.v1.BoolValue")
+	proto.RegisterType((*Timestamp)(nil), "csi.v1.Timestamp")
+	proto.RegisterType((*CreateVolumeRequest)(nil), "csi.v1.CreateVolumeRequest")
+	proto.RegisterType((*CapacityRange)(nil), "csi.v1.CapacityRange")
+	proto.RegisterType((*VolumeCapability)(nil), "csi.v1.VolumeCapability")
+	proto.RegisterType((*VolumeCapability_BlockVolume)(nil), "csi.v1.VolumeCapability.BlockVolume")
+	proto.RegisterType((*VolumeCapability_MountVolume)(nil), "csi.v1.VolumeCapability.MountVolume")
+	proto.RegisterType((*VolumeCapability_AccessMode)(nil), "csi.v1.VolumeCapability.AccessMode")
+	proto.RegisterType((*VolumeContentSource)(nil), "csi.v1.VolumeContentSource")
+	proto.RegisterType((*VolumeContentSource_SnapshotSource)(nil), "csi.v1.VolumeContentSource.SnapshotSource")
+	proto.RegisterType((*VolumeContentSource_VolumeSource)(nil), "csi.v1.VolumeContentSource.VolumeSource")
+	proto.RegisterType((*CreateVolumeResponse)(nil), "csi.v1.CreateVolumeResponse")
+	proto.RegisterType((*Volume)(nil), "csi.v1.Volume")
+	proto.RegisterType((*DeleteVolumeRequest)(nil), "csi.v1.DeleteVolumeRequest")
+	proto.RegisterType((*DeleteVolumeResponse)(nil), "csi.v1.DeleteVolumeResponse")
+	proto.RegisterType((*ValidateVolumeCapabilitiesRequest)(nil), "csi.v1.ValidateVolumeCapabilitiesRequest")
+	proto.RegisterType((*ValidateVolumeCapabilitiesResponse)(nil), "csi.v1.ValidateVolumeCapabilitiesResponse")
+	proto.RegisterType((*ValidateVolumeCapabilitiesResponse_Confirmed)(nil), "csi.v1.ValidateVolumeCapabilitiesResponse.Confirmed")
+	proto.RegisterType((*ControllerGetCapabilitiesRequest)(nil), "csi.v1.ControllerGetCapabilitiesRequest")
+	proto.RegisterType((*ControllerGetCapabilitiesResponse)(nil), "csi.v1.ControllerGetCapabilitiesResponse")
+	proto.RegisterType((*ControllerServiceCapability)(nil), "csi.v1.ControllerServiceCapability")
+	proto.RegisterType((*ControllerServiceCapability_RPC)(nil), "csi.v1.ControllerServiceCapability.RPC")
+	proto.RegisterType((*CreateSnapshotRequest)(nil), "csi.v1.CreateSnapshotRequest")
+	proto.RegisterType((*CreateSnapshotResponse)(nil), "csi.v1.CreateSnapshotResponse")
+	proto.RegisterType((*Snapshot)(nil), "csi.v1.Snapshot")
+	proto.RegisterType((*DeleteSnapshotRequest)(nil), "csi.v1.DeleteSnapshotRequest")
+	proto.RegisterType((*DeleteSnapshotResponse)(nil), "csi.v1.DeleteSnapshotResponse")
+	proto.RegisterType((*ControllerExpandVolumeRequest)(nil), "csi.v1.ControllerExpandVolumeRequest")
+	proto.RegisterType((*ControllerExpandVolumeResponse)(nil), "csi.v1.ControllerExpandVolumeResponse")
+	proto.RegisterType((*NodeStageVolumeRequest)(nil), "csi.v1.NodeStageVolumeRequest")
+	proto.RegisterType((*NodeStageVolumeResponse)(nil), "csi.v1.NodeStageVolumeResponse")
+	proto.RegisterType((*NodeUnstageVolumeRequest)(nil), "csi.v1.NodeUnstageVolumeRequest")
+	proto.RegisterType((*NodeUnstageVolumeResponse)(nil), "csi.v1.NodeUnstageVolumeResponse")
+	proto.RegisterType((*NodePublishVolumeRequest)(nil), "csi.v1.NodePublishVolumeRequest")
+	proto.RegisterType((*NodePublishVolumeResponse)(nil), "csi.v1.NodePublishVolumeResponse")
+	proto.RegisterType((*NodeUnpublishVolumeRequest)(nil), "csi.v1.NodeUnpublishVolumeRequest")
+	proto.RegisterType((*NodeUnpublishVolumeResponse)(nil), "csi.v1.NodeUnpublishVolumeResponse")
+	proto.RegisterType((*NodeExpandVolumeRequest)(nil), "csi.v1.NodeExpandVolumeRequest")
+	proto.RegisterType((*NodeExpandVolumeResponse)(nil), "csi.v1.NodeExpandVolumeResponse")
+	proto.RegisterType((*NodeGetCapabilitiesRequest)(nil), "csi.v1.NodeGetCapabilitiesRequest")
+	proto.RegisterType((*NodeGetCapabilitiesResponse)(nil), "csi.v1.NodeGetCapabilitiesResponse")
+	proto.RegisterType((*NodeServiceCapability)(nil), "csi.v1.NodeServiceCapability")
+	proto.RegisterType((*NodeServiceCapability_RPC)(nil), "csi.v1.NodeServiceCapability.RPC")
+	proto.RegisterType((*NodeGetInfoRequest)(nil), "csi.v1.NodeGetInfoRequest")
+	proto.RegisterType((*NodeGetInfoResponse)(nil), "csi.v1.NodeGetInfoResponse")
+	proto.RegisterEnum("csi.v1.PluginCapability_Service_Type", PluginCapability_Service_Type_name, PluginCapability_Service_Type_value)
+	proto.RegisterEnum("csi.v1.PluginCapability_VolumeExpansion_Type", PluginCapability_VolumeExpansion_Type_name, PluginCapability_VolumeExpansion_Type_value)
+	proto.RegisterEnum("csi.v1.VolumeCapability_AccessMode_Mode", VolumeCapability_AccessMode_Mode_name, VolumeCapability_AccessMode_Mode_value)
+	proto.RegisterEnum("csi.v1.ControllerServiceCapability_RPC_Type", ControllerServiceCapability_RPC_Type_name, ControllerServiceCapability_RPC_Type_value)
+	proto.RegisterEnum("csi.v1.NodeServiceCapability_RPC_Type", NodeServiceCapability_RPC_Type_name, NodeServiceCapability_RPC_Type_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion2
+
+// Client API for Identity service
+
+type IdentityClient interface {
+	GetPluginInfo(ctx context.Context, in *GetPluginInfoRequest, opts ...grpc.CallOption) (*GetPluginInfoResponse, error)
+	GetPluginCapabilities(ctx context.Context, in *GetPluginCapabilitiesRequest, opts ...grpc.CallOption) (*GetPluginCapabilitiesResponse, error)
+	Probe(ctx context.Context, in *ProbeRequest, opts ...grpc.CallOption) (*ProbeResponse, error)
+}
+
+type identityClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewIdentityClient(cc *grpc.ClientConn) IdentityClient {
+	return &identityClient{cc}
+}
+
+func (c *identityClient) GetPluginInfo(ctx context.Context, in *GetPluginInfoRequest, opts ...grpc.CallOption) (*GetPluginInfoResponse, error) {
+	out := new(GetPluginInfoResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Identity/GetPluginInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityClient) GetPluginCapabilities(ctx context.Context, in *GetPluginCapabilitiesRequest, opts ...grpc.CallOption) (*GetPluginCapabilitiesResponse, error) {
+	out := new(GetPluginCapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Identity/GetPluginCapabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityClient) Probe(ctx context.Context, in *ProbeRequest, opts ...grpc.CallOption) (*ProbeResponse, error) {
+	out := new(ProbeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Identity/Probe", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Identity service
+
+type IdentityServer interface {
+	GetPluginInfo(context.Context, *GetPluginInfoRequest) (*GetPluginInfoResponse, error)
+	GetPluginCapabilities(context.Context, *GetPluginCapabilitiesRequest) (*GetPluginCapabilitiesResponse, error)
+	Probe(context.Context, *ProbeRequest) (*ProbeResponse, error)
+}
+
+func RegisterIdentityServer(s *grpc.Server, srv IdentityServer) {
+	s.RegisterService(&_Identity_serviceDesc, srv)
+}
+
+func _Identity_GetPluginInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPluginInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServer).GetPluginInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Identity/GetPluginInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServer).GetPluginInfo(ctx, req.(*GetPluginInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Identity_GetPluginCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPluginCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServer).GetPluginCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Identity/GetPluginCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServer).GetPluginCapabilities(ctx, req.(*GetPluginCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Identity_Probe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProbeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServer).Probe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Identity/Probe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServer).Probe(ctx, req.(*ProbeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Identity_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "csi.v1.Identity",
+	HandlerType: (*IdentityServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPluginInfo",
+			Handler:    _Identity_GetPluginInfo_Handler,
+		},
+		{
+			MethodName: "GetPluginCapabilities",
+			Handler:    _Identity_GetPluginCapabilities_Handler,
+		},
+		{
+			MethodName: "Probe",
+			Handler:    _Identity_Probe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// Client API for Controller service
+
+type ControllerClient interface {
+	CreateVolume(ctx context.Context, in *CreateVolumeRequest, opts ...grpc.CallOption) (*CreateVolumeResponse, error)
+	DeleteVolume(ctx context.Context, in *DeleteVolumeRequest, opts ...grpc.CallOption) (*DeleteVolumeResponse, error)
+	ValidateVolumeCapabilities(ctx context.Context, in *ValidateVolumeCapabilitiesRequest, opts ...grpc.CallOption) (*ValidateVolumeCapabilitiesResponse, error)
+	ControllerGetCapabilities(ctx context.Context, in *ControllerGetCapabilitiesRequest, opts ...grpc.CallOption) (*ControllerGetCapabilitiesResponse, error)
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotResponse, error)
+	DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotResponse, error)
+	ControllerExpandVolume(ctx context.Context, in *ControllerExpandVolumeRequest, opts ...grpc.CallOption) (*ControllerExpandVolumeResponse, error)
+}
+
+type controllerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewControllerClient(cc *grpc.ClientConn) ControllerClient {
+	return &controllerClient{cc}
+}
+
+func (c *controllerClient) CreateVolume(ctx context.Context, in *CreateVolumeRequest, opts ...grpc.CallOption) (*CreateVolumeResponse, error) {
+	out := new(CreateVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/CreateVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) DeleteVolume(ctx context.Context, in *DeleteVolumeRequest, opts ...grpc.CallOption) (*DeleteVolumeResponse, error) {
+	out := new(DeleteVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/DeleteVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) ValidateVolumeCapabilities(ctx context.Context, in *ValidateVolumeCapabilitiesRequest, opts ...grpc.CallOption) (*ValidateVolumeCapabilitiesResponse, error) {
+	out := new(ValidateVolumeCapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/ValidateVolumeCapabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) ControllerGetCapabilities(ctx context.Context, in *ControllerGetCapabilitiesRequest, opts ...grpc.CallOption) (*ControllerGetCapabilitiesResponse, error) {
+	out := new(ControllerGetCapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/ControllerGetCapabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotResponse, error) {
+	out := new(CreateSnapshotResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/CreateSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotResponse, error) {
+	out := new(DeleteSnapshotResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/DeleteSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) ControllerExpandVolume(ctx context.Context, in *ControllerExpandVolumeRequest, opts ...grpc.CallOption) (*ControllerExpandVolumeResponse, error) {
+	out := new(ControllerExpandVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Controller/ControllerExpandVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Controller service
+
+type ControllerServer interface {
+	CreateVolume(context.Context, *CreateVolumeRequest) (*CreateVolumeResponse, error)
+	DeleteVolume(context.Context, *DeleteVolumeRequest) (*DeleteVolumeResponse, error)
+	ValidateVolumeCapabilities(context.Context, *ValidateVolumeCapabilitiesRequest) (*ValidateVolumeCapabilitiesResponse, error)
+	ControllerGetCapabilities(context.Context, *ControllerGetCapabilitiesRequest) (*ControllerGetCapabilitiesResponse, error)
+	CreateSnapshot(context.Context, *CreateSnapshotRequest) (*CreateSnapshotResponse, error)
+	DeleteSnapshot(context.Context, *DeleteSnapshotRequest) (*DeleteSnapshotResponse, error)
+	ControllerExpandVolume(context.Context, *ControllerExpandVolumeRequest) (*ControllerExpandVolumeResponse, error)
+}
+
+func RegisterControllerServer(s *grpc.Server, srv ControllerServer) {
+	s.RegisterService(&_Controller_serviceDesc, srv)
+}
+
+func _Controller_CreateVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).CreateVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/CreateVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).CreateVolume(ctx, req.(*CreateVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_DeleteVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).DeleteVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/DeleteVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).DeleteVolume(ctx, req.(*DeleteVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_ValidateVolumeCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateVolumeCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).ValidateVolumeCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/ValidateVolumeCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).ValidateVolumeCapabilities(ctx, req.(*ValidateVolumeCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_ControllerGetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ControllerGetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).ControllerGetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/ControllerGetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).ControllerGetCapabilities(ctx, req.(*ControllerGetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/CreateSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).CreateSnapshot(ctx, req.(*CreateSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_DeleteSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).DeleteSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/DeleteSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).DeleteSnapshot(ctx, req.(*DeleteSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_ControllerExpandVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ControllerExpandVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).ControllerExpandVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Controller/ControllerExpandVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).ControllerExpandVolume(ctx, req.(*ControllerExpandVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Controller_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "csi.v1.Controller",
+	HandlerType: (*ControllerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateVolume",
+			Handler:    _Controller_CreateVolume_Handler,
+		},
+		{
+			MethodName: "DeleteVolume",
+			Handler:    _Controller_DeleteVolume_Handler,
+		},
+		{
+			MethodName: "ValidateVolumeCapabilities",
+			Handler:    _Controller_ValidateVolumeCapabilities_Handler,
+		},
+		{
+			MethodName: "ControllerGetCapabilities",
+			Handler:    _Controller_ControllerGetCapabilities_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _Controller_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "DeleteSnapshot",
+			Handler:    _Controller_DeleteSnapshot_Handler,
+		},
+		{
+			MethodName: "ControllerExpandVolume",
+			Handler:    _Controller_ControllerExpandVolume_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// Client API for Node service
+
+type NodeClient interface {
+	NodeStageVolume(ctx context.Context, in *NodeStageVolumeRequest, opts ...grpc.CallOption) (*NodeStageVolumeResponse, error)
+	NodeUnstageVolume(ctx context.Context, in *NodeUnstageVolumeRequest, opts ...grpc.CallOption) (*NodeUnstageVolumeResponse, error)
+	NodePublishVolume(ctx context.Context, in *NodePublishVolumeRequest, opts ...grpc.CallOption) (*NodePublishVolumeResponse, error)
+	NodeUnpublishVolume(ctx context.Context, in *NodeUnpublishVolumeRequest, opts ...grpc.CallOption) (*NodeUnpublishVolumeResponse, error)
+	NodeExpandVolume(ctx context.Context, in *NodeExpandVolumeRequest, opts ...grpc.CallOption) (*NodeExpandVolumeResponse, error)
+	NodeGetCapabilities(ctx context.Context, in *NodeGetCapabilitiesRequest, opts ...grpc.CallOption) (*NodeGetCapabilitiesResponse, error)
+	NodeGetInfo(ctx context.Context, in *NodeGetInfoRequest, opts ...grpc.CallOption) (*NodeGetInfoResponse, error)
+}
+
+type nodeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewNodeClient(cc *grpc.ClientConn) NodeClient {
+	return &nodeClient{cc}
+}
+
+func (c *nodeClient) NodeStageVolume(ctx context.Context, in *NodeStageVolumeRequest, opts ...grpc.CallOption) (*NodeStageVolumeResponse, error) {
+	out := new(NodeStageVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeStageVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodeUnstageVolume(ctx context.Context, in *NodeUnstageVolumeRequest, opts ...grpc.CallOption) (*NodeUnstageVolumeResponse, error) {
+	out := new(NodeUnstageVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeUnstageVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodePublishVolume(ctx context.Context, in *NodePublishVolumeRequest, opts ...grpc.CallOption) (*NodePublishVolumeResponse, error) {
+	out := new(NodePublishVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodePublishVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodeUnpublishVolume(ctx context.Context, in *NodeUnpublishVolumeRequest, opts ...grpc.CallOption) (*NodeUnpublishVolumeResponse, error) {
+	out := new(NodeUnpublishVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeUnpublishVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodeExpandVolume(ctx context.Context, in *NodeExpandVolumeRequest, opts ...grpc.CallOption) (*NodeExpandVolumeResponse, error) {
+	out := new(NodeExpandVolumeResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeExpandVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodeGetCapabilities(ctx context.Context, in *NodeGetCapabilitiesRequest, opts ...grpc.CallOption) (*NodeGetCapabilitiesResponse, error) {
+	out := new(NodeGetCapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeGetCapabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NodeGetInfo(ctx context.Context, in *NodeGetInfoRequest, opts ...grpc.CallOption) (*NodeGetInfoResponse, error) {
+	out := new(NodeGetInfoResponse)
+	err := grpc.Invoke(ctx, "/csi.v1.Node/NodeGetInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Node service
+
+type NodeServer interface {
+	NodeStageVolume(context.Context, *NodeStageVolumeRequest) (*NodeStageVolumeResponse, error)
+	NodeUnstageVolume(context.Context, *NodeUnstageVolumeRequest) (*NodeUnstageVolumeResponse, error)
+	NodePublishVolume(context.Context, *NodePublishVolumeRequest) (*NodePublishVolumeResponse, error)
+	NodeUnpublishVolume(context.Context, *NodeUnpublishVolumeRequest) (*NodeUnpublishVolumeResponse, error)
+	NodeExpandVolume(context.Context, *NodeExpandVolumeRequest) (*NodeExpandVolumeResponse, error)
+	NodeGetCapabilities(context.Context, *NodeGetCapabilitiesRequest) (*NodeGetCapabilitiesResponse, error)
+	NodeGetInfo(context.Context, *NodeGetInfoRequest) (*NodeGetInfoResponse, error)
+}
+
+func RegisterNodeServer(s *grpc.Server, srv NodeServer) {
+	s.RegisterService(&_Node_serviceDesc, srv)
+}
+
+func _Node_NodeStageVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeStageVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeStageVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeStageVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeStageVolume(ctx, req.(*NodeStageVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodeUnstageVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeUnstageVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeUnstageVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeUnstageVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeUnstageVolume(ctx, req.(*NodeUnstageVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodePublishVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodePublishVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodePublishVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodePublishVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodePublishVolume(ctx, req.(*NodePublishVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodeUnpublishVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeUnpublishVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeUnpublishVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeUnpublishVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeUnpublishVolume(ctx, req.(*NodeUnpublishVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodeExpandVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeExpandVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeExpandVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeExpandVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeExpandVolume(ctx, req.(*NodeExpandVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodeGetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeGetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeGetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeGetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeGetCapabilities(ctx, req.(*NodeGetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NodeGetInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeGetInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NodeGetInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/csi.v1.Node/NodeGetInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NodeGetInfo(ctx, req.(*NodeGetInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Node_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "csi.v1.Node",
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "NodeStageVolume",
+			Handler:    _Node_NodeStageVolume_Handler,
+		},
+		{
+			MethodName: "NodeUnstageVolume",
+			Handler:    _Node_NodeUnstageVolume_Handler,
+		},
+		{
+			MethodName: "NodePublishVolume",
+			Handler:    _Node_NodePublishVolume_Handler,
+		},
+		{
+			MethodName: "NodeUnpublishVolume",
+			Handler:    _Node_NodeUnpublishVolume_Handler,
+		},
+		{
+			MethodName: "NodeExpandVolume",
+			Handler:    _Node_NodeExpandVolume_Handler,
+		},
+		{
+			MethodName: "NodeGetCapabilities",
+			Handler:    _Node_NodeGetCapabilities_Handler,
+		},
+		{
+			MethodName: "NodeGetInfo",
+			Handler:    _Node_NodeGetInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+var fileDescriptor0 = []byte{
+	// 2512 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe4, 0x5a, 0x4d, 0x6c, 0xdb, 0xc8,
+	0x15, 0xae, 0x28, 0xc9, 0x96, 0x9e, 0x2c, 0x45, 0x1e, 0xff, 0x44, 0xa1, 0xed, 0xc4, 0xe6, 0x6e,
+	0xb2, 0xde, 0x24, 0xab, 0x22, 0x4e, 0x10, 0x6c, 0xb2, 0x69, 0x11, 0x59, 0xa6, 0x1d, 0x61, 0x65,
+	0x49, 0xa5, 0x64, 0xe7, 0xa7, 0xd8, 0xb2, 0xb4, 0x34, 0x76, 0x88, 0x95, 0x48, 0x2d, 0x49, 0x1b,
+	0x51, 0x2f, 0x05, 0x7a, 0x2e, 0xd0, 0x4b, 0x81, 0xa2, 0x40, 0x8f, 0xbd, 0xf4, 0x50, 0xa0, 0xe8,
+	0xb1, 0xc7, 0xbd, 0x16, 0xe8, 0xa9, 0x87, 0x62, 0xd1, 0x6b, 0x81, 0x02, 0x45, 0x6f, 0xbd, 0x6f,
+	0x41, 0xce, 0x90, 0xe2, 0x50, 0xa4, 0x7e, 0xe2, 0x6c, 0xf7, 0xd0, 0x8b, 0xad, 0x79, 0x3f, 0xdf,
+	0xbc, 0x99, 0x79, 0xef, 0xcd, 0x7b, 0x23, 0x41, 0xba, 0x6d, 0xaa, 0xc5, 0xbe, 0xa1, 0x5b, 0x3a,
+	0x9a, 0xb3, 0x3f, 0x5e, 0xdc, 0x13, 0x56, 0x61, 0xf9, 0x00, 0x5b, 0x8d, 0xee, 0xf9, 0x99, 0xaa,
+	0x55, 0xb4, 0x53, 0x5d, 0xc2, 0x5f, 0x9c, 0x63, 0xd3, 0x12, 0xfe, 0x16, 0x83, 0x95, 0x00, 0xc3,
+	0xec, 0xeb, 0x9a, 0x89, 0x11, 0x82, 0x84, 0xa6, 0xf4, 0x70, 0x21, 0xb6, 0x19, 0xdb, 0x4e, 0x4b,
+	0xce, 0x67, 0x74, 0x13, 0x72, 0x17, 0x58, 0xeb, 0xe8, 0x86, 0x7c, 0x81, 0x0d, 0x53, 0xd5, 0xb5,
+	0x02, 0xe7, 0x70, 0xb3, 0x84, 0x7a, 0x4c, 0x88, 0xe8, 0x00, 0x52, 0x3d, 0x45, 0x53, 0x4f, 0xb1,
+	0x69, 0x15, 0xe2, 0x9b, 0xf1, 0xed, 0xcc, 0xce, 0x9d, 0x22, 0xb1, 0xa3, 0x18, 0x3a, 0x57, 0xf1,
+	0x90, 0x4a, 0x8b, 0x9a, 0x65, 0x0c, 0x24, 0x4f, 0x99, 0xff, 0x04, 0xb2, 0x0c, 0x0b, 0xe5, 0x21,
+	0xfe, 0x39, 0x1e, 0x50, 0x9b, 0xec, 0x8f, 0x68, 0x19, 0x92, 0x17, 0x4a, 0xf7, 0x1c, 0x53, 0x4b,
+	0xc8, 0xe0, 0x31, 0xf7, 0x71, 0x4c, 0xb8, 0x0e, 0xeb, 0xde, 0x6c, 0x65, 0xa5, 0xaf, 0x9c, 0xa8,
+	0x5d, 0xd5, 0x52, 0xb1, 0xe9, 0x2e, 0xfd, 0x33, 0xd8, 0x88, 0xe0, 0xd3, 0x1d, 0x78, 0x02, 0x0b,
+	0x6d, 0x1f, 0xbd, 0x10, 0x73, 0x96, 0x52, 0x70, 0x97, 0x12, 0xd0, 0x1c, 0x48, 0x8c, 0xb4, 0xf0,
+	0x65, 0x1c, 0xf2, 0x41, 0x11, 0xf4, 0x18, 0xe6, 0x4d, 0x6c, 0x5c, 0xa8, 0x6d, 0xb2, 0xaf, 0x99,
+	0x9d, 0xcd, 0x28, 0xb4, 0x62, 0x93, 0xc8, 0x49, 0xae, 0x02, 0x6a, 0x42, 0xfe, 0x42, 0xef, 0x9e,
+	0xf7, 0xb0, 0x8c, 0xdf, 0xf4, 0x15, 0xcd, 0xdb, 0xfe, 0xcc, 0xce, 0x76, 0x24, 0xc8, 0xb1, 0xa3,
+	0x20, 0xba, 0xf2, 0xd2, 0x95, 0x0b, 0x96, 0xc0, 0xff, 0x2a, 0x06, 0xf3, 0x74, 0x26, 0xf4, 0x08,
+	0x12, 0xd6, 0xa0, 0x4f, 0x2c, 0xcb, 0xed, 0xdc, 0x9c, 0x64, 0x59, 0xb1, 0x35, 0xe8, 0x63, 0xc9,
+	0x51, 0x11, 0x7e, 0x00, 0x09, 0x7b, 0x84, 0x32, 0x30, 0x7f, 0x54, 0xfb, 0xb4, 0x56, 0x7f, 0x5e,
+	0xcb, 0x7f, 0x07, 0xad, 0x02, 0x2a, 0xd7, 0x6b, 0x2d, 0xa9, 0x5e, 0xad, 0x8a, 0x92, 0xdc, 0x14,
+	0xa5, 0xe3, 0x4a, 0x59, 0xcc, 0xc7, 0xd0, 0xfb, 0xb0, 0x79, 0x5c, 0xaf, 0x1e, 0x1d, 0x8a, 0x72,
+	0xa9, 0x5c, 0x16, 0x9b, 0xcd, 0xca, 0x6e, 0xa5, 0x5a, 0x69, 0xbd, 0x94, 0xcb, 0xf5, 0x5a, 0xb3,
+	0x25, 0x95, 0x2a, 0xb5, 0x56, 0x33, 0xcf, 0xf1, 0x3f, 0x8b, 0xc1, 0x95, 0x80, 0xf9, 0xa8, 0xc4,
+	0x58, 0xf8, 0xd1, 0xb4, 0xcb, 0xf6, 0x5b, 0x7a, 0x37, 0xcc, 0x52, 0x80, 0xb9, 0x7a, 0xad, 0x5a,
+	0xa9, 0xd9, 0xd6, 0x65, 0x60, 0xbe, 0xbe, 0xbf, 0xef, 0x0c, 0x38, 0x21, 0x07, 0x0b, 0x0d, 0x43,
+	0x3f, 0xc1, 0xae, 0xcf, 0x7c, 0x0c, 0x59, 0x3a, 0xa6, 0x3e, 0xf2, 0x01, 0x24, 0x0d, 0xac, 0x74,
+	0x06, 0xf4, 0x38, 0x17, 0x5d, 0x93, 0x76, 0x75, 0xbd, 0x7b, 0x6c, 0xbb, 0xa2, 0x44, 0xf8, 0xc2,
+	0x16, 0xa4, 0x3d, 0xda, 0xd0, 0x69, 0x6d, 0xad, 0x14, 0x75, 0x5a, 0xe1, 0x13, 0x48, 0xb7, 0xd4,
+	0x1e, 0x36, 0x2d, 0xa5, 0xd7, 0x47, 0x05, 0xdb, 0x53, 0xda, 0xba, 0xd6, 0x31, 0x1d, 0xa1, 0xb8,
+	0xe4, 0x0e, 0x6d, 0x65, 0x4d, 0xd1, 0x74, 0xd3, 0x39, 0xfc, 0xa4, 0x44, 0x06, 0xc2, 0x2f, 0x12,
+	0xb0, 0x54, 0x36, 0xb0, 0x62, 0x61, 0xb2, 0x78, 0x6a, 0x71, 0x68, 0x18, 0x3f, 0x81, 0x9c, 0xed,
+	0xaa, 0x6d, 0xd5, 0x1a, 0xc8, 0x86, 0xa2, 0x9d, 0x61, 0xea, 0x47, 0x2b, 0xae, 0xf5, 0x65, 0xca,
+	0x95, 0x6c, 0xa6, 0x94, 0x6d, 0xfb, 0x87, 0xa8, 0x02, 0x4b, 0xd4, 0x0f, 0x99, 0xe8, 0x88, 0xb3,
+	0xd1, 0x41, 0xac, 0xf0, 0x45, 0x07, 0xba, 0x60, 0x29, 0x2a, 0x36, 0xd1, 0xa7, 0x00, 0x7d, 0xc5,
+	0x50, 0x7a, 0xd8, 0xc2, 0x86, 0x59, 0x48, 0xb0, 0xa9, 0x22, 0x64, 0x35, 0xc5, 0x86, 0x27, 0x4d,
+	0x52, 0x85, 0x4f, 0x1d, 0xed, 0x3a, 0x3b, 0x66, 0x60, 0xcb, 0x2c, 0x24, 0x37, 0xe3, 0xfe, 0xb0,
+	0x08, 0x43, 0x6a, 0x12, 0x51, 0x02, 0xe3, 0x2a, 0xa2, 0x3a, 0xac, 0xb8, 0x6b, 0xd3, 0x35, 0x0b,
+	0x6b, 0x96, 0x6c, 0xea, 0xe7, 0x46, 0x1b, 0x17, 0xe6, 0x9c, 0x0d, 0x5a, 0x0b, 0xac, 0x8e, 0xc8,
+	0x34, 0x1d, 0x11, 0x69, 0xe9, 0x62, 0x94, 0xc8, 0x7f, 0x0f, 0xae, 0x04, 0x6c, 0x9e, 0x25, 0x87,
+	0xf1, 0x8f, 0x61, 0xc1, 0x6f, 0xe8, 0x4c, 0xf9, 0xef, 0x39, 0x64, 0x99, 0x73, 0xb4, 0xb3, 0xb7,
+	0x81, 0xbf, 0x38, 0x57, 0x0d, 0xdc, 0x91, 0x4f, 0x06, 0x16, 0x76, 0x3d, 0x2b, 0xeb, 0x52, 0x77,
+	0x6d, 0x22, 0xba, 0x01, 0x99, 0xae, 0xda, 0x53, 0x2d, 0x2a, 0xc3, 0x39, 0x32, 0xe0, 0x90, 0x1c,
+	0x01, 0xe1, 0xaf, 0x09, 0xc8, 0x07, 0x8f, 0x17, 0x3d, 0x86, 0xe4, 0x49, 0x57, 0x6f, 0x7f, 0x4e,
+	0x03, 0xe1, 0xfd, 0x28, 0x3f, 0x28, 0xee, 0xda, 0x52, 0xf4, 0x2c, 0x88, 0x8a, 0xad, 0xdb, 0xd3,
+	0xcf, 0x35, 0xab, 0xc0, 0x4d, 0xd0, 0x3d, 0xb4, 0xa5, 0x5c, 0x5d, 0x47, 0x05, 0xed, 0x41, 0x46,
+	0x69, 0xb7, 0xb1, 0x69, 0xca, 0x3d, 0xbd, 0x83, 0x0b, 0x71, 0x07, 0xe1, 0xbd, 0x48, 0x84, 0x92,
+	0x23, 0x7b, 0xa8, 0x77, 0xb0, 0x04, 0x8a, 0xf7, 0x99, 0xcf, 0x42, 0xc6, 0x67, 0x17, 0x7f, 0x00,
+	0x19, 0xdf, 0x54, 0xe8, 0x2a, 0xcc, 0x9f, 0x9a, 0xb2, 0x97, 0x79, 0xd2, 0xd2, 0xdc, 0xa9, 0xe9,
+	0x24, 0x91, 0x1b, 0x90, 0x71, 0xac, 0x90, 0x4f, 0xbb, 0xca, 0x99, 0xbd, 0x55, 0xf1, 0xed, 0xb4,
+	0x04, 0x0e, 0x69, 0xdf, 0xa6, 0xf0, 0xbf, 0xe3, 0x00, 0x86, 0x53, 0xa2, 0x27, 0x90, 0x70, 0xac,
+	0x24, 0xf9, 0x6b, 0x7b, 0x0a, 0x2b, 0x8b, 0xf6, 0x1f, 0xc9, 0xd1, 0x12, 0xfe, 0x1e, 0x83, 0x84,
+	0x03, 0x13, 0xcc, 0xb2, 0xcd, 0x4a, 0xed, 0xa0, 0x2a, 0xca, 0xb5, 0xfa, 0x9e, 0x28, 0x3f, 0x97,
+	0x2a, 0x2d, 0x51, 0xca, 0xc7, 0xd0, 0x1a, 0x5c, 0xf5, 0xd3, 0x25, 0xb1, 0xb4, 0x27, 0x4a, 0x72,
+	0xbd, 0x56, 0x7d, 0x99, 0xe7, 0x10, 0x0f, 0xab, 0x87, 0x47, 0xd5, 0x56, 0x65, 0x94, 0x17, 0x47,
+	0xeb, 0x50, 0xf0, 0xf1, 0x28, 0x06, 0x85, 0x4d, 0xd8, 0xb0, 0x3e, 0x2e, 0xf9, 0x48, 0x99, 0x49,
+	0xb4, 0x01, 0xd7, 0xfc, 0x73, 0xb2, 0xba, 0x73, 0x36, 0xb2, 0x9f, 0xcd, 0x28, 0xcf, 0x0b, 0x5f,
+	0xc7, 0x60, 0x29, 0x24, 0xae, 0xd0, 0x3e, 0xa4, 0x4c, 0x4d, 0xe9, 0x9b, 0xaf, 0x75, 0x8b, 0x3a,
+	0xd7, 0xed, 0x31, 0x61, 0x58, 0x6c, 0x52, 0x59, 0x32, 0x94, 0x3c, 0x5d, 0xf4, 0x14, 0xe6, 0x48,
+	0x84, 0x06, 0x6f, 0xcd, 0x30, 0x14, 0x42, 0xa3, 0x18, 0x54, 0x8f, 0xbf, 0x07, 0x39, 0x16, 0xdd,
+	0x76, 0x00, 0x17, 0x5f, 0x56, 0x3b, 0xd4, 0x3b, 0xc0, 0x25, 0x55, 0x3a, 0xfc, 0x1d, 0x58, 0xf0,
+	0x43, 0xa1, 0x35, 0x48, 0xd3, 0x04, 0xe3, 0x89, 0xa7, 0x08, 0xa1, 0xd2, 0x11, 0xbe, 0x0f, 0xcb,
+	0x6c, 0xaa, 0xa2, 0x97, 0xcc, 0x2d, 0xcf, 0x72, 0xb2, 0xfe, 0x1c, 0x6b, 0xb9, 0x6b, 0x9f, 0xf0,
+	0x1b, 0x0e, 0xe6, 0xa8, 0xcb, 0xde, 0xf4, 0xa5, 0x78, 0x26, 0xd6, 0x5d, 0x2a, 0x89, 0x75, 0xc6,
+	0x1c, 0x8e, 0x35, 0x07, 0x3d, 0x83, 0x9c, 0x3f, 0x19, 0xbe, 0x71, 0x8b, 0xb9, 0x2d, 0x76, 0x7a,
+	0xff, 0xfe, 0xbd, 0xa1, 0x25, 0x5c, 0xf6, 0xc2, 0x4f, 0x43, 0xbb, 0x90, 0x0b, 0xe4, 0xd3, 0xc4,
+	0xe4, 0x7c, 0x9a, 0x6d, 0x33, 0x99, 0xf4, 0x29, 0xa0, 0xd1, 0x89, 0x66, 0x4a, 0x88, 0x7f, 0x8c,
+	0xc1, 0xd2, 0x1e, 0xee, 0xe2, 0xe0, 0x15, 0x39, 0xee, 0x4c, 0xfc, 0xb7, 0x0a, 0xc7, 0xde, 0x2a,
+	0x21, 0x50, 0xe1, 0xb7, 0xca, 0xa5, 0xb2, 0xf8, 0x2a, 0x2c, 0xb3, 0x13, 0x11, 0x9f, 0x10, 0xbe,
+	0x4a, 0xc0, 0xd6, 0xb1, 0xd2, 0x55, 0x3b, 0x9e, 0xbb, 0x84, 0xd4, 0xb8, 0xe3, 0x97, 0xd6, 0x1e,
+	0x39, 0x5f, 0xb2, 0xc2, 0x27, 0xde, 0xa9, 0x4c, 0xc2, 0x9f, 0xe2, 0xe8, 0xdf, 0x61, 0xb5, 0xf0,
+	0x32, 0xa4, 0x5a, 0x78, 0x34, 0xbd, 0xad, 0xe3, 0x6a, 0x87, 0x46, 0xb0, 0x76, 0x78, 0x38, 0x3d,
+	0x6e, 0xf8, 0x99, 0x5f, 0xda, 0x5d, 0xbf, 0xcd, 0xd2, 0xe1, 0xcb, 0x04, 0x08, 0xe3, 0x16, 0x4e,
+	0xf3, 0x92, 0x04, 0xe9, 0xb6, 0xae, 0x9d, 0xaa, 0x46, 0x0f, 0x77, 0x68, 0x6a, 0x7a, 0x30, 0xcd,
+	0xbe, 0x11, 0xf5, 0x62, 0xd9, 0xd5, 0x95, 0x86, 0x30, 0x76, 0xdd, 0xdb, 0xc3, 0xa6, 0xa9, 0x9c,
+	0xb9, 0x66, 0xb9, 0x43, 0xfe, 0xf7, 0x71, 0x48, 0x7b, 0x2a, 0x48, 0x1b, 0x71, 0x5e, 0xd2, 0x9e,
+	0x1d, 0xbc, 0x8d, 0x01, 0x6f, 0xef, 0xc7, 0xdc, 0x5b, 0xf8, 0x71, 0x87, 0xf1, 0x63, 0x12, 0x09,
+	0x7b, 0x6f, 0x65, 0xf6, 0x18, 0x97, 0xfe, 0xd6, 0x1d, 0x50, 0x10, 0x60, 0xd3, 0x9e, 0xda, 0xd0,
+	0xbb, 0x5d, 0x6c, 0x1c, 0x60, 0x2b, 0xac, 0x07, 0xef, 0xc2, 0xd6, 0x18, 0x19, 0xea, 0x66, 0x07,
+	0xa1, 0x7d, 0xb8, 0x57, 0xe3, 0x0d, 0x01, 0x68, 0x63, 0x1a, 0xd9, 0x92, 0x7f, 0xcd, 0xc1, 0xda,
+	0x18, 0x69, 0xf4, 0x08, 0xe2, 0x46, 0xbf, 0x4d, 0x3d, 0xf9, 0x83, 0x29, 0xf0, 0x8b, 0x52, 0xa3,
+	0x2c, 0xd9, 0x3a, 0xfc, 0x6f, 0x39, 0x88, 0x4b, 0x8d, 0x32, 0x7a, 0xca, 0x74, 0xa8, 0x77, 0xa7,
+	0xc4, 0xf0, 0x37, 0xa8, 0x5f, 0xc5, 0xc2, 0x3a, 0xd4, 0x02, 0x2c, 0x97, 0x25, 0xb1, 0xd4, 0x12,
+	0xe5, 0x3d, 0xb1, 0x2a, 0xb6, 0x44, 0x99, 0x74, 0xd0, 0xf9, 0x98, 0x5d, 0x54, 0x35, 0x8e, 0x76,
+	0xab, 0x95, 0xe6, 0x33, 0xf9, 0xa8, 0xe6, 0x7e, 0xa2, 0x5c, 0x0e, 0xe5, 0x61, 0xa1, 0x5a, 0x69,
+	0xb6, 0x28, 0xa1, 0x99, 0x8f, 0xdb, 0x94, 0x03, 0xb1, 0x25, 0x97, 0x4b, 0x8d, 0x52, 0xb9, 0xd2,
+	0x7a, 0x99, 0x4f, 0xd8, 0xc5, 0x20, 0x8b, 0xdd, 0xac, 0x95, 0x1a, 0xcd, 0x67, 0xf5, 0x56, 0x3e,
+	0x89, 0x10, 0xe4, 0x1c, 0x7d, 0x97, 0xd4, 0xcc, 0xcf, 0xd9, 0x08, 0xe5, 0x6a, 0xbd, 0xe6, 0xd9,
+	0x30, 0x8f, 0x96, 0x21, 0xef, 0xce, 0x6c, 0xd7, 0x92, 0x4e, 0x21, 0x99, 0x42, 0x8b, 0x90, 0x15,
+	0x5f, 0x34, 0x4a, 0xb5, 0x3d, 0x57, 0x30, 0x2d, 0xfc, 0x83, 0x83, 0x15, 0x52, 0xe2, 0xb8, 0x85,
+	0x94, 0x7b, 0x53, 0x6d, 0x43, 0x9e, 0x94, 0x06, 0x72, 0xf0, 0xc2, 0xca, 0x11, 0xfa, 0xb1, 0x7b,
+	0x6d, 0xb9, 0x1d, 0x2d, 0xe7, 0xeb, 0x68, 0xf7, 0x86, 0xf9, 0x9b, 0xc4, 0xd3, 0x6d, 0xb6, 0xf7,
+	0x0b, 0xcc, 0x16, 0xd1, 0xfd, 0x1d, 0x86, 0x5c, 0x30, 0x1f, 0x8d, 0x07, 0x1a, 0x17, 0x81, 0x97,
+	0xc8, 0xc0, 0x97, 0x8d, 0xbd, 0x7d, 0x58, 0x0d, 0xda, 0x4b, 0x83, 0xe9, 0xee, 0x48, 0x35, 0x9d,
+	0x77, 0x57, 0xe8, 0xc9, 0x7a, 0x12, 0xc2, 0x5f, 0x62, 0x90, 0x72, 0xc9, 0x68, 0x03, 0xc0, 0x54,
+	0x7f, 0x82, 0x99, 0x7a, 0x32, 0x6d, 0x53, 0xbc, 0xbe, 0xd1, 0x5f, 0x0b, 0x73, 0xc1, 0x5a, 0x38,
+	0xf4, 0x88, 0xe3, 0xa1, 0x47, 0xfc, 0x10, 0xb2, 0x6d, 0xdb, 0x7c, 0x55, 0xd7, 0x64, 0x4b, 0xed,
+	0xb9, 0xe5, 0xa2, 0xf7, 0xba, 0xe2, 0x3d, 0x93, 0x48, 0x0b, 0xae, 0x9c, 0x4d, 0x42, 0x9b, 0xb0,
+	0xe0, 0xbc, 0xb6, 0xc8, 0x96, 0x2e, 0x9f, 0x9b, 0xb8, 0x90, 0x74, 0x9e, 0x57, 0xc0, 0xa1, 0xb5,
+	0xf4, 0x23, 0x13, 0x0b, 0x7f, 0x8a, 0xc1, 0x0a, 0xa9, 0xa7, 0x82, 0x0e, 0x38, 0xa9, 0x94, 0xf7,
+	0xfb, 0x18, 0xc7, 0xfa, 0x58, 0x28, 0xe0, 0x37, 0x50, 0x0b, 0x16, 0x60, 0x35, 0x38, 0x15, 0xad,
+	0x06, 0xff, 0xcc, 0xc1, 0xc6, 0x30, 0xc7, 0x38, 0x0f, 0x5f, 0x9d, 0x19, 0x8a, 0xdc, 0xcb, 0x3d,
+	0x08, 0x55, 0x83, 0xc1, 0xb7, 0x33, 0x9a, 0xf6, 0x42, 0x4c, 0x8a, 0x08, 0x42, 0x11, 0x16, 0x83,
+	0x17, 0xed, 0x80, 0x9e, 0x7f, 0xf4, 0x35, 0x9b, 0x0f, 0x5c, 0xb3, 0x83, 0x4b, 0xed, 0xf3, 0x4f,
+	0xe1, 0x7a, 0x94, 0xe5, 0x34, 0x8a, 0xa6, 0x6c, 0xaf, 0x1e, 0xc2, 0x55, 0x4d, 0xef, 0xf8, 0x1e,
+	0x6c, 0x65, 0xf7, 0xa9, 0xc5, 0x99, 0x34, 0x25, 0xad, 0xd8, 0xec, 0xe1, 0xf3, 0x2c, 0x65, 0x0a,
+	0xff, 0x4a, 0xc0, 0x6a, 0x4d, 0xef, 0xe0, 0xa6, 0xa5, 0x9c, 0xcd, 0xd2, 0xac, 0xfc, 0x10, 0xae,
+	0xf4, 0xcf, 0x4f, 0xba, 0xaa, 0xf9, 0x3a, 0x50, 0xd2, 0x7b, 0x27, 0x12, 0x8e, 0x5a, 0x6c, 0x10,
+	0x2d, 0xa6, 0x00, 0xca, 0xf5, 0x19, 0x22, 0x2a, 0xc2, 0x92, 0x69, 0x29, 0x67, 0xaa, 0x76, 0x26,
+	0x5b, 0x8a, 0x71, 0x86, 0x2d, 0xb9, 0xaf, 0x58, 0xaf, 0x69, 0x04, 0x2f, 0x52, 0x56, 0xcb, 0xe1,
+	0x34, 0x14, 0xeb, 0xf5, 0x3b, 0x3a, 0x48, 0x24, 0x06, 0x4b, 0xf3, 0x3b, 0x13, 0xd6, 0x12, 0xee,
+	0x56, 0x2f, 0x46, 0xea, 0xc5, 0x39, 0x07, 0xed, 0xde, 0x04, 0xb4, 0x89, 0x95, 0x21, 0x5f, 0x82,
+	0xa5, 0x90, 0xed, 0xfb, 0x5f, 0xd5, 0xea, 0xef, 0xa0, 0x2f, 0xbe, 0x06, 0x57, 0x47, 0x16, 0x4f,
+	0xf3, 0xca, 0x19, 0x14, 0x6c, 0xd6, 0x91, 0x66, 0xce, 0xe8, 0x89, 0x11, 0xce, 0xc2, 0x45, 0x38,
+	0x8b, 0xb0, 0x06, 0xd7, 0x42, 0x26, 0xa2, 0x56, 0xfc, 0x21, 0x49, 0xcc, 0xa0, 0xdb, 0x3c, 0x83,
+	0x19, 0x9f, 0x45, 0x05, 0xc4, 0x03, 0xff, 0xb1, 0x87, 0xe1, 0x7e, 0x23, 0x21, 0x71, 0x03, 0x32,
+	0x7e, 0xb9, 0x84, 0x23, 0x07, 0xd6, 0x84, 0x98, 0x49, 0xce, 0x1c, 0x33, 0x3c, 0xa4, 0xec, 0x3b,
+	0x4f, 0xd7, 0xba, 0x03, 0xe7, 0xe5, 0x3a, 0x25, 0x79, 0x63, 0x74, 0x30, 0x8c, 0xa7, 0x79, 0xb6,
+	0xc2, 0x89, 0xdc, 0x8a, 0xf0, 0x88, 0x7a, 0x35, 0x12, 0x51, 0x29, 0x07, 0xef, 0xfe, 0x44, 0xbc,
+	0xff, 0x83, 0x98, 0xa2, 0xfe, 0x1c, 0x58, 0x3e, 0xf5, 0xe7, 0x57, 0xc0, 0x13, 0x67, 0xef, 0xcf,
+	0xec, 0xd0, 0x01, 0x0f, 0xe2, 0x82, 0x1e, 0x24, 0x6c, 0xc0, 0x5a, 0x28, 0x36, 0x9d, 0xfa, 0xe7,
+	0x1c, 0x09, 0xf6, 0x99, 0x4b, 0x84, 0x1b, 0x90, 0xa1, 0x4c, 0xff, 0xc4, 0x84, 0xe4, 0xb8, 0xee,
+	0x68, 0x0d, 0x11, 0x9f, 0xa1, 0x86, 0x88, 0x88, 0xa4, 0xc4, 0x4c, 0x97, 0xcb, 0xcc, 0x81, 0x22,
+	0x94, 0xa0, 0x30, 0xba, 0x1b, 0x33, 0xdd, 0xf1, 0xc2, 0x3a, 0x39, 0xcc, 0x88, 0x06, 0xf7, 0xc7,
+	0xb0, 0x16, 0xca, 0xa5, 0x73, 0x94, 0x42, 0x5b, 0xdb, 0x0d, 0xe6, 0x4e, 0x9a, 0xd0, 0xd4, 0xfe,
+	0x33, 0x06, 0x2b, 0xa1, 0x72, 0xe8, 0xbe, 0xbf, 0x9d, 0xdd, 0x1a, 0x8b, 0x39, 0x6c, 0x64, 0x7f,
+	0x1d, 0x23, 0x8d, 0xec, 0x63, 0xa6, 0x91, 0xbd, 0x35, 0x51, 0xdb, 0xdf, 0xc2, 0x1e, 0x47, 0x74,
+	0xb0, 0xcd, 0x56, 0xe9, 0x40, 0x94, 0x8f, 0x6a, 0xe4, 0xbf, 0xd7, 0xc1, 0x2e, 0x43, 0xde, 0xee,
+	0x48, 0xc9, 0x58, 0x6e, 0xb6, 0x4a, 0xf6, 0xf7, 0xbf, 0xa3, 0xdd, 0x63, 0x5c, 0x58, 0x06, 0x44,
+	0x37, 0xd3, 0xff, 0x13, 0x06, 0x19, 0x96, 0x18, 0x2a, 0xdd, 0xda, 0xab, 0x30, 0xef, 0xd4, 0x5e,
+	0x9e, 0x2f, 0xcf, 0xd9, 0xc3, 0x4a, 0x07, 0x7d, 0x17, 0x96, 0x7b, 0xca, 0x1b, 0xda, 0x83, 0x98,
+	0x72, 0x1f, 0x1b, 0xb2, 0xcd, 0xa1, 0x5f, 0x74, 0x2d, 0xf6, 0x94, 0x37, 0xc4, 0x11, 0xcc, 0x06,
+	0x36, 0x6c, 0xe0, 0x9d, 0xff, 0xc4, 0x20, 0x55, 0xe9, 0x60, 0xcd, 0xb2, 0x37, 0xb5, 0x0a, 0x59,
+	0xe6, 0x37, 0x0c, 0x68, 0x3d, 0xe2, 0xa7, 0x0d, 0x8e, 0x71, 0xfc, 0xc6, 0xd8, 0x1f, 0x3e, 0xa0,
+	0x8e, 0xef, 0xd7, 0x17, 0xcc, 0x1b, 0xd1, 0xfb, 0x23, 0x7a, 0x21, 0xde, 0xc5, 0xdf, 0x9c, 0x20,
+	0x45, 0x67, 0x79, 0x00, 0x49, 0xe7, 0x5b, 0x6b, 0xb4, 0xec, 0xca, 0xfb, 0xbf, 0xd4, 0xe6, 0x57,
+	0x02, 0x54, 0xa2, 0xb5, 0xf3, 0xcb, 0x24, 0xc0, 0xb0, 0x0c, 0x46, 0x15, 0x58, 0xf0, 0x7f, 0x39,
+	0x81, 0xd6, 0xc6, 0x7c, 0xbb, 0xca, 0xaf, 0x87, 0x33, 0xa9, 0x3d, 0x15, 0x58, 0xf0, 0xbf, 0x69,
+	0x0f, 0xa1, 0x42, 0x9e, 0xd4, 0xf9, 0xf5, 0x70, 0x26, 0x85, 0x32, 0x81, 0x8f, 0x7e, 0x31, 0x43,
+	0x1f, 0x4e, 0xfd, 0x8a, 0xcb, 0xdf, 0x9e, 0xfe, 0x01, 0x0e, 0xf5, 0xe1, 0x5a, 0xe4, 0xab, 0x15,
+	0xda, 0x1e, 0x6d, 0x7e, 0xc2, 0x73, 0x03, 0xff, 0xe1, 0x14, 0x92, 0x74, 0xc6, 0x3a, 0xe4, 0xd8,
+	0x7e, 0x1e, 0x6d, 0x8c, 0x7d, 0x97, 0xe0, 0xaf, 0x47, 0xb1, 0x87, 0x80, 0x6c, 0x2b, 0x39, 0x04,
+	0x0c, 0xed, 0x66, 0xf9, 0xeb, 0x51, 0x6c, 0x0a, 0x78, 0x06, 0xab, 0xe1, 0x3d, 0x13, 0xba, 0x39,
+	0x55, 0x37, 0xc8, 0xdf, 0x9a, 0x24, 0x46, 0xdd, 0xf2, 0xdf, 0x09, 0x48, 0xd8, 0x61, 0x89, 0x24,
+	0xb8, 0x12, 0x28, 0x5b, 0xd1, 0xf5, 0xf1, 0xc5, 0x3c, 0x7f, 0x23, 0x92, 0x4f, 0x57, 0xf1, 0x02,
+	0x16, 0x47, 0xca, 0x50, 0xb4, 0xe9, 0xd7, 0x0a, 0x2b, 0x85, 0xf9, 0xad, 0x31, 0x12, 0x2c, 0x32,
+	0x53, 0x10, 0xb0, 0xc8, 0x61, 0xa5, 0x12, 0xbf, 0x35, 0x46, 0x82, 0x22, 0xff, 0x08, 0x96, 0x42,
+	0x6e, 0x7c, 0x24, 0xb0, 0x36, 0x85, 0x95, 0x1a, 0xfc, 0x7b, 0x63, 0x65, 0x28, 0xfe, 0x11, 0xe4,
+	0x83, 0x77, 0x24, 0x62, 0x36, 0x32, 0xec, 0x34, 0x37, 0xa3, 0x05, 0x58, 0xb3, 0x83, 0xe1, 0xc3,
+	0x98, 0x1d, 0x11, 0x38, 0xef, 0x8d, 0x95, 0xa1, 0xf8, 0xfb, 0x90, 0xf1, 0x5d, 0x0b, 0x88, 0x0f,
+	0xe8, 0xf8, 0x93, 0xf4, 0x5a, 0x28, 0x8f, 0xe0, 0xec, 0x26, 0x5f, 0xc5, 0xdb, 0xa6, 0x7a, 0x32,
+	0xe7, 0xfc, 0x9e, 0xee, 0xfe, 0x7f, 0x07, 0x00, 0x57, 0x02, 0xbb, 0xd2, 0x5c, 0x27, 0x00, 0x00,
+}
@@ -0,0 +1,138 @@
+// Package csi implements a Container Storage Interface plugin for torus block
+// volumes. Its controller service creates, snapshots, clones and resizes
+// volumes; its node service attaches them to NBD devices and mounts them.
+package csi
+
+// csi.pb.go is generated with the protoc-gen-go of the golang/protobuf
+// version in go.mod; newer ones generate code for a newer proto package.
+//go:generate protoc --go_out=plugins=grpc:. csi.proto
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "csi")
+
+const (
+	// PluginName is the name the plugin is known by to container
+	// orchestrators.
+	PluginName = "block.torus.coreos.com"
+
+	// defaultVolumeSize is the size of volumes created without a capacity.
+	defaultVolumeSize = 1 << 30
+	// defaultFSType is the filesystem made on volumes mounted without one.
+	defaultFSType = "ext4"
+)
+
+// Options select what a Driver serves.
+type Options struct {
+	// Controller serves the controller service, which manages volumes for
+	// the whole cluster; one instance of the plugin should serve it.
+	Controller bool
+	// Node serves the node service, which attaches volumes to this host,
+	// identified to the orchestrator as NodeID.
+	Node   bool
+	NodeID string
+}
+
+// Driver serves the CSI services for the block volumes of a torus cluster.
+type Driver struct {
+	srv  *torus.Server
+	opts Options
+
+	mu sync.Mutex
+	// staged are the volumes the node service has attached, by volume ID.
+	staged map[string]*stagedVolume
+}
+
+func NewDriver(srv *torus.Server, opts Options) *Driver {
+	return &Driver{
+		srv:    srv,
+		opts:   opts,
+		staged: make(map[string]*stagedVolume),
+	}
+}
+
+// Register registers the services the driver serves with s.
+func (d *Driver) Register(s *grpc.Server) {
+	RegisterIdentityServer(s, d)
+	if d.opts.Controller {
+		RegisterControllerServer(s, d)
+	}
+	if d.opts.Node {
+		RegisterNodeServer(s, d)
+	}
+}
+
+// Close detaches the volumes the node service attached. Their mounts are
+// left in place, but fail I/O until the volumes are staged again.
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	for id, st := range d.staged {
+		if cerr := st.detach(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(d.staged, id)
+	}
+	return err
+}
+
+// lookup returns the block volume with the volume ID id, which is its name.
+func (d *Driver) lookup(id string) (*models.Volume, error) {
+	vols, _, err := d.srv.MDS.GetVolumes()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, v := range vols {
+		if v.Name != id {
+			continue
+		}
+		if v.Type != block.VolumeType {
+			return nil, grpc.Errorf(codes.InvalidArgument, "volume %s isn't a block volume", id)
+		}
+		return v, nil
+	}
+	return nil, grpc.Errorf(codes.NotFound, "no volume %s", id)
+}
+
+// Snapshot IDs are of the form VOLUME@SNAPSHOT, as the names NBD exports
+// snapshots under.
+func snapshotID(volume, snapshot string) string { return volume + "@" + snapshot }
+
+func parseSnapshotID(id string) (volume, snapshot string, ok bool) {
+	p := strings.SplitN(id, "@", 2)
+	if len(p) != 2 || p[0] == "" || p[1] == "" {
+		return "", "", false
+	}
+	return p[0], p[1], true
+}
+
+// toStatus converts an error from torus into a gRPC error with the code
+// orchestrators expect.
+func toStatus(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case torus.ErrNotExist:
+		return grpc.Errorf(codes.NotFound, "%v", err)
+	case torus.ErrExists:
+		return grpc.Errorf(codes.AlreadyExists, "%v", err)
+	case torus.ErrLocked, block.ErrReplica:
+		return grpc.Errorf(codes.FailedPrecondition, "%v", err)
+	case torus.ErrInvalid, block.ErrShrink:
+		return grpc.Errorf(codes.InvalidArgument, "%v", err)
+	case torus.ErrAgain:
+		return grpc.Errorf(codes.Aborted, "%v", err)
+	}
+	return grpc.Errorf(codes.Internal, "%v", err)
+}
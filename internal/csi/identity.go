@@ -0,0 +1,34 @@
+package csi
+
+import (
+	"github.com/coreos/torus"
+	"golang.org/x/net/context"
+)
+
+func (d *Driver) GetPluginInfo(ctx context.Context, req *GetPluginInfoRequest) (*GetPluginInfoResponse, error) {
+	version := torus.Version
+	if version == "" {
+		version = "unknown"
+	}
+	return &GetPluginInfoResponse{
+		Name:          PluginName,
+		VendorVersion: version,
+	}, nil
+}
+
+func (d *Driver) GetPluginCapabilities(ctx context.Context, req *GetPluginCapabilitiesRequest) (*GetPluginCapabilitiesResponse, error) {
+	caps := []*PluginCapability{
+		{VolumeExpansion: &PluginCapability_VolumeExpansion{Type: PluginCapability_VolumeExpansion_ONLINE}},
+	}
+	if d.opts.Controller {
+		caps = append(caps, &PluginCapability{
+			Service: &PluginCapability_Service{Type: PluginCapability_Service_CONTROLLER_SERVICE},
+		})
+	}
+	return &GetPluginCapabilitiesResponse{Capabilities: caps}, nil
+}
+
+func (d *Driver) Probe(ctx context.Context, req *ProbeRequest) (*ProbeResponse, error) {
+	_, _, err := d.srv.MDS.GetVolumes()
+	return &ProbeResponse{Ready: &BoolValue{Value: err == nil}}, nil
+}
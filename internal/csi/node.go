@@ -0,0 +1,273 @@
+package csi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/internal/mount"
+	"github.com/coreos/torus/internal/nbd"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// attachTimeout bounds how long the kernel takes to connect an NBD
+	// device.
+	attachTimeout = 10 * time.Second
+	// resizeTimeout bounds how long an attached volume takes to apply a
+	// resize request from the controller.
+	resizeTimeout = 30 * time.Second
+)
+
+// A stagedVolume is a volume the node service attached to an NBD device.
+// The device is served by this process, so it lasts as long as the plugin
+// does.
+type stagedVolume struct {
+	file *block.BlockFile
+	nbd  *nbd.NBD
+	dev  string
+	// path is where the volume's filesystem is mounted, or the empty string
+	// if it's used as a raw block device.
+	path string
+	// served is closed once the NBD server returns.
+	served chan struct{}
+}
+
+// attach attaches vol to a free NBD device. It's called with d.mu held, so
+// that two volumes don't pick the same device.
+func (d *Driver) attach(vol *block.BlockVolume) (st *stagedVolume, err error) {
+	f, err := vol.OpenBlockFile()
+	if err != nil {
+		return nil, err
+	}
+	dev, err := nbd.FindDevice()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	handle := nbd.Create(f, int64(f.Size()), int64(d.srv.MDS.GlobalMetadata().BlockSize))
+	if _, err = handle.OpenDevice(dev); err != nil {
+		f.Close()
+		return nil, err
+	}
+	st = &stagedVolume{
+		file:   f,
+		nbd:    handle,
+		dev:    dev,
+		served: make(chan struct{}),
+	}
+	go func() {
+		defer close(st.served)
+		if err := handle.Serve(); err != nil {
+			clog.Errorf("error serving %s: %v", dev, err)
+		}
+	}()
+	f.OnResize(func(size uint64) {
+		if err := handle.SetSize(int64(size)); err != nil {
+			clog.Errorf("couldn't resize %s: %v", dev, err)
+		}
+	})
+	if err = waitConnected(dev); err != nil {
+		st.detach()
+		return nil, err
+	}
+	return st, nil
+}
+
+// waitConnected waits for the kernel to connect dev.
+func waitConnected(dev string) error {
+	for deadline := time.Now().Add(attachTimeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if nbd.Connected(dev) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s wasn't connected within %s", dev, attachTimeout)
+}
+
+func (st *stagedVolume) detach() error {
+	st.nbd.Disconnect()
+	<-st.served
+	return st.file.Close()
+}
+
+func (d *Driver) NodeStageVolume(ctx context.Context, req *NodeStageVolumeRequest) (*NodeStageVolumeResponse, error) {
+	id, path := req.VolumeId, req.StagingTargetPath
+	if id == "" || path == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID and staging path are required")
+	}
+	c := req.GetVolumeCapability()
+	if why := checkCapability(c); why != "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", why)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.staged[id]; ok {
+		return &NodeStageVolumeResponse{}, nil
+	}
+	vol, err := block.OpenBlockVolume(d.srv, id)
+	if err != nil {
+		return nil, grpc.Errorf(codes.NotFound, "no volume %s: %v", id, err)
+	}
+	st, err := d.attach(vol)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if m := c.GetMount(); m != nil {
+		fstype := m.FsType
+		if fstype == "" {
+			fstype = defaultFSType
+		}
+		options := m.MountFlags
+		if accessMode(c) == VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY {
+			options = append(options, "ro")
+		}
+		if err = mount.FormatAndMount(st.dev, fstype, path, options); err != nil {
+			st.detach()
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+		st.path = path
+	}
+	d.staged[id] = st
+	clog.Infof("staged volume %s on %s", id, st.dev)
+	return &NodeStageVolumeResponse{}, nil
+}
+
+func (d *Driver) NodeUnstageVolume(ctx context.Context, req *NodeUnstageVolumeRequest) (*NodeUnstageVolumeResponse, error) {
+	id := req.VolumeId
+	if id == "" || req.StagingTargetPath == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID and staging path are required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.staged[id]
+	if !ok {
+		return &NodeUnstageVolumeResponse{}, nil
+	}
+	if st.path != "" {
+		if err := mount.Unmount(st.path); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+	}
+	delete(d.staged, id)
+	if err := st.detach(); err != nil {
+		return nil, toStatus(err)
+	}
+	clog.Infof("unstaged volume %s from %s", id, st.dev)
+	return &NodeUnstageVolumeResponse{}, nil
+}
+
+func (d *Driver) NodePublishVolume(ctx context.Context, req *NodePublishVolumeRequest) (*NodePublishVolumeResponse, error) {
+	id, target := req.VolumeId, req.TargetPath
+	if id == "" || target == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID and target path are required")
+	}
+	c := req.GetVolumeCapability()
+	if why := checkCapability(c); why != "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", why)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.staged[id]
+	if !ok {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "volume %s isn't staged", id)
+	}
+	if mounted, err := mount.IsMounted(target); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	} else if mounted {
+		return &NodePublishVolumeResponse{}, nil
+	}
+
+	// Publishing bind mounts the staged filesystem, or the device itself.
+	options := []string{"bind"}
+	if req.Readonly {
+		options = append(options, "ro")
+	}
+	source := st.path
+	var err error
+	if c.GetBlock() != nil {
+		source = st.dev
+		if err = os.MkdirAll(filepath.Dir(target), 0750); err == nil {
+			var f *os.File
+			if f, err = os.OpenFile(target, os.O_CREATE, 0640); err == nil {
+				err = f.Close()
+			}
+		}
+	} else {
+		if source == "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "volume %s was staged as a block device", id)
+		}
+		err = os.MkdirAll(target, 0750)
+	}
+	if err == nil {
+		err = mount.Mount(source, target, "", options)
+	}
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	return &NodePublishVolumeResponse{}, nil
+}
+
+func (d *Driver) NodeUnpublishVolume(ctx context.Context, req *NodeUnpublishVolumeRequest) (*NodeUnpublishVolumeResponse, error) {
+	target := req.TargetPath
+	if req.VolumeId == "" || target == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID and target path are required")
+	}
+	if err := mount.Unmount(target); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	return &NodeUnpublishVolumeResponse{}, nil
+}
+
+func (d *Driver) NodeExpandVolume(ctx context.Context, req *NodeExpandVolumeRequest) (*NodeExpandVolumeResponse, error) {
+	id := req.VolumeId
+	if id == "" || req.VolumePath == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "volume ID and path are required")
+	}
+	d.mu.Lock()
+	st, ok := d.staged[id]
+	d.mu.Unlock()
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "volume %s isn't staged on this node", id)
+	}
+
+	// The attached volume picks up the controller's resize request on its
+	// own; wait for it to.
+	r, _ := capacity(req.GetCapacityRange())
+	required := uint64(r)
+	for deadline := time.Now().Add(resizeTimeout); st.file.Size() < required; time.Sleep(time.Second) {
+		if time.Now().After(deadline) {
+			return nil, grpc.Errorf(codes.Unavailable, "volume %s wasn't resized to %d bytes within %s", id, required, resizeTimeout)
+		}
+	}
+	if st.path != "" && req.GetVolumeCapability().GetBlock() == nil {
+		if err := mount.GrowFS(st.dev, st.path); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+	}
+	return &NodeExpandVolumeResponse{CapacityBytes: int64(st.file.Size())}, nil
+}
+
+func (d *Driver) NodeGetCapabilities(ctx context.Context, req *NodeGetCapabilitiesRequest) (*NodeGetCapabilitiesResponse, error) {
+	var caps []*NodeServiceCapability
+	for _, t := range []NodeServiceCapability_RPC_Type{
+		NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+		NodeServiceCapability_RPC_EXPAND_VOLUME,
+	} {
+		caps = append(caps, &NodeServiceCapability{Rpc: &NodeServiceCapability_RPC{Type: t}})
+	}
+	return &NodeGetCapabilitiesResponse{Capabilities: caps}, nil
+}
+
+func (d *Driver) NodeGetInfo(ctx context.Context, req *NodeGetInfoRequest) (*NodeGetInfoResponse, error) {
+	return &NodeGetInfoResponse{NodeId: d.opts.NodeID}, nil
+}
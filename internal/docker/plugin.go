@@ -0,0 +1,508 @@
+// Package docker serves block volumes to Docker as a volume plugin. A volume
+// is attached to this host and mounted when the first container using it
+// starts, and unmounted and detached when the last one stops.
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/internal/mount"
+	"github.com/coreos/torus/models"
+	"github.com/dustin/go-humanize"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "docker")
+
+const (
+	// contentType is the type of plugin requests and responses.
+	contentType = "application/vnd.docker.plugins.v1+json"
+	// defaultVolumeSize is the size of volumes created without one.
+	defaultVolumeSize = 1 << 30
+	// defaultFSType is the filesystem made on volumes created without one.
+	defaultFSType = "ext4"
+	// exportProtocol names the options kept with each volume.
+	exportProtocol = "docker"
+)
+
+// VolumeOptions are how a volume is attached and mounted, as given to
+// `docker volume create -o`. They're kept with the volume, so that every host
+// mounts it the same way.
+type VolumeOptions struct {
+	FSType         string `json:"fsType"`
+	WriteLevel     string `json:"writeLevel,omitempty"`
+	WriteCacheSize string `json:"writeCacheSize,omitempty"`
+	Trim           bool   `json:"trim,omitempty"`
+}
+
+// parseOptions parses the options of a create request into the volume size,
+// or 0 if it's not given, and the rest of the options, or nil if none of
+// them are.
+func parseOptions(opts map[string]string) (uint64, *VolumeOptions, error) {
+	var size uint64
+	vo := &VolumeOptions{FSType: defaultFSType}
+	given := false
+	for k, v := range opts {
+		var err error
+		given = given || k != "size"
+		switch k {
+		case "size":
+			size, err = humanize.ParseBytes(v)
+			if err == nil && size == 0 {
+				err = torus.ErrInvalid
+			}
+		case "fstype":
+			vo.FSType = v
+		case "write-level":
+			_, err = torus.ParseWriteLevel(v)
+			vo.WriteLevel = v
+		case "write-cache-size":
+			_, err = humanize.ParseBytes(v)
+			vo.WriteCacheSize = v
+		case "trim":
+			vo.Trim, err = strconv.ParseBool(v)
+		default:
+			return 0, nil, fmt.Errorf("unknown option %s", k)
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("bad %s %q: %v", k, v, err)
+		}
+	}
+	if !given {
+		vo = nil
+	}
+	return size, vo, nil
+}
+
+// An Attachment is a volume attached to a block device on this host.
+type Attachment interface {
+	// Device is the path of the block device.
+	Device() string
+	// Detach detaches the volume, once nothing uses the device.
+	Detach() error
+}
+
+// An Attacher attaches a volume to a block device on this host, as opts say.
+// The plugin calls it for one volume at a time.
+type Attacher func(volume string, opts *VolumeOptions) (Attachment, error)
+
+// Options configure a Plugin.
+type Options struct {
+	// Root is the directory volumes are mounted under, each in a directory
+	// of its name.
+	Root string
+	// Attach attaches volumes to mount.
+	Attach Attacher
+}
+
+// A Plugin serves the Docker volume plugin protocol over HTTP.
+type Plugin struct {
+	srv      *torus.Server
+	opts     Options
+	handlers map[string]func(*volumeRequest) (*volumeResponse, error)
+
+	mu      sync.Mutex
+	mounted map[string]*mountedVolume
+}
+
+// A mountedVolume is a volume the plugin attached and mounted.
+type mountedVolume struct {
+	att  Attachment
+	path string
+	// ids are the mount IDs Docker has mounted the volume under. A volume
+	// without any is one whose release failed, to be retried.
+	ids map[string]bool
+	// unmounted and detached record how far a failed release got, so that
+	// retrying it doesn't redo those steps.
+	unmounted bool
+	detached  bool
+}
+
+// volumeRequest has the fields of any VolumeDriver request.
+type volumeRequest struct {
+	Name string
+	Opts map[string]string
+	ID   string
+}
+
+type volumeInfo struct {
+	Name       string
+	Mountpoint string                 `json:",omitempty"`
+	Status     map[string]interface{} `json:",omitempty"`
+}
+
+type capabilities struct {
+	Scope string
+}
+
+// volumeResponse has the fields of any VolumeDriver response.
+type volumeResponse struct {
+	Mountpoint   string        `json:",omitempty"`
+	Volume       *volumeInfo   `json:",omitempty"`
+	Volumes      []*volumeInfo `json:",omitempty"`
+	Capabilities *capabilities `json:",omitempty"`
+	Err          string
+}
+
+type activateResponse struct {
+	Implements []string
+}
+
+// NewPlugin returns a plugin serving the block volumes of srv.
+func NewPlugin(srv *torus.Server, opts Options) *Plugin {
+	p := &Plugin{
+		srv:     srv,
+		opts:    opts,
+		mounted: make(map[string]*mountedVolume),
+	}
+	p.handlers = map[string]func(*volumeRequest) (*volumeResponse, error){
+		"/VolumeDriver.Create":       p.create,
+		"/VolumeDriver.Remove":       p.remove,
+		"/VolumeDriver.Mount":        p.mount,
+		"/VolumeDriver.Unmount":      p.unmount,
+		"/VolumeDriver.Path":         p.path,
+		"/VolumeDriver.Get":          p.get,
+		"/VolumeDriver.List":         p.list,
+		"/VolumeDriver.Capabilities": p.capabilities,
+	}
+	return p
+}
+
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resp interface{}
+	if r.URL.Path == "/Plugin.Activate" {
+		resp = &activateResponse{Implements: []string{"VolumeDriver"}}
+	} else {
+		h, ok := p.handlers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// Some requests, such as List, have no body.
+		req := &volumeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && err != io.EOF {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		vr, err := h(req)
+		if err != nil {
+			clog.Errorf("%s %s: %v", r.URL.Path, req.Name, err)
+			vr = &volumeResponse{Err: err.Error()}
+		}
+		resp = vr
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		clog.Errorf("couldn't write response to %s: %v", r.URL.Path, err)
+	}
+}
+
+// Close unmounts and detaches the volumes the plugin mounted.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for name, m := range p.mounted {
+		if cerr := m.release(); cerr != nil {
+			if err == nil {
+				err = cerr
+			}
+			continue
+		}
+		delete(p.mounted, name)
+	}
+	return err
+}
+
+// checkName rejects names which aren't a single path element, since
+// volumes are mounted in a directory of their name.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("bad volume name %q", name)
+	}
+	return nil
+}
+
+// lookup returns the block volume name, or nil if there's no volume of that
+// name.
+func (p *Plugin) lookup(name string) (*models.Volume, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	vols, _, err := p.srv.MDS.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if v.Name != name {
+			continue
+		}
+		if v.Type != block.VolumeType {
+			return nil, fmt.Errorf("volume %s isn't a block volume", name)
+		}
+		return v, nil
+	}
+	return nil, nil
+}
+
+// volumeOptions returns the options kept with vol, or the defaults if it has
+// none, as volumes created with torusctl don't.
+func volumeOptions(vol *block.BlockVolume) (*VolumeOptions, error) {
+	data, err := vol.ExportState(exportProtocol)
+	if err != nil {
+		return nil, err
+	}
+	vo := &VolumeOptions{FSType: defaultFSType}
+	if len(data) == 0 {
+		return vo, nil
+	}
+	if err = json.Unmarshal(data, vo); err != nil {
+		return nil, err
+	}
+	return vo, nil
+}
+
+// create creates a volume. Creating a volume which exists adopts it, so that
+// Docker can use volumes created with torusctl; its options are replaced by
+// any given besides its size.
+func (p *Plugin) create(req *volumeRequest) (*volumeResponse, error) {
+	size, vo, err := parseOptions(req.Opts)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := p.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case existing == nil:
+		if size == 0 {
+			size = defaultVolumeSize
+		}
+		if vo == nil {
+			vo = &VolumeOptions{FSType: defaultFSType}
+		}
+		if err = block.CreateBlockVolume(p.srv.MDS, req.Name, size); err != nil {
+			return nil, err
+		}
+		clog.Infof("created volume %s of %d bytes", req.Name, size)
+	case size != 0 && size != existing.MaxBytes:
+		return nil, fmt.Errorf("volume %s exists with size %s", req.Name, humanize.IBytes(existing.MaxBytes))
+	case vo == nil:
+		return &volumeResponse{}, nil
+	}
+	vol, err := block.OpenBlockVolume(p.srv, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(vo)
+	if err != nil {
+		return nil, err
+	}
+	return &volumeResponse{}, vol.SetExportState(exportProtocol, data)
+}
+
+// remove deletes a volume. Volumes with snapshots are kept, since the
+// snapshots would go with them.
+func (p *Plugin) remove(req *volumeRequest) (*volumeResponse, error) {
+	v, err := p.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return &volumeResponse{}, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.mounted[req.Name]; ok {
+		return nil, fmt.Errorf("volume %s is in use", req.Name)
+	}
+	vol, err := block.OpenBlockVolume(p.srv, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := vol.GetSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) != 0 {
+		return nil, fmt.Errorf("volume %s has %d snapshots; delete them with torusctl first", req.Name, len(snaps))
+	}
+	if err = block.DeleteBlockVolume(p.srv.MDS, req.Name); err != nil {
+		if err == torus.ErrLocked {
+			return nil, fmt.Errorf("volume %s is attached on another host", req.Name)
+		}
+		return nil, err
+	}
+	clog.Infof("removed volume %s", req.Name)
+	return &volumeResponse{}, nil
+}
+
+func (p *Plugin) mount(req *volumeRequest) (*volumeResponse, error) {
+	if err := checkName(req.Name); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.mounted[req.Name]; ok {
+		if len(m.ids) != 0 {
+			m.ids[req.ID] = true
+			return &volumeResponse{Mountpoint: m.path}, nil
+		}
+		// Finish the failed unmount before mounting it afresh.
+		if err := m.release(); err != nil {
+			return nil, err
+		}
+		delete(p.mounted, req.Name)
+	}
+
+	vol, err := block.OpenBlockVolume(p.srv, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	vo, err := volumeOptions(vol)
+	if err != nil {
+		return nil, err
+	}
+	att, err := p.opts.Attach(req.Name, vo)
+	if err != nil {
+		return nil, err
+	}
+	options := []string{"noatime"}
+	if vo.Trim {
+		options = append(options, "discard")
+	}
+	path := filepath.Join(p.opts.Root, req.Name)
+	if err = mount.FormatAndMount(att.Device(), vo.FSType, path, options); err != nil {
+		if derr := att.Detach(); derr != nil {
+			clog.Errorf("couldn't detach %s: %v", att.Device(), derr)
+		}
+		return nil, err
+	}
+	p.mounted[req.Name] = &mountedVolume{
+		att:  att,
+		path: path,
+		ids:  map[string]bool{req.ID: true},
+	}
+	clog.Infof("mounted volume %s from %s at %s", req.Name, att.Device(), path)
+	return &volumeResponse{Mountpoint: path}, nil
+}
+
+func (p *Plugin) unmount(req *volumeRequest) (*volumeResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.mounted[req.Name]
+	if !ok {
+		return nil, fmt.Errorf("volume %s isn't mounted", req.Name)
+	}
+	delete(m.ids, req.ID)
+	if len(m.ids) != 0 {
+		return &volumeResponse{}, nil
+	}
+	// Keep the volume until it's released, so that a failure can be retried
+	// rather than leave it attached with no record of it.
+	if err := m.release(); err != nil {
+		return nil, err
+	}
+	delete(p.mounted, req.Name)
+	clog.Infof("unmounted volume %s", req.Name)
+	return &volumeResponse{}, nil
+}
+
+// release unmounts and detaches m. It can be retried after a failure.
+func (m *mountedVolume) release() error {
+	if !m.unmounted {
+		if err := mount.Unmount(m.path); err != nil {
+			return err
+		}
+		m.unmounted = true
+	}
+	if !m.detached {
+		if err := m.att.Detach(); err != nil {
+			return err
+		}
+		m.detached = true
+	}
+	if err := os.Remove(m.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// mountpoint returns where the volume name is mounted, or the empty string
+// if it isn't.
+func (p *Plugin) mountpoint(name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.mounted[name]; ok {
+		return m.path
+	}
+	return ""
+}
+
+func (p *Plugin) path(req *volumeRequest) (*volumeResponse, error) {
+	return &volumeResponse{Mountpoint: p.mountpoint(req.Name)}, nil
+}
+
+func (p *Plugin) get(req *volumeRequest) (*volumeResponse, error) {
+	v, err := p.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("no volume %s", req.Name)
+	}
+	vol, err := block.OpenBlockVolume(p.srv, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	vo, err := volumeOptions(vol)
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]interface{}{
+		"size":   humanize.IBytes(v.MaxBytes),
+		"fstype": vo.FSType,
+	}
+	if vo.WriteLevel != "" {
+		status["write-level"] = vo.WriteLevel
+	}
+	return &volumeResponse{Volume: &volumeInfo{
+		Name:       v.Name,
+		Mountpoint: p.mountpoint(v.Name),
+		Status:     status,
+	}}, nil
+}
+
+func (p *Plugin) list(req *volumeRequest) (*volumeResponse, error) {
+	vols, _, err := p.srv.MDS.GetVolumes()
+	if err != nil {
+		return nil, err
+	}
+	resp := &volumeResponse{}
+	for _, v := range vols {
+		if v.Type != block.VolumeType {
+			continue
+		}
+		resp.Volumes = append(resp.Volumes, &volumeInfo{
+			Name:       v.Name,
+			Mountpoint: p.mountpoint(v.Name),
+		})
+	}
+	return resp, nil
+}
+
+// capabilities reports that volumes are global: a name is the same volume on
+// every host, so Docker needn't create it on each.
+func (p *Plugin) capabilities(req *volumeRequest) (*volumeResponse, error) {
+	return &volumeResponse{Capabilities: &capabilities{Scope: "global"}}, nil
+}
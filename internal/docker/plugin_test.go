@@ -0,0 +1,244 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/block"
+
+	_ "github.com/coreos/torus/metadata/temp"
+	_ "github.com/coreos/torus/storage"
+)
+
+type pluginTest struct {
+	t   *testing.T
+	srv *torus.Server
+	ts  *httptest.Server
+}
+
+// newPluginTest serves the plugin over HTTP, backed by the temp metadata
+// service. Volumes can't be mounted, which takes NBD devices.
+func newPluginTest(t *testing.T) *pluginTest {
+	srv := torus.NewMemoryServer()
+	p := NewPlugin(srv, Options{})
+	return &pluginTest{
+		t:   t,
+		srv: srv,
+		ts:  httptest.NewServer(p),
+	}
+}
+
+func (pt *pluginTest) close() {
+	pt.ts.Close()
+	pt.srv.Close()
+}
+
+// call posts req to the endpoint and decodes the response into resp.
+func (pt *pluginTest) call(endpoint string, req, resp interface{}) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		pt.t.Fatal(err)
+	}
+	r, err := http.Post(pt.ts.URL+endpoint, contentType, bytes.NewReader(body))
+	if err != nil {
+		pt.t.Fatal(err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		pt.t.Fatalf("%s: status %s", endpoint, r.Status)
+	}
+	if err = json.NewDecoder(r.Body).Decode(resp); err != nil {
+		pt.t.Fatal(err)
+	}
+}
+
+func (pt *pluginTest) volume(req *volumeRequest, endpoint string) *volumeResponse {
+	resp := &volumeResponse{}
+	pt.call("/VolumeDriver."+endpoint, req, resp)
+	return resp
+}
+
+func TestActivate(t *testing.T) {
+	pt := newPluginTest(t)
+	defer pt.close()
+
+	resp := &activateResponse{}
+	pt.call("/Plugin.Activate", nil, resp)
+	if len(resp.Implements) != 1 || resp.Implements[0] != "VolumeDriver" {
+		t.Fatalf("implements %v", resp.Implements)
+	}
+	caps := pt.volume(&volumeRequest{}, "Capabilities")
+	if caps.Capabilities == nil || caps.Capabilities.Scope != "global" {
+		t.Fatalf("capabilities %+v", caps.Capabilities)
+	}
+}
+
+func TestCreateRemove(t *testing.T) {
+	pt := newPluginTest(t)
+	defer pt.close()
+
+	opts := map[string]string{"size": "10MiB", "fstype": "xfs", "write-level": "one"}
+	if resp := pt.volume(&volumeRequest{Name: "vol", Opts: opts}, "Create"); resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	resp := pt.volume(&volumeRequest{Name: "vol"}, "Get")
+	if resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	st := resp.Volume.Status
+	if resp.Volume.Name != "vol" || st["size"] != "10 MiB" || st["fstype"] != "xfs" || st["write-level"] != "one" {
+		t.Fatalf("got %+v", resp.Volume)
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Path"); resp.Err != "" || resp.Mountpoint != "" {
+		t.Fatalf("unmounted volume has path %q (%s)", resp.Mountpoint, resp.Err)
+	}
+
+	// Creating it again adopts it, unless the size differs.
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Create"); resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol", Opts: map[string]string{"size": "20MiB"}}, "Create"); resp.Err == "" {
+		t.Fatal("recreated a volume with another size")
+	}
+	resp = pt.volume(&volumeRequest{Name: "vol"}, "Get")
+	if resp.Volume == nil || resp.Volume.Status["fstype"] != "xfs" {
+		t.Fatalf("adopting lost the options: %+v", resp.Volume)
+	}
+
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Remove"); resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Get"); resp.Err == "" {
+		t.Fatal("got a removed volume")
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Remove"); resp.Err != "" {
+		t.Fatalf("removing a removed volume: %s", resp.Err)
+	}
+}
+
+func TestCreateBadOptions(t *testing.T) {
+	pt := newPluginTest(t)
+	defer pt.close()
+
+	for _, opts := range []map[string]string{
+		{"size": "lots"},
+		{"size": "0"},
+		{"write-level": "most"},
+		{"trim": "maybe"},
+		{"color": "blue"},
+	} {
+		if resp := pt.volume(&volumeRequest{Name: "vol", Opts: opts}, "Create"); resp.Err == "" {
+			t.Errorf("created a volume with options %v", opts)
+		}
+	}
+	if resp := pt.volume(&volumeRequest{Name: "../vol"}, "Create"); resp.Err == "" {
+		t.Error("created a volume named ../vol")
+	}
+	if resp := pt.volume(&volumeRequest{}, "List"); len(resp.Volumes) != 0 {
+		t.Errorf("bad options created volumes %v", resp.Volumes)
+	}
+}
+
+func TestList(t *testing.T) {
+	pt := newPluginTest(t)
+	defer pt.close()
+
+	if err := block.CreateBlockVolume(pt.srv.MDS, "made-by-torusctl", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol"}, "Create"); resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	resp := pt.volume(&volumeRequest{}, "List")
+	if resp.Err != "" {
+		t.Fatal(resp.Err)
+	}
+	names := make(map[string]bool)
+	for _, v := range resp.Volumes {
+		names[v.Name] = true
+	}
+	if len(names) != 2 || !names["vol"] || !names["made-by-torusctl"] {
+		t.Fatalf("listed %v", names)
+	}
+
+	// Volumes without options get the defaults.
+	resp = pt.volume(&volumeRequest{Name: "made-by-torusctl"}, "Get")
+	if resp.Volume == nil || resp.Volume.Status["fstype"] != defaultFSType {
+		t.Fatalf("got %+v (%s)", resp.Volume, resp.Err)
+	}
+	if resp := pt.volume(&volumeRequest{Name: "vol", ID: "c1"}, "Unmount"); resp.Err == "" {
+		t.Fatal("unmounted a volume that wasn't mounted")
+	}
+}
+
+// flakyAttachment fails to detach until failures run out.
+type flakyAttachment struct {
+	failures int
+	detached bool
+}
+
+func (a *flakyAttachment) Device() string { return "/dev/null" }
+
+func (a *flakyAttachment) Detach() error {
+	if a.failures > 0 {
+		a.failures--
+		return errors.New("device busy")
+	}
+	a.detached = true
+	return nil
+}
+
+func TestUnmountRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vol")
+	if err = os.Mkdir(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	srv := torus.NewMemoryServer()
+	defer srv.Close()
+	p := NewPlugin(srv, Options{Root: dir})
+	att := &flakyAttachment{failures: 1}
+	p.mounted["vol"] = &mountedVolume{
+		att:  att,
+		path: path,
+		ids:  map[string]bool{"c1": true, "c2": true},
+	}
+
+	if _, err = p.unmount(&volumeRequest{Name: "vol", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if att.detached || p.mountpoint("vol") != path {
+		t.Fatal("released a volume still mounted by a container")
+	}
+	if _, err = p.unmount(&volumeRequest{Name: "vol", ID: "c2"}); err == nil {
+		t.Fatal("unmount succeeded though detaching failed")
+	}
+	if p.mountpoint("vol") != path {
+		t.Fatal("forgot a volume which is still attached")
+	}
+	if m := p.mounted["vol"]; !m.unmounted || m.detached {
+		t.Fatal("release didn't record that it got as far as detaching")
+	}
+	// Docker retries the unmount.
+	if _, err = p.unmount(&volumeRequest{Name: "vol", ID: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !att.detached || p.mountpoint("vol") != "" {
+		t.Fatal("volume wasn't released")
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("mountpoint left behind: %v", err)
+	}
+}
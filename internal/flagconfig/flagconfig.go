@@ -35,6 +35,11 @@ var (
 	etcdCertFile      string
 	etcdKeyFile       string
 	etcdCAFile        string
+	raftMembers       string
+	raftCertFile      string
+	raftKeyFile       string
+	raftCAFile        string
+	namespace         string
 	config            string
 	profile           string
 )
@@ -48,6 +53,11 @@ func AddConfigFlags(set *flag.FlagSet) {
 	set.StringVarP(&etcdCertFile, "etcd-cert-file", "", "", "Certificate to use to authenticate against etcd")
 	set.StringVarP(&etcdKeyFile, "etcd-key-file", "", "", "Key for Certificate")
 	set.StringVarP(&etcdCAFile, "etcd-ca-file", "", "", "CA to authenticate etcd against")
+	set.StringVarP(&namespace, "namespace", "", "", "Namespace of this torus cluster, among those sharing the same etcd")
+	set.StringVarP(&raftMembers, "raft", "", "", "Comma-separated URLs of the torusd processes serving embedded raft metadata, used instead of etcd")
+	set.StringVarP(&raftCertFile, "raft-cert-file", "", "", "Certificate to present to raft metadata members, and to serve with as one; members must then be https URLs")
+	set.StringVarP(&raftKeyFile, "raft-key-file", "", "", "Key for the raft Certificate")
+	set.StringVarP(&raftCAFile, "raft-ca-file", "", "", "CA to authenticate raft metadata members and their clients against")
 	set.StringVarP(&config, "config", "", "", "path to torus config file")
 	set.StringVarP(&profile, "profile", "", "default", "profile to use in torus config file")
 }
@@ -79,6 +89,15 @@ func LoadConfigFile(config string) (*cli.TorusConfig, error) {
 
 }
 
+// MetadataServiceName returns the name of the metadata service the flags
+// configure: "raft" if --raft was given, "etcd" otherwise.
+func MetadataServiceName() string {
+	if raftMembers != "" {
+		return "raft"
+	}
+	return "etcd"
+}
+
 func BuildConfigFromFlags() torus.Config {
 	var err error
 	if config == "" {
@@ -102,6 +121,9 @@ func BuildConfigFromFlags() torus.Config {
 		if etcdCAFile == "" {
 			etcdCAFile = conf.EtcdConfig[profile].EtcdCAFile
 		}
+		if namespace == "" {
+			namespace = conf.EtcdConfig[profile].Namespace
+		}
 	}
 
 	readCacheSize, err = humanize.ParseBytes(readCacheSizeStr)
@@ -132,11 +154,21 @@ func BuildConfigFromFlags() torus.Config {
 	}
 
 	cfg := torus.Config{
-		StorageSize:     localBlockSize,
-		ReadCacheSize:   readCacheSize,
-		WriteLevel:      wl,
-		ReadLevel:       rl,
-		MetadataAddress: etcdAddress,
+		StorageSize:       localBlockSize,
+		ReadCacheSize:     readCacheSize,
+		WriteLevel:        wl,
+		ReadLevel:         rl,
+		MetadataAddress:   etcdAddress,
+		MetadataNamespace: namespace,
+	}
+	if raftMembers != "" {
+		cfg.MetadataAddress = raftMembers
+		if raftCertFile != "" {
+			// Members verify whoever connects against the same CAs, so the
+			// config serves as is for both sides.
+			cfg.TLS = loadTLS(raftCertFile, raftKeyFile, raftCAFile)
+		}
+		return cfg
 	}
 	etcdURL, err := url.Parse(etcdAddress)
 	if err != nil {
@@ -145,24 +177,29 @@ func BuildConfigFromFlags() torus.Config {
 	}
 
 	if etcdCertFile != "" {
-		etcdCert, err := tls.LoadX509KeyPair(etcdCertFile, etcdKeyFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "couldn't load cert/key: %s", err)
-			os.Exit(1)
-		}
-		caPem, err := ioutil.ReadFile(etcdCAFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "couldn't load trusted CA cert: %s", err)
-			os.Exit(1)
-		}
-		etcdCertPool := x509.NewCertPool()
-		etcdCertPool.AppendCertsFromPEM(caPem)
-		cfg.TLS = &tls.Config{
-			Certificates: []tls.Certificate{etcdCert},
-			RootCAs:      etcdCertPool,
-			ServerName:   strings.Split(etcdURL.Host, ":")[0],
-		}
+		cfg.TLS = loadTLS(etcdCertFile, etcdKeyFile, etcdCAFile)
+		cfg.TLS.ServerName = strings.Split(etcdURL.Host, ":")[0]
 	}
 
 	return cfg
 }
+
+// loadTLS loads a client certificate and the CA to verify servers against.
+func loadTLS(certFile, keyFile, caFile string) *tls.Config {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't load cert/key: %s", err)
+		os.Exit(1)
+	}
+	caPem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't load trusted CA cert: %s", err)
+		os.Exit(1)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPem)
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}
+}
@@ -0,0 +1,121 @@
+// mount formats, mounts and grows the filesystems on attached block volumes,
+// with the host's tools.
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// run runs a command, and returns its output with the error if it fails.
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// FSType returns the type of the filesystem on dev, or the empty string if
+// it has none.
+func FSType(dev string) (string, error) {
+	out, err := exec.Command("blkid", "-p", "-s", "TYPE", "-o", "value", dev).Output()
+	if ee, ok := err.(*exec.ExitError); ok && ee.Sys().(syscall.WaitStatus).ExitStatus() == 2 {
+		// blkid found nothing.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("blkid %s: %v", dev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Format makes a filesystem of type fstype on dev, unless it has one. It
+// fails if dev holds a filesystem of another type.
+func Format(dev, fstype string) error {
+	have, err := FSType(dev)
+	if err != nil {
+		return err
+	}
+	switch have {
+	case "":
+		return run("mkfs", "-t", fstype, dev)
+	case fstype:
+		return nil
+	}
+	return fmt.Errorf("%s holds a %s filesystem, not %s", dev, have, fstype)
+}
+
+// FormatAndMount formats dev, as Format does, and mounts it at target,
+// creating target if need be.
+func FormatAndMount(dev, fstype, target string, options []string) error {
+	if err := Format(dev, fstype); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, 0750); err != nil {
+		return err
+	}
+	return Mount(dev, target, fstype, options)
+}
+
+// Mount mounts source at target with the given options. An empty fstype
+// leaves the type to mount.
+func Mount(source, target, fstype string, options []string) error {
+	var args []string
+	if fstype != "" {
+		args = append(args, "-t", fstype)
+	}
+	if len(options) != 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	return run("mount", append(args, source, target)...)
+}
+
+// Unmount unmounts target, if something's mounted there.
+func Unmount(target string) error {
+	ok, err := IsMounted(target)
+	if err != nil || !ok {
+		return err
+	}
+	return run("umount", target)
+}
+
+var mountsUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// IsMounted is true if something is mounted at target.
+func IsMounted(target string) (bool, error) {
+	target = filepath.Clean(target)
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && mountsUnescaper.Replace(fields[1]) == target {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// GrowFS grows the filesystem on dev, mounted at path, to fill it.
+func GrowFS(dev, path string) error {
+	fstype, err := FSType(dev)
+	if err != nil {
+		return err
+	}
+	switch fstype {
+	case "ext2", "ext3", "ext4":
+		return run("resize2fs", dev)
+	case "xfs":
+		return run("xfs_growfs", path)
+	}
+	return fmt.Errorf("can't grow a %q filesystem", fstype)
+}
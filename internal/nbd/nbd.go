@@ -11,6 +11,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
@@ -34,32 +35,65 @@ const (
 )
 
 const (
-	cmdRead  = 0
-	cmdWrite = 1
-	cmdDisc  = 2
-	cmdFlush = 3
-	cmdTrim  = 4
+	cmdRead        = 0
+	cmdWrite       = 1
+	cmdDisc        = 2
+	cmdFlush       = 3
+	cmdTrim        = 4
+	cmdWriteZeroes = 6
+	cmdBlockStatus = 7
 )
 
 const (
-	flagHasFlags  = (1 << 0) // nbd-server supports flags
-	flagSendFlush = (1 << 2) // can flush writeback cache
-	flagSendTrim  = (1 << 5) // Send TRIM (discard)
-	// flagReadOnly   = (1 << 1) // device is read-only
+	cmdFlagNoHole = (1 << 1) // don't leave a hole when writing zeroes
+	cmdFlagReqOne = (1 << 3) // reply with only one block status extent
+)
+
+const (
+	flagHasFlags        = (1 << 0) // nbd-server supports flags
+	flagReadOnly        = (1 << 1) // device is read-only
+	flagSendFlush       = (1 << 2) // can flush writeback cache
+	flagSendTrim        = (1 << 5) // Send TRIM (discard)
+	flagSendWriteZeroes = (1 << 6) // Send WRITE_ZEROES
+	flagCanMulti        = (1 << 8) // multiple connections to the export are safe
 	// flagSendFUA    = (1 << 3) // Send FUA (Force Unit Access)
 	// flagRotational = (1 << 4) // Use elevator algorithm - rotational media
 )
 
 const (
-	magicRequest = 0x25609513
-	magicReply   = 0x67446698
+	magicRequest         = 0x25609513
+	magicReply           = 0x67446698
+	magicStructuredReply = 0x668e33ef
 	// Do *not* use magics: 0x12560953 0x96744668.
 )
 
 const (
-	errIO = 5
+	errPerm  = 1
+	errIO    = 5
+	errInval = 22
+)
+
+// structured reply chunk types, and the flag on the last chunk
+const (
+	replyFlagDone = (1 << 0)
+
+	replyTypeOffsetData  = 1
+	replyTypeBlockStatus = 5
+	replyTypeError       = (1 << 15) + 1
 )
 
+// block status flags of base:allocation
+const (
+	stateHole = (1 << 0)
+	stateZero = (1 << 1)
+)
+
+// maxRequestSize is the largest read or write accepted.
+const maxRequestSize = 32 << 20
+
+// maxInFlight is how many requests a connection handles at once.
+const maxInFlight = 64
+
 // ioctl() helper function
 func ioctl(a1, a2, a3 uintptr) (err error) {
 	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, a1, a2, a3)
@@ -79,6 +113,40 @@ type Device interface {
 	Close() error
 }
 
+// A Zeroer is a Device which can zero a range, leaving holes where it can,
+// faster than writing zeroes to it.
+type Zeroer interface {
+	Zero(off, length int64) error
+}
+
+// An Extent is a run of a Device which either holds data or is a hole,
+// taking up no space and reading as zeroes.
+type Extent struct {
+	Length int64
+	Hole   bool
+}
+
+// An ExtentMapper is a Device which knows where its holes are.
+type ExtentMapper interface {
+	// Extents maps the length bytes at off into extents.
+	Extents(off, length int64) ([]Extent, error)
+}
+
+// A ReadOnlyDevice is a Device which may refuse writes.
+type ReadOnlyDevice interface {
+	ReadOnly() bool
+}
+
+func isReadOnly(dev Device) bool {
+	ro, ok := dev.(ReadOnlyDevice)
+	return ok && ro.ReadOnly()
+}
+
+// A BlockSizer is a Device with a preferred I/O size.
+type BlockSizer interface {
+	BlockSize() uint32
+}
+
 type NBD struct {
 	device    Device
 	size      int64
@@ -111,6 +179,8 @@ func (nbd *NBD) Size() int64 {
 	return nbd.size
 }
 
+// SetSize sets the size of the device. Once connected, it tells the kernel
+// the device has been resized.
 func (nbd *NBD) SetSize(size int64) error {
 	if err := ioctl(nbd.nbd.Fd(), ioctlSetSize, uintptr(size)); err != nil {
 		return &os.PathError{
@@ -119,6 +189,7 @@ func (nbd *NBD) SetSize(size int64) error {
 			Err:  err,
 		}
 	}
+	nbd.size = size
 	return nil
 }
 
@@ -141,7 +212,7 @@ func FindDevice() (string, error) {
 		if _, err := os.Stat(dev); os.IsNotExist(err) {
 			break // no more devices
 		}
-		if _, err := os.Stat(fmt.Sprintf("/sys/block/nbd%d/pid", i)); !os.IsNotExist(err) {
+		if Connected(dev) {
 			continue // busy
 		}
 		return dev, nil
@@ -149,6 +220,13 @@ func FindDevice() (string, error) {
 	return "", errors.New("no devices available")
 }
 
+// Connected is true if the kernel has connected the NBD device dev to a
+// server.
+func Connected(dev string) bool {
+	_, err := os.Stat(filepath.Join("/sys/block", filepath.Base(dev), "pid"))
+	return !os.IsNotExist(err)
+}
+
 func (nbd *NBD) OpenDevice(dev string) (string, error) {
 	f, err := os.Open(dev)
 	if err != nil {
@@ -207,23 +285,14 @@ func (nbd *NBD) Serve() error {
 	}
 
 	fmt.Printf("Attached to %s. Server loop begins ... \n", nbd.nbd.Name())
-	c := &serverConn{
-		rw: os.NewFile(uintptr(nbd.socket), "<nbd socket>"),
-	}
-	// TODO(barakmich): Scale up NBD by handling multiple requests.
-	// Requires thread-safety across the block.BlockFile/torus.File
-	//n := runtime.GOMAXPROCS(0) - 1
-	n := 1
-
+	c := newServerConn(os.NewFile(uintptr(nbd.socket), "<nbd socket>"))
 	wg := new(sync.WaitGroup)
-	wg.Add(n)
-	for i := 0; i < n; i++ {
-		go func() {
-			if err := c.serveLoop(nbd.device, wg); err != nil {
-				clog.Errorf("server returned: %s", err)
-			}
-		}()
-	}
+	wg.Add(1)
+	go func() {
+		if err := c.serveLoop(nbd.device, wg); err != nil {
+			clog.Errorf("server returned: %s", err)
+		}
+	}()
 	if !blksized {
 		// Back to the hack.
 		go func(nbd *NBD) {
@@ -289,69 +358,63 @@ func (nbd *NBD) Disconnect() {
 	}
 }
 
+// serverConn reads requests off a connection in order, and handles up to
+// maxInFlight of them at once, replying to each as it completes.
 type serverConn struct {
-	mu sync.Mutex
 	rw io.ReadWriteCloser
+	// wmu keeps replies from interleaving.
+	wmu      sync.Mutex
+	inflight sync.WaitGroup
+	sem      chan struct{}
+	// structured is set when replying with structured replies, and
+	// allocCtx when the client has asked for block status.
+	structured bool
+	allocCtx   bool
+}
+
+func newServerConn(rw io.ReadWriteCloser) *serverConn {
+	return &serverConn{
+		rw:  rw,
+		sem: make(chan struct{}, maxInFlight),
+	}
 }
 
 func (c *serverConn) serveLoop(dev Device, wg *sync.WaitGroup) error {
 	if wg != nil {
 		defer wg.Done()
 	}
+	// Whatever ends the loop, let the requests already read finish.
+	defer c.inflight.Wait()
 
-	hdr := new(reqHeader)
-	buf := make([]byte, 16) // FIXME: Maybe this should be hdr[:16] instead?
 	for {
-		c.mu.Lock()
-
+		hdr := new(reqHeader)
 		if _, err := io.ReadFull(c.rw, hdr[:]); err != nil {
 			// FIXME: Are there any valid short reads we need to handle?
-			c.mu.Unlock()
 			return err
 		}
 
 		if magic := hdr.magic(); magic != magicRequest {
-			c.mu.Unlock()
 			return fmt.Errorf("nbd: invalid magic: 0x%x", magic)
 		}
 
+		var buf []byte
 		cmd, _ := hdr.command()
-		if cmd == cmdWrite {
-			buf = hdr.resize(buf)
-			if _, err := io.ReadFull(c.rw, buf[16:]); err != nil {
-				return err
-			}
-		}
-		c.mu.Unlock()
-
 		switch cmd {
-		case cmdRead:
-			buf = hdr.resize(buf)
-			if _, err := dev.ReadAt(buf[16:], hdr.offset()); err != nil {
-				hdr.putReplyHeader(buf, errIO)
-			} else {
-				hdr.putReplyHeader(buf, 0)
-			}
 		case cmdWrite:
-			if _, err := dev.WriteAt(buf[16:], hdr.offset()); err != nil {
-				hdr.putReplyHeader(buf, errIO)
-			} else {
-				hdr.putReplyHeader(buf, 0)
+			if hdr.length() > maxRequestSize {
+				return fmt.Errorf("nbd: write of %d bytes is too big", hdr.length())
 			}
-			buf = buf[:16]
-		case cmdTrim:
-			if err := dev.Trim(hdr.offset(), int64(hdr.length())); err != nil {
-				clog.Printf("trim error: %s", err)
+			buf = hdr.resize(nil)
+			if _, err := io.ReadFull(c.rw, buf[16:]); err != nil {
+				return err
 			}
-			fallthrough
-		case cmdFlush:
-			if err := dev.Sync(); err != nil {
-				clog.Printf("sync error: %s", err)
+		case cmdRead:
+			if hdr.length() > maxRequestSize {
+				return fmt.Errorf("nbd: read of %d bytes is too big", hdr.length())
 			}
-			hdr.putReplyHeader(buf, 0)
-			buf = buf[:16]
+		case cmdTrim, cmdFlush, cmdWriteZeroes, cmdBlockStatus:
 		case cmdDisc:
-			// FIXME: We're actually supposed to wait for outstanding requests to finish.
+			c.inflight.Wait()
 			if err := dev.Sync(); err != nil {
 				clog.Printf("sync error: %s", err)
 			}
@@ -360,11 +423,156 @@ func (c *serverConn) serveLoop(dev Device, wg *sync.WaitGroup) error {
 			return errors.New("nbd: invalid command")
 		}
 
-		// FIXME: Are we sure this whole write is going to be atomic?
-		if _, err := c.rw.Write(buf); err != nil {
+		c.sem <- struct{}{}
+		c.inflight.Add(1)
+		go func() {
+			defer func() {
+				<-c.sem
+				c.inflight.Done()
+			}()
+			if err := c.handle(dev, hdr, buf); err != nil {
+				clog.Errorf("couldn't reply: %s", err)
+				c.rw.Close()
+			}
+		}()
+	}
+}
+
+func (c *serverConn) handle(dev Device, hdr *reqHeader, buf []byte) error {
+	cmd, flags := hdr.command()
+	if isReadOnly(dev) && (cmd == cmdWrite || cmd == cmdTrim || cmd == cmdWriteZeroes) {
+		buf = make([]byte, 16)
+		hdr.putReplyHeader(buf, errPerm)
+		return c.reply(buf)
+	}
+	switch cmd {
+	case cmdRead:
+		if c.structured {
+			return c.structuredRead(dev, hdr)
+		}
+		buf = hdr.resize(nil)
+		if _, err := dev.ReadAt(buf[16:], hdr.offset()); err != nil {
+			hdr.putReplyHeader(buf, errIO)
+			buf = buf[:16]
+		} else {
+			hdr.putReplyHeader(buf, 0)
+		}
+	case cmdWrite:
+		if _, err := dev.WriteAt(buf[16:], hdr.offset()); err != nil {
+			hdr.putReplyHeader(buf, errIO)
+		} else {
+			hdr.putReplyHeader(buf, 0)
+		}
+		buf = buf[:16]
+	case cmdTrim:
+		buf = make([]byte, 16)
+		if err := dev.Trim(hdr.offset(), int64(hdr.length())); err != nil {
+			clog.Printf("trim error: %s", err)
+		}
+		if err := dev.Sync(); err != nil {
+			clog.Printf("sync error: %s", err)
+		}
+		hdr.putReplyHeader(buf, 0)
+	case cmdFlush:
+		buf = make([]byte, 16)
+		if err := dev.Sync(); err != nil {
+			clog.Printf("sync error: %s", err)
+		}
+		hdr.putReplyHeader(buf, 0)
+	case cmdWriteZeroes:
+		buf = make([]byte, 16)
+		if err := writeZeroes(dev, hdr.offset(), int64(hdr.length()), flags&cmdFlagNoHole != 0); err != nil {
+			clog.Printf("write zeroes error: %s", err)
+			hdr.putReplyHeader(buf, errIO)
+		} else {
+			hdr.putReplyHeader(buf, 0)
+		}
+	case cmdBlockStatus:
+		if !c.allocCtx {
+			if c.structured {
+				return c.reply(hdr.structuredError(errInval))
+			}
+			// Without structured replies the client can only read a
+			// simple one.
+			buf = make([]byte, 16)
+			hdr.putReplyHeader(buf, errInval)
+			break
+		}
+		return c.blockStatus(dev, hdr, flags&cmdFlagReqOne != 0)
+	}
+
+	return c.reply(buf)
+}
+
+func (c *serverConn) reply(buf []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return writeFull(c.rw, buf)
+}
+
+// structuredRead replies to a read with one chunk of data.
+func (c *serverConn) structuredRead(dev Device, hdr *reqHeader) error {
+	const head = 20 + 8
+	buf := make([]byte, head+int(hdr.length()))
+	if _, err := dev.ReadAt(buf[head:], hdr.offset()); err != nil && err != io.EOF {
+		return c.reply(hdr.structuredError(errIO))
+	}
+	hdr.putStructuredHeader(buf, replyFlagDone, replyTypeOffsetData, uint32(len(buf)-20))
+	binary.BigEndian.PutUint64(buf[20:28], uint64(hdr.offset()))
+	return c.reply(buf)
+}
+
+// blockStatus replies with the holes of the requested range.
+func (c *serverConn) blockStatus(dev Device, hdr *reqHeader, one bool) error {
+	length := int64(hdr.length())
+	if size := int64(dev.Size()); hdr.offset()+length > size {
+		length = size - hdr.offset()
+	}
+	if length <= 0 {
+		return c.reply(hdr.structuredError(errInval))
+	}
+	extents := []Extent{{Length: length}}
+	if m, ok := dev.(ExtentMapper); ok {
+		var err error
+		if extents, err = m.Extents(hdr.offset(), length); err != nil {
+			clog.Printf("block status error: %s", err)
+			return c.reply(hdr.structuredError(errIO))
+		}
+	}
+	if one && len(extents) > 1 {
+		extents = extents[:1]
+	}
+	buf := make([]byte, 20+4+8*len(extents))
+	hdr.putStructuredHeader(buf, replyFlagDone, replyTypeBlockStatus, uint32(len(buf)-20))
+	binary.BigEndian.PutUint32(buf[20:24], metaCtxAllocationID)
+	for i, e := range extents {
+		var state uint32
+		if e.Hole {
+			state = stateHole | stateZero
+		}
+		binary.BigEndian.PutUint32(buf[24+8*i:], uint32(e.Length))
+		binary.BigEndian.PutUint32(buf[28+8*i:], state)
+	}
+	return c.reply(buf)
+}
+
+func writeZeroes(dev Device, off, length int64, noHole bool) error {
+	if z, ok := dev.(Zeroer); ok && !noHole {
+		return z.Zero(off, length)
+	}
+	zero := make([]byte, 1<<20)
+	for length > 0 {
+		n := int64(len(zero))
+		if n > length {
+			n = length
+		}
+		if _, err := dev.WriteAt(zero[:n], off); err != nil {
 			return err
 		}
+		off += n
+		length -= n
 	}
+	return nil
 }
 
 type reqHeader [28]byte
@@ -400,6 +608,24 @@ func (h *reqHeader) resize(buf []byte) []byte {
 	}
 }
 
+// putStructuredHeader writes the header of a structured reply chunk with
+// length bytes of payload.
+func (h *reqHeader) putStructuredHeader(dst []byte, flags, typ uint16, length uint32) {
+	binary.BigEndian.PutUint32(dst[0:4], magicStructuredReply)
+	binary.BigEndian.PutUint16(dst[4:6], flags)
+	binary.BigEndian.PutUint16(dst[6:8], typ)
+	copy(dst[8:16], h[8:16])
+	binary.BigEndian.PutUint32(dst[16:20], length)
+}
+
+// structuredError returns an error chunk ending the reply.
+func (h *reqHeader) structuredError(err uint32) []byte {
+	buf := make([]byte, 20+4+2)
+	h.putStructuredHeader(buf, replyFlagDone, replyTypeError, 6)
+	binary.BigEndian.PutUint32(buf[20:24], err)
+	return buf
+}
+
 func (h *reqHeader) putReplyHeader(dst []byte, err uint32) {
 	binary.BigEndian.PutUint32(dst[0:4], magicReply)
 	binary.BigEndian.PutUint32(dst[4:8], err)
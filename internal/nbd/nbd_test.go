@@ -0,0 +1,245 @@
+package nbd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowDevice is a memDevice whose reads and writes at some offsets take a
+// while, and which logs what's done to it.
+type slowDevice struct {
+	*memDevice
+	delays map[int64]time.Duration
+
+	mu     sync.Mutex
+	log    []string
+	closes int
+}
+
+func newSlowDevice(size int, delays map[int64]time.Duration) *slowDevice {
+	return &slowDevice{memDevice: newMemDevice(size), delays: delays}
+}
+
+func (d *slowDevice) logf(format string, args ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, fmt.Sprintf(format, args...))
+}
+
+func (d *slowDevice) events() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.log...)
+}
+
+func (d *slowDevice) ReadAt(b []byte, off int64) (int, error) {
+	time.Sleep(d.delays[off])
+	return d.memDevice.ReadAt(b, off)
+}
+
+func (d *slowDevice) WriteAt(b []byte, off int64) (int, error) {
+	time.Sleep(d.delays[off])
+	n, err := d.memDevice.WriteAt(b, off)
+	d.logf("write %d", off)
+	return n, err
+}
+
+func (d *slowDevice) Sync() error {
+	d.logf("sync")
+	return nil
+}
+
+func (d *slowDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closes++
+	return nil
+}
+
+// serve runs serveLoop on dev over a pipe, returning the client's end and
+// what serveLoop returns.
+func serve(t *testing.T, dev Device) (*testClient, chan error) {
+	client, server := net.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- newServerConn(server).serveLoop(dev, nil)
+	}()
+	return &testClient{t: t, c: client}, errc
+}
+
+// request sends a request of cmd, with data if it's a write.
+func (tc *testClient) request(cmd uint16, handle uint64, off int64, length uint32, data []byte) {
+	var hdr reqHeader
+	binary.BigEndian.PutUint32(hdr[0:4], magicRequest)
+	binary.BigEndian.PutUint16(hdr[6:8], cmd)
+	binary.BigEndian.PutUint64(hdr[8:16], handle)
+	binary.BigEndian.PutUint64(hdr[16:24], uint64(off))
+	binary.BigEndian.PutUint32(hdr[24:28], length)
+	tc.write(hdr[:])
+	if data != nil {
+		tc.write(data)
+	}
+}
+
+// simpleReply reads a simple reply with n bytes of data, returning its
+// handle and error.
+func (tc *testClient) simpleReply(n int) (uint64, uint32, []byte) {
+	buf := make([]byte, 16+n)
+	if _, err := io.ReadFull(tc.c, buf[:16]); err != nil {
+		tc.t.Fatal(err)
+	}
+	if magic := binary.BigEndian.Uint32(buf[0:4]); magic != magicReply {
+		tc.t.Fatalf("reply magic 0x%x", magic)
+	}
+	errno := binary.BigEndian.Uint32(buf[4:8])
+	if errno == 0 {
+		if _, err := io.ReadFull(tc.c, buf[16:]); err != nil {
+			tc.t.Fatal(err)
+		}
+	}
+	return binary.BigEndian.Uint64(buf[8:16]), errno, buf[16:]
+}
+
+// exportName chooses the export with NBD_OPT_EXPORT_NAME, which ends the
+// handshake.
+func (tc *testClient) exportName(name string) {
+	tc.sendOpt(nbdOptExportName, []byte(name))
+	rep := make([]byte, 8+2)
+	if _, err := io.ReadFull(tc.c, rep); err != nil {
+		tc.t.Fatal(err)
+	}
+}
+
+func wait(t *testing.T, errc <-chan error) error {
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't return")
+		return nil
+	}
+}
+
+func TestOutOfOrderReplies(t *testing.T) {
+	dev := newSlowDevice(8192, map[int64]time.Duration{0: 200 * time.Millisecond})
+	copy(dev.data[0:512], bytes.Repeat([]byte{'a'}, 512))
+	copy(dev.data[4096:4608], bytes.Repeat([]byte{'b'}, 512))
+	tc, errc := serve(t, dev)
+
+	tc.request(cmdRead, 1, 0, 512, nil)
+	tc.request(cmdRead, 2, 4096, 512, nil)
+	// The second read overtakes the slow first one, and each reply carries
+	// the data of its own request.
+	for _, want := range []struct {
+		handle uint64
+		data   byte
+	}{{2, 'b'}, {1, 'a'}} {
+		handle, errno, data := tc.simpleReply(512)
+		if handle != want.handle || errno != 0 {
+			t.Fatalf("got reply to %d (error %d), want %d", handle, errno, want.handle)
+		}
+		if !bytes.Equal(data, bytes.Repeat([]byte{want.data}, 512)) {
+			t.Fatalf("reply to %d has the wrong data", handle)
+		}
+	}
+	tc.request(cmdDisc, 3, 0, 0, nil)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscWaitsForWrites(t *testing.T) {
+	dev := newSlowDevice(8192, map[int64]time.Duration{0: 200 * time.Millisecond})
+	tc, errc := serve(t, dev)
+
+	tc.request(cmdWrite, 1, 0, 512, bytes.Repeat([]byte{'a'}, 512))
+	tc.request(cmdDisc, 2, 0, 0, nil)
+	if handle, errno, _ := tc.simpleReply(0); handle != 1 || errno != 0 {
+		t.Fatalf("got reply to %d (error %d), want 1", handle, errno)
+	}
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	if log := fmt.Sprint(dev.events()); log != "[write 0 sync]" {
+		t.Fatalf("device saw %s, want the write, then a sync", log)
+	}
+	if dev.data[0] != 'a' {
+		t.Fatal("write wasn't done")
+	}
+}
+
+func TestBlockStatusUnnegotiated(t *testing.T) {
+	tc, errc := serve(t, newSlowDevice(8192, nil))
+
+	// The connection has no structured replies, so the refusal has to be
+	// a simple reply.
+	tc.request(cmdBlockStatus, 1, 0, 4096, nil)
+	if handle, errno, _ := tc.simpleReply(0); handle != 1 || errno != errInval {
+		t.Fatalf("got reply to %d (error %d), want 1 (error %d)", handle, errno, errInval)
+	}
+	tc.request(cmdDisc, 2, 0, 0, nil)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countingFinder finds a new slowDevice for each export, once.
+type countingFinder struct {
+	mu    sync.Mutex
+	finds int
+	devs  map[string]*slowDevice
+}
+
+func (f *countingFinder) FindDevice(name string) (Device, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finds++
+	d := newSlowDevice(8192, nil)
+	f.devs[name] = d
+	return d, nil
+}
+
+func (f *countingFinder) ListDevices() ([]string, error) { return nil, nil }
+
+func TestSharedDevice(t *testing.T) {
+	f := &countingFinder{devs: make(map[string]*slowDevice)}
+	s := newTestServer(f)
+	c1, c2 := dial(t, s), dial(t, s)
+	c1.exportName("vol")
+	c2.exportName("vol")
+
+	// A write through one connection is read back through the other.
+	c1.request(cmdWrite, 1, 0, 5, []byte("hello"))
+	if _, errno, _ := c1.simpleReply(0); errno != 0 {
+		t.Fatalf("write failed with %d", errno)
+	}
+	c2.request(cmdRead, 1, 0, 5, nil)
+	if _, _, data := c2.simpleReply(5); string(data) != "hello" {
+		t.Fatalf("read %q through the second connection", data)
+	}
+	if f.finds != 1 {
+		t.Fatalf("device was opened %d times", f.finds)
+	}
+
+	// It's closed once both are done with it.
+	dev := f.devs["vol"]
+	c1.request(cmdDisc, 2, 0, 0, nil)
+	<-c1.done
+	dev.mu.Lock()
+	closes := dev.closes
+	dev.mu.Unlock()
+	if closes != 0 {
+		t.Fatal("device closed while a connection still uses it")
+	}
+	c2.request(cmdDisc, 2, 0, 0, nil)
+	<-c2.done
+	if dev.closes != 1 {
+		t.Fatalf("device closed %d times", dev.closes)
+	}
+}
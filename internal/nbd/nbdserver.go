@@ -1,7 +1,9 @@
 package nbd
 
 import (
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -18,16 +20,42 @@ const (
 	nbdOpts  uint64 = 0x49484156454F5054
 
 	nbdFlagFixedNewStyle = 0x1
+	nbdFlagNoZeroes      = 0x2
 
 	// options
-	nbdOptExportName = 0x1
-	nbdOptAbort      = 0x2
-	nbdOptList       = 0x3
+	nbdOptExportName     = 0x1
+	nbdOptAbort          = 0x2
+	nbdOptList           = 0x3
+	nbdOptStartTLS       = 0x5
+	nbdOptInfo           = 0x6
+	nbdOptGo             = 0x7
+	nbdOptStructuredRepl = 0x8
+	nbdOptListMetaCtx    = 0x9
+	nbdOptSetMetaCtx     = 0xa
 
 	// option replies
-	nbdRepAck      = 0x1
-	nbdRepServer   = 0x2
-	nbdRepErrUnsup = 0x80000001
+	nbdRepAck        = 0x1
+	nbdRepServer     = 0x2
+	nbdRepInfo       = 0x3
+	nbdRepMetaCtx    = 0x4
+	nbdRepErrUnsup   = 0x80000001
+	nbdRepErrPolicy  = 0x80000002
+	nbdRepErrInvalid = 0x80000003
+	nbdRepErrTLSReqd = 0x80000005
+	nbdRepErrUnknown = 0x80000006
+
+	// information types of NBD_REP_INFO
+	nbdInfoExport    = 0
+	nbdInfoName      = 1
+	nbdInfoBlockSize = 3
+
+	// metaCtxAllocation is the only metadata context served, mapping holes
+	// for NBD_CMD_BLOCK_STATUS. metaCtxAllocationID identifies it in replies.
+	metaCtxAllocation   = "base:allocation"
+	metaCtxAllocationID = 1
+
+	// maxOptionLength bounds the data of options.
+	maxOptionLength = 4096
 
 	tcpKeepAlive = 10 * time.Second
 )
@@ -37,9 +65,27 @@ type DeviceFinder interface {
 	ListDevices() ([]string, error)
 }
 
+// An Authorizer reports whether the client of a TLS connection may use the
+// export.
+type Authorizer func(cs tls.ConnectionState, export string) bool
+
 type NBDServer struct {
 	l      *net.TCPListener
 	finder DeviceFinder
+
+	tlsConfig *tls.Config
+	authorize Authorizer
+
+	mut     sync.Mutex
+	devices map[string]*sharedDevice
+}
+
+// sharedDevice is a Device opened once for all the connections to its
+// export. As they all write through it, a flush on any of them covers the
+// writes of all of them, which makes multiple connections safe.
+type sharedDevice struct {
+	Device
+	refs int
 }
 
 func NewNBDServer(addr string, finder DeviceFinder) (*NBDServer, error) {
@@ -54,13 +100,30 @@ func NewNBDServer(addr string, finder DeviceFinder) (*NBDServer, error) {
 	}
 
 	ns := &NBDServer{
-		l:      ln,
-		finder: finder,
+		l:       ln,
+		finder:  finder,
+		devices: make(map[string]*sharedDevice),
 	}
 
 	return ns, nil
 }
 
+// EnableTLS makes the server require its clients to upgrade their
+// connections to TLS with NBD_OPT_STARTTLS before choosing an export. If
+// authorize isn't nil, only the exports it allows are listed and served.
+func (s *NBDServer) EnableTLS(cfg *tls.Config, authorize Authorizer) {
+	s.tlsConfig = cfg
+	s.authorize = authorize
+}
+
+// allowed reports whether the client of c may use the export.
+func (s *NBDServer) allowed(c *NBDConn, export string) bool {
+	if s.authorize == nil || c.tls == nil {
+		return true
+	}
+	return s.authorize(*c.tls, export)
+}
+
 func (s *NBDServer) Serve() error {
 	for {
 		c, err := s.l.AcceptTCP()
@@ -73,7 +136,7 @@ func (s *NBDServer) Serve() error {
 
 		conn := &NBDConn{
 			c:      c,
-			finder: s.finder,
+			server: s,
 			export: "<none>",
 		}
 
@@ -90,6 +153,33 @@ func (s *NBDServer) Close() error {
 	return s.l.Close()
 }
 
+func (s *NBDServer) openDevice(name string) (Device, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if d, ok := s.devices[name]; ok {
+		d.refs++
+		return d.Device, nil
+	}
+	dev, err := s.finder.FindDevice(name)
+	if err != nil {
+		return nil, err
+	}
+	s.devices[name] = &sharedDevice{Device: dev, refs: 1}
+	return dev, nil
+}
+
+func (s *NBDServer) closeDevice(name string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	d := s.devices[name]
+	d.refs--
+	if d.refs > 0 {
+		return d.Sync()
+	}
+	delete(s.devices, name)
+	return d.Close()
+}
+
 type option struct {
 	opt  uint32
 	data []byte
@@ -97,9 +187,30 @@ type option struct {
 
 type NBDConn struct {
 	c      net.Conn
-	finder DeviceFinder
+	server *NBDServer
 	device Device
 	export string
+
+	clientFlags uint32
+	// tls is the state of the connection once it's been upgraded to TLS.
+	tls *tls.ConnectionState
+	// structured is set once the client has negotiated structured replies,
+	// and allocCtx once it has selected the base:allocation context.
+	structured bool
+	allocCtx   bool
+}
+
+// errAbort ends the handshake when the client aborts it.
+var errAbort = errors.New("nbd: client aborted the handshake")
+
+// transmissionFlags are the flags of every export.
+const transmissionFlags = flagHasFlags | flagSendFlush | flagSendTrim | flagSendWriteZeroes | flagCanMulti
+
+func exportFlags(dev Device) uint16 {
+	if isReadOnly(dev) {
+		return transmissionFlags | flagReadOnly
+	}
+	return transmissionFlags
 }
 
 func (c *NBDConn) errorf(format string, stuff ...interface{}) {
@@ -113,8 +224,6 @@ func (c *NBDConn) tracef(format string, stuff ...interface{}) {
 }
 
 func (c *NBDConn) handler() error {
-	var clientFlags uint32
-
 	c.tracef("handshaking")
 
 	// initial handshake
@@ -126,60 +235,36 @@ func (c *NBDConn) handler() error {
 		return err
 	}
 
-	if err := binary.Write(c.c, binary.BigEndian, uint16(nbdFlagFixedNewStyle)); err != nil {
+	if err := binary.Write(c.c, binary.BigEndian, uint16(nbdFlagFixedNewStyle|nbdFlagNoZeroes)); err != nil {
 		return err
 	}
 
-	// these are read but ignore since none are used.
-	if err := binary.Read(c.c, binary.BigEndian, &clientFlags); err != nil {
+	if err := binary.Read(c.c, binary.BigEndian, &c.clientFlags); err != nil {
 		return err
 	}
 
 	c.tracef("reading options")
 
 	// get options
-	if err := c.options(); err != nil {
+	if err := c.options(); err == errAbort {
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("handshake failure: %v", err)
 	}
 
-	// send transmission flags
-	if err := binary.Write(c.c, binary.BigEndian, c.device.Size()); err != nil {
-		return err
-	}
-
-	if err := binary.Write(c.c, binary.BigEndian, uint16(flagHasFlags|flagSendFlush|flagSendTrim)); err != nil {
-		return err
-	}
-
-	// reserved zero pad
-	zpad := make([]byte, 124)
-	if err := writeFull(c.c, zpad); err != nil {
-		return err
-	}
-
 	c.tracef("serving")
 
-	srv := &serverConn{
-		rw: c.c,
+	srv := newServerConn(c.c)
+	srv.structured = c.structured
+	srv.allocCtx = c.allocCtx
+	if err := srv.serveLoop(c.device, nil); err != nil {
+		clog.Errorf("server returned: %s", err)
 	}
-
-	n := 1
-
-	wg := new(sync.WaitGroup)
-	wg.Add(n)
-	for i := 0; i < n; i++ {
-		go func() {
-			if err := srv.serveLoop(c.device, wg); err != nil {
-				clog.Errorf("server returned: %s", err)
-			}
-		}()
-	}
-
-	wg.Wait()
 	return nil
 }
 
-// do nbd option exchange and return once export name is given.
+// do nbd option exchange and return once an export is chosen, with
+// NBD_OPT_EXPORT_NAME or NBD_OPT_GO.
 func (c *NBDConn) options() error {
 	for {
 		opt, err := c.getOpt()
@@ -187,12 +272,29 @@ func (c *NBDConn) options() error {
 			return err
 		}
 
+		if c.server.tlsConfig != nil && c.tls == nil {
+			switch opt.opt {
+			case nbdOptStartTLS, nbdOptAbort:
+			case nbdOptExportName:
+				// there's no way to refuse it but hanging up
+				return fmt.Errorf("client chose export %q without starting TLS", opt.data)
+			default:
+				if err := c.optReply(opt.opt, nbdRepErrTLSReqd, nil); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
 		switch opt.opt {
 		case nbdOptExportName:
 			if len(opt.data) == 0 {
 				return fmt.Errorf("nbdserve doesn't support empty volume name. client needs to specify it")
 			}
-			dev, err := c.finder.FindDevice(string(opt.data))
+			if !c.server.allowed(c, string(opt.data)) {
+				return fmt.Errorf("client isn't allowed export %q", opt.data)
+			}
+			dev, err := c.server.openDevice(string(opt.data))
 			if err != nil {
 				// terminate the connection on failure
 				return err
@@ -201,21 +303,37 @@ func (c *NBDConn) options() error {
 			c.device = dev
 			c.export = string(opt.data)
 
-			// got dev, done with options.
-			return nil
+			// send transmission flags
+			rep := make([]byte, 8+2+124)
+			binary.BigEndian.PutUint64(rep[0:8], dev.Size())
+			binary.BigEndian.PutUint16(rep[8:10], exportFlags(dev))
+			if c.clientFlags&nbdFlagNoZeroes != 0 {
+				rep = rep[:10]
+			}
+			return writeFull(c.c, rep)
 		case nbdOptAbort:
-			if err := c.optReply(nbdOptList, nbdRepAck, nil); err != nil {
+			if err := c.optReply(nbdOptAbort, nbdRepAck, nil); err != nil {
 				return err
 			}
 
-			return nil
+			return errAbort
 		case nbdOptList:
-			devs, err := c.finder.ListDevices()
+			if len(opt.data) != 0 {
+				if err := c.optReply(opt.opt, nbdRepErrInvalid, nil); err != nil {
+					return err
+				}
+				continue
+			}
+
+			devs, err := c.server.finder.ListDevices()
 			if err != nil {
 				return err
 			}
 
 			for _, d := range devs {
+				if !c.server.allowed(c, d) {
+					continue
+				}
 				// a bit silly. prefix string with length..
 				buf := make([]byte, 4+len(d))
 				binary.BigEndian.PutUint32(buf[0:4], uint32(len(d)))
@@ -228,6 +346,29 @@ func (c *NBDConn) options() error {
 			if err := c.optReply(nbdOptList, nbdRepAck, nil); err != nil {
 				return err
 			}
+		case nbdOptStartTLS:
+			if err := c.startTLS(opt); err != nil {
+				return err
+			}
+		case nbdOptInfo, nbdOptGo:
+			done, err := c.info(opt)
+			if err != nil || done {
+				return err
+			}
+		case nbdOptStructuredRepl:
+			rep := uint32(nbdRepAck)
+			if len(opt.data) != 0 {
+				rep = nbdRepErrInvalid
+			} else {
+				c.structured = true
+			}
+			if err := c.optReply(opt.opt, rep, nil); err != nil {
+				return err
+			}
+		case nbdOptListMetaCtx, nbdOptSetMetaCtx:
+			if err := c.metaContext(opt); err != nil {
+				return err
+			}
 		default:
 			if err := c.optReply(opt.opt, nbdRepErrUnsup, nil); err != nil {
 				return err
@@ -236,6 +377,161 @@ func (c *NBDConn) options() error {
 	}
 }
 
+// info answers NBD_OPT_INFO and NBD_OPT_GO, returning true once the client
+// has gone to transmission.
+func (c *NBDConn) info(opt *option) (bool, error) {
+	d := opt.data
+	if len(d) < 4 {
+		return false, c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	}
+	// Lengths are compared as uint64, so that huge ones can't wrap around.
+	nameLen := uint64(binary.BigEndian.Uint32(d[0:4]))
+	if uint64(len(d)-4) < nameLen+2 {
+		return false, c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	}
+	name := string(d[4 : 4+nameLen])
+	d = d[4+nameLen:]
+	nreqs := int(binary.BigEndian.Uint16(d[0:2]))
+	d = d[2:]
+	if len(d) != 2*nreqs {
+		return false, c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	}
+	if name == "" {
+		return false, c.optReply(opt.opt, nbdRepErrUnknown, []byte("nbdserve has no default export, name a volume"))
+	}
+
+	if !c.server.allowed(c, name) {
+		return false, c.optReply(opt.opt, nbdRepErrPolicy, []byte("not allowed to use this export"))
+	}
+	dev, err := c.server.openDevice(name)
+	if err != nil {
+		return false, c.optReply(opt.opt, nbdRepErrUnknown, []byte(err.Error()))
+	}
+	if opt.opt == nbdOptInfo {
+		defer c.server.closeDevice(name)
+	}
+
+	info := make([]byte, 2+8+2)
+	binary.BigEndian.PutUint16(info[0:2], nbdInfoExport)
+	binary.BigEndian.PutUint64(info[2:10], dev.Size())
+	binary.BigEndian.PutUint16(info[10:12], exportFlags(dev))
+	if err := c.optReply(opt.opt, nbdRepInfo, info); err != nil {
+		return false, err
+	}
+	for i := 0; i < nreqs; i++ {
+		switch binary.BigEndian.Uint16(d[2*i : 2*i+2]) {
+		case nbdInfoName:
+			info = make([]byte, 2+len(name))
+			binary.BigEndian.PutUint16(info[0:2], nbdInfoName)
+			copy(info[2:], name)
+		case nbdInfoBlockSize:
+			preferred := uint32(4096)
+			if bs, ok := dev.(BlockSizer); ok {
+				preferred = bs.BlockSize()
+			}
+			info = make([]byte, 2+4+4+4)
+			binary.BigEndian.PutUint16(info[0:2], nbdInfoBlockSize)
+			binary.BigEndian.PutUint32(info[2:6], 1)
+			binary.BigEndian.PutUint32(info[6:10], preferred)
+			binary.BigEndian.PutUint32(info[10:14], maxRequestSize)
+		default:
+			continue
+		}
+		if err := c.optReply(opt.opt, nbdRepInfo, info); err != nil {
+			return false, err
+		}
+	}
+	if err := c.optReply(opt.opt, nbdRepAck, nil); err != nil {
+		return false, err
+	}
+	if opt.opt == nbdOptInfo {
+		return false, nil
+	}
+	c.device = dev
+	c.export = name
+	return true, nil
+}
+
+// startTLS answers NBD_OPT_STARTTLS, upgrading the connection to TLS.
+// Whatever was negotiated before is forgotten, as the spec requires.
+func (c *NBDConn) startTLS(opt *option) error {
+	switch {
+	case len(opt.data) != 0:
+		return c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	case c.server.tlsConfig == nil:
+		return c.optReply(opt.opt, nbdRepErrUnsup, []byte("nbdserve isn't configured for TLS"))
+	case c.tls != nil:
+		return c.optReply(opt.opt, nbdRepErrInvalid, []byte("TLS is already started"))
+	}
+	if err := c.optReply(opt.opt, nbdRepAck, nil); err != nil {
+		return err
+	}
+	tc := tls.Server(c.c, c.server.tlsConfig)
+	if err := tc.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake: %v", err)
+	}
+	cs := tc.ConnectionState()
+	c.c = tc
+	c.tls = &cs
+	c.structured = false
+	c.allocCtx = false
+	c.tracef("started TLS")
+	return nil
+}
+
+// metaContext answers NBD_OPT_LIST_META_CONTEXT and
+// NBD_OPT_SET_META_CONTEXT. Only base:allocation is served.
+func (c *NBDConn) metaContext(opt *option) error {
+	d := opt.data
+	if len(d) < 4 {
+		return c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	}
+	nameLen := uint64(binary.BigEndian.Uint32(d[0:4]))
+	if uint64(len(d)-4) < nameLen+4 {
+		return c.optReply(opt.opt, nbdRepErrInvalid, nil)
+	}
+	d = d[4+nameLen:]
+	nqueries := binary.BigEndian.Uint32(d[0:4])
+	d = d[4:]
+	var queries []string
+	for i := uint32(0); i < nqueries; i++ {
+		if len(d) < 4 {
+			return c.optReply(opt.opt, nbdRepErrInvalid, nil)
+		}
+		l := uint64(binary.BigEndian.Uint32(d[0:4]))
+		if uint64(len(d)-4) < l {
+			return c.optReply(opt.opt, nbdRepErrInvalid, nil)
+		}
+		queries = append(queries, string(d[4:4+l]))
+		d = d[4+l:]
+	}
+	if opt.opt == nbdOptSetMetaCtx && !c.structured {
+		return c.optReply(opt.opt, nbdRepErrInvalid, []byte("structured replies must be negotiated first"))
+	}
+
+	alloc := false
+	if opt.opt == nbdOptListMetaCtx && len(queries) == 0 {
+		alloc = true
+	}
+	for _, q := range queries {
+		if q == metaCtxAllocation || (opt.opt == nbdOptListMetaCtx && q == "base:") {
+			alloc = true
+		}
+	}
+	if opt.opt == nbdOptSetMetaCtx {
+		c.allocCtx = alloc
+	}
+	if alloc {
+		rep := make([]byte, 4+len(metaCtxAllocation))
+		binary.BigEndian.PutUint32(rep[0:4], metaCtxAllocationID)
+		copy(rep[4:], metaCtxAllocation)
+		if err := c.optReply(opt.opt, nbdRepMetaCtx, rep); err != nil {
+			return err
+		}
+	}
+	return c.optReply(opt.opt, nbdRepAck, nil)
+}
+
 func writeFull(w io.Writer, buf []byte) error {
 	n, err := w.Write(buf)
 	if err != nil {
@@ -286,34 +582,27 @@ func (c *NBDConn) getOpt() (*option, error) {
 		return nil, err
 	}
 
-	if optLen > 4096 {
+	if optLen > maxOptionLength {
 		return nil, fmt.Errorf("strange option length: %d", optLen)
 	}
 
-	switch opt {
-	case nbdOptExportName:
-		o := &option{
-			opt:  opt,
-			data: make([]byte, optLen),
-		}
+	o := &option{
+		opt:  opt,
+		data: make([]byte, optLen),
+	}
 
-		if optLen > 0 {
-			if _, err := io.ReadFull(c.c, o.data); err != nil {
-				return nil, err
-			}
+	if optLen > 0 {
+		if _, err := io.ReadFull(c.c, o.data); err != nil {
+			return nil, err
 		}
-
-		return o, nil
-	case nbdOptAbort, nbdOptList:
-		return &option{opt: opt}, nil
-	default:
-		return nil, fmt.Errorf("unknown option %032b", opt)
 	}
+
+	return o, nil
 }
 
 func (c *NBDConn) Close() error {
 	if c.device != nil {
-		c.device.Close()
+		c.server.closeDevice(c.export)
 	}
 	return c.c.Close()
 }
@@ -0,0 +1,400 @@
+package nbd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memDevice is a Device in memory.
+type memDevice struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func newMemDevice(size int) *memDevice {
+	return &memDevice{data: make([]byte, size)}
+}
+
+func (d *memDevice) ReadAt(b []byte, off int64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return copy(b, d.data[off:]), nil
+}
+
+func (d *memDevice) WriteAt(b []byte, off int64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return copy(d.data[off:], b), nil
+}
+
+func (d *memDevice) Trim(off, length int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.data[off : off+length] {
+		d.data[off+int64(i)] = 0
+	}
+	return nil
+}
+
+func (d *memDevice) Sync() error  { return nil }
+func (d *memDevice) Size() uint64 { return uint64(len(d.data)) }
+func (d *memDevice) Close() error { return nil }
+
+// memFinder finds the devices in a map.
+type memFinder map[string]Device
+
+func (f memFinder) FindDevice(name string) (Device, error) {
+	if d, ok := f[name]; ok {
+		return d, nil
+	}
+	return nil, errors.New("no such device")
+}
+
+func (f memFinder) ListDevices() ([]string, error) {
+	var names []string
+	for name := range f {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func newTestServer(finder DeviceFinder) *NBDServer {
+	return &NBDServer{
+		finder:  finder,
+		devices: make(map[string]*sharedDevice),
+	}
+}
+
+// testClient speaks the handshake to a connection of a server over a pipe.
+type testClient struct {
+	t *testing.T
+	c net.Conn
+	// done is closed once the server has closed the connection.
+	done chan struct{}
+}
+
+// dial connects to s and reads its greeting.
+func dial(t *testing.T, s *NBDServer) *testClient {
+	client, server := net.Pipe()
+	conn := &NBDConn{c: server, server: s, export: "<none>"}
+	tc := &testClient{t: t, c: client, done: make(chan struct{})}
+	go func() {
+		defer close(tc.done)
+		conn.handler()
+		conn.Close()
+	}()
+	greeting := make([]byte, 8+8+2)
+	if _, err := io.ReadFull(client, greeting); err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint64(greeting[0:8]) != nbdMagic || binary.BigEndian.Uint64(greeting[8:16]) != nbdOpts {
+		t.Fatalf("bad greeting %x", greeting)
+	}
+	tc.write(uint32(nbdFlagFixedNewStyle | nbdFlagNoZeroes))
+	return tc
+}
+
+func (tc *testClient) write(v interface{}) {
+	if err := binary.Write(tc.c, binary.BigEndian, v); err != nil {
+		tc.t.Fatal(err)
+	}
+}
+
+func (tc *testClient) sendOpt(opt uint32, data []byte) {
+	tc.write(nbdOpts)
+	tc.write(opt)
+	tc.write(uint32(len(data)))
+	// An empty write to a pipe waits for a reader, which the server may
+	// not be yet.
+	if len(data) > 0 {
+		tc.write(data)
+	}
+}
+
+// reply reads an option reply to opt, returning its type and data.
+func (tc *testClient) reply(opt uint32) (uint32, []byte) {
+	hdr := make([]byte, 8+4+4+4)
+	if _, err := io.ReadFull(tc.c, hdr); err != nil {
+		tc.t.Fatal(err)
+	}
+	if got := binary.BigEndian.Uint32(hdr[8:12]); got != opt {
+		tc.t.Fatalf("reply to option %d, want %d", got, opt)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(hdr[16:20]))
+	if _, err := io.ReadFull(tc.c, data); err != nil {
+		tc.t.Fatal(err)
+	}
+	return binary.BigEndian.Uint32(hdr[12:16]), data
+}
+
+// goData is the data of NBD_OPT_INFO or NBD_OPT_GO for the export name.
+func goData(name string) []byte {
+	d := make([]byte, 4+len(name)+2)
+	binary.BigEndian.PutUint32(d[0:4], uint32(len(name)))
+	copy(d[4:], name)
+	return d
+}
+
+func TestOptionLengthOverflow(t *testing.T) {
+	s := newTestServer(memFinder{"vol": newMemDevice(4096)})
+	tc := dial(t, s)
+	defer tc.c.Close()
+
+	for _, tt := range []struct {
+		opt  uint32
+		data []byte
+	}{
+		// name lengths which wrap around when the fields after them are
+		// added in uint32
+		{nbdOptGo, []byte{0xff, 0xff, 0xff, 0xfe, 0, 0}},
+		{nbdOptInfo, []byte{0xff, 0xff, 0xff, 0xff}},
+		{nbdOptListMetaCtx, []byte{0xff, 0xff, 0xff, 0xfc, 0, 0, 0, 0}},
+		// a query longer than the option
+		{nbdOptListMetaCtx, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}},
+		// a name longer than the option
+		{nbdOptGo, []byte{0, 0, 0, 8, 'v', 'o', 'l', 0, 0}},
+	} {
+		tc.sendOpt(tt.opt, tt.data)
+		if rep, _ := tc.reply(tt.opt); rep != nbdRepErrInvalid {
+			t.Errorf("option %d with data %x: reply %x, want NBD_REP_ERR_INVALID", tt.opt, tt.data, rep)
+		}
+	}
+
+	// The connection survives them.
+	tc.sendOpt(nbdOptInfo, goData("vol"))
+	if rep, _ := tc.reply(nbdOptInfo); rep != nbdRepInfo {
+		t.Fatalf("NBD_OPT_INFO after malformed options: reply %x", rep)
+	}
+	if rep, _ := tc.reply(nbdOptInfo); rep != nbdRepAck {
+		t.Fatalf("NBD_OPT_INFO after malformed options: reply %x", rep)
+	}
+}
+
+// testCA issues certificates for tests.
+type testCA struct {
+	t    *testing.T
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{t: t, cert: cert, key: key, pool: pool}
+}
+
+// issue returns a certificate for name, as both server and client.
+func (ca *testCA) issue(name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTLS upgrades the connection to TLS with cert.
+func (tc *testClient) startTLS(ca *testCA, cert tls.Certificate) {
+	tc.sendOpt(nbdOptStartTLS, nil)
+	if rep, _ := tc.reply(nbdOptStartTLS); rep != nbdRepAck {
+		tc.t.Fatalf("NBD_OPT_STARTTLS: reply %x", rep)
+	}
+	c := tls.Client(tc.c, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      ca.pool,
+		ServerName:   "nbd-server",
+	})
+	if err := c.Handshake(); err != nil {
+		tc.t.Fatal(err)
+	}
+	tc.c = c
+}
+
+// list returns the exports NBD_OPT_LIST lists.
+func (tc *testClient) list() []string {
+	tc.sendOpt(nbdOptList, nil)
+	var names []string
+	for {
+		rep, data := tc.reply(nbdOptList)
+		switch rep {
+		case nbdRepServer:
+			names = append(names, string(data[4:]))
+		case nbdRepAck:
+			sort.Strings(names)
+			return names
+		default:
+			tc.t.Fatalf("NBD_OPT_LIST: reply %x", rep)
+		}
+	}
+}
+
+// hungUp reports whether the server closes the connection soon. It reads
+// what's left, as closing a TLS connection writes an alert first.
+func (tc *testClient) hungUp() bool {
+	tc.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(ioutil.Discard, tc.c); err != nil {
+		return false
+	}
+	<-tc.done
+	return true
+}
+
+func newTLSServer(t *testing.T, finder DeviceFinder) (*NBDServer, *testCA) {
+	ca := newTestCA(t)
+	s := newTestServer(finder)
+	s.EnableTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue("nbd-server")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, func(cs tls.ConnectionState, export string) bool {
+		return cs.VerifiedChains[0][0].Subject.CommonName == "alice" && export == "vol"
+	})
+	return s, ca
+}
+
+func TestTLSRequired(t *testing.T) {
+	f := &countingFinder{devs: make(map[string]*slowDevice)}
+	s, _ := newTLSServer(t, f)
+
+	tc := dial(t, s)
+	defer tc.c.Close()
+	for _, opt := range []uint32{nbdOptList, nbdOptInfo, nbdOptGo} {
+		data := goData("vol")
+		if opt == nbdOptList {
+			data = nil
+		}
+		tc.sendOpt(opt, data)
+		if rep, _ := tc.reply(opt); rep != nbdRepErrTLSReqd {
+			t.Errorf("option %d before NBD_OPT_STARTTLS: reply %x, want NBD_REP_ERR_TLS_REQD", opt, rep)
+		}
+	}
+	// NBD_OPT_EXPORT_NAME can't be answered with an error, only refused by
+	// hanging up.
+	tc.sendOpt(nbdOptExportName, []byte("vol"))
+	if !tc.hungUp() {
+		t.Fatal("server served NBD_OPT_EXPORT_NAME without TLS")
+	}
+	if f.finds != 0 {
+		t.Fatal("device opened without TLS")
+	}
+}
+
+// openFinder records the devices opened from a memFinder.
+type openFinder struct {
+	memFinder
+	mu     sync.Mutex
+	opened []string
+}
+
+func (f *openFinder) FindDevice(name string) (Device, error) {
+	f.mu.Lock()
+	f.opened = append(f.opened, name)
+	f.mu.Unlock()
+	return f.memFinder.FindDevice(name)
+}
+
+func (f *openFinder) wasOpened(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range f.opened {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTLSAllowList(t *testing.T) {
+	f := &openFinder{memFinder: memFinder{"vol": newMemDevice(4096), "secret": newMemDevice(4096)}}
+	s, ca := newTLSServer(t, f)
+
+	tc := dial(t, s)
+	defer tc.c.Close()
+	tc.startTLS(ca, ca.issue("alice"))
+	if names := tc.list(); len(names) != 1 || names[0] != "vol" {
+		t.Fatalf("listed %v, want only the allowed export", names)
+	}
+	for _, opt := range []uint32{nbdOptInfo, nbdOptGo} {
+		tc.sendOpt(opt, goData("secret"))
+		if rep, _ := tc.reply(opt); rep != nbdRepErrPolicy {
+			t.Errorf("option %d for a disallowed export: reply %x, want NBD_REP_ERR_POLICY", opt, rep)
+		}
+	}
+	tc.sendOpt(nbdOptInfo, goData("vol"))
+	for {
+		rep, _ := tc.reply(nbdOptInfo)
+		if rep == nbdRepAck {
+			break
+		}
+		if rep != nbdRepInfo {
+			t.Fatalf("NBD_OPT_INFO for an allowed export: reply %x", rep)
+		}
+	}
+	tc.sendOpt(nbdOptExportName, []byte("secret"))
+	if !tc.hungUp() {
+		t.Fatal("server served a disallowed export")
+	}
+	if f.wasOpened("secret") {
+		t.Fatal("disallowed export was opened")
+	}
+
+	// A client allowed nothing sees nothing.
+	tc = dial(t, s)
+	defer tc.c.Close()
+	tc.startTLS(ca, ca.issue("mallory"))
+	if names := tc.list(); len(names) != 0 {
+		t.Fatalf("listed %v to a client allowed nothing", names)
+	}
+	tc.sendOpt(nbdOptGo, goData("vol"))
+	if rep, _ := tc.reply(nbdOptGo); rep != nbdRepErrPolicy {
+		t.Fatalf("NBD_OPT_GO from a client allowed nothing: reply %x", rep)
+	}
+}
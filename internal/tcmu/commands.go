@@ -1,8 +1,14 @@
 package torustcmu
 
 import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+
 	"github.com/coreos/go-tcmu"
 	"github.com/coreos/go-tcmu/scsi"
+
+	"github.com/coreos/torus/block/reservation"
 )
 
 func (h *torusHandler) handleSyncCommand(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
@@ -88,3 +94,293 @@ func (h *torusHandler) handleWrite(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error)
 	}
 	return cmd.Ok(), nil
 }
+
+const (
+	// maxUnmapGranules bounds an UNMAP, in volume blocks, so the kernel
+	// splits large discards on block boundaries.
+	maxUnmapGranules    = 1024
+	maxUnmapDescriptors = 64
+	maxWriteSameBlocks  = 1 << 18
+
+	ascLBAOutOfRange = 0x2100
+)
+
+// granularity is the number of logical blocks in a block of the volume,
+// which is the least that can be unmapped.
+func (h *torusHandler) granularity() uint32 {
+	g := uint32(h.volBlockSize / defaultBlockSize)
+	if g == 0 {
+		return 1
+	}
+	return g
+}
+
+// handleInquiry answers the VPD pages describing thin provisioning, leaving
+// the rest to go-tcmu.
+func (h *torusHandler) handleInquiry(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	if cmd.GetCDB(1)&0x01 == 0 {
+		return tcmu.EmulateInquiry(cmd, h.inq)
+	}
+	var data []byte
+	switch cmd.GetCDB(2) {
+	case 0x00: // supported pages
+		data = []byte{0, 0x00, 0, 4, 0x00, 0x83, 0xb0, 0xb2}
+	case 0xb0: // block limits
+		data = make([]byte, 64)
+		data[1] = 0xb0
+		data[3] = 0x3c
+		order := binary.BigEndian
+		order.PutUint32(data[20:24], maxUnmapGranules*h.granularity())
+		order.PutUint32(data[24:28], maxUnmapDescriptors)
+		order.PutUint32(data[28:32], h.granularity())
+		// UGAVALID, aligned to LBA 0
+		order.PutUint32(data[32:36], 0x80000000)
+		order.PutUint64(data[36:44], maxWriteSameBlocks)
+	case 0xb2: // logical block provisioning
+		data = make([]byte, 8)
+		data[1] = 0xb2
+		data[3] = 4
+		// UNMAP, WRITE SAME (16) and WRITE SAME (10) may unmap. Unmapped
+		// blocks don't read as zeroes, as partial volume blocks are kept.
+		data[5] = 0x80 | 0x40 | 0x20
+		data[6] = 0x02 // thin provisioned
+	default:
+		return tcmu.EmulateInquiry(cmd, h.inq)
+	}
+	if alloc := int(binary.BigEndian.Uint16([]byte{cmd.GetCDB(3), cmd.GetCDB(4)})); len(data) > alloc {
+		data = data[:alloc]
+	}
+	if _, err := cmd.Write(data); err != nil {
+		clog.Errorf("inquiry failed: %v", err)
+		return cmd.MediumError(), nil
+	}
+	return cmd.Ok(), nil
+}
+
+// handleReadCapacity16 answers READ CAPACITY (16) as go-tcmu does, but with
+// logical block provisioning enabled, without which the kernel won't send
+// discards.
+func (h *torusHandler) handleReadCapacity16(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	sizes := cmd.Device().Sizes()
+	data := make([]byte, 32)
+	binary.BigEndian.PutUint64(data[0:8], h.blocks(cmd)-1)
+	binary.BigEndian.PutUint32(data[8:12], uint32(sizes.BlockSize))
+	data[14] = 0x80 // LBPME
+	if alloc := int(cmd.XferLen()); len(data) > alloc {
+		data = data[:alloc]
+	}
+	if _, err := cmd.Write(data); err != nil {
+		clog.Errorf("read capacity failed: %v", err)
+		return cmd.MediumError(), nil
+	}
+	return cmd.Ok(), nil
+}
+
+// blocks returns the number of logical blocks in the volume.
+func (h *torusHandler) blocks(cmd *tcmu.SCSICmd) uint64 {
+	return uint64(atomic.LoadInt64(&h.size) / cmd.Device().Sizes().BlockSize)
+}
+
+// checkRange checks that n logical blocks from lba lie within a volume of
+// the given number of blocks.
+func checkRange(lba, n, blocks uint64) bool {
+	return lba+n >= lba && lba+n <= blocks
+}
+
+// unmapExtent is a range of logical blocks to unmap.
+type unmapExtent struct {
+	lba, n uint64
+}
+
+// parseUnmap decodes the block descriptors of the parameter list of an
+// UNMAP, merging adjacent ones so that a volume block they cover together
+// is trimmed even if neither covers it alone.
+func parseUnmap(params []byte) ([]unmapExtent, bool) {
+	if len(params) < 8 {
+		return nil, len(params) == 0
+	}
+	descs := params[8:]
+	if n := int(binary.BigEndian.Uint16(params[2:4])); n < len(descs) {
+		descs = descs[:n]
+	}
+	if len(descs)/16 > maxUnmapDescriptors {
+		return nil, false
+	}
+	var exts []unmapExtent
+	for ; len(descs) >= 16; descs = descs[16:] {
+		e := unmapExtent{
+			lba: binary.BigEndian.Uint64(descs[0:8]),
+			n:   uint64(binary.BigEndian.Uint32(descs[8:12])),
+		}
+		if e.n == 0 {
+			continue
+		}
+		if l := len(exts); l > 0 && exts[l-1].lba+exts[l-1].n == e.lba {
+			exts[l-1].n += e.n
+			continue
+		}
+		exts = append(exts, e)
+	}
+	return exts, true
+}
+
+// handleUnmap trims the volume blocks wholly within the ranges of an UNMAP.
+// The partial blocks at their edges are left as they are, which the
+// initiator has been told to expect.
+func (h *torusHandler) handleUnmap(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	params := make([]byte, cmd.XferLen())
+	if n, err := cmd.Read(params); err != nil || n < len(params) {
+		clog.Errorf("unmap failed: couldn't read parameters: %v", err)
+		return cmd.MediumError(), nil
+	}
+	exts, ok := parseUnmap(params)
+	if !ok {
+		return cmd.CheckCondition(scsi.SenseIllegalRequest, scsi.AscInvalidFieldInParameterList), nil
+	}
+	bs := uint64(cmd.Device().Sizes().BlockSize)
+	blocks := h.blocks(cmd)
+	var total uint64
+	for _, e := range exts {
+		if !checkRange(e.lba, e.n, blocks) {
+			return cmd.CheckCondition(scsi.SenseIllegalRequest, ascLBAOutOfRange), nil
+		}
+		total += e.n
+	}
+	if total > uint64(maxUnmapGranules*h.granularity()) {
+		return cmd.CheckCondition(scsi.SenseIllegalRequest, scsi.AscInvalidFieldInParameterList), nil
+	}
+	for _, e := range exts {
+		clog.Debugf("unmapping %d blocks at %d", e.n, e.lba)
+		if err := h.file.Trim(int64(e.lba*bs), int64(e.n*bs)); err != nil {
+			clog.Errorf("unmap failed: %v", err)
+			return cmd.MediumError(), nil
+		}
+	}
+	return cmd.Ok(), nil
+}
+
+// handleWriteSame writes one logical block over a range. A block of zeroes
+// is written with File.Zero, which leaves holes in the whole volume blocks
+// of the range, as if they'd been unmapped.
+func (h *torusHandler) handleWriteSame(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	lba, n := cmd.LBA(), uint64(cmd.XferLen())
+	if n == 0 || n > maxWriteSameBlocks {
+		return cmd.IllegalRequest(), nil
+	}
+	if !checkRange(lba, n, h.blocks(cmd)) {
+		return cmd.CheckCondition(scsi.SenseIllegalRequest, ascLBAOutOfRange), nil
+	}
+	bs := int(cmd.Device().Sizes().BlockSize)
+	block := make([]byte, bs)
+	// WRITE SAME (16) with NDOB has no data; the block is zeroes.
+	if !(cmd.Command() == scsi.WriteSame16 && cmd.GetCDB(1)&0x01 != 0) {
+		if k, err := cmd.Read(block); err != nil || k < bs {
+			clog.Errorf("write same failed: couldn't read the block: %v", err)
+			return cmd.MediumError(), nil
+		}
+	}
+	off, length := int64(lba)*int64(bs), int64(n)*int64(bs)
+	if isZero(block) {
+		clog.Debugf("zeroing %d blocks at %d", n, lba)
+		if err := h.file.Zero(off, length); err != nil {
+			clog.Errorf("write same failed: %v", err)
+			return cmd.MediumError(), nil
+		}
+		return cmd.Ok(), nil
+	}
+	buf := bytes.Repeat(block, 256)
+	for length > 0 {
+		if length < int64(len(buf)) {
+			buf = buf[:length]
+		}
+		if _, err := h.file.WriteAt(buf, off); err != nil {
+			clog.Errorf("write same failed: %v", err)
+			return cmd.MediumError(), nil
+		}
+		off += int64(len(buf))
+		length -= int64(len(buf))
+	}
+	return cmd.Ok(), nil
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mediaAccess returns whether a command reads or writes the volume, and so
+// may conflict with a persistent reservation, and whether it writes.
+func mediaAccess(op byte) (access, write bool) {
+	switch op {
+	case scsi.Read6, scsi.Read10, scsi.Read12, scsi.Read16:
+		return true, false
+	case scsi.Write6, scsi.Write10, scsi.Write12, scsi.Write16,
+		scsi.WriteSame, scsi.WriteSame16, scsi.Unmap,
+		scsi.SynchronizeCache, scsi.SynchronizeCache16:
+		return true, true
+	}
+	return false, false
+}
+
+func reservationResponse(cmd *tcmu.SCSICmd, err error) tcmu.SCSIResponse {
+	switch err := err.(type) {
+	case reservation.IllegalRequest:
+		return cmd.CheckCondition(scsi.SenseIllegalRequest, uint16(err))
+	}
+	switch err {
+	case reservation.ErrConflict:
+		return cmd.RespondStatus(scsi.SamStatReservationConflict)
+	case reservation.ErrNotReady:
+		// LOGICAL UNIT NOT READY, CAUSE NOT REPORTABLE
+		return cmd.CheckCondition(scsi.SenseNotReady, 0x0400)
+	}
+	clog.Errorf("persistent reservation failed: %v", err)
+	return cmd.CheckCondition(scsi.SenseHardwareError, 0)
+}
+
+func (h *torusHandler) handlePersistentReserveIn(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	cdb := make([]byte, 10)
+	for i := range cdb {
+		cdb[i] = cmd.GetCDB(i)
+	}
+	data, err := h.pr.In(cdb)
+	if err != nil {
+		return reservationResponse(cmd, err), nil
+	}
+	if alloc := int(binary.BigEndian.Uint16(cdb[7:9])); len(data) > alloc {
+		data = data[:alloc]
+	}
+	if _, err = cmd.Write(data); err != nil {
+		clog.Errorf("persistent reserve in failed: %v", err)
+		return cmd.MediumError(), nil
+	}
+	return cmd.Ok(), nil
+}
+
+// maxReserveOutParams bounds the parameter list of a PERSISTENT RESERVE OUT,
+// which is 24 bytes for every service action supported.
+const maxReserveOutParams = 4096
+
+func (h *torusHandler) handlePersistentReserveOut(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	cdb := make([]byte, 10)
+	for i := range cdb {
+		cdb[i] = cmd.GetCDB(i)
+	}
+	n := binary.BigEndian.Uint32(cdb[5:9])
+	if n > maxReserveOutParams {
+		return reservationResponse(cmd, reservation.ParameterListLengthError), nil
+	}
+	params := make([]byte, n)
+	if k, err := cmd.Read(params); err != nil || k < len(params) {
+		clog.Errorf("persistent reserve out failed: couldn't read parameters: %v", err)
+		return cmd.MediumError(), nil
+	}
+	if err := h.pr.Out(cdb, params); err != nil {
+		return reservationResponse(cmd, err), nil
+	}
+	return cmd.Ok(), nil
+}
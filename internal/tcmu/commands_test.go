@@ -0,0 +1,143 @@
+package torustcmu
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// unmapParams builds the parameter list of an UNMAP of the extents.
+func unmapParams(exts ...unmapExtent) []byte {
+	params := make([]byte, 8+16*len(exts))
+	binary.BigEndian.PutUint16(params[0:2], uint16(6+16*len(exts)))
+	binary.BigEndian.PutUint16(params[2:4], uint16(16*len(exts)))
+	for i, e := range exts {
+		d := params[8+16*i:]
+		binary.BigEndian.PutUint64(d[0:8], e.lba)
+		binary.BigEndian.PutUint32(d[8:12], uint32(e.n))
+	}
+	return params
+}
+
+// withDescLen sets the block descriptor data length of params.
+func withDescLen(params []byte, n int) []byte {
+	binary.BigEndian.PutUint16(params[2:4], uint16(n))
+	return params
+}
+
+func manyExtents(n int) []unmapExtent {
+	exts := make([]unmapExtent, n)
+	for i := range exts {
+		exts[i] = unmapExtent{lba: uint64(2 * i), n: 1}
+	}
+	return exts
+}
+
+func TestParseUnmap(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		params []byte
+		want   []unmapExtent
+		ok     bool
+	}{
+		{"no parameters", nil, nil, true},
+		{"short header", make([]byte, 4), nil, false},
+		{"no descriptors", unmapParams(), nil, true},
+		{
+			"one",
+			unmapParams(unmapExtent{8, 4}),
+			[]unmapExtent{{8, 4}}, true,
+		},
+		{
+			"apart",
+			unmapParams(unmapExtent{0, 4}, unmapExtent{8, 4}),
+			[]unmapExtent{{0, 4}, {8, 4}}, true,
+		},
+		{
+			"adjacent",
+			unmapParams(unmapExtent{0, 4}, unmapExtent{4, 4}, unmapExtent{8, 2}),
+			[]unmapExtent{{0, 10}}, true,
+		},
+		{
+			"adjacent out of order",
+			unmapParams(unmapExtent{4, 4}, unmapExtent{0, 4}),
+			[]unmapExtent{{4, 4}, {0, 4}}, true,
+		},
+		{
+			"adjacent then apart",
+			unmapParams(unmapExtent{0, 4}, unmapExtent{4, 4}, unmapExtent{16, 4}, unmapExtent{20, 1}),
+			[]unmapExtent{{0, 8}, {16, 5}}, true,
+		},
+		{
+			"empty",
+			unmapParams(unmapExtent{8, 0}),
+			nil, true,
+		},
+		{
+			"empty between adjacent",
+			unmapParams(unmapExtent{0, 4}, unmapExtent{4, 0}, unmapExtent{4, 4}),
+			[]unmapExtent{{0, 8}}, true,
+		},
+		{
+			"largest",
+			unmapParams(unmapExtent{math.MaxUint64 - math.MaxUint32, math.MaxUint32}),
+			[]unmapExtent{{math.MaxUint64 - math.MaxUint32, math.MaxUint32}}, true,
+		},
+		{
+			"most descriptors",
+			unmapParams(manyExtents(maxUnmapDescriptors)...),
+			manyExtents(maxUnmapDescriptors), true,
+		},
+		{
+			"too many descriptors",
+			unmapParams(manyExtents(maxUnmapDescriptors + 1)...),
+			nil, false,
+		},
+		{
+			"too many descriptors sent, few counted",
+			withDescLen(unmapParams(manyExtents(maxUnmapDescriptors+1)...), 16*2),
+			manyExtents(2), true,
+		},
+		{
+			"descriptors counted past the data",
+			withDescLen(unmapParams(unmapExtent{0, 4}), 16*2),
+			[]unmapExtent{{0, 4}}, true,
+		},
+		{
+			"partial descriptor",
+			withDescLen(append(unmapParams(unmapExtent{0, 4}), make([]byte, 8)...), 16+8),
+			[]unmapExtent{{0, 4}}, true,
+		},
+	} {
+		got, ok := parseUnmap(tt.params)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckRange(t *testing.T) {
+	for _, tt := range []struct {
+		lba, n, blocks uint64
+		want           bool
+	}{
+		{0, 8, 8, true},
+		{0, 0, 8, true},
+		{8, 0, 8, true},
+		{7, 1, 8, true},
+		{7, 2, 8, false},
+		{9, 0, 8, false},
+		{0, 9, 8, false},
+		// lba+n wraps around to within the volume.
+		{math.MaxUint64, 1, 8, false},
+		{math.MaxUint64 - 1, 4, 8, false},
+		{1, math.MaxUint64, 8, false},
+		{math.MaxUint64, 1, math.MaxUint64, false},
+		{math.MaxUint64 - 1, 1, math.MaxUint64, true},
+	} {
+		if got := checkRange(tt.lba, tt.n, tt.blocks); got != tt.want {
+			t.Errorf("checkRange(%d, %d, %d) = %v, want %v", tt.lba, tt.n, tt.blocks, got, tt.want)
+		}
+	}
+}
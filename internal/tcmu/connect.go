@@ -2,11 +2,13 @@ package torustcmu
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/coreos/go-tcmu"
 	"github.com/coreos/go-tcmu/scsi"
 	"github.com/coreos/pkg/capnslog"
 	"github.com/coreos/torus/block"
+	"github.com/coreos/torus/block/reservation"
 )
 
 const (
@@ -16,7 +18,12 @@ const (
 
 var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "tcmu")
 
-func ConnectAndServe(f *block.BlockFile, name string, closer chan bool) error {
+// ConnectAndServe serves the file as a SCSI disk through TCMU until closer
+// is closed. volBlockSize is the block size of its volume, which is the
+// granularity of unmapping. pr is the nexus through which this host takes
+// part in the volume's persistent reservations; if it's nil, they aren't
+// supported.
+func ConnectAndServe(f *block.BlockFile, name string, volBlockSize uint64, pr *reservation.Nexus, closer chan bool) error {
 	wwn := tcmu.NaaWWN{
 		// TODO(barakmich): CoreOS OUI here
 		OUI:      "000000",
@@ -32,17 +39,27 @@ func ConnectAndServe(f *block.BlockFile, name string, closer chan bool) error {
 			VolumeSize: int64(f.Size()),
 			BlockSize:  defaultBlockSize,
 		},
-		DevReady: tcmu.MultiThreadedDevReady(
-			&torusHandler{
-				file: f,
-				name: name,
-				inq: &tcmu.InquiryInfo{
-					VendorID:   "CoreOS",
-					ProductID:  "TorusBlk",
-					ProductRev: "0001",
-				},
-			}, 2),
 	}
+	th := &torusHandler{
+		file:         f,
+		name:         name,
+		volBlockSize: volBlockSize,
+		size:         int64(f.Size()),
+		pr:           pr,
+		inq: &tcmu.InquiryInfo{
+			VendorID:   "CoreOS",
+			ProductID:  "TorusBlk",
+			ProductRev: "0001",
+		},
+	}
+	h.DevReady = tcmu.MultiThreadedDevReady(th, 2)
+	// READ CAPACITY is answered from th.size, so the initiator sees a new
+	// size the next time it rescans the device. The kernel's dev_size,
+	// which go-tcmu only sets when it opens the device, keeps the size the
+	// volume was attached with until it's attached again.
+	f.OnResize(func(size uint64) {
+		atomic.StoreInt64(&th.size, int64(size))
+	})
 	d, err := tcmu.OpenTCMUDevice(devPath, h)
 	if err != nil {
 		return err
@@ -54,18 +71,31 @@ func ConnectAndServe(f *block.BlockFile, name string, closer chan bool) error {
 }
 
 type torusHandler struct {
-	file *block.BlockFile
-	name string
+	file         *block.BlockFile
+	name         string
+	volBlockSize uint64
+	// size is the size of the volume, which changes as it's resized. It's
+	// accessed atomically.
+	size int64
+	pr   *reservation.Nexus
 	inq  *tcmu.InquiryInfo
 }
 
 func (h *torusHandler) HandleCommand(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, error) {
+	if access, write := mediaAccess(cmd.Command()); access && h.pr != nil {
+		if err := h.pr.Check(write); err != nil {
+			return reservationResponse(cmd, err), nil
+		}
+	}
 	switch cmd.Command() {
 	case scsi.Inquiry:
-		return tcmu.EmulateInquiry(cmd, h.inq)
+		return h.handleInquiry(cmd)
 	case scsi.TestUnitReady:
 		return tcmu.EmulateTestUnitReady(cmd)
 	case scsi.ServiceActionIn16:
+		if cmd.GetCDB(1)&0x1f == scsi.SaiReadCapacity16 {
+			return h.handleReadCapacity16(cmd)
+		}
 		return tcmu.EmulateServiceActionIn(cmd)
 	case scsi.ModeSense, scsi.ModeSense10:
 		return tcmu.EmulateModeSense(cmd, true)
@@ -77,8 +107,20 @@ func (h *torusHandler) HandleCommand(cmd *tcmu.SCSICmd) (tcmu.SCSIResponse, erro
 		return h.handleWrite(cmd)
 	case scsi.SynchronizeCache, scsi.SynchronizeCache16:
 		return h.handleSyncCommand(cmd)
+	case scsi.Unmap:
+		return h.handleUnmap(cmd)
+	case scsi.WriteSame, scsi.WriteSame16:
+		return h.handleWriteSame(cmd)
 	case scsi.MaintenanceIn:
 		return h.handleReportDeviceID(cmd)
+	case scsi.PersistentReserveIn:
+		if h.pr != nil {
+			return h.handlePersistentReserveIn(cmd)
+		}
+	case scsi.PersistentReserveOut:
+		if h.pr != nil {
+			return h.handlePersistentReserveOut(cmd)
+		}
 	default:
 		clog.Debugf("Ignore unknown SCSI command 0x%x\n", cmd.Command())
 	}
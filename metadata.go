@@ -17,6 +17,8 @@ type MetadataKind int
 const (
 	EtcdMetadata MetadataKind = iota
 	TempMetadata
+	LocalMetadata
+	RaftMetadata
 )
 
 // MetadataService is the interface representing the basic ways to manipulate
@@ -46,6 +46,8 @@ func init() {
 	torus.RegisterMetadataInit("etcd", initEtcdMetadata)
 	torus.RegisterMetadataWipe("etcd", wipeEtcdMetadata)
 	torus.RegisterSetRing("etcd", setRing)
+	torus.RegisterMetadataExport("etcd", exportEtcdMetadata)
+	torus.RegisterMetadataImport("etcd", importEtcdMetadata)
 
 	prometheus.MustRegister(promAtomicRetries)
 	prometheus.MustRegister(promOps)
@@ -58,6 +60,7 @@ type etcdCtx struct {
 
 type Etcd struct {
 	etcdCtx
+	Namespace
 	mut          sync.RWMutex
 	cfg          torus.Config
 	global       torus.GlobalMetadata
@@ -71,8 +74,11 @@ type Etcd struct {
 }
 
 func newEtcdMetadata(cfg torus.Config) (torus.MetadataService, error) {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var uuid string
-	var err error
 	if cfg.DataDir == "" {
 		uuid = metadata.MakeUUID()
 	} else {
@@ -89,6 +95,7 @@ func newEtcdMetadata(cfg torus.Config) (torus.MetadataService, error) {
 	}
 
 	e := &Etcd{
+		Namespace:    ns,
 		cfg:          cfg,
 		Client:       client,
 		volumesCache: make(map[string]*models.Volume),
@@ -122,9 +129,9 @@ func (e *Etcd) Close() error {
 func (e *Etcd) getGlobalMetadata() error {
 	txn := e.Client.Txn(context.Background())
 	resp, err := txn.If(
-		etcdv3.Compare(etcdv3.Version(MkKey("meta", "globalmetadata")), ">", 0),
+		etcdv3.Compare(etcdv3.Version(e.MkKey("meta", "globalmetadata")), ">", 0),
 	).Then(
-		etcdv3.OpGet(MkKey("meta", "globalmetadata")),
+		etcdv3.OpGet(e.MkKey("meta", "globalmetadata")),
 	).Commit()
 	if err != nil {
 		return err
@@ -203,13 +210,13 @@ func (c *etcdCtx) RegisterPeer(lease int64, p *models.PeerInfo) error {
 
 	lid := etcdv3.LeaseID(lease)
 	_, err = c.etcd.Client.Put(
-		c.getContext(), MkKey("nodes", p.UUID), string(data), etcdv3.WithLease(lid))
+		c.getContext(), c.etcd.MkKey("nodes", p.UUID), string(data), etcdv3.WithLease(lid))
 	return err
 }
 
 func (c *etcdCtx) GetPeers() (torus.PeerInfoList, error) {
 	promOps.WithLabelValues("get-peers").Inc()
-	resp, err := c.etcd.Client.Get(c.getContext(), MkKey("nodes"), etcdv3.WithPrefix())
+	resp, err := c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("nodes"), etcdv3.WithPrefix())
 	if err != nil {
 		return nil, err
 	}
@@ -290,8 +297,8 @@ func BytesAddOne(in []byte) ([]byte, interface{}, error) {
 func (c *etcdCtx) GetVolumes() ([]*models.Volume, torus.VolumeID, error) {
 	promOps.WithLabelValues("get-volumes").Inc()
 	txn := c.etcd.Client.Txn(c.getContext()).Then(
-		etcdv3.OpGet(MkKey("meta", "volumeminter")),
-		etcdv3.OpGet(MkKey("volumeid"), etcdv3.WithPrefix()),
+		etcdv3.OpGet(c.etcd.MkKey("meta", "volumeminter")),
+		etcdv3.OpGet(c.etcd.MkKey("volumeid"), etcdv3.WithPrefix()),
 	)
 	resp, err := txn.Commit()
 	if err != nil {
@@ -317,7 +324,7 @@ func (c *etcdCtx) GetVolume(volume string) (*models.Volume, error) {
 	}
 	c.etcd.mut.Lock()
 	defer c.etcd.mut.Unlock()
-	resp, err := c.etcd.Client.Get(c.getContext(), MkKey("volumes", volume))
+	resp, err := c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("volumes", volume))
 	if err != nil {
 		return nil, err
 	}
@@ -326,7 +333,7 @@ func (c *etcdCtx) GetVolume(volume string) (*models.Volume, error) {
 
 	}
 	vid := BytesToUint64(resp.Kvs[0].Value)
-	resp, err = c.etcd.Client.Get(c.getContext(), MkKey("volumeid", Uint64ToHex(vid)))
+	resp, err = c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("volumeid", Uint64ToHex(vid)))
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +351,7 @@ func (c *etcdCtx) GetVolume(volume string) (*models.Volume, error) {
 }
 
 func (c *etcdCtx) GetLockStatus(vid uint64) string {
-	resp, err := c.etcd.Client.Get(c.getContext(), MkKey("volumemeta", Uint64ToHex(uint64(vid)), "blocklock"))
+	resp, err := c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("volumemeta", Uint64ToHex(uint64(vid)), "blocklock"))
 	if err != nil {
 		clog.Debugf("Failed to get lock status: %v", err)
 		return "unknown"
@@ -382,7 +389,7 @@ func (c *etcdCtx) GetRing() (torus.Ring, error) {
 }
 func (c *etcdCtx) getRing() (torus.Ring, int64, error) {
 	promOps.WithLabelValues("get-ring").Inc()
-	resp, err := c.etcd.Client.Get(c.getContext(), MkKey("meta", "the-one-ring"))
+	resp, err := c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("meta", "the-one-ring"))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -416,7 +423,7 @@ func (c *etcdCtx) SetRing(ring torus.Ring) error {
 	if err != nil {
 		return err
 	}
-	key := MkKey("meta", "the-one-ring")
+	key := c.etcd.MkKey("meta", "the-one-ring")
 	txn := c.etcd.Client.Txn(c.getContext()).If(
 		etcdv3.Compare(etcdv3.Version(key), "=", etcdver),
 	).Then(
@@ -436,7 +443,7 @@ func (c *etcdCtx) CommitINodeIndex(vid torus.VolumeID) (torus.INodeID, error) {
 	promOps.WithLabelValues("commit-inode-index").Inc()
 	c.etcd.mut.Lock()
 	defer c.etcd.mut.Unlock()
-	k := []byte(MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"))
+	k := []byte(c.etcd.MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"))
 	newID, err := c.AtomicModifyKey(k, BytesAddOne)
 	if err != nil {
 		return 0, err
@@ -447,7 +454,7 @@ func (c *etcdCtx) CommitINodeIndex(vid torus.VolumeID) (torus.INodeID, error) {
 func (c *etcdCtx) NewVolumeID() (torus.VolumeID, error) {
 	c.etcd.mut.Lock()
 	defer c.etcd.mut.Unlock()
-	k := []byte(MkKey("meta", "volumeminter"))
+	k := []byte(c.etcd.MkKey("meta", "volumeminter"))
 	newID, err := c.AtomicModifyKey(k, BytesAddOne)
 	if err != nil {
 		return 0, err
@@ -459,7 +466,7 @@ func (c *etcdCtx) GetINodeIndex(vid torus.VolumeID) (torus.INodeID, error) {
 	promOps.WithLabelValues("get-inode-index").Inc()
 	c.etcd.mut.Lock()
 	defer c.etcd.mut.Unlock()
-	resp, err := c.etcd.Client.Get(c.getContext(), MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"))
+	resp, err := c.etcd.Client.Get(c.getContext(), c.etcd.MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"))
 	if err != nil {
 		return torus.INodeID(0), err
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata"
 	"github.com/coreos/torus/models"
 	"github.com/coreos/torus/ring"
 
@@ -12,6 +13,10 @@ import (
 )
 
 func initEtcdMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus.RingType) error {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return err
+	}
 	gmdbytes, err := json.Marshal(gmd)
 	if err != nil {
 		return err
@@ -37,10 +42,10 @@ func initEtcdMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus
 
 	txn := client.Txn(context.Background())
 	resp, err := txn.If(
-		etcdv3.Compare(etcdv3.Version(MkKey("meta", "globalmetadata")), "=", 0),
+		etcdv3.Compare(etcdv3.Version(ns.MkKey("meta", "globalmetadata")), "=", 0),
 	).Then(
-		etcdv3.OpPut(MkKey("meta", "volumeminter"), string(Uint64ToBytes(1))),
-		etcdv3.OpPut(MkKey("meta", "globalmetadata"), string(gmdbytes)),
+		etcdv3.OpPut(ns.MkKey("meta", "volumeminter"), string(Uint64ToBytes(1))),
+		etcdv3.OpPut(ns.MkKey("meta", "globalmetadata"), string(gmdbytes)),
 	).Commit()
 	if err != nil {
 		return err
@@ -48,7 +53,7 @@ func initEtcdMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus
 	if !resp.Succeeded {
 		return torus.ErrExists
 	}
-	_, err = client.Put(context.Background(), MkKey("meta", "the-one-ring"), string(ringb))
+	_, err = client.Put(context.Background(), ns.MkKey("meta", "the-one-ring"), string(ringb))
 	if err != nil {
 		return err
 	}
@@ -56,12 +61,16 @@ func initEtcdMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus
 }
 
 func wipeEtcdMetadata(cfg torus.Config) error {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return err
+	}
 	client, err := etcdv3.New(etcdv3.Config{Endpoints: []string{cfg.MetadataAddress}, TLS: cfg.TLS})
 	if err != nil {
 		return err
 	}
 	defer client.Close()
-	_, err = client.Delete(context.Background(), MkKey(), etcdv3.WithPrefix())
+	_, err = client.Delete(context.Background(), string(ns), etcdv3.WithPrefix())
 	if err != nil {
 		return err
 	}
@@ -69,13 +78,17 @@ func wipeEtcdMetadata(cfg torus.Config) error {
 }
 
 func setRing(cfg torus.Config, r torus.Ring) error {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return err
+	}
 	client, err := etcdv3.New(etcdv3.Config{Endpoints: []string{cfg.MetadataAddress}, TLS: cfg.TLS})
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	resp, err := client.Get(context.Background(), MkKey("meta", "the-one-ring"))
+	resp, err := client.Get(context.Background(), ns.MkKey("meta", "the-one-ring"))
 	if err != nil {
 		return err
 	}
@@ -93,6 +106,59 @@ func setRing(cfg torus.Config, r torus.Ring) error {
 	if err != nil {
 		return err
 	}
-	_, err = client.Put(context.Background(), MkKey("meta", "the-one-ring"), string(b))
+	_, err = client.Put(context.Background(), ns.MkKey("meta", "the-one-ring"), string(b))
 	return err
 }
+
+func exportEtcdMetadata(cfg torus.Config) (*torus.MetadataExport, error) {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := etcdv3.New(etcdv3.Config{Endpoints: []string{cfg.MetadataAddress}, TLS: cfg.TLS})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	resp, err := client.Get(context.Background(), string(ns), etcdv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte)
+	for _, kv := range resp.Kvs {
+		kvs[string(kv.Key)] = kv.Value
+	}
+	return metadata.ExportKeys(string(ns), kvs)
+}
+
+func importEtcdMetadata(cfg torus.Config, exp *torus.MetadataExport) error {
+	ns, err := configNamespace(cfg)
+	if err != nil {
+		return err
+	}
+	kvs, err := metadata.ImportKeys(string(ns), exp)
+	if err != nil {
+		return err
+	}
+	err = initEtcdMetadata(cfg, exp.GlobalMetadata, torus.RingType(exp.Ring.Type))
+	if err != nil {
+		return err
+	}
+
+	client, err := etcdv3.New(etcdv3.Config{Endpoints: []string{cfg.MetadataAddress}, TLS: cfg.TLS})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// An export may well be larger than etcd allows in one transaction;
+	// nothing uses the volumes until the import is done, so put them one by
+	// one.
+	for k, v := range kvs {
+		if _, err = client.Put(context.Background(), k, string(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,10 +5,39 @@ import (
 	"encoding/binary"
 	"fmt"
 	"path"
+	"strings"
+
+	"github.com/coreos/torus"
 )
 
-func MkKey(s ...string) string {
-	s = append([]string{KeyPrefix}, s...)
+// Namespace is the root of every key of one torus cluster, so that several
+// clusters can share an etcd cluster.
+type Namespace string
+
+// DefaultNamespace is the Namespace of clusters configured without one.
+const DefaultNamespace = Namespace(KeyPrefix)
+
+// NewNamespace returns the Namespace with the given name, at KeyPrefix below
+// /name. The name must be a single path element, so that it can't reach into
+// the keys of another namespace.
+func NewNamespace(name string) (Namespace, error) {
+	if name == "" || name == "." || strings.Contains(name, "/") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid metadata namespace %q", name)
+	}
+	return Namespace(path.Join("/", name, KeyPrefix) + "/"), nil
+}
+
+// configNamespace returns the Namespace of cfg.
+func configNamespace(cfg torus.Config) (Namespace, error) {
+	if cfg.MetadataNamespace == "" {
+		return DefaultNamespace, nil
+	}
+	return NewNamespace(cfg.MetadataNamespace)
+}
+
+// MkKey joins s into a key in the namespace.
+func (n Namespace) MkKey(s ...string) string {
+	s = append([]string{string(n)}, s...)
 	return path.Join(s...)
 }
 
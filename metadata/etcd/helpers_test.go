@@ -0,0 +1,21 @@
+package etcd
+
+import "testing"
+
+func TestNewNamespace(t *testing.T) {
+	ns, err := NewNamespace("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := ns.MkKey("meta", "the-one-ring"); key != "/staging/github.com/coreos/torus/meta/the-one-ring" {
+		t.Fatalf("key %q", key)
+	}
+	for _, name := range []string{"", ".", "..", "../staging", "a/b", "/", "staging/..", "x..y"} {
+		if _, err := NewNamespace(name); err == nil {
+			t.Errorf("namespace %q was accepted", name)
+		}
+	}
+	if key := DefaultNamespace.MkKey("meta"); key != "/github.com/coreos/torus/meta" {
+		t.Fatalf("key %q in the default namespace", key)
+	}
+}
@@ -20,7 +20,7 @@ func (e *Etcd) watchRingUpdates() error {
 func (e *Etcd) watchRing(r torus.Ring) {
 	ctx, cancel := context.WithCancel(e.getContext())
 	defer cancel()
-	wch := e.Client.Watch(ctx, MkKey("meta", "the-one-ring"))
+	wch := e.Client.Watch(ctx, e.MkKey("meta", "the-one-ring"))
 
 	for resp := range wch {
 		if err := resp.Err(); err != nil {
@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/models"
+)
+
+// ExportKeys builds a MetadataExport out of the keys of a metadata service
+// laid out like metadata/etcd, below the given prefix. Keys that aren't part
+// of an export, like peers, volume locks and accounted usage, which is
+// recomputed, are skipped.
+func ExportKeys(prefix string, kvs map[string][]byte) (*torus.MetadataExport, error) {
+	exp := &torus.MetadataExport{Version: torus.MetadataExportVersion}
+	volumes := make(map[uint64]*torus.ExportedVolume)
+	vol := func(hex string) (*torus.ExportedVolume, error) {
+		id, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: bad volume ID %q", hex)
+		}
+		if _, ok := volumes[id]; !ok {
+			volumes[id] = &torus.ExportedVolume{}
+		}
+		return volumes[id], nil
+	}
+	root := path.Join(prefix) + "/"
+	var haveGlobal bool
+	for k, v := range kvs {
+		if !strings.HasPrefix(k, root) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(k, root), "/", 4)
+		var err error
+		switch {
+		case len(parts) == 2 && parts[0] == "meta":
+			switch parts[1] {
+			case "globalmetadata":
+				haveGlobal = true
+				err = json.Unmarshal(v, &exp.GlobalMetadata)
+			case "volumeminter":
+				exp.VolumeMinter = torus.VolumeID(bytesToUint64(v))
+			case "the-one-ring":
+				exp.Ring = &models.Ring{}
+				err = exp.Ring.Unmarshal(v)
+			}
+		case len(parts) == 2 && parts[0] == "volumeid":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.Volume = &models.Volume{}
+				err = ev.Volume.Unmarshal(v)
+			}
+		case len(parts) == 2 && parts[0] == "snapgroups":
+			if exp.SnapshotGroups == nil {
+				exp.SnapshotGroups = make(map[string]json.RawMessage)
+			}
+			exp.SnapshotGroups[parts[1]] = v
+		case len(parts) == 2 && parts[0] == "trash":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.Trash = v
+				// The trash record holds the volume it replaced.
+				var t struct{ Volume *models.Volume }
+				if err = json.Unmarshal(v, &t); err == nil && ev.Volume == nil {
+					ev.Volume = t.Volume
+				}
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "inode":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.INodeIndex = torus.INodeID(bytesToUint64(v))
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "blockinode":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.BlockINode = v
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "fence":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.Fence = bytesToUint64(v)
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "replica":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.ReplicaOf = string(v)
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "quota":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.Quota = bytesToUint64(v)
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "qos":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.QoS = v
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "snappolicy":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.SnapshotPolicy = v
+			}
+		case len(parts) == 3 && parts[0] == "volumemeta" && parts[2] == "reservations":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				ev.Reservations = v
+			}
+		case len(parts) == 4 && parts[0] == "volumemeta" && parts[2] == "export":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				if ev.ExportState == nil {
+					ev.ExportState = make(map[string][]byte)
+				}
+				ev.ExportState[parts[3]] = v
+			}
+		case len(parts) == 4 && parts[0] == "volumemeta" && parts[2] == "lockaudit":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				if ev.LockAudit == nil {
+					ev.LockAudit = make(map[string]json.RawMessage)
+				}
+				ev.LockAudit[parts[3]] = v
+			}
+		case len(parts) == 4 && parts[0] == "volumemeta" && parts[2] == "snapshots":
+			var ev *torus.ExportedVolume
+			if ev, err = vol(parts[1]); err == nil {
+				var s torus.ExportedSnapshot
+				err = json.Unmarshal(v, &s)
+				ev.Snapshots = append(ev.Snapshots, s)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("metadata: couldn't export %s: %v", k, err)
+		}
+	}
+	if !haveGlobal || exp.Ring == nil {
+		return nil, torus.ErrNoGlobalMetadata
+	}
+	for _, ev := range volumes {
+		if ev.Volume == nil {
+			// Leftovers of a deleted volume.
+			continue
+		}
+		sort.Sort(snapshotsByName(ev.Snapshots))
+		exp.Volumes = append(exp.Volumes, ev)
+	}
+	sort.Sort(volumesByID(exp.Volumes))
+	return exp, nil
+}
+
+// ImportKeys is the inverse of ExportKeys. It returns the keys to write to
+// an initialized metadata service, below the given prefix, to restore the
+// export.
+func ImportKeys(prefix string, exp *torus.MetadataExport) (map[string][]byte, error) {
+	mkKey := func(s ...string) string {
+		return path.Join(append([]string{prefix}, s...)...)
+	}
+	kvs := make(map[string][]byte)
+	gmd, err := json.Marshal(exp.GlobalMetadata)
+	if err != nil {
+		return nil, err
+	}
+	kvs[mkKey("meta", "globalmetadata")] = gmd
+	kvs[mkKey("meta", "volumeminter")] = uint64ToBytes(uint64(exp.VolumeMinter))
+	if kvs[mkKey("meta", "the-one-ring")], err = exp.Ring.Marshal(); err != nil {
+		return nil, err
+	}
+	for name, g := range exp.SnapshotGroups {
+		kvs[mkKey("snapgroups", name)] = g
+	}
+	for _, ev := range exp.Volumes {
+		if ev.Volume == nil {
+			return nil, fmt.Errorf("metadata: exported volume without a volume")
+		}
+		hex := fmt.Sprintf("%x", ev.Volume.Id)
+		vb, err := ev.Volume.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if ev.Trash != nil {
+			kvs[mkKey("trash", hex)] = ev.Trash
+		} else {
+			kvs[mkKey("volumes", ev.Volume.Name)] = uint64ToBytes(ev.Volume.Id)
+			kvs[mkKey("volumeid", hex)] = vb
+		}
+		kvs[mkKey("volumemeta", hex, "inode")] = uint64ToBytes(uint64(ev.INodeIndex))
+		if ev.BlockINode != nil {
+			kvs[mkKey("volumemeta", hex, "blockinode")] = ev.BlockINode
+		}
+		if ev.Fence != 0 {
+			kvs[mkKey("volumemeta", hex, "fence")] = uint64ToBytes(ev.Fence)
+		}
+		if ev.ReplicaOf != "" {
+			kvs[mkKey("volumemeta", hex, "replica")] = []byte(ev.ReplicaOf)
+		}
+		if ev.Quota != 0 {
+			kvs[mkKey("volumemeta", hex, "quota")] = uint64ToBytes(ev.Quota)
+		}
+		if ev.QoS != nil {
+			kvs[mkKey("volumemeta", hex, "qos")] = ev.QoS
+		}
+		if ev.SnapshotPolicy != nil {
+			kvs[mkKey("volumemeta", hex, "snappolicy")] = ev.SnapshotPolicy
+		}
+		if ev.Reservations != nil {
+			kvs[mkKey("volumemeta", hex, "reservations")] = ev.Reservations
+		}
+		for protocol, state := range ev.ExportState {
+			kvs[mkKey("volumemeta", hex, "export", protocol)] = state
+		}
+		for name, rec := range ev.LockAudit {
+			kvs[mkKey("volumemeta", hex, "lockaudit", name)] = rec
+		}
+		for _, s := range ev.Snapshots {
+			sb, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			kvs[mkKey("volumemeta", hex, "snapshots", s.Name)] = sb
+		}
+	}
+	return kvs, nil
+}
+
+func uint64ToBytes(x uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, x)
+	return b
+}
+
+func bytesToUint64(b []byte) uint64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+type volumesByID []*torus.ExportedVolume
+
+func (v volumesByID) Len() int           { return len(v) }
+func (v volumesByID) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v volumesByID) Less(i, j int) bool { return v[i].Volume.Id < v[j].Volume.Id }
+
+type snapshotsByName []torus.ExportedSnapshot
+
+func (s snapshotsByName) Len() int           { return len(s) }
+func (s snapshotsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
@@ -0,0 +1,166 @@
+package local
+
+import (
+	"encoding/json"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata"
+	"github.com/coreos/torus/models"
+	"github.com/coreos/torus/ring"
+)
+
+func withStore(cfg torus.Config, f func(b Backend) error) error {
+	if err := torus.MkdirsFor(cfg.DataDir); err != nil {
+		return err
+	}
+	p, err := storePath(cfg)
+	if err != nil {
+		return err
+	}
+	s, err := openStore(p)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return f(s)
+}
+
+func initLocalMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus.RingType) error {
+	return withStore(cfg, func(b Backend) error {
+		return InitBackend(b, gmd, ringType)
+	})
+}
+
+func wipeLocalMetadata(cfg torus.Config) error {
+	return withStore(cfg, WipeBackend)
+}
+
+func setRing(cfg torus.Config, r torus.Ring) error {
+	return withStore(cfg, func(b Backend) error {
+		return SetBackendRing(b, r)
+	})
+}
+
+func exportLocalMetadata(cfg torus.Config) (exp *torus.MetadataExport, err error) {
+	err = withStore(cfg, func(b Backend) error {
+		exp, err = ExportBackend(b)
+		return err
+	})
+	return exp, err
+}
+
+func importLocalMetadata(cfg torus.Config, exp *torus.MetadataExport) error {
+	return withStore(cfg, func(b Backend) error {
+		return ImportBackend(b, exp)
+	})
+}
+
+// InitBackend formats a Backend with the global metadata and an empty ring.
+func InitBackend(b Backend, gmd torus.GlobalMetadata, ringType torus.RingType) error {
+	gmdbytes, err := json.Marshal(gmd)
+	if err != nil {
+		return err
+	}
+	emptyRing, err := ring.CreateRing(&models.Ring{
+		Type:              uint32(ringType),
+		Version:           1,
+		ReplicationFactor: 2,
+	})
+	if err != nil {
+		return err
+	}
+	ringb, err := emptyRing.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err := b.Txn(&Txn{
+		If: []Compare{CmpVersion(MkKey("meta", "globalmetadata"), "=", 0)},
+		Then: []Op{
+			OpPutKey(MkKey("meta", "volumeminter"), Uint64ToBytes(1)),
+			OpPutKey(MkKey("meta", "globalmetadata"), gmdbytes),
+			OpPutKey(MkKey("meta", "the-one-ring"), ringb),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrExists
+	}
+	return nil
+}
+
+// WipeBackend deletes all torus metadata from a Backend.
+func WipeBackend(b Backend) error {
+	_, err := b.Txn(&Txn{
+		Then: []Op{OpDeletePrefix(MkKey())},
+	})
+	return err
+}
+
+// SetBackendRing replaces the ring stored in a Backend with the next version.
+func SetBackendRing(b Backend, r torus.Ring) error {
+	key := MkKey("meta", "the-one-ring")
+	resp, err := b.Txn(&Txn{Then: []Op{OpGetKey(key)}})
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return torus.ErrNoGlobalMetadata
+	}
+	kv := resp.Responses[0][0]
+	oldr, err := ring.Unmarshal(kv.Value)
+	if err != nil {
+		return err
+	}
+	if oldr.Version() != r.Version()-1 {
+		return torus.ErrNonSequentialRing
+	}
+	rb, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	resp, err = b.Txn(&Txn{
+		If:   []Compare{CmpVersion(key, "=", kv.Version)},
+		Then: []Op{OpPutKey(key, rb)},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return torus.ErrAgain
+	}
+	return nil
+}
+
+// ExportBackend reads all torus metadata out of a Backend.
+func ExportBackend(b Backend) (*torus.MetadataExport, error) {
+	resp, err := b.Txn(&Txn{
+		Then: []Op{OpGetPrefix(MkKey())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte)
+	for _, kv := range resp.Responses[0] {
+		kvs[kv.Key] = kv.Value
+	}
+	return metadata.ExportKeys(KeyPrefix, kvs)
+}
+
+// ImportBackend formats an empty Backend with an export.
+func ImportBackend(b Backend, exp *torus.MetadataExport) error {
+	kvs, err := metadata.ImportKeys(KeyPrefix, exp)
+	if err != nil {
+		return err
+	}
+	if err = InitBackend(b, exp.GlobalMetadata, torus.RingType(exp.Ring.Type)); err != nil {
+		return err
+	}
+	var ops []Op
+	for k, v := range kvs {
+		ops = append(ops, OpPutKey(k, v))
+	}
+	_, err = b.Txn(&Txn{Then: ops})
+	return err
+}
@@ -0,0 +1,454 @@
+// local is a metadata service backed by a single file in the data directory.
+// It is persistent, but can only be shared by the servers in one process, and
+// is meant for single-node deployments that don't want to run etcd.
+package local
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"golang.org/x/net/context"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata"
+	"github.com/coreos/torus/models"
+	"github.com/coreos/torus/ring"
+)
+
+var clog = capnslog.NewPackageLogger("github.com/coreos/torus", "local")
+
+const (
+	// KeyPrefix is the root of all keys. The layout below it is the same as
+	// in metadata/etcd.
+	KeyPrefix = "/github.com/coreos/torus/"
+
+	// StoreFile is the name of the file, in the metadata directory, that
+	// holds the metadata.
+	StoreFile = "local.json"
+
+	leaseTTL = 30
+)
+
+func init() {
+	torus.RegisterMetadataService("local", newLocalMetadata)
+	torus.RegisterMetadataInit("local", initLocalMetadata)
+	torus.RegisterMetadataWipe("local", wipeLocalMetadata)
+	torus.RegisterSetRing("local", setRing)
+	torus.RegisterMetadataExport("local", exportLocalMetadata)
+	torus.RegisterMetadataImport("local", importLocalMetadata)
+}
+
+// Backend is the key-value space a Client stores metadata in. A Store is
+// the Backend of the local metadata service.
+type Backend interface {
+	Txn(t *Txn) (*TxnResponse, error)
+	Grant(ttl int64) (int64, error)
+	KeepAlive(lease int64) error
+	Revoke(lease int64) error
+	TimeToLive(lease int64) (time.Duration, error)
+
+	// Watch calls f for every key written or deleted from now on.
+	Watch(f func(*KeyValue))
+	Close() error
+}
+
+// Client implements torus.MetadataService, and the block volume metadata,
+// on top of a Backend.
+type Client struct {
+	mut     sync.RWMutex
+	cfg     torus.Config
+	backend Backend
+	kind    torus.MetadataKind
+	global  torus.GlobalMetadata
+	uuid    string
+
+	ringListeners []chan torus.Ring
+	keyWatchers   map[uint64]keyWatcher
+	keyWatchID    uint64
+}
+
+type keyWatcher struct {
+	key string
+	f   func(*KeyValue)
+}
+
+func MkKey(s ...string) string {
+	s = append([]string{KeyPrefix}, s...)
+	return path.Join(s...)
+}
+
+func storePath(cfg torus.Config) (string, error) {
+	if cfg.DataDir == "" {
+		return "", errors.New("local: a data directory is required")
+	}
+	return filepath.Join(cfg.DataDir, "metadata", StoreFile), nil
+}
+
+func newLocalMetadata(cfg torus.Config) (torus.MetadataService, error) {
+	p, err := storePath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := metadata.GetUUID(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	store, err := openStore(p)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cfg, uuid, store, torus.LocalMetadata)
+}
+
+// NewClient creates a metadata service of the given kind on a formatted
+// Backend. The Client takes ownership of the Backend and closes it with
+// itself.
+func NewClient(cfg torus.Config, uuid string, backend Backend, kind torus.MetadataKind) (*Client, error) {
+	c := &Client{
+		cfg:     cfg,
+		backend: backend,
+		kind:    kind,
+		uuid:    uuid,
+	}
+	if err := c.getGlobalMetadata(); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	backend.Watch(c.ringWatch)
+	backend.Watch(c.keyWatch)
+	return c, nil
+}
+
+func (c *Client) getGlobalMetadata() error {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("meta", "globalmetadata"))},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return torus.ErrNoGlobalMetadata
+	}
+	return json.Unmarshal(resp.Responses[0][0].Value, &c.global)
+}
+
+func (c *Client) ringWatch(kv *KeyValue) {
+	if kv.Key != MkKey("meta", "the-one-ring") || kv.Deleted {
+		return
+	}
+	newRing, err := ring.Unmarshal(kv.Value)
+	if err != nil {
+		clog.Errorf("failed to unmarshal ring: %s", err)
+		return
+	}
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	for _, x := range c.ringListeners {
+		x <- newRing
+	}
+}
+
+// WatchKey calls f for every write and deletion of key, until cancel is
+// called.
+func (c *Client) WatchKey(key string, f func(*KeyValue)) (cancel func()) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.keyWatchers == nil {
+		c.keyWatchers = make(map[uint64]keyWatcher)
+	}
+	c.keyWatchID++
+	id := c.keyWatchID
+	c.keyWatchers[id] = keyWatcher{key, f}
+	return func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		delete(c.keyWatchers, id)
+	}
+}
+
+func (c *Client) keyWatch(kv *KeyValue) {
+	c.mut.RLock()
+	var fs []func(*KeyValue)
+	for _, w := range c.keyWatchers {
+		if w.key == kv.Key {
+			fs = append(fs, w.f)
+		}
+	}
+	c.mut.RUnlock()
+	for _, f := range fs {
+		f(kv)
+	}
+}
+
+// Txn runs a transaction directly against the Backend.
+func (c *Client) Txn(t *Txn) (*TxnResponse, error) {
+	return c.backend.Txn(t)
+}
+
+// Backend returns the underlying Backend.
+func (c *Client) Backend() Backend {
+	return c.backend
+}
+
+func (c *Client) Kind() torus.MetadataKind {
+	return c.kind
+}
+
+func (c *Client) GlobalMetadata() torus.GlobalMetadata {
+	return c.global
+}
+
+func (c *Client) UUID() string {
+	return c.uuid
+}
+
+func (c *Client) WithContext(_ context.Context) torus.MetadataService {
+	return c
+}
+
+func (c *Client) Close() error {
+	c.mut.Lock()
+	for _, l := range c.ringListeners {
+		close(l)
+	}
+	c.ringListeners = nil
+	c.mut.Unlock()
+	return c.backend.Close()
+}
+
+func (c *Client) GetLease() (int64, error) {
+	return c.backend.Grant(leaseTTL)
+}
+
+func (c *Client) RenewLease(lease int64) error {
+	return c.backend.KeepAlive(lease)
+}
+
+func (c *Client) RegisterPeer(lease int64, p *models.PeerInfo) error {
+	if lease == 0 {
+		return errors.New("no lease")
+	}
+	p.LastSeen = time.Now().UnixNano()
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = c.backend.Txn(&Txn{
+		Then: []Op{OpPutLease(MkKey("nodes", p.UUID), data, lease)},
+	})
+	return err
+}
+
+func (c *Client) GetPeers() (torus.PeerInfoList, error) {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetPrefix(MkKey("nodes"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []*models.PeerInfo
+	for _, x := range resp.Responses[0] {
+		var p models.PeerInfo
+		err := p.Unmarshal(x.Value)
+		if err != nil {
+			clog.Errorf("peer at key %s didn't unmarshal correctly: %v", x.Key, err)
+			continue
+		}
+		out = append(out, &p)
+	}
+	return torus.PeerInfoList(out), nil
+}
+
+// AtomicModifyFunc is a class of commutative functions that, given the current
+// state of a key's value `in`, returns the new state of the key `out`, and
+// `data` to be returned to the calling function on success, or an `err`.
+type AtomicModifyFunc func(in []byte) (out []byte, data interface{}, err error)
+
+func (c *Client) AtomicModifyKey(key string, f AtomicModifyFunc) (interface{}, error) {
+	for {
+		resp, err := c.backend.Txn(&Txn{Then: []Op{OpGetKey(key)}})
+		if err != nil {
+			return nil, err
+		}
+		var version int64
+		value := []byte{}
+		if len(resp.Responses[0]) == 1 {
+			version = resp.Responses[0][0].Version
+			value = resp.Responses[0][0].Value
+		}
+		newBytes, fval, err := f(value)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.backend.Txn(&Txn{
+			If:   []Compare{CmpVersion(key, "=", version)},
+			Then: []Op{OpPutKey(key, newBytes)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Succeeded {
+			return fval, nil
+		}
+	}
+}
+
+func Uint64ToBytes(x uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, x)
+	return b
+}
+
+func BytesToUint64(b []byte) uint64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func Uint64ToHex(x uint64) string {
+	return fmt.Sprintf("%x", x)
+}
+
+func BytesAddOne(in []byte) ([]byte, interface{}, error) {
+	newval := BytesToUint64(in) + 1
+	return Uint64ToBytes(newval), newval, nil
+}
+
+func (c *Client) GetVolumes() ([]*models.Volume, torus.VolumeID, error) {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{
+			OpGetKey(MkKey("meta", "volumeminter")),
+			OpGetPrefix(MkKey("volumeid")),
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, 0, torus.ErrNoGlobalMetadata
+	}
+	highwater := BytesToUint64(resp.Responses[0][0].Value)
+	var out []*models.Volume
+	for _, x := range resp.Responses[1] {
+		v := &models.Volume{}
+		err := v.Unmarshal(x.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+	}
+	return out, torus.VolumeID(highwater), nil
+}
+
+func (c *Client) GetVolume(volume string) (*models.Volume, error) {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("volumes", volume))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, fmt.Errorf("local: volume %q not found", volume)
+	}
+	vid := BytesToUint64(resp.Responses[0][0].Value)
+	resp, err = c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("volumeid", Uint64ToHex(vid)))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, fmt.Errorf("local: volume ID %q not found", Uint64ToHex(vid))
+	}
+	v := &models.Volume{}
+	err = v.Unmarshal(resp.Responses[0][0].Value)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *Client) NewVolumeID() (torus.VolumeID, error) {
+	newID, err := c.AtomicModifyKey(MkKey("meta", "volumeminter"), BytesAddOne)
+	if err != nil {
+		return 0, err
+	}
+	return torus.VolumeID(newID.(uint64)), nil
+}
+
+func (c *Client) CommitINodeIndex(vid torus.VolumeID) (torus.INodeID, error) {
+	newID, err := c.AtomicModifyKey(MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"), BytesAddOne)
+	if err != nil {
+		return 0, err
+	}
+	return torus.INodeID(newID.(uint64)), nil
+}
+
+func (c *Client) GetINodeIndex(vid torus.VolumeID) (torus.INodeID, error) {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("volumemeta", Uint64ToHex(uint64(vid)), "inode"))},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Responses[0]) != 1 {
+		return 0, torus.ErrNotExist
+	}
+	return torus.INodeID(BytesToUint64(resp.Responses[0][0].Value)), nil
+}
+
+func (c *Client) GetLockStatus(vid uint64) string {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("volumemeta", Uint64ToHex(vid), "blocklock"))},
+	})
+	if err != nil {
+		clog.Debugf("Failed to get lock status: %v", err)
+		return "unknown"
+	}
+	if len(resp.Responses[0]) == 0 {
+		return "free"
+	}
+	return "in-use"
+}
+
+func (c *Client) GetRing() (torus.Ring, error) {
+	resp, err := c.backend.Txn(&Txn{
+		Then: []Op{OpGetKey(MkKey("meta", "the-one-ring"))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[0]) == 0 {
+		return nil, torus.ErrNoGlobalMetadata
+	}
+	return ring.Unmarshal(resp.Responses[0][0].Value)
+}
+
+func (c *Client) SubscribeNewRings(ch chan torus.Ring) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.ringListeners = append(c.ringListeners, ch)
+}
+
+func (c *Client) UnsubscribeNewRings(ch chan torus.Ring) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for i, x := range c.ringListeners {
+		if ch == x {
+			c.ringListeners = append(c.ringListeners[:i], c.ringListeners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Client) SetRing(r torus.Ring) error {
+	return SetBackendRing(c.backend, r)
+}
@@ -0,0 +1,314 @@
+package local
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/blockset"
+	"github.com/coreos/torus/models"
+	"github.com/coreos/torus/ring"
+)
+
+func newTestConfig(t *testing.T) torus.Config {
+	dir, err := ioutil.TempDir("", "torus-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := torus.MkdirsFor(dir); err != nil {
+		t.Fatal(err)
+	}
+	return torus.Config{DataDir: dir}
+}
+
+func mustInit(t *testing.T, cfg torus.Config) {
+	err := torus.InitMDS("local", cfg, torus.GlobalMetadata{
+		BlockSize:        1024,
+		DefaultBlockSpec: blockset.MustParseBlockLayerSpec("crc,base"),
+	}, ring.Empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	cfg := newTestConfig(t)
+	defer os.RemoveAll(cfg.DataDir)
+	mustInit(t, cfg)
+
+	mds, err := torus.CreateMetadataService("local", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vid, err := mds.NewVolumeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease, err := mds.GetLease()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mds.(*Client)
+	_, err = c.Txn(&Txn{Then: []Op{OpPutLease(MkKey("leased"), []byte("x"), lease)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mds.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mds, err = torus.CreateMetadataService("local", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mds.Close()
+	if mds.GlobalMetadata().BlockSize != 1024 {
+		t.Fatal("global metadata was not persisted")
+	}
+	next, err := mds.NewVolumeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != vid+1 {
+		t.Fatalf("expected volume ID %d, got %d", vid+1, next)
+	}
+	resp, err := mds.(*Client).Txn(&Txn{Then: []Op{OpGetKey(MkKey("leased"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Responses[0]) != 0 {
+		t.Fatal("leased key survived a restart")
+	}
+	if err = mds.RenewLease(lease); err != torus.ErrLeaseNotFound {
+		t.Fatalf("expected lease to be gone, got %v", err)
+	}
+}
+
+func TestTxn(t *testing.T) {
+	s, err := openStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	put := &Txn{
+		If:   []Compare{CmpVersion("a", "=", 0)},
+		Then: []Op{OpPutKey("a", []byte("1"))},
+	}
+	resp, err := s.Txn(put)
+	if err != nil || !resp.Succeeded {
+		t.Fatalf("first create failed: %v", err)
+	}
+	resp, err = s.Txn(put)
+	if err != nil || resp.Succeeded {
+		t.Fatalf("second create should not succeed: %v", err)
+	}
+	resp, err = s.Txn(&Txn{
+		If:   []Compare{CmpValue("a", "=", []byte("1"))},
+		Then: []Op{OpPutKey("a/b", []byte("2")), OpGetPrefix("a")},
+	})
+	if err != nil || !resp.Succeeded {
+		t.Fatalf("compare value failed: %v", err)
+	}
+	if len(resp.Responses[1]) != 2 || resp.Responses[1][1].Key != "a/b" {
+		t.Fatalf("unexpected range: %#v", resp.Responses[1])
+	}
+	_, err = s.Txn(&Txn{Then: []Op{OpPutLease("c", nil, 42)}})
+	if err != torus.ErrLeaseNotFound {
+		t.Fatalf("expected ErrLeaseNotFound, got %v", err)
+	}
+	lease, _ := s.Grant(30)
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutLease("c", nil, lease)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Revoke(lease); err != nil {
+		t.Fatal(err)
+	}
+	resp, _ = s.Txn(&Txn{Then: []Op{OpGetKey("c")}})
+	if len(resp.Responses[0]) != 0 {
+		t.Fatal("revoked lease didn't remove key")
+	}
+}
+
+func TestTxnSaveFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torus-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.json")
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutKey("a", []byte("1"))}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in the way of the temporary file makes saving fail.
+	if err = os.Mkdir(path+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutKey("a", []byte("2")), OpDeleteKey("b")}}); err == nil {
+		t.Fatal("transaction succeeded without being saved")
+	}
+	resp, err := s.Txn(&Txn{Then: []Op{OpGetKey("a")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kv := resp.Responses[0][0]; string(kv.Value) != "1" || kv.Version != 1 {
+		t.Fatalf("unsaved transaction changed a to %q, version %d", kv.Value, kv.Version)
+	}
+}
+
+func TestWatchDeletes(t *testing.T) {
+	s, err := openStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	events := make(chan *KeyValue, 10)
+	s.Watch(func(kv *KeyValue) { events <- kv })
+	next := func() *KeyValue {
+		select {
+		case kv := <-events:
+			return kv
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return nil
+		}
+	}
+	expectDelete := func(what, key string) {
+		if kv := next(); kv.Key != key || !kv.Deleted {
+			t.Fatalf("%s: got event %+v, want the deletion of %s", what, kv, key)
+		}
+	}
+
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutKey("a", []byte("1"))}}); err != nil {
+		t.Fatal(err)
+	}
+	if kv := next(); kv.Key != "a" || kv.Deleted || string(kv.Value) != "1" {
+		t.Fatalf("got event %+v for a put", kv)
+	}
+	if _, err = s.Txn(&Txn{Then: []Op{OpDeleteKey("a")}}); err != nil {
+		t.Fatal(err)
+	}
+	expectDelete("delete", "a")
+
+	lease, _ := s.Grant(30)
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutLease("revoked", nil, lease)}}); err != nil {
+		t.Fatal(err)
+	}
+	next()
+	if err = s.Revoke(lease); err != nil {
+		t.Fatal(err)
+	}
+	expectDelete("revoke", "revoked")
+
+	lease, _ = s.Grant(1)
+	if _, err = s.Txn(&Txn{Then: []Op{OpPutLease("expired", nil, lease)}}); err != nil {
+		t.Fatal(err)
+	}
+	next()
+	expectDelete("expiry", "expired")
+}
+
+func TestExportImport(t *testing.T) {
+	cfg := newTestConfig(t)
+	defer os.RemoveAll(cfg.DataDir)
+	mustInit(t, cfg)
+
+	mds, err := torus.CreateMetadataService("local", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vid, err := mds.NewVolumeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vol := &models.Volume{Name: "vol", Id: uint64(vid), Type: "block", MaxBytes: 1024}
+	vb, _ := vol.Marshal()
+	hex := Uint64ToHex(uint64(vid))
+	// A volume deleted to the trash, with a snapshot.
+	tvid, err := mds.NewVolumeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thex := Uint64ToHex(uint64(tvid))
+	tb, _ := json.Marshal(map[string]interface{}{
+		"Volume": &models.Volume{Name: "trashed", Id: uint64(tvid), Type: "block", MaxBytes: 1024},
+	})
+	lease, _ := mds.GetLease()
+	_, err = mds.(*Client).Txn(&Txn{Then: []Op{
+		OpPutKey(MkKey("trash", thex), tb),
+		OpPutKey(MkKey("snapgroups", "snap"), []byte(`{"Name":"snap"}`)),
+		OpPutKey(MkKey("volumemeta", thex, "inode"), Uint64ToBytes(1)),
+		OpPutKey(MkKey("volumemeta", thex, "snapshots", "snap"), []byte(`{"Name":"snap","INodeRef":"AQI="}`)),
+		OpPutKey(MkKey("volumes", "vol"), Uint64ToBytes(uint64(vid))),
+		OpPutKey(MkKey("volumeid", hex), vb),
+		OpPutKey(MkKey("volumemeta", hex, "inode"), Uint64ToBytes(3)),
+		OpPutKey(MkKey("volumemeta", hex, "blockinode"), []byte("inode")),
+		OpPutKey(MkKey("volumemeta", hex, "fence"), Uint64ToBytes(7)),
+		OpPutKey(MkKey("volumemeta", hex, "snapshots", "snap"), []byte(`{"Name":"snap","INodeRef":"AQI="}`)),
+		OpPutKey(MkKey("volumemeta", hex, "lockaudit", "00000000000000000001"), []byte(`{"By":"admin"}`)),
+		OpPutKey(MkKey("volumemeta", hex, "reservations"), []byte(`{"Generation":2}`)),
+		OpPutKey(MkKey("volumemeta", hex, "export", "aoe"), []byte(`{"Config":"c"}`)),
+		OpPutLease(MkKey("volumemeta", hex, "blocklock"), []byte("me"), lease),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mds.Close()
+
+	exp, err := torus.ExportMDS("local", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp.Volumes) != 2 || exp.Volumes[0].INodeIndex != 3 || exp.Volumes[0].Fence != 7 || len(exp.Volumes[0].Snapshots) != 1 || len(exp.Volumes[0].LockAudit) != 1 || exp.Volumes[0].Reservations == nil || len(exp.Volumes[0].ExportState) != 1 {
+		t.Fatalf("unexpected export: %#v", exp.Volumes)
+	}
+	if len(exp.SnapshotGroups) != 1 {
+		t.Fatalf("snapshot groups weren't exported: %v", exp.SnapshotGroups)
+	}
+	if tv := exp.Volumes[1]; tv.Trash == nil || tv.Volume.Name != "trashed" || len(tv.Snapshots) != 1 {
+		t.Fatalf("trashed volume wasn't exported: %#v", tv)
+	}
+	if err = torus.ImportMDS("local", cfg, exp); err != torus.ErrExists {
+		t.Fatalf("expected ErrExists importing over metadata, got %v", err)
+	}
+
+	restored := newTestConfig(t)
+	defer os.RemoveAll(restored.DataDir)
+	if err = torus.ImportMDS("local", restored, exp); err != nil {
+		t.Fatal(err)
+	}
+	again, err := torus.ExportMDS("local", restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, again) {
+		t.Fatalf("import didn't restore the export:\n%#v\n%#v", exp, again)
+	}
+	mds, err = torus.CreateMetadataService("local", restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mds.Close()
+	if _, err = mds.GetVolume("vol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mds.GetVolume("trashed"); err == nil {
+		t.Fatal("trashed volume was imported as a live one")
+	}
+	if mds.GetLockStatus(uint64(vid)) != "free" {
+		t.Fatal("volume lock was imported")
+	}
+	if next, _ := mds.NewVolumeID(); next != tvid+1 {
+		t.Fatalf("expected volume ID %d, got %d", tvid+1, next)
+	}
+}
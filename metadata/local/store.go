@@ -0,0 +1,512 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/torus"
+)
+
+// The store is a small, versioned key-value space with transactions and
+// leases, modeled on the subset of etcd v3 that the etcd metadata service
+// uses. Keeping the same shape means the local metadata service can share
+// its key layout with metadata/etcd.
+
+// CompareTarget is the property of a key a Compare is run against.
+type CompareTarget int
+
+const (
+	CompareVersion CompareTarget = iota
+	CompareValue
+)
+
+// Compare is a single condition of a transaction.
+type Compare struct {
+	Key     string
+	Target  CompareTarget
+	Result  string
+	Version int64
+	Value   []byte
+}
+
+// CmpVersion compares the version of key (0 if the key doesn't exist) with v.
+func CmpVersion(key string, result string, v int64) Compare {
+	return Compare{Key: key, Target: CompareVersion, Result: result, Version: v}
+}
+
+// CmpValue compares the value of key with v.
+func CmpValue(key string, result string, v []byte) Compare {
+	return Compare{Key: key, Target: CompareValue, Result: result, Value: v}
+}
+
+// OpType is the kind of operation.
+type OpType int
+
+const (
+	OpGet OpType = iota
+	OpPut
+	OpDelete
+)
+
+// Op is a single operation performed by a transaction.
+type Op struct {
+	Type   OpType
+	Key    string
+	Value  []byte
+	Lease  int64
+	Prefix bool
+}
+
+func OpGetKey(key string) Op               { return Op{Type: OpGet, Key: key} }
+func OpGetPrefix(key string) Op            { return Op{Type: OpGet, Key: key, Prefix: true} }
+func OpPutKey(key string, value []byte) Op { return Op{Type: OpPut, Key: key, Value: value} }
+func OpDeleteKey(key string) Op            { return Op{Type: OpDelete, Key: key} }
+func OpDeletePrefix(key string) Op         { return Op{Type: OpDelete, Key: key, Prefix: true} }
+func OpPutLease(key string, value []byte, lease int64) Op {
+	return Op{Type: OpPut, Key: key, Value: value, Lease: lease}
+}
+
+// Txn runs Then if every condition in If holds, and Else otherwise.
+type Txn struct {
+	If   []Compare
+	Then []Op
+	Else []Op
+}
+
+func (t *Txn) writes(succeeded bool) bool {
+	ops := t.Then
+	if !succeeded {
+		ops = t.Else
+	}
+	for _, op := range ops {
+		if op.Type != OpGet {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyValue is a stored key, along with the number of times it has been
+// written since creation and the lease it's bound to, if any.
+type KeyValue struct {
+	Key     string
+	Value   []byte
+	Version int64
+	Lease   int64 `json:",omitempty"`
+	// Deleted is set on the watch events of deleted keys, which have
+	// nothing else but the key.
+	Deleted bool `json:",omitempty"`
+}
+
+// TxnResponse holds the result of a transaction. Responses has one entry for
+// each executed Op; only OpGet has any KeyValues.
+type TxnResponse struct {
+	Succeeded bool
+	Responses [][]*KeyValue
+}
+
+// State is the deterministic part of the store: the same sequence of
+// transactions and lease operations always produces the same State. This
+// lets it be replicated, as the raft metadata service does.
+type State struct {
+	Keys        map[string]*KeyValue
+	Leases      map[int64]int64
+	LeaseMinter int64
+}
+
+func NewState() *State {
+	return &State{
+		Keys:   make(map[string]*KeyValue),
+		Leases: make(map[int64]int64),
+	}
+}
+
+func (s *State) compare(c Compare) bool {
+	kv, ok := s.Keys[c.Key]
+	switch c.Target {
+	case CompareVersion:
+		var v int64
+		if ok {
+			v = kv.Version
+		}
+		return compareResult(c.Result, int(v-c.Version))
+	case CompareValue:
+		if !ok {
+			return false
+		}
+		return compareResult(c.Result, bytes.Compare(kv.Value, c.Value))
+	}
+	return false
+}
+
+func compareResult(result string, cmp int) bool {
+	switch result {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+	return false
+}
+
+func (s *State) rangeKeys(key string, prefix bool) []*KeyValue {
+	if !prefix {
+		if kv, ok := s.Keys[key]; ok {
+			return []*KeyValue{kv}
+		}
+		return nil
+	}
+	var out []*KeyValue
+	for k, kv := range s.Keys {
+		if strings.HasPrefix(k, key) {
+			out = append(out, kv)
+		}
+	}
+	sort.Sort(byKey(out))
+	return out
+}
+
+// Apply executes the transaction. It returns the response and the events
+// of the keys it wrote or deleted.
+func (s *State) Apply(t *Txn) (*TxnResponse, []*KeyValue, error) {
+	succeeded := true
+	for _, c := range t.If {
+		if !s.compare(c) {
+			succeeded = false
+			break
+		}
+	}
+	ops := t.Then
+	if !succeeded {
+		ops = t.Else
+	}
+	// Check before modifying anything, so that a failed transaction has no
+	// effect.
+	for _, op := range ops {
+		if op.Type != OpPut || op.Lease == 0 {
+			continue
+		}
+		if _, ok := s.Leases[op.Lease]; !ok {
+			return nil, nil, torus.ErrLeaseNotFound
+		}
+	}
+	resp := &TxnResponse{
+		Succeeded: succeeded,
+		Responses: make([][]*KeyValue, len(ops)),
+	}
+	var changed []*KeyValue
+	for i, op := range ops {
+		switch op.Type {
+		case OpGet:
+			for _, kv := range s.rangeKeys(op.Key, op.Prefix) {
+				resp.Responses[i] = append(resp.Responses[i], kv.clone())
+			}
+		case OpPut:
+			kv := &KeyValue{
+				Key:   op.Key,
+				Value: op.Value,
+				Lease: op.Lease,
+			}
+			if old, ok := s.Keys[op.Key]; ok {
+				kv.Version = old.Version
+			}
+			kv.Version++
+			s.Keys[op.Key] = kv
+			changed = append(changed, kv.clone())
+		case OpDelete:
+			for _, kv := range s.rangeKeys(op.Key, op.Prefix) {
+				delete(s.Keys, kv.Key)
+				changed = append(changed, &KeyValue{Key: kv.Key, Deleted: true})
+			}
+		}
+	}
+	return resp, changed, nil
+}
+
+// Grant creates a new lease, with a TTL in seconds.
+func (s *State) Grant(ttl int64) int64 {
+	s.LeaseMinter++
+	s.Leases[s.LeaseMinter] = ttl
+	return s.LeaseMinter
+}
+
+// LeaseTTL returns the TTL of a lease, and whether it exists.
+func (s *State) LeaseTTL(lease int64) (int64, bool) {
+	ttl, ok := s.Leases[lease]
+	return ttl, ok
+}
+
+// Revoke removes a lease and every key bound to it, returning the events of
+// their deletion.
+func (s *State) Revoke(lease int64) []*KeyValue {
+	delete(s.Leases, lease)
+	var deleted []*KeyValue
+	for k, kv := range s.Keys {
+		if kv.Lease == lease {
+			delete(s.Keys, k)
+			deleted = append(deleted, &KeyValue{Key: k, Deleted: true})
+		}
+	}
+	sort.Sort(byKey(deleted))
+	return deleted
+}
+
+// clone returns a copy of the state, which Apply can change without
+// changing s. KeyValues are shared, as Apply replaces them rather than
+// changing them.
+func (s *State) clone() *State {
+	out := &State{
+		Keys:        make(map[string]*KeyValue, len(s.Keys)),
+		Leases:      make(map[int64]int64, len(s.Leases)),
+		LeaseMinter: s.LeaseMinter,
+	}
+	for k, kv := range s.Keys {
+		out.Keys[k] = kv
+	}
+	for id, ttl := range s.Leases {
+		out.Leases[id] = ttl
+	}
+	return out
+}
+
+// persistent returns a copy of the state without anything bound to a lease.
+// Leases are only valid while the process that holds them is alive, so there
+// is nothing to recover them into on restart.
+func (s *State) persistent() *State {
+	out := NewState()
+	out.LeaseMinter = s.LeaseMinter
+	for k, kv := range s.Keys {
+		if kv.Lease != 0 {
+			continue
+		}
+		out.Keys[k] = kv
+	}
+	return out
+}
+
+func (kv *KeyValue) clone() *KeyValue {
+	out := *kv
+	return &out
+}
+
+type byKey []*KeyValue
+
+func (b byKey) Len() int           { return len(b) }
+func (b byKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
+func (b byKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Store is a State persisted to a single file, with leases that expire in
+// real time. Every successful write is synced to disk before it returns.
+type Store struct {
+	mut      sync.Mutex
+	path     string
+	st       *State
+	expiries map[int64]time.Time
+	watchers []func(*KeyValue)
+	refs     int
+	closed   chan struct{}
+}
+
+var (
+	storesMut sync.Mutex
+	stores    = make(map[string]*Store)
+)
+
+// openStore returns the Store for the file at path, loading it if needed.
+// Stores are shared within the process, so that the service, and the init,
+// wipe and ring functions all see the same state. An empty path creates a
+// private, in-memory Store.
+func openStore(path string) (*Store, error) {
+	storesMut.Lock()
+	defer storesMut.Unlock()
+	if s, ok := stores[path]; ok {
+		s.refs++
+		return s, nil
+	}
+	s := &Store{
+		path:     path,
+		st:       NewState(),
+		expiries: make(map[int64]time.Time),
+		refs:     1,
+		closed:   make(chan struct{}),
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, s.st); err != nil {
+				return nil, err
+			}
+		}
+		stores[path] = s
+	}
+	go s.expireLeases()
+	return s, nil
+}
+
+// Close releases a reference to the Store.
+func (s *Store) Close() error {
+	storesMut.Lock()
+	defer storesMut.Unlock()
+	s.refs--
+	if s.refs != 0 {
+		return nil
+	}
+	if s.path != "" {
+		delete(stores, s.path)
+	}
+	close(s.closed)
+	return nil
+}
+
+// save writes st to the file of the Store.
+func (s *Store) save(st *State) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(st.persistent())
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Clean(s.path))
+}
+
+// Txn atomically runs the transaction. A transaction which writes is run on
+// a copy of the state, which replaces it once it's saved, so that one which
+// can't be saved has no effect.
+func (s *Store) Txn(t *Txn) (*TxnResponse, error) {
+	s.mut.Lock()
+	st := s.st
+	if t.writes(true) || t.writes(false) {
+		st = s.st.clone()
+	}
+	resp, changed, err := st.Apply(t)
+	if err == nil && t.writes(resp.Succeeded) {
+		if err = s.save(st); err == nil {
+			s.st = st
+		}
+	}
+	watchers := s.watchers
+	s.mut.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	notify(watchers, changed)
+	return resp, nil
+}
+
+// notify calls the watchers with the events.
+func notify(watchers []func(*KeyValue), events []*KeyValue) {
+	for _, kv := range events {
+		for _, w := range watchers {
+			w(kv)
+		}
+	}
+}
+
+// Watch calls f, outside of any lock, for every key written or deleted from
+// now on.
+func (s *Store) Watch(f func(*KeyValue)) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.watchers = append(s.watchers, f)
+}
+
+// Grant creates a new lease that expires after ttl seconds without being
+// kept alive.
+func (s *Store) Grant(ttl int64) (int64, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	id := s.st.Grant(ttl)
+	s.expiries[id] = time.Now().Add(time.Duration(ttl) * time.Second)
+	return id, nil
+}
+
+// KeepAlive renews a lease for its full TTL.
+func (s *Store) KeepAlive(lease int64) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	ttl, ok := s.st.LeaseTTL(lease)
+	if !ok {
+		return torus.ErrLeaseNotFound
+	}
+	s.expiries[lease] = time.Now().Add(time.Duration(ttl) * time.Second)
+	return nil
+}
+
+// Revoke removes a lease and every key bound to it.
+func (s *Store) Revoke(lease int64) error {
+	s.mut.Lock()
+	if _, ok := s.st.LeaseTTL(lease); !ok {
+		s.mut.Unlock()
+		return torus.ErrLeaseNotFound
+	}
+	delete(s.expiries, lease)
+	deleted := s.st.Revoke(lease)
+	watchers := s.watchers
+	s.mut.Unlock()
+	notify(watchers, deleted)
+	return nil
+}
+
+// TimeToLive returns the remaining time on a lease.
+func (s *Store) TimeToLive(lease int64) (time.Duration, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	exp, ok := s.expiries[lease]
+	if !ok {
+		return 0, torus.ErrLeaseNotFound
+	}
+	return exp.Sub(time.Now()), nil
+}
+
+func (s *Store) expireLeases() {
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(time.Second):
+		}
+		now := time.Now()
+		var deleted []*KeyValue
+		s.mut.Lock()
+		for id, exp := range s.expiries {
+			if now.After(exp) {
+				clog.Debugf("lease %d expired", id)
+				delete(s.expiries, id)
+				deleted = append(deleted, s.st.Revoke(id)...)
+			}
+		}
+		watchers := s.watchers
+		s.mut.Unlock()
+		notify(watchers, deleted)
+	}
+}
@@ -0,0 +1,521 @@
+package raft
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	etcdraft "github.com/coreos/etcd/raft"
+	"github.com/coreos/etcd/raft/raftpb"
+	"github.com/coreos/etcd/snap"
+	"github.com/coreos/etcd/wal"
+	"github.com/coreos/etcd/wal/walpb"
+	"golang.org/x/net/context"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata/local"
+)
+
+const (
+	tickInterval   = 100 * time.Millisecond
+	electionTicks  = 10
+	proposeTimeout = 5 * time.Second
+
+	// snapshotEntries is the number of applied entries between snapshots of
+	// the state, after which the log is compacted.
+	snapshotEntries = 1000
+)
+
+// request is the payload of a raft log entry. Exactly one of the operations
+// is set.
+type request struct {
+	ID        uint64
+	Txn       *local.Txn `json:",omitempty"`
+	Grant     int64      `json:",omitempty"`
+	KeepAlive int64      `json:",omitempty"`
+	Revoke    int64      `json:",omitempty"`
+}
+
+type result struct {
+	resp  *local.TxnResponse
+	lease int64
+	err   error
+}
+
+// Node is a member of a raft group replicating a local.State. It implements
+// local.Backend; every call, reads included, goes through the raft log, so
+// all of them are linearizable.
+type Node struct {
+	id    uint64
+	peers []string
+	// tls, if set, is how the member dials the others over https.
+	tls *tls.Config
+
+	node        etcdraft.Node
+	storage     *etcdraft.MemoryStorage
+	wal         *wal.WAL
+	snapshotter *snap.Snapshotter
+	transport   *transport
+
+	mut       sync.Mutex
+	st        *local.State
+	confState raftpb.ConfState
+	applied   uint64
+	snapIndex uint64
+	nextID    uint64
+	waiters   map[uint64]chan result
+	watchers  map[uint64]func(*local.KeyValue)
+	watchID   uint64
+	expiries  map[int64]time.Time
+	leader    bool
+
+	events chan []*local.KeyValue
+	stopc  chan struct{}
+	donec  chan struct{}
+}
+
+// StartNode starts (or restarts, if dir holds its log) the member with the
+// given ID. Members are numbered from 1, in the order of peers, which must be
+// the same for every member. Messages from other members have to be fed to
+// the Node through its Handler. If tlsConfig is set, the member dials the
+// others with it, and they must be https URLs.
+func StartNode(id uint64, peers []string, dir string, tlsConfig *tls.Config) (*Node, error) {
+	walDir := filepath.Join(dir, "wal")
+	snapDir := filepath.Join(dir, "snap")
+	if err := os.MkdirAll(snapDir, 0700); err != nil {
+		return nil, err
+	}
+	n := &Node{
+		id:          id,
+		peers:       peers,
+		tls:         tlsConfig,
+		storage:     etcdraft.NewMemoryStorage(),
+		snapshotter: snap.New(snapDir),
+		st:          local.NewState(),
+		nextID:      uint64(rand.Int63()),
+		waiters:     make(map[uint64]chan result),
+		watchers:    make(map[uint64]func(*local.KeyValue)),
+		expiries:    make(map[int64]time.Time),
+		events:      make(chan []*local.KeyValue, 1024),
+		stopc:       make(chan struct{}),
+		donec:       make(chan struct{}),
+	}
+	c := &etcdraft.Config{
+		ID:              id,
+		ElectionTick:    electionTicks,
+		HeartbeatTick:   1,
+		Storage:         n.storage,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: 256,
+		Logger:          rlog,
+	}
+	var err error
+	if wal.Exist(walDir) {
+		err = n.replay(walDir)
+		if err != nil {
+			return nil, err
+		}
+		c.Applied = n.applied
+		n.node = etcdraft.RestartNode(c)
+	} else {
+		n.wal, err = wal.Create(walDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		rpeers := make([]etcdraft.Peer, len(peers))
+		for i := range peers {
+			rpeers[i] = etcdraft.Peer{ID: uint64(i + 1)}
+		}
+		n.node = etcdraft.StartNode(c, rpeers)
+	}
+	n.transport = newTransport(n)
+	go n.run()
+	go n.dispatchEvents()
+	go n.expireLeases()
+	return n, nil
+}
+
+func (n *Node) replay(walDir string) error {
+	snapshot, err := n.snapshotter.Load()
+	if err != nil && err != snap.ErrNoSnapshot {
+		return err
+	}
+	walsnap := walpb.Snapshot{}
+	if snapshot != nil {
+		walsnap.Index, walsnap.Term = snapshot.Metadata.Index, snapshot.Metadata.Term
+		if err = n.storage.ApplySnapshot(*snapshot); err != nil {
+			return err
+		}
+		if err = n.restore(*snapshot); err != nil {
+			return err
+		}
+	}
+	n.wal, err = wal.Open(walDir, walsnap)
+	if err != nil {
+		return err
+	}
+	_, hs, ents, err := n.wal.ReadAll()
+	if err != nil {
+		return err
+	}
+	if err = n.storage.SetHardState(hs); err != nil {
+		return err
+	}
+	return n.storage.Append(ents)
+}
+
+func (n *Node) restore(snapshot raftpb.Snapshot) error {
+	st := local.NewState()
+	if err := json.Unmarshal(snapshot.Data, st); err != nil {
+		return err
+	}
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	n.st = st
+	n.confState = snapshot.Metadata.ConfState
+	n.applied = snapshot.Metadata.Index
+	n.snapIndex = snapshot.Metadata.Index
+	return nil
+}
+
+// Close shuts the member down. It doesn't leave the raft group.
+func (n *Node) Close() error {
+	close(n.stopc)
+	<-n.donec
+	n.transport.stop()
+	return n.wal.Close()
+}
+
+func (n *Node) run() {
+	defer close(n.donec)
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.node.Tick()
+		case rd := <-n.node.Ready():
+			if rd.SoftState != nil {
+				n.setLeader(rd.SoftState.Lead == n.id)
+			}
+			if err := n.wal.Save(rd.HardState, rd.Entries); err != nil {
+				clog.Fatalf("couldn't write raft log: %v", err)
+			}
+			if !etcdraft.IsEmptySnap(rd.Snapshot) {
+				if err := n.saveSnap(rd.Snapshot); err != nil {
+					clog.Fatalf("couldn't save snapshot: %v", err)
+				}
+				n.storage.ApplySnapshot(rd.Snapshot)
+				if err := n.restore(rd.Snapshot); err != nil {
+					clog.Fatalf("couldn't restore snapshot: %v", err)
+				}
+			}
+			n.storage.Append(rd.Entries)
+			n.transport.send(rd.Messages)
+			n.apply(rd.CommittedEntries)
+			n.maybeSnapshot()
+			n.node.Advance()
+		case <-n.stopc:
+			n.node.Stop()
+			return
+		}
+	}
+}
+
+func (n *Node) saveSnap(snapshot raftpb.Snapshot) error {
+	err := n.wal.SaveSnapshot(walpb.Snapshot{
+		Index: snapshot.Metadata.Index,
+		Term:  snapshot.Metadata.Term,
+	})
+	if err != nil {
+		return err
+	}
+	return n.snapshotter.SaveSnap(snapshot)
+}
+
+func (n *Node) maybeSnapshot() {
+	n.mut.Lock()
+	if n.applied-n.snapIndex <= snapshotEntries {
+		n.mut.Unlock()
+		return
+	}
+	data, err := json.Marshal(n.st)
+	applied, cs := n.applied, n.confState
+	n.mut.Unlock()
+	if err != nil {
+		clog.Errorf("couldn't marshal state: %v", err)
+		return
+	}
+	snapshot, err := n.storage.CreateSnapshot(applied, &cs, data)
+	if err != nil {
+		clog.Errorf("couldn't create snapshot: %v", err)
+		return
+	}
+	if err = n.saveSnap(snapshot); err != nil {
+		clog.Errorf("couldn't save snapshot: %v", err)
+		return
+	}
+	// Keep some entries around for slow followers.
+	if applied > snapshotEntries {
+		n.storage.Compact(applied - snapshotEntries)
+	}
+	n.mut.Lock()
+	n.snapIndex = applied
+	n.mut.Unlock()
+}
+
+func (n *Node) apply(ents []raftpb.Entry) {
+	for _, e := range ents {
+		n.mut.Lock()
+		applied := n.applied
+		n.mut.Unlock()
+		if e.Index <= applied {
+			continue
+		}
+		switch e.Type {
+		case raftpb.EntryNormal:
+			if len(e.Data) != 0 {
+				n.applyRequest(e.Data)
+			}
+		case raftpb.EntryConfChange:
+			var cc raftpb.ConfChange
+			if err := cc.Unmarshal(e.Data); err != nil {
+				clog.Errorf("couldn't unmarshal conf change: %v", err)
+				break
+			}
+			cs := n.node.ApplyConfChange(cc)
+			n.mut.Lock()
+			n.confState = *cs
+			n.mut.Unlock()
+		}
+		n.mut.Lock()
+		n.applied = e.Index
+		n.mut.Unlock()
+	}
+}
+
+func (n *Node) applyRequest(data []byte) {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		clog.Errorf("couldn't unmarshal raft entry: %v", err)
+		return
+	}
+	var (
+		res     result
+		changed []*local.KeyValue
+	)
+	now := time.Now()
+	n.mut.Lock()
+	switch {
+	case req.Txn != nil:
+		res.resp, changed, res.err = n.st.Apply(req.Txn)
+	case req.Grant != 0:
+		res.lease = n.st.Grant(req.Grant)
+		n.expiries[res.lease] = now.Add(time.Duration(req.Grant) * time.Second)
+	case req.KeepAlive != 0:
+		ttl, ok := n.st.LeaseTTL(req.KeepAlive)
+		if !ok {
+			res.err = torus.ErrLeaseNotFound
+			break
+		}
+		n.expiries[req.KeepAlive] = now.Add(time.Duration(ttl) * time.Second)
+	case req.Revoke != 0:
+		if _, ok := n.st.LeaseTTL(req.Revoke); !ok {
+			res.err = torus.ErrLeaseNotFound
+			break
+		}
+		delete(n.expiries, req.Revoke)
+		changed = n.st.Revoke(req.Revoke)
+	}
+	w, ok := n.waiters[req.ID]
+	delete(n.waiters, req.ID)
+	n.mut.Unlock()
+	if ok {
+		w <- res
+	}
+	if len(changed) != 0 {
+		n.events <- changed
+	}
+}
+
+// dispatchEvents calls the watchers outside of the raft loop, so that a slow
+// watcher can't stall replication.
+func (n *Node) dispatchEvents() {
+	for {
+		select {
+		case <-n.stopc:
+			return
+		case kvs := <-n.events:
+			n.mut.Lock()
+			watchers := make([]func(*local.KeyValue), 0, len(n.watchers))
+			for _, w := range n.watchers {
+				watchers = append(watchers, w)
+			}
+			n.mut.Unlock()
+			for _, kv := range kvs {
+				for _, w := range watchers {
+					w(kv)
+				}
+			}
+		}
+	}
+}
+
+func (n *Node) setLeader(leader bool) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	if leader && !n.leader {
+		// Keepalives may have been sent to the old leader, so give every
+		// lease a full TTL with the new one.
+		now := time.Now()
+		for id, ttl := range n.st.Leases {
+			n.expiries[id] = now.Add(time.Duration(ttl) * time.Second)
+		}
+	}
+	n.leader = leader
+}
+
+// expireLeases revokes the leases that weren't kept alive. Only the leader
+// does this, through the log, so all members agree on when a lease expired.
+func (n *Node) expireLeases() {
+	for {
+		select {
+		case <-n.stopc:
+			return
+		case <-time.After(time.Second):
+		}
+		var expired []int64
+		now := time.Now()
+		n.mut.Lock()
+		if n.leader {
+			for id, exp := range n.expiries {
+				if now.After(exp) {
+					expired = append(expired, id)
+				}
+			}
+		}
+		n.mut.Unlock()
+		for _, id := range expired {
+			clog.Debugf("lease %d expired", id)
+			if _, err := n.propose(&request{Revoke: id}); err != nil {
+				clog.Warningf("couldn't revoke lease %d: %v", id, err)
+			}
+		}
+	}
+}
+
+func (n *Node) propose(req *request) (result, error) {
+	ch := make(chan result, 1)
+	n.mut.Lock()
+	n.nextID++
+	req.ID = n.nextID
+	n.waiters[req.ID] = ch
+	n.mut.Unlock()
+	cleanup := func() {
+		n.mut.Lock()
+		delete(n.waiters, req.ID)
+		n.mut.Unlock()
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		cleanup()
+		return result{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), proposeTimeout)
+	defer cancel()
+	// Proposals are dropped while there's no leader, so wait for one.
+	for n.Leader() == 0 {
+		select {
+		case <-time.After(tickInterval):
+		case <-ctx.Done():
+			cleanup()
+			return result{}, torus.ErrAgain
+		case <-n.stopc:
+			cleanup()
+			return result{}, torus.ErrClosed
+		}
+	}
+	if err = n.node.Propose(ctx, data); err != nil {
+		cleanup()
+		return result{}, torus.ErrAgain
+	}
+	select {
+	case r := <-ch:
+		return r, nil
+	case <-ctx.Done():
+		cleanup()
+		return result{}, torus.ErrAgain
+	case <-n.stopc:
+		cleanup()
+		return result{}, torus.ErrClosed
+	}
+}
+
+// Leader returns the ID of the current leader, or 0 if there is none.
+func (n *Node) Leader() uint64 {
+	return n.node.Status().Lead
+}
+
+func (n *Node) Txn(t *local.Txn) (*local.TxnResponse, error) {
+	r, err := n.propose(&request{Txn: t})
+	if err != nil {
+		return nil, err
+	}
+	return r.resp, r.err
+}
+
+func (n *Node) Grant(ttl int64) (int64, error) {
+	r, err := n.propose(&request{Grant: ttl})
+	if err != nil {
+		return 0, err
+	}
+	return r.lease, r.err
+}
+
+func (n *Node) KeepAlive(lease int64) error {
+	r, err := n.propose(&request{KeepAlive: lease})
+	if err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (n *Node) Revoke(lease int64) error {
+	r, err := n.propose(&request{Revoke: lease})
+	if err != nil {
+		return err
+	}
+	return r.err
+}
+
+// TimeToLive returns the remaining time on a lease, as seen by this member.
+func (n *Node) TimeToLive(lease int64) (time.Duration, error) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	exp, ok := n.expiries[lease]
+	if !ok {
+		return 0, torus.ErrLeaseNotFound
+	}
+	return exp.Sub(time.Now()), nil
+}
+
+func (n *Node) Watch(f func(*local.KeyValue)) {
+	n.watch(f)
+}
+
+func (n *Node) watch(f func(*local.KeyValue)) (cancel func()) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	n.watchID++
+	id := n.watchID
+	n.watchers[id] = f
+	return func() {
+		n.mut.Lock()
+		defer n.mut.Unlock()
+		delete(n.watchers, id)
+	}
+}
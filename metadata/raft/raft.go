@@ -0,0 +1,236 @@
+// raft is a metadata service replicated between torusd processes with the
+// raft consensus protocol, so that a cluster can run without etcd.
+//
+// The metadata is a local.State, and the service is a local.Client, so the
+// key layout and semantics are those of the local metadata service. A small
+// set of torusd processes are the members of the raft group; every other
+// process is a client of them. Both are configured with the list of member
+// URLs in Config.MetadataAddress; members also set Config.MetadataMember to
+// their own URL from the list.
+//
+// Members serve both each other and their clients on their URL. If
+// Config.TLS is set, the URLs must be https, and members only accept
+// connections presenting a certificate from the CAs in it; otherwise anyone
+// who can reach a member can change the metadata.
+package raft
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata"
+	"github.com/coreos/torus/metadata/local"
+)
+
+var (
+	clog = capnslog.NewPackageLogger("github.com/coreos/torus", "raft")
+	rlog = capnslog.NewPackageLogger("github.com/coreos/torus", "etcd-raft")
+)
+
+func init() {
+	torus.RegisterMetadataService("raft", newRaftMetadata)
+	torus.RegisterMetadataInit("raft", initRaftMetadata)
+	torus.RegisterMetadataWipe("raft", wipeRaftMetadata)
+	torus.RegisterSetRing("raft", setRing)
+	torus.RegisterMetadataExport("raft", exportRaftMetadata)
+	torus.RegisterMetadataImport("raft", importRaftMetadata)
+}
+
+// member is a Node served over HTTP, shared by everything in the process
+// that's configured as the same member.
+type member struct {
+	*Node
+	key    string
+	server *http.Server
+	refs   int
+}
+
+var (
+	membersMut sync.Mutex
+	members    = make(map[string]*member)
+)
+
+// ParsePeers splits a comma-separated list of member URLs.
+func ParsePeers(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func openBackend(cfg torus.Config) (local.Backend, error) {
+	peers := ParsePeers(cfg.MetadataAddress)
+	if len(peers) == 0 {
+		return nil, errors.New("raft: no members given")
+	}
+	if err := checkSchemes(peers, cfg.TLS); err != nil {
+		return nil, err
+	}
+	if cfg.MetadataMember == "" {
+		return newRemote(peers, cfg.TLS), nil
+	}
+	var id uint64
+	for i, p := range peers {
+		if p == cfg.MetadataMember {
+			id = uint64(i + 1)
+		}
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("raft: %s is not one of the members %v", cfg.MetadataMember, peers)
+	}
+	if cfg.DataDir == "" {
+		return nil, errors.New("raft: members require a data directory")
+	}
+	return openMember(id, peers, filepath.Join(cfg.DataDir, "metadata", "raft"), cfg.TLS)
+}
+
+// checkSchemes checks that the members are https URLs if there's a TLS
+// config, and http URLs if there isn't.
+func checkSchemes(peers []string, tlsConfig *tls.Config) error {
+	want := "http"
+	if tlsConfig != nil {
+		want = "https"
+	}
+	for _, p := range peers {
+		u, err := url.Parse(p)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != want {
+			if tlsConfig != nil {
+				return fmt.Errorf("raft: member %s isn't https, but TLS is configured", p)
+			}
+			return fmt.Errorf("raft: member %s is %s, but TLS isn't configured", p, u.Scheme)
+		}
+	}
+	return nil
+}
+
+func openMember(id uint64, peers []string, dir string, tlsConfig *tls.Config) (*member, error) {
+	membersMut.Lock()
+	defer membersMut.Unlock()
+	key := peers[id-1]
+	if m, ok := members[key]; ok {
+		m.refs++
+		return m, nil
+	}
+	u, err := url.Parse(key)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, serverTLS(tlsConfig))
+	} else {
+		clog.Warningf("serving raft metadata on %s without TLS; anyone who can reach it can change the metadata", key)
+	}
+	n, err := StartNode(id, peers, dir, tlsConfig)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	srv := &http.Server{Handler: n.Handler()}
+	go srv.Serve(l)
+	m := &member{
+		Node:   n,
+		key:    key,
+		server: srv,
+		refs:   1,
+	}
+	members[key] = m
+	return m, nil
+}
+
+// Close releases a reference to the member, and stops it with the last one.
+func (m *member) Close() error {
+	membersMut.Lock()
+	defer membersMut.Unlock()
+	m.refs--
+	if m.refs != 0 {
+		return nil
+	}
+	delete(members, m.key)
+	// Close the connections too, or peers keep talking to this node after
+	// a new one has taken its place.
+	m.server.Close()
+	return m.Node.Close()
+}
+
+func newRaftMetadata(cfg torus.Config) (torus.MetadataService, error) {
+	var (
+		uuid string
+		err  error
+	)
+	if cfg.DataDir == "" {
+		uuid = metadata.MakeUUID()
+	} else {
+		uuid, err = metadata.GetUUID(cfg.DataDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := openBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return local.NewClient(cfg, uuid, b, torus.RaftMetadata)
+}
+
+func withBackend(cfg torus.Config, f func(b local.Backend) error) error {
+	if err := torus.MkdirsFor(cfg.DataDir); err != nil {
+		return err
+	}
+	b, err := openBackend(cfg)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	return f(b)
+}
+
+func initRaftMetadata(cfg torus.Config, gmd torus.GlobalMetadata, ringType torus.RingType) error {
+	return withBackend(cfg, func(b local.Backend) error {
+		return local.InitBackend(b, gmd, ringType)
+	})
+}
+
+func wipeRaftMetadata(cfg torus.Config) error {
+	return withBackend(cfg, local.WipeBackend)
+}
+
+func setRing(cfg torus.Config, r torus.Ring) error {
+	return withBackend(cfg, func(b local.Backend) error {
+		return local.SetBackendRing(b, r)
+	})
+}
+
+func exportRaftMetadata(cfg torus.Config) (exp *torus.MetadataExport, err error) {
+	err = withBackend(cfg, func(b local.Backend) error {
+		exp, err = local.ExportBackend(b)
+		return err
+	})
+	return exp, err
+}
+
+func importRaftMetadata(cfg torus.Config, exp *torus.MetadataExport) error {
+	return withBackend(cfg, func(b local.Backend) error {
+		return local.ImportBackend(b, exp)
+	})
+}
@@ -0,0 +1,428 @@
+package raft
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/blockset"
+	"github.com/coreos/torus/metadata/local"
+	"github.com/coreos/torus/models"
+	"github.com/coreos/torus/ring"
+)
+
+const basePort = 42380
+
+func clusterConfigs(t *testing.T, n int) []torus.Config {
+	var peers []string
+	for i := 0; i < n; i++ {
+		peers = append(peers, fmt.Sprintf("http://127.0.0.1:%d", basePort+i))
+	}
+	var out []torus.Config
+	for i := 0; i < n; i++ {
+		dir, err := ioutil.TempDir("", "torus-raft")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = torus.MkdirsFor(dir); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, torus.Config{
+			DataDir:         dir,
+			MetadataAddress: strings.Join(peers, ","),
+			MetadataMember:  peers[i],
+		})
+	}
+	return out
+}
+
+func createWithRetry(cfg torus.Config) (torus.MetadataService, error) {
+	for {
+		mds, err := torus.CreateMetadataService("raft", cfg)
+		if err != torus.ErrAgain {
+			return mds, err
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	cfgs := clusterConfigs(t, 3)
+	for _, c := range cfgs {
+		defer os.RemoveAll(c.DataDir)
+	}
+	// Start every member up front; they need a quorum to do anything.
+	var backends []local.Backend
+	for _, c := range cfgs {
+		b, err := openBackend(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backends = append(backends, b)
+	}
+	err := torus.InitMDS("raft", cfgs[0], torus.GlobalMetadata{
+		BlockSize:        1024,
+		DefaultBlockSpec: blockset.MustParseBlockLayerSpec("crc,base"),
+	}, ring.Empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mdss []torus.MetadataService
+	for _, c := range cfgs {
+		m, err := createWithRetry(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mdss = append(mdss, m)
+	}
+	for _, b := range backends {
+		b.Close()
+	}
+
+	clientCfg := cfgs[0]
+	clientCfg.MetadataMember = ""
+	clientCfg.DataDir = ""
+	client, err := torus.CreateMetadataService("raft", clientCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if client.GlobalMetadata().BlockSize != 1024 {
+		t.Fatal("client didn't see the global metadata")
+	}
+
+	// Volume IDs minted concurrently on every member are unique.
+	var (
+		mut  sync.Mutex
+		wg   sync.WaitGroup
+		seen = make(map[torus.VolumeID]bool)
+	)
+	for _, m := range append(mdss, client) {
+		wg.Add(1)
+		go func(m torus.MetadataService) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				id, err := m.NewVolumeID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mut.Lock()
+				if seen[id] {
+					t.Errorf("volume ID %d minted twice", id)
+				}
+				seen[id] = true
+				mut.Unlock()
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	// New rings reach subscribers on other members and on clients.
+	chs := []chan torus.Ring{make(chan torus.Ring, 1), make(chan torus.Ring, 1)}
+	mdss[2].SubscribeNewRings(chs[0])
+	client.SubscribeNewRings(chs[1])
+	time.Sleep(100 * time.Millisecond)
+	r, err := mdss[1].GetRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRing, err := ring.CreateRing(&models.Ring{
+		Type:              uint32(ring.Single),
+		Peers:             torus.PeerInfoList{{UUID: mdss[0].UUID()}},
+		ReplicationFactor: 1,
+		Version:           uint32(r.Version() + 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mdss[1].SetRing(newRing); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range chs {
+		select {
+		case got := <-ch:
+			if got.Version() != newRing.Version() {
+				t.Fatalf("got ring version %d, expected %d", got.Version(), newRing.Version())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("new ring never arrived")
+		}
+	}
+	mdss[2].UnsubscribeNewRings(chs[0])
+	client.UnsubscribeNewRings(chs[1])
+
+	// Leases from a client bind keys on every member.
+	lease, err := client.GetLease()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.RegisterPeer(lease, &models.PeerInfo{UUID: "peer"}); err != nil {
+		t.Fatal(err)
+	}
+	peers, err := mdss[0].GetPeers()
+	if err != nil || len(peers) != 1 {
+		t.Fatalf("expected one peer, got %v (%v)", peers, err)
+	}
+	if err = client.(*local.Client).Backend().Revoke(lease); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.RenewLease(lease); err != torus.ErrLeaseNotFound {
+		t.Fatalf("expected ErrLeaseNotFound, got %v", err)
+	}
+
+	// Losing a member keeps the service available, and it catches up when it
+	// comes back.
+	if err = mdss[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, hw, err := client.GetVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := mdss[1].NewVolumeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != hw+1 {
+		t.Fatalf("expected volume ID %d, got %d", hw+1, id)
+	}
+	mdss[0], err = createWithRetry(cfgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hw, err = mdss[0].GetVolumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hw != id {
+		t.Fatalf("restarted member has volume ID %d, expected %d", hw, id)
+	}
+	for _, m := range mdss {
+		m.Close()
+	}
+}
+
+// testTLS returns a config with a certificate for 127.0.0.1, and the CA that
+// signed it, and a config trusting the CA without a certificate.
+func testTLS(t *testing.T) (withCert, withoutCert *tls.Config) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "torus test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "member"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	withCert = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{leafDER}, PrivateKey: key}},
+		RootCAs:      pool,
+	}
+	return withCert, &tls.Config{RootCAs: pool}
+}
+
+func TestTLS(t *testing.T) {
+	cfg := clusterConfigs(t, 1)[0]
+	defer os.RemoveAll(cfg.DataDir)
+	member := fmt.Sprintf("https://127.0.0.1:%d", basePort+10)
+	cfg.MetadataAddress = member
+	cfg.MetadataMember = member
+
+	if _, err := openBackend(cfg); err == nil {
+		t.Fatal("served https without TLS")
+	}
+	withCert, withoutCert := testTLS(t)
+	cfg.TLS = withCert
+	b, err := openBackend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Clients with a certificate from the CA get in.
+	clientCfg := cfg
+	clientCfg.MetadataMember = ""
+	client, err := openBackend(clientCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for {
+		_, err = client.Txn(&local.Txn{Then: []local.Op{local.OpPutKey("k", []byte("v"))}})
+		if err != torus.ErrAgain {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Those without one don't, whichever path they try.
+	for _, path := range []string{pathTxn, pathMsg, pathWatch} {
+		c := &http.Client{Transport: httpTransport(withoutCert), Timeout: 5 * time.Second}
+		resp, err := c.Post(member+path, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("client without a certificate got %s from %s", resp.Status, path)
+		}
+	}
+	// Nor do plain HTTP clients.
+	plain := strings.Replace(member, "https", "http", 1)
+	if resp, err := http.Post(plain+pathTxn, "application/json", strings.NewReader("{}")); err == nil {
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plain HTTP client was served")
+		}
+		resp.Body.Close()
+	}
+}
+
+// fakeMember serves a State for a remote, ending each watch stream when told
+// to, without sending anything on it.
+type fakeMember struct {
+	mut     sync.Mutex
+	st      *local.State
+	watches int
+	end     chan struct{}
+}
+
+func (m *fakeMember) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case pathTxn:
+		var t local.Txn
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.mut.Lock()
+		resp, _, err := m.st.Apply(&t)
+		m.mut.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	case pathWatch:
+		m.mut.Lock()
+		m.watches++
+		m.mut.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-m.end:
+		case <-r.Context().Done():
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *fakeMember) put(key, value string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.st.Apply(&local.Txn{Then: []local.Op{local.OpPutKey(key, []byte(value))}})
+}
+
+func (m *fakeMember) watchCount() int {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.watches
+}
+
+func TestWatchReconnect(t *testing.T) {
+	m := &fakeMember{st: local.NewState(), end: make(chan struct{})}
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+	m.put("old", "1")
+	m.put("gone", "1")
+
+	r := newRemote([]string{ts.URL}, nil)
+	defer r.Close()
+	events := make(chan *local.KeyValue, 10)
+	r.Watch(func(kv *local.KeyValue) { events <- kv })
+	waitFor := func(n int) {
+		for deadline := time.Now().Add(5 * time.Second); m.watchCount() < n; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("no watch stream %d", n)
+			}
+		}
+	}
+	waitFor(1)
+
+	// Writes and deletions while the stream is down are delivered once it's
+	// back, and keys that haven't changed aren't.
+	close(m.end)
+	m.put("missed", "2")
+	m.put("old", "3")
+	m.mut.Lock()
+	m.st.Apply(&local.Txn{Then: []local.Op{local.OpDeleteKey("gone")}})
+	m.mut.Unlock()
+	got := make(map[string]string)
+	for len(got) < 3 {
+		select {
+		case kv := <-events:
+			if _, ok := got[kv.Key]; ok {
+				t.Fatalf("got %s twice", kv.Key)
+			}
+			got[kv.Key] = string(kv.Value)
+			if kv.Deleted {
+				got[kv.Key] = "deleted"
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got only %v after reconnecting", got)
+		}
+	}
+	if got["missed"] != "2" || got["old"] != "3" || got["gone"] != "deleted" {
+		t.Fatalf("got %v after reconnecting", got)
+	}
+	select {
+	case kv := <-events:
+		t.Fatalf("unexpected event for %s", kv.Key)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
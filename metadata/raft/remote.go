@@ -0,0 +1,244 @@
+package raft
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata/local"
+)
+
+type errUnexpectedStatus int
+
+func (e errUnexpectedStatus) Error() string {
+	return fmt.Sprintf("raft: unexpected HTTP status %d", int(e))
+}
+
+// remote is the local.Backend of processes that aren't members of the raft
+// group. It sends every call to the first member that answers.
+type remote struct {
+	endpoints []string
+	client    *http.Client
+	// watchClient has no timeout, as watch streams are long-lived.
+	watchClient *http.Client
+
+	mut      sync.Mutex
+	next     int
+	watchers []func(*local.KeyValue)
+	watching bool
+	// seen is the version of every key as of the last event delivered, so
+	// that a reconnecting watch can tell what it missed. It's nil until the
+	// first watch stream is open.
+	seen   map[string]int64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newRemote(endpoints []string, tlsConfig *tls.Config) *remote {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := httpTransport(tlsConfig)
+	r := &remote{
+		client:      &http.Client{Timeout: 2 * proposeTimeout, Transport: rt},
+		watchClient: &http.Client{Transport: rt},
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	for _, e := range endpoints {
+		r.endpoints = append(r.endpoints, strings.TrimSuffix(e, "/"))
+	}
+	return r
+}
+
+func (r *remote) endpoint(i int) string {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.endpoints[(r.next+i)%len(r.endpoints)]
+}
+
+func (r *remote) post(path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	for i := range r.endpoints {
+		var resp *http.Response
+		resp, err = r.client.Post(r.endpoint(i)+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(out)
+			resp.Body.Close()
+			r.mut.Lock()
+			r.next = (r.next + i) % len(r.endpoints)
+			r.mut.Unlock()
+			return err
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return torus.ErrLeaseNotFound
+		case http.StatusServiceUnavailable:
+			err = torus.ErrAgain
+			continue
+		}
+		return fmt.Errorf("raft: %s", strings.TrimSpace(string(msg)))
+	}
+	return err
+}
+
+func (r *remote) Txn(t *local.Txn) (*local.TxnResponse, error) {
+	var resp local.TxnResponse
+	if err := r.post(pathTxn, t, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (r *remote) Grant(ttl int64) (int64, error) {
+	var resp leaseResponse
+	err := r.post(pathGrant, leaseRequest{TTL: ttl}, &resp)
+	return resp.ID, err
+}
+
+func (r *remote) KeepAlive(lease int64) error {
+	var resp leaseResponse
+	return r.post(pathKeepAlive, leaseRequest{ID: lease}, &resp)
+}
+
+func (r *remote) Revoke(lease int64) error {
+	var resp leaseResponse
+	return r.post(pathRevoke, leaseRequest{ID: lease}, &resp)
+}
+
+func (r *remote) TimeToLive(lease int64) (time.Duration, error) {
+	var resp leaseResponse
+	err := r.post(pathTTL, leaseRequest{ID: lease}, &resp)
+	return resp.TTL, err
+}
+
+func (r *remote) Watch(f func(*local.KeyValue)) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.watchers = append(r.watchers, f)
+	if !r.watching {
+		r.watching = true
+		go r.watchLoop()
+	}
+}
+
+// watchLoop keeps a watch stream open to some member until the remote is
+// closed. Events written while no stream was open are caught up on by
+// re-reading the keys once the next one is.
+func (r *remote) watchLoop() {
+	for i := 0; ; i++ {
+		err := r.watchOne(r.endpoint(i))
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		clog.Debugf("watch stream ended: %v", err)
+	}
+}
+
+// watchOne streams events from endpoint. The member registers the watch
+// before it answers, so reading the keys after that misses nothing.
+func (r *remote) watchOne(endpoint string) error {
+	req, err := http.NewRequest("GET", endpoint+pathWatch, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.watchClient.Do(req.WithContext(r.ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errUnexpectedStatus(resp.StatusCode)
+	}
+	if err = r.readKeys(); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var kv local.KeyValue
+		if err := dec.Decode(&kv); err != nil {
+			return err
+		}
+		r.deliver(&kv)
+	}
+}
+
+// readKeys reads every key. The first time, it just records their versions;
+// after that, it delivers those that changed since they were last seen, and
+// the deletions of those that are gone.
+func (r *remote) readKeys() error {
+	resp, err := r.Txn(&local.Txn{Then: []local.Op{local.OpGetPrefix("")}})
+	if err != nil {
+		return err
+	}
+	r.mut.Lock()
+	if r.seen == nil {
+		r.seen = make(map[string]int64)
+		for _, kv := range resp.Responses[0] {
+			r.seen[kv.Key] = kv.Version
+		}
+		r.mut.Unlock()
+		return nil
+	}
+	var deleted []string
+	present := make(map[string]bool)
+	for _, kv := range resp.Responses[0] {
+		present[kv.Key] = true
+	}
+	for k := range r.seen {
+		if !present[k] {
+			deleted = append(deleted, k)
+		}
+	}
+	r.mut.Unlock()
+	for _, kv := range resp.Responses[0] {
+		r.deliver(kv)
+	}
+	for _, k := range deleted {
+		r.deliver(&local.KeyValue{Key: k, Deleted: true})
+	}
+	return nil
+}
+
+// deliver calls the watchers with kv, unless they've already seen that
+// version of it, or its deletion.
+func (r *remote) deliver(kv *local.KeyValue) {
+	r.mut.Lock()
+	v, ok := r.seen[kv.Key]
+	if kv.Deleted && !ok || !kv.Deleted && ok && v == kv.Version {
+		r.mut.Unlock()
+		return
+	}
+	if kv.Deleted {
+		delete(r.seen, kv.Key)
+	} else {
+		r.seen[kv.Key] = kv.Version
+	}
+	watchers := r.watchers
+	r.mut.Unlock()
+	for _, w := range watchers {
+		w(kv)
+	}
+}
+
+func (r *remote) Close() error {
+	r.cancel()
+	return nil
+}
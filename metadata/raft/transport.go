@@ -0,0 +1,272 @@
+package raft
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	etcdraft "github.com/coreos/etcd/raft"
+	"github.com/coreos/etcd/raft/raftpb"
+	"golang.org/x/net/context"
+
+	"github.com/coreos/torus"
+	"github.com/coreos/torus/metadata/local"
+)
+
+// Members talk to each other, and clients talk to members, over HTTP. Raft
+// messages are POSTed to /raft/msg; the rest of the paths are the client API,
+// which mirrors local.Backend.
+const (
+	pathMsg       = "/raft/msg"
+	pathTxn       = "/raft/txn"
+	pathGrant     = "/raft/lease/grant"
+	pathKeepAlive = "/raft/lease/keepalive"
+	pathRevoke    = "/raft/lease/revoke"
+	pathTTL       = "/raft/lease/ttl"
+	pathWatch     = "/raft/watch"
+
+	peerQueueLength = 4096
+)
+
+type leaseRequest struct {
+	ID  int64 `json:",omitempty"`
+	TTL int64 `json:",omitempty"`
+}
+
+type leaseResponse struct {
+	ID  int64         `json:",omitempty"`
+	TTL time.Duration `json:",omitempty"`
+}
+
+// transport sends raft messages to the other members, in order, with one
+// queue and goroutine per member.
+type transport struct {
+	n      *Node
+	client *http.Client
+	queues map[uint64]chan raftpb.Message
+}
+
+func newTransport(n *Node) *transport {
+	t := &transport{
+		n:      n,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: httpTransport(n.tls)},
+		queues: make(map[uint64]chan raftpb.Message),
+	}
+	for i, p := range n.peers {
+		id := uint64(i + 1)
+		if id == n.id {
+			continue
+		}
+		q := make(chan raftpb.Message, peerQueueLength)
+		t.queues[id] = q
+		go t.sendLoop(id, strings.TrimSuffix(p, "/"), q)
+	}
+	return t
+}
+
+func (t *transport) send(msgs []raftpb.Message) {
+	for _, m := range msgs {
+		q, ok := t.queues[m.To]
+		if !ok {
+			continue
+		}
+		select {
+		case q <- m:
+		default:
+			// The member is too far behind; raft will probe it again.
+			t.n.node.ReportUnreachable(m.To)
+		}
+	}
+}
+
+func (t *transport) sendLoop(to uint64, addr string, q chan raftpb.Message) {
+	for m := range q {
+		data, err := m.Marshal()
+		if err != nil {
+			clog.Errorf("couldn't marshal raft message: %v", err)
+			continue
+		}
+		resp, err := t.client.Post(addr+pathMsg, "application/octet-stream", bytes.NewReader(data))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent {
+				err = errUnexpectedStatus(resp.StatusCode)
+			}
+		}
+		if err != nil {
+			clog.Tracef("couldn't send to member %d: %v", to, err)
+			t.n.node.ReportUnreachable(to)
+		}
+		if m.Type == raftpb.MsgSnap {
+			status := etcdraft.SnapshotFinish
+			if err != nil {
+				status = etcdraft.SnapshotFailure
+			}
+			t.n.node.ReportSnapshot(to, status)
+		}
+	}
+}
+
+func (t *transport) stop() {
+	for _, q := range t.queues {
+		close(q)
+	}
+}
+
+// httpTransport returns the transport to reach members with, over https if
+// tlsConfig is set.
+func httpTransport(tlsConfig *tls.Config) http.RoundTripper {
+	if tlsConfig == nil {
+		return http.DefaultTransport
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// serverTLS is the config members serve with. They present the certificate
+// they dial the others with, and accept only peers and clients with a
+// certificate from the CAs they verify members against.
+func serverTLS(tlsConfig *tls.Config) *tls.Config {
+	s := tlsConfig.Clone()
+	if s.ClientCAs == nil {
+		s.ClientCAs = s.RootCAs
+	}
+	s.ClientAuth = tls.RequireAndVerifyClientCert
+	return s
+}
+
+// Handler returns the HTTP handler serving both the other members and the
+// clients of this one. It does no authentication of its own; serve it with
+// serverTLS, or only on a network where everyone may change the metadata.
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathMsg, n.handleMsg)
+	mux.HandleFunc(pathTxn, n.handleTxn)
+	mux.HandleFunc(pathGrant, n.handleLease)
+	mux.HandleFunc(pathKeepAlive, n.handleLease)
+	mux.HandleFunc(pathRevoke, n.handleLease)
+	mux.HandleFunc(pathTTL, n.handleLease)
+	mux.HandleFunc(pathWatch, n.handleWatch)
+	return mux
+}
+
+func (n *Node) handleMsg(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var m raftpb.Message
+	if err = m.Unmarshal(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = n.node.Step(context.TODO(), m); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (n *Node) handleTxn(w http.ResponseWriter, r *http.Request) {
+	var t local.Txn
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := n.Txn(&t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (n *Node) handleLease(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var (
+		resp leaseResponse
+		err  error
+	)
+	switch r.URL.Path {
+	case pathGrant:
+		resp.ID, err = n.Grant(req.TTL)
+	case pathKeepAlive:
+		err = n.KeepAlive(req.ID)
+	case pathRevoke:
+		err = n.Revoke(req.ID)
+	case pathTTL:
+		resp.TTL, err = n.TimeToLive(req.ID)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleWatch streams every written and deleted key to the client, as a sequence of JSON
+// objects, until either side goes away.
+func (n *Node) handleWatch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan *local.KeyValue, 256)
+	overflow := make(chan struct{})
+	cancel := n.watch(func(kv *local.KeyValue) {
+		select {
+		case ch <- kv:
+		default:
+			// Drop the client rather than stall every other watcher; it
+			// will reconnect.
+			select {
+			case <-overflow:
+			default:
+				close(overflow)
+			}
+		}
+	})
+	defer cancel()
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case kv := <-ch:
+			if err := enc.Encode(kv); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-overflow:
+			return
+		case <-n.stopc:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case torus.ErrLeaseNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case torus.ErrAgain, torus.ErrClosed:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -251,6 +251,14 @@ func (t *Client) SetData(x string, v interface{}) {
 	t.srv.keys[x] = v
 }
 
+// RestoreVolume brings back a deleted volume, keeping its INode index.
+func (t *Client) RestoreVolume(volume *models.Volume) error {
+	t.srv.mut.Lock()
+	defer t.srv.mut.Unlock()
+	t.srv.volIndex[volume.Name] = volume
+	return nil
+}
+
 func (t *Client) DeleteVolume(name string) error {
 	t.srv.mut.Lock()
 	defer t.srv.mut.Unlock()
@@ -0,0 +1,141 @@
+package torus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/coreos/torus/models"
+)
+
+// MetadataExportVersion is the version of the MetadataExport document written
+// by this version of torus.
+const MetadataExportVersion = 1
+
+// MetadataExport is everything a metadata service holds that can't be
+// recovered from the storage nodes. Peers and volume locks are bound to
+// leases, and aren't part of it.
+type MetadataExport struct {
+	Version        int
+	GlobalMetadata GlobalMetadata
+	Ring           *models.Ring
+	// VolumeMinter is the last volume ID handed out.
+	VolumeMinter VolumeID
+	Volumes      []*ExportedVolume
+	// SnapshotGroups are the snapshot groups of block volumes, as stored, by
+	// name.
+	SnapshotGroups map[string]json.RawMessage `json:",omitempty"`
+}
+
+// ExportedVolume is a volume and the metadata kept for it.
+type ExportedVolume struct {
+	Volume *models.Volume
+	// Trash is the volume's trash record, as stored, if it's been deleted
+	// to the trash. Such a volume is restored to the trash, not as a live
+	// volume.
+	Trash json.RawMessage `json:",omitempty"`
+	// INodeIndex is the last INode ID committed for the volume.
+	INodeIndex INodeID
+	// BlockINode is the marshaled INodeRef of a block volume's current
+	// INode.
+	BlockINode []byte `json:",omitempty"`
+	// Fence is the last fencing token handed out with the volume's lock.
+	// Restoring it keeps storage nodes from rejecting the next holder's
+	// writes.
+	Fence uint64 `json:",omitempty"`
+	// ReplicaOf is where the volume is mirrored from, if it's a mirror
+	// replica.
+	ReplicaOf string `json:",omitempty"`
+	// Quota is the most space a block volume may allocate, if limited.
+	Quota uint64 `json:",omitempty"`
+	// QoS is a block volume's I/O limits, as stored.
+	QoS json.RawMessage `json:",omitempty"`
+	// SnapshotPolicy is a block volume's snapshot schedule, as stored.
+	SnapshotPolicy json.RawMessage    `json:",omitempty"`
+	Snapshots      []ExportedSnapshot `json:",omitempty"`
+	// LockAudit is the record of the volume's forced unlocks, as stored, by
+	// name.
+	LockAudit map[string]json.RawMessage `json:",omitempty"`
+	// Reservations is the volume's SCSI persistent reservation state, as
+	// stored.
+	Reservations []byte `json:",omitempty"`
+	// ExportState is the state export protocols, such as AoE, keep for the
+	// volume, by protocol.
+	ExportState map[string][]byte `json:",omitempty"`
+}
+
+// ExportedSnapshot is a snapshot of a block volume.
+type ExportedSnapshot struct {
+	Name     string
+	When     time.Time
+	INodeRef []byte
+}
+
+// ExportMDSFunc is the signature of a function which reads everything out of
+// a metadata service.
+type ExportMDSFunc func(cfg Config) (*MetadataExport, error)
+
+// ImportMDSFunc is the signature of a function which restores an export into
+// an empty metadata service.
+type ImportMDSFunc func(cfg Config, exp *MetadataExport) error
+
+var (
+	exportMDSFuncs map[string]ExportMDSFunc
+	importMDSFuncs map[string]ImportMDSFunc
+)
+
+// RegisterMetadataExport is the hook used for implementations of
+// MetadataServices to register their ways of exporting their metadata.
+func RegisterMetadataExport(name string, newFunc ExportMDSFunc) {
+	if exportMDSFuncs == nil {
+		exportMDSFuncs = make(map[string]ExportMDSFunc)
+	}
+
+	if _, ok := exportMDSFuncs[name]; ok {
+		panic("torus: attempted to register ExportMDSFunc " + name + " twice")
+	}
+
+	exportMDSFuncs[name] = newFunc
+}
+
+// RegisterMetadataImport is the hook used for implementations of
+// MetadataServices to register their ways of importing an export.
+func RegisterMetadataImport(name string, newFunc ImportMDSFunc) {
+	if importMDSFuncs == nil {
+		importMDSFuncs = make(map[string]ImportMDSFunc)
+	}
+
+	if _, ok := importMDSFuncs[name]; ok {
+		panic("torus: attempted to register ImportMDSFunc " + name + " twice")
+	}
+
+	importMDSFuncs[name] = newFunc
+}
+
+// ExportMDS calls the specific export function provided by a metadata package.
+func ExportMDS(name string, cfg Config) (*MetadataExport, error) {
+	clog.Debugf("running ExportMDS for service type: %s", name)
+	f, ok := exportMDSFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("torus: the metadata service %q can't be exported", name)
+	}
+	return f(cfg)
+}
+
+// ImportMDS calls the specific import function provided by a metadata
+// package. Like InitMDS, it returns ErrExists if the metadata service has
+// already been initialized.
+func ImportMDS(name string, cfg Config, exp *MetadataExport) error {
+	clog.Debugf("running ImportMDS for service type: %s", name)
+	if exp.Version != MetadataExportVersion {
+		return fmt.Errorf("torus: unsupported metadata export version %d", exp.Version)
+	}
+	if exp.Ring == nil {
+		return fmt.Errorf("torus: metadata export has no ring")
+	}
+	f, ok := importMDSFuncs[name]
+	if !ok {
+		return fmt.Errorf("torus: the metadata service %q can't be imported", name)
+	}
+	return f(cfg, exp)
+}
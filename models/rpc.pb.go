@@ -79,6 +79,7 @@ func (*BlockResponse) Descriptor() ([]byte, []int) { return fileDescriptorRpc, [
 type PutBlockRequest struct {
 	Refs   []*BlockRef `protobuf:"bytes,1,rep,name=refs" json:"refs,omitempty"`
 	Blocks [][]byte    `protobuf:"bytes,2,rep,name=blocks" json:"blocks,omitempty"`
+	Fence  uint64      `protobuf:"varint,3,opt,name=fence,proto3" json:"fence,omitempty"`
 }
 
 func (m *PutBlockRequest) Reset()                    { *m = PutBlockRequest{} }
@@ -304,6 +305,9 @@ func (this *PutBlockRequest) VerboseEqual(that interface{}) error {
 			return fmt.Errorf("Blocks this[%v](%v) Not Equal that[%v](%v)", i, this.Blocks[i], i, that1.Blocks[i])
 		}
 	}
+	if this.Fence != that1.Fence {
+		return fmt.Errorf("Fence this(%v) Not Equal that(%v)", this.Fence, that1.Fence)
+	}
 	return nil
 }
 func (this *PutBlockRequest) Equal(that interface{}) bool {
@@ -347,6 +351,9 @@ func (this *PutBlockRequest) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if this.Fence != that1.Fence {
+		return false
+	}
 	return true
 }
 func (this *PutResponse) VerboseEqual(that interface{}) error {
@@ -796,6 +803,11 @@ func (m *PutBlockRequest) MarshalTo(data []byte) (int, error) {
 			i += copy(data[i:], b)
 		}
 	}
+	if m.Fence != 0 {
+		data[i] = 0x18
+		i++
+		i = encodeVarintRpc(data, i, uint64(m.Fence))
+	}
 	return i, nil
 }
 
@@ -966,6 +978,7 @@ func NewPopulatedPutBlockRequest(r randyRpc, easy bool) *PutBlockRequest {
 			this.Blocks[i][j] = byte(r.Intn(256))
 		}
 	}
+	this.Fence = uint64(uint64(r.Uint32()))
 	if !easy && r.Intn(10) != 0 {
 	}
 	return this
@@ -1120,6 +1133,9 @@ func (m *PutBlockRequest) Size() (n int) {
 			n += 1 + l + sovRpc(uint64(l))
 		}
 	}
+	if m.Fence != 0 {
+		n += 1 + sovRpc(uint64(m.Fence))
+	}
 	return n
 }
 
@@ -1446,6 +1462,25 @@ func (m *PutBlockRequest) Unmarshal(data []byte) error {
 			m.Blocks = append(m.Blocks, make([]byte, postIndex-iNdEx))
 			copy(m.Blocks[len(m.Blocks)-1], data[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Fence", wireType)
+			}
+			m.Fence = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Fence |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(data[iNdEx:])
@@ -1842,28 +1877,30 @@ var (
 )
 
 var fileDescriptorRpc = []byte{
-	// 365 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x92, 0xcf, 0x4e, 0xf2, 0x40,
-	0x14, 0xc5, 0xbf, 0xf2, 0x2f, 0x70, 0x5b, 0xf8, 0xcc, 0x28, 0x4a, 0x9a, 0xd8, 0x98, 0x4a, 0x8c,
-	0x2e, 0x2c, 0x09, 0x98, 0xe8, 0xc6, 0x85, 0xf8, 0x00, 0x1a, 0x74, 0x6f, 0xa6, 0x65, 0x28, 0x84,
-	0xc2, 0x60, 0x67, 0xea, 0x73, 0xf8, 0x18, 0x3e, 0x82, 0x4b, 0x97, 0xee, 0xf4, 0x11, 0x50, 0x5f,
-	0xc2, 0xa5, 0x97, 0x69, 0x41, 0x69, 0x60, 0x71, 0x92, 0xb9, 0xdc, 0x7b, 0xcf, 0xef, 0x72, 0x52,
-	0x28, 0x85, 0x13, 0xcf, 0x99, 0x84, 0x5c, 0x72, 0x52, 0x18, 0xf1, 0x2e, 0x0b, 0x84, 0x79, 0xec,
-	0x0f, 0x64, 0x3f, 0x72, 0x1d, 0x8f, 0x8f, 0x1a, 0x3e, 0xf7, 0x79, 0x43, 0xb5, 0xdd, 0xa8, 0xa7,
-	0x2a, 0x55, 0xa8, 0x57, 0xbc, 0x66, 0xea, 0x92, 0x87, 0x91, 0x88, 0x0b, 0xbb, 0x05, 0x46, 0x3b,
-	0xe0, 0xde, 0xb0, 0xc3, 0xee, 0x23, 0x26, 0x24, 0xd9, 0x87, 0x92, 0x3b, 0xab, 0xef, 0x42, 0xd6,
-	0xab, 0x69, 0x7b, 0xda, 0xa1, 0xde, 0xdc, 0x70, 0x62, 0x8e, 0x93, 0x0c, 0xf6, 0xec, 0x23, 0x28,
-	0x27, 0x6f, 0x31, 0xe1, 0x63, 0xc1, 0x08, 0x40, 0x86, 0x0f, 0xd5, 0x78, 0x91, 0x18, 0x90, 0xeb,
-	0x52, 0x49, 0x6b, 0x19, 0xac, 0x0c, 0xfb, 0x02, 0xfe, 0x5f, 0x47, 0x72, 0x09, 0x61, 0x41, 0x0e,
-	0xcd, 0x05, 0x8e, 0x67, 0x57, 0xb9, 0x93, 0x0a, 0x14, 0xd4, 0x09, 0x02, 0x2d, 0xb2, 0x68, 0x71,
-	0x00, 0x3a, 0x5a, 0xac, 0x64, 0xe9, 0x90, 0x65, 0x61, 0xa8, 0x50, 0x25, 0xfb, 0x1c, 0xaa, 0x1d,
-	0xe6, 0xd2, 0x80, 0x8e, 0x3d, 0x76, 0xd9, 0x67, 0xbf, 0xc0, 0x3a, 0xc0, 0xe2, 0x3f, 0xad, 0xc5,
-	0xda, 0xa7, 0xb0, 0x9d, 0x5e, 0x4f, 0x88, 0x65, 0xc8, 0x3f, 0xd0, 0x60, 0xd0, 0x55, 0xab, 0xc5,
-	0xd9, 0x7d, 0x42, 0x52, 0x19, 0x09, 0xc5, 0xcd, 0x37, 0xdf, 0x34, 0x30, 0x6e, 0x67, 0x91, 0xde,
-	0x60, 0xb0, 0xd4, 0x67, 0xe4, 0x04, 0xf2, 0xca, 0x95, 0x6c, 0xa5, 0x20, 0xea, 0x1c, 0xb3, 0x9a,
-	0xfa, 0x35, 0xa1, 0x9c, 0x41, 0x71, 0x9e, 0x14, 0xd9, 0x99, 0x8f, 0xa4, 0xb2, 0x33, 0x37, 0xff,
-	0x34, 0x16, 0x9b, 0x57, 0x50, 0x59, 0xbe, 0x9c, 0xec, 0xce, 0xc7, 0x56, 0x06, 0x62, 0x5a, 0xeb,
-	0xda, 0xb1, 0x61, 0xbb, 0x3e, 0xfd, 0xb0, 0xb4, 0x6f, 0xd4, 0xd3, 0xa7, 0xa5, 0x3d, 0xa3, 0x5e,
-	0x50, 0xaf, 0xa8, 0x77, 0xd4, 0x14, 0xf5, 0xf8, 0x65, 0xfd, 0x73, 0x0b, 0xea, 0x0b, 0x6a, 0xfd,
-	0x04, 0x00, 0x00, 0xff, 0xff, 0x80, 0x1e, 0x4f, 0xc2, 0x92, 0x02, 0x00, 0x00,
+	// 385 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0x41, 0x6e, 0xda, 0x40,
+	0x14, 0x86, 0x3b, 0x18, 0x23, 0x78, 0x36, 0xb4, 0x9a, 0x96, 0xd6, 0xb2, 0xd4, 0x91, 0xe5, 0xa2,
+	0xca, 0x5d, 0xd4, 0x48, 0x50, 0xa9, 0xdd, 0x74, 0x43, 0x0f, 0x50, 0x44, 0xbb, 0xaf, 0x6c, 0x33,
+	0x36, 0x08, 0xc3, 0xd0, 0x99, 0x71, 0xce, 0x91, 0x63, 0xe4, 0x08, 0x59, 0x66, 0x99, 0x5d, 0x72,
+	0x04, 0x70, 0x2e, 0x91, 0x65, 0xc4, 0xd8, 0x90, 0x60, 0xc1, 0xee, 0x3d, 0xbf, 0xf7, 0xbf, 0xef,
+	0xf7, 0xaf, 0x81, 0x16, 0x5f, 0x47, 0xfe, 0x9a, 0x33, 0xc9, 0x70, 0x63, 0xc9, 0xa6, 0x34, 0x15,
+	0xf6, 0xd7, 0x64, 0x2e, 0x67, 0x59, 0xe8, 0x47, 0x6c, 0xd9, 0x4f, 0x58, 0xc2, 0xfa, 0x6a, 0x1c,
+	0x66, 0xb1, 0xea, 0x54, 0xa3, 0xaa, 0x42, 0x66, 0x1b, 0x92, 0xf1, 0x4c, 0x14, 0x8d, 0x3b, 0x04,
+	0x73, 0x94, 0xb2, 0x68, 0x31, 0xa1, 0xff, 0x33, 0x2a, 0x24, 0xfe, 0x04, 0xad, 0x70, 0xd7, 0xff,
+	0xe3, 0x34, 0xb6, 0x90, 0x83, 0x3c, 0x63, 0xf0, 0xc6, 0x2f, 0x38, 0x7e, 0xb9, 0x18, 0xbb, 0x5f,
+	0xa0, 0x5d, 0xd6, 0x62, 0xcd, 0x56, 0x82, 0x62, 0x80, 0x1a, 0x5b, 0xa8, 0xf5, 0x26, 0x36, 0xa1,
+	0x3e, 0x0d, 0x64, 0x60, 0xd5, 0x1c, 0xe4, 0x99, 0xee, 0x18, 0x5e, 0x8f, 0x33, 0x79, 0x84, 0x20,
+	0x50, 0xe7, 0x34, 0x16, 0x16, 0x72, 0xb4, 0x53, 0xd7, 0x71, 0x07, 0x1a, 0xca, 0x82, 0xb0, 0x6a,
+	0x8e, 0xe6, 0x99, 0xb8, 0x0d, 0x7a, 0x4c, 0x57, 0x11, 0xb5, 0x34, 0x07, 0x79, 0x75, 0xf7, 0x33,
+	0x18, 0xe3, 0x4c, 0x9e, 0x44, 0x1b, 0xa0, 0x51, 0xce, 0x15, 0xb9, 0xe5, 0xfe, 0x84, 0xee, 0x84,
+	0x86, 0x41, 0x1a, 0xac, 0x22, 0xfa, 0x6b, 0x46, 0x9f, 0xf9, 0x3d, 0x80, 0xc3, 0x2f, 0x9e, 0x75,
+	0xe1, 0x7e, 0x87, 0xf7, 0x55, 0x79, 0x49, 0x6c, 0x83, 0x7e, 0x11, 0xa4, 0xf3, 0xa9, 0x92, 0x36,
+	0x77, 0x76, 0x85, 0x0c, 0x64, 0x26, 0x14, 0x57, 0x1f, 0xdc, 0x21, 0x30, 0xff, 0xee, 0x12, 0xfe,
+	0x23, 0x19, 0x0f, 0x12, 0x8a, 0xbf, 0x81, 0xae, 0xae, 0xe2, 0x77, 0x15, 0x88, 0xb2, 0x63, 0x77,
+	0x2b, 0x5f, 0x4b, 0xca, 0x0f, 0x68, 0xee, 0x83, 0xc3, 0x1f, 0xf6, 0x2b, 0x95, 0x28, 0xed, 0xb7,
+	0x2f, 0x06, 0x07, 0xe5, 0x6f, 0xe8, 0x1c, 0x3b, 0xc7, 0x1f, 0xf7, 0x6b, 0x27, 0x03, 0xb1, 0xc9,
+	0xb9, 0x71, 0x71, 0x70, 0xd4, 0xdb, 0x6c, 0x09, 0x7a, 0xdc, 0x12, 0x74, 0x95, 0x13, 0x74, 0x9d,
+	0x13, 0x74, 0x93, 0x13, 0x74, 0x9b, 0x13, 0x74, 0x9f, 0x13, 0xb4, 0xc9, 0x09, 0xba, 0x7c, 0x20,
+	0xaf, 0xc2, 0x86, 0x7a, 0x50, 0xc3, 0xa7, 0x01, 0x00, 0x1c, 0xf7, 0x2d, 0x5c, 0xa1, 0x02, 0x00,
+	0x00,
 }
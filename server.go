@@ -24,8 +24,67 @@ func init() {
 const (
 	CtxWriteLevel int = iota
 	CtxReadLevel
+	CtxFence
 )
 
+// WithFence returns a context carrying the fencing token of a volume lock.
+// Block writes made with it are rejected by storage nodes which have seen a
+// newer token for the same volume.
+func WithFence(ctx context.Context, fence uint64) context.Context {
+	return context.WithValue(ctx, CtxFence, fence)
+}
+
+// FenceFromContext returns the fencing token carried by ctx, or 0 if the
+// writes aren't fenced.
+func FenceFromContext(ctx context.Context) uint64 {
+	fence, _ := ctx.Value(CtxFence).(uint64)
+	return fence
+}
+
+// A FenceReader returns the fencing token of the last lock of the volume vid,
+// as recorded in mds.
+type FenceReader func(mds MetadataService, vid VolumeID) (uint64, error)
+
+var fenceReader FenceReader
+
+// RegisterFenceReader sets how storage nodes read the fencing token of a
+// volume they haven't seen fenced writes for, to check the first of them by.
+func RegisterFenceReader(f FenceReader) {
+	fenceReader = f
+}
+
+// ReadFence returns the fencing token of the last lock of the volume vid, or
+// 0 if no FenceReader is registered.
+func ReadFence(mds MetadataService, vid VolumeID) (uint64, error) {
+	if fenceReader == nil {
+		return 0, nil
+	}
+	return fenceReader(mds, vid)
+}
+
+// A FenceWatcher watches the fencing token of the volume vid in mds. The
+// changes channel receives whenever the token may have changed, until stop
+// is called, and is closed when the watch ends.
+type FenceWatcher func(mds MetadataService, vid VolumeID) (changes <-chan struct{}, stop func(), err error)
+
+var fenceWatcher FenceWatcher
+
+// RegisterFenceWatcher sets how storage nodes learn of new locks of the
+// volumes they've seen fenced writes for, so that they turn away the writes
+// of the previous holders without waiting for the new one to write.
+func RegisterFenceWatcher(f FenceWatcher) {
+	fenceWatcher = f
+}
+
+// WatchFence watches the fencing token of the volume vid. If no FenceWatcher
+// is registered, changes never receives.
+func WatchFence(mds MetadataService, vid VolumeID) (changes <-chan struct{}, stop func(), err error) {
+	if fenceWatcher == nil {
+		return nil, func() {}, nil
+	}
+	return fenceWatcher(mds, vid)
+}
+
 // Server is the type representing the generic distributed block store.
 type Server struct {
 	mut        sync.RWMutex